@@ -0,0 +1,205 @@
+package book
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const apiKeyLabel = "bks_" //starts every key, so leaked keys are easy to spot
+
+/* A key for server-to-server integrations, owned by an admin. Only the hash of the secret is kept; the prefix finds the key without it. */
+type APIKey struct {
+	KeyID      uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []Permission
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+type CreateAPIKeyRequest struct {
+	UserID uuid.UUID
+	Name   string
+	Scopes []Permission
+}
+
+/* Issues a new API key for an admin. The key itself is returned only here, as just its hash is stored. */
+func (s *Service) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (APIKey, string, error) {
+	owner, err := s.repo.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("error on call to GetUserByID: %w", err)
+	}
+	if owner.Role != UserRoleAdmin {
+		return APIKey{}, "", ErrResponseForbidden
+	}
+
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	_, err = rand.Read(prefixBytes)
+	if err == nil {
+		_, err = rand.Read(secretBytes)
+	}
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("generating api key: %w", err)
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	newKey := APIKey{
+		KeyID:     uuid.New(),
+		UserID:    req.UserID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKeySecret(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	storedKey, err := txRepo.CreateAPIKey(ctx, newKey)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("error on call to CreateAPIKey: %w", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventAPIKeyCreated, withoutKeyHash(storedKey))
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return storedKey, apiKeyLabel + prefix + "." + secret, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	keys, err := s.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListAPIKeys: %w", err)
+	}
+	return keys, nil
+}
+
+/* Revokes an API key, so it is refused from now on. */
+func (s *Service) RevokeAPIKey(ctx context.Context, keyID uuid.UUID) (APIKey, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return APIKey{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	revoked, err := txRepo.RevokeAPIKey(ctx, keyID, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return APIKey{}, fmt.Errorf("error on call to RevokeAPIKey: %w", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventAPIKeyRevoked, withoutKeyHash(revoked))
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return APIKey{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return revoked, nil
+}
+
+/* The key as told at events, that leave its hash out. */
+func withoutKeyHash(key APIKey) APIKey {
+	key.KeyHash = ""
+	return key
+}
+
+/* Checks an API key sent by an integration and returns the claims it acts with: its owner, limited to the scopes of the key. */
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (Claims, error) {
+	prefix, secret, ok := splitAPIKey(key)
+	if !ok {
+		return Claims{}, ErrResponseAPIKeyInvalid
+	}
+
+	stored, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, ErrResponseAPIKeyNotFound) {
+			return Claims{}, ErrResponseAPIKeyInvalid
+		}
+		return Claims{}, fmt.Errorf("error on call to GetAPIKeyByPrefix: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(stored.KeyHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		return Claims{}, ErrResponseAPIKeyInvalid
+	}
+	if stored.RevokedAt != nil {
+		return Claims{}, ErrResponseAPIKeyInvalid
+	}
+
+	owner, err := s.repo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return Claims{}, fmt.Errorf("error on call to GetUserByID: %w", err)
+	}
+	if owner.Role != UserRoleAdmin { //Keys stop working when their owner is no longer an admin.
+		return Claims{}, ErrResponseAPIKeyInvalid
+	}
+
+	err = s.repo.TouchAPIKey(ctx, stored.KeyID, time.Now().UTC().Round(time.Millisecond))
+	if err != nil { //Not worth refusing the call for.
+		log.Println(fmt.Errorf("error on call to TouchAPIKey: %w", err))
+	}
+
+	return Claims{
+		UserID:   owner.UserID,
+		Role:     owner.Role,
+		APIKeyID: stored.KeyID,
+		Scopes:   stored.Scopes,
+	}, nil
+}
+
+/* Splits a key made by CreateAPIKey into its lookup prefix and its secret. */
+func splitAPIKey(key string) (prefix, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, apiKeyLabel)
+	if !found {
+		return "", "", false
+	}
+	prefix, secret, found = strings.Cut(rest, ".")
+	if !found || prefix == "" || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
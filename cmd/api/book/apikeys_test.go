@@ -0,0 +1,200 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	admin := book.User{UserID: uuid.New(), Name: "Warehouse Admin", Role: book.UserRoleAdmin}
+	req := book.CreateAPIKeyRequest{UserID: admin.UserID, Name: "warehouse", Scopes: []book.Permission{book.PermissionOrdersRead, book.PermissionFulfillmentWrite}}
+
+	t.Run("issues a key, storing only its hash", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), admin.UserID).Return(admin, nil)
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, newKey book.APIKey) (book.APIKey, error) {
+			is.Equal(newKey.UserID, admin.UserID)
+			is.Equal(newKey.Scopes, req.Scopes)
+			return newKey, nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventAPIKeyCreated)
+			var payload book.APIKey
+			is.NoErr(json.Unmarshal(event.Payload, &payload))
+			is.Equal(payload.UserID, admin.UserID)
+			is.Equal(payload.KeyHash, "") //the hash is not told at events
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		storedKey, key, err := mS.CreateAPIKey(ctx, req)
+		is.NoErr(err)
+		is.True(strings.HasPrefix(key, "bks_"+storedKey.Prefix+"."))
+		is.True(storedKey.KeyHash != "")
+		is.True(!strings.Contains(key, storedKey.KeyHash))
+	})
+
+	t.Run("expected forbidden error for a key of a user that is not an admin", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), admin.UserID).Return(book.User{UserID: admin.UserID, Role: book.UserRoleUser}, nil)
+
+		_, _, err := mS.CreateAPIKey(ctx, req)
+		is.True(errors.Is(err, book.ErrResponseForbidden))
+	})
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	admin := book.User{UserID: uuid.New(), Name: "Warehouse Admin", Role: book.UserRoleAdmin}
+	scopes := []book.Permission{book.PermissionOrdersRead}
+
+	/* Issues a key through the service, so its stored form can be searched by the next calls. */
+	issue := func(t *testing.T) (book.APIKey, string) {
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mS := book.NewService(mockRepo, bookmock.NewMockNotifier(ctrl), bookmock.NewMockPriceCalculator(ctrl), bookmock.NewMockPaymentGateway(ctrl), bookmock.NewMockWebhookSender(ctrl), notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), admin.UserID).Return(admin, nil)
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, newKey book.APIKey) (book.APIKey, error) {
+			return newKey, nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+		storedKey, key, err := mS.CreateAPIKey(ctx, book.CreateAPIKeyRequest{UserID: admin.UserID, Name: "warehouse", Scopes: scopes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return storedKey, key
+	}
+
+	t.Run("gives the claims of the owner limited to the key scopes", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		storedKey, key := issue(t)
+		mockRepo.EXPECT().GetAPIKeyByPrefix(gomock.Any(), storedKey.Prefix).Return(storedKey, nil)
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), admin.UserID).Return(admin, nil)
+		mockRepo.EXPECT().TouchAPIKey(gomock.Any(), storedKey.KeyID, gomock.Any()).Return(nil)
+
+		claims, err := mS.AuthenticateAPIKey(ctx, key)
+		is.NoErr(err)
+		is.Equal(claims.UserID, admin.UserID)
+		is.Equal(claims.APIKeyID, storedKey.KeyID)
+		is.Equal(claims.Scopes, scopes)
+		is.NoErr(book.Authorize(claims, book.PermissionOrdersRead))
+		is.True(errors.Is(book.Authorize(claims, book.PermissionBooksWrite), book.ErrResponseForbidden))
+	})
+
+	t.Run("expected api key invalid error for a wrong secret", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		storedKey, _ := issue(t)
+		mockRepo.EXPECT().GetAPIKeyByPrefix(gomock.Any(), storedKey.Prefix).Return(storedKey, nil)
+
+		_, err := mS.AuthenticateAPIKey(ctx, "bks_"+storedKey.Prefix+".wrong-secret")
+		is.True(errors.Is(err, book.ErrResponseAPIKeyInvalid))
+	})
+
+	t.Run("expected api key invalid error for a revoked key", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		storedKey, key := issue(t)
+		revokedAt := time.Now().Add(-time.Minute)
+		storedKey.RevokedAt = &revokedAt
+		mockRepo.EXPECT().GetAPIKeyByPrefix(gomock.Any(), storedKey.Prefix).Return(storedKey, nil)
+
+		_, err := mS.AuthenticateAPIKey(ctx, key)
+		is.True(errors.Is(err, book.ErrResponseAPIKeyInvalid))
+	})
+
+	t.Run("expected api key invalid error when the owner is no longer an admin", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		storedKey, key := issue(t)
+		mockRepo.EXPECT().GetAPIKeyByPrefix(gomock.Any(), storedKey.Prefix).Return(storedKey, nil)
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), admin.UserID).Return(book.User{UserID: admin.UserID, Role: book.UserRoleUser}, nil)
+
+		_, err := mS.AuthenticateAPIKey(ctx, key)
+		is.True(errors.Is(err, book.ErrResponseAPIKeyInvalid))
+	})
+
+	t.Run("expected api key invalid error for an unknown or malformed key", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().GetAPIKeyByPrefix(gomock.Any(), "0a1b2c3d4e5f").Return(book.APIKey{}, book.ErrResponseAPIKeyNotFound)
+
+		_, err := mS.AuthenticateAPIKey(ctx, "bks_0a1b2c3d4e5f.secret")
+		is.True(errors.Is(err, book.ErrResponseAPIKeyInvalid))
+
+		_, err = mS.AuthenticateAPIKey(ctx, "not-a-key")
+		is.True(errors.Is(err, book.ErrResponseAPIKeyInvalid))
+	})
+}
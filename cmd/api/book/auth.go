@@ -0,0 +1,208 @@
+package book
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+/* Configures the tokens given at login. */
+type AuthConfig struct {
+	SigningKey      []byte        //HMAC key of the access tokens
+	AccessTokenTTL  time.Duration //how long an access token is accepted
+	RefreshTokenTTL time.Duration //how long a refresh token can be traded for new tokens
+	CartTokenTTL    time.Duration //how long a cart token opens its guest order
+}
+
+/* A refresh token as stored: only its hash is kept, so a leaked table can't be used to log in. */
+type RefreshToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+type AuthTokens struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+/* Compared against when the email is unknown, so a login takes as long whether the user exists or not. */
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+/* Checks the email and password of a user and gives it a new access token and refresh token. */
+func (s *Service) Login(ctx context.Context, email, password string) (AuthTokens, error) {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrResponseUserNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			return AuthTokens{}, ErrResponseCredentialsInvalid
+		}
+		return AuthTokens{}, fmt.Errorf("error on call to GetUserByEmail: %w", err)
+	}
+	if user.PasswordHash == "" { //Users with no password can't log in.
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return AuthTokens{}, ErrResponseCredentialsInvalid
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return AuthTokens{}, ErrResponseCredentialsInvalid
+	}
+
+	return s.issueTokens(ctx, s.repo, user)
+}
+
+/* Trades a refresh token for a new access token and refresh token, through a transaction. Each refresh token is used only once. */
+func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (AuthTokens, error) {
+	var tokens AuthTokens
+	err := s.retryTx(ctx, func() error {
+		var err error
+		tokens, err = s.refreshTokens(ctx, refreshToken)
+		return err
+	})
+	if err != nil {
+		return AuthTokens{}, err
+	}
+	return tokens, nil
+}
+
+/* Consumes the refresh token and issues the new tokens. */
+func (s *Service) refreshTokens(ctx context.Context, refreshToken string) (AuthTokens, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return AuthTokens{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	stored, err := txRepo.GetRefreshTokenForUpdate(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, ErrResponseRefreshTokenInvalid) {
+			return AuthTokens{}, err
+		}
+		return AuthTokens{}, fmt.Errorf("error on call to GetRefreshTokenForUpdate: %w ", err)
+	}
+	if stored.RevokedAt != nil || !now.Before(stored.ExpiresAt) {
+		return AuthTokens{}, ErrResponseRefreshTokenInvalid
+	}
+
+	err = txRepo.RevokeRefreshToken(ctx, stored.TokenHash, now)
+	if err != nil {
+		return AuthTokens{}, fmt.Errorf("error on call to RevokeRefreshToken: %w ", err)
+	}
+
+	user, err := txRepo.GetUserByID(ctx, stored.UserID) //The role may have changed since the last login.
+	if err != nil {
+		return AuthTokens{}, fmt.Errorf("error on call to GetUserByID: %w ", err)
+	}
+
+	tokens, err := s.issueTokens(ctx, txRepo, user)
+	if err != nil {
+		return AuthTokens{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return AuthTokens{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return tokens, nil
+}
+
+/* Revokes a refresh token, so it can't be traded anymore. Access tokens already given stay valid until they expire. */
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	err := s.repo.RevokeRefreshToken(ctx, hashRefreshToken(refreshToken), time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("error on call to RevokeRefreshToken: %w", err)
+	}
+	return nil
+}
+
+/* Creates an admin with the given email and password, unless a user with that email already exists. Used to have a first admin at new deployments. */
+func (s *Service) BootstrapAdmin(ctx context.Context, email, password string) error {
+	_, err := s.repo.GetUserByEmail(ctx, email)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrResponseUserNotFound) {
+		return fmt.Errorf("error on call to GetUserByEmail: %w", err)
+	}
+
+	_, err = s.CreateUser(ctx, CreateUserRequest{Name: "Admin", Role: UserRoleAdmin, Email: email, Password: password})
+	return err
+}
+
+/* Signs a new access token for the user and stores a new refresh token, through repo. */
+func (s *Service) issueTokens(ctx context.Context, repo Repository, user User) (AuthTokens, error) {
+	now := time.Now().UTC().Round(time.Second)
+	accessExpiresAt := now.Add(s.authConfig.AccessTokenTTL)
+	accessToken, err := SignAccessToken(s.authConfig.SigningKey, Claims{
+		UserID:    user.UserID,
+		Role:      user.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: accessExpiresAt.Unix(),
+	})
+	if err != nil {
+		return AuthTokens{}, err
+	}
+
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		return AuthTokens{}, fmt.Errorf("generating refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(random)
+	refreshExpiresAt := now.Add(s.authConfig.RefreshTokenTTL)
+	err = repo.StoreRefreshToken(ctx, RefreshToken{
+		TokenHash: hashRefreshToken(refreshToken),
+		UserID:    user.UserID,
+		ExpiresAt: refreshExpiresAt,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return AuthTokens{}, fmt.Errorf("error on call to StoreRefreshToken: %w ", err)
+	}
+
+	return AuthTokens{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+/* Hashes a password to be stored. Blank passwords give blank hashes, for users that can't log in. */
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,205 @@
+package book_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccessTokens(t *testing.T) {
+	claims := book.Claims{
+		UserID:    uuid.New(),
+		Role:      book.UserRoleAdmin,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}
+
+	t.Run("parses the claims of a signed token", func(t *testing.T) {
+		is := is.New(t)
+
+		token, err := book.SignAccessToken(authConfig.SigningKey, claims)
+		is.NoErr(err)
+
+		parsed, err := book.ParseAccessToken(authConfig.SigningKey, token, time.Now())
+		is.NoErr(err)
+		is.Equal(parsed, claims)
+	})
+
+	t.Run("expected access token invalid error for an expired token", func(t *testing.T) {
+		is := is.New(t)
+
+		token, err := book.SignAccessToken(authConfig.SigningKey, claims)
+		is.NoErr(err)
+
+		_, err = book.ParseAccessToken(authConfig.SigningKey, token, time.Now().Add(2*time.Minute))
+		is.True(errors.Is(err, book.ErrResponseAccessTokenInvalid))
+	})
+
+	t.Run("expected access token invalid error for changed claims", func(t *testing.T) {
+		is := is.New(t)
+
+		token, err := book.SignAccessToken(authConfig.SigningKey, book.Claims{UserID: claims.UserID, Role: book.UserRoleUser, ExpiresAt: claims.ExpiresAt})
+		is.NoErr(err)
+		parts := strings.Split(token, ".")
+		promoted, err := book.SignAccessToken([]byte("unknown key"), claims)
+		is.NoErr(err)
+		parts[1] = strings.Split(promoted, ".")[1] //The payload of an admin with the signature of a user.
+
+		_, err = book.ParseAccessToken(authConfig.SigningKey, strings.Join(parts, "."), time.Now())
+		is.True(errors.Is(err, book.ErrResponseAccessTokenInvalid))
+	})
+
+	t.Run("expected access token invalid error for an unsigned token", func(t *testing.T) {
+		is := is.New(t)
+
+		token, err := book.SignAccessToken(authConfig.SigningKey, claims)
+		is.NoErr(err)
+		parts := strings.Split(token, ".")
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+		_, err = book.ParseAccessToken(authConfig.SigningKey, header+"."+parts[1]+".", time.Now())
+		is.True(errors.Is(err, book.ErrResponseAccessTokenInvalid))
+	})
+}
+
+func TestLogin(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := book.User{
+		UserID:       uuid.New(),
+		Name:         "Maria Silva",
+		Role:         book.UserRoleUser,
+		Email:        "maria@mail.com",
+		PasswordHash: string(passwordHash),
+	}
+
+	t.Run("gives tokens to a user with the right password", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		var storedHash string
+		mockRepo.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Return(user, nil)
+		mockRepo.EXPECT().StoreRefreshToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, token book.RefreshToken) error {
+			is.Equal(token.UserID, user.UserID)
+			storedHash = token.TokenHash
+			return nil
+		})
+
+		tokens, err := mS.Login(ctx, user.Email, "secret-password")
+		is.NoErr(err)
+		is.True(tokens.RefreshToken != "")
+		is.True(storedHash != tokens.RefreshToken) //Only the hash is stored.
+
+		claims, err := book.ParseAccessToken(authConfig.SigningKey, tokens.AccessToken, time.Now())
+		is.NoErr(err)
+		is.Equal(claims.UserID, user.UserID)
+		is.Equal(claims.Role, book.UserRoleUser)
+	})
+
+	t.Run("expected credentials invalid error for a wrong password", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Return(user, nil)
+
+		_, err := mS.Login(ctx, user.Email, "wrong-password")
+		is.True(errors.Is(err, book.ErrResponseCredentialsInvalid))
+	})
+
+	t.Run("expected credentials invalid error for an unknown email", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().GetUserByEmail(gomock.Any(), "nobody@mail.com").Return(book.User{}, book.ErrResponseUserNotFound)
+
+		_, err := mS.Login(ctx, "nobody@mail.com", "secret-password")
+		is.True(errors.Is(err, book.ErrResponseCredentialsInvalid))
+	})
+}
+
+func TestRefreshTokens(t *testing.T) {
+	user := book.User{UserID: uuid.New(), Name: "Maria Silva", Role: book.UserRoleAdmin}
+
+	t.Run("trades a refresh token for new tokens, revoking it", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		stored := book.RefreshToken{TokenHash: "hash", UserID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetRefreshTokenForUpdate(gomock.Any(), gomock.Any()).Return(stored, nil)
+		mockTxRepo.EXPECT().RevokeRefreshToken(gomock.Any(), "hash", gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), user.UserID).Return(user, nil)
+		mockTxRepo.EXPECT().StoreRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		tokens, err := mS.RefreshTokens(ctx, "refresh")
+		is.NoErr(err)
+
+		claims, err := book.ParseAccessToken(authConfig.SigningKey, tokens.AccessToken, time.Now())
+		is.NoErr(err)
+		is.Equal(claims.Role, book.UserRoleAdmin)
+	})
+
+	t.Run("expected refresh token invalid error for a token already used", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		revokedAt := time.Now().Add(-time.Minute)
+		stored := book.RefreshToken{TokenHash: "hash", UserID: user.UserID, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetRefreshTokenForUpdate(gomock.Any(), gomock.Any()).Return(stored, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.RefreshTokens(ctx, "refresh")
+		is.True(errors.Is(err, book.ErrResponseRefreshTokenInvalid))
+	})
+}
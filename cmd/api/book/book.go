@@ -9,11 +9,13 @@ import (
 const PriceMax = 9999.99 //max value to field price on db, set to: numeric(6,2)
 
 type Book struct {
-	ID        uuid.UUID
-	Name      string
-	Price     *float32
-	Inventory *int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Archived  bool
+	ID                   uuid.UUID
+	Name                 string
+	Price                *float32
+	Inventory            *int
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Archived             bool
+	MaxUnitsPerOrder     *int //nil means no limit
+	MaxUnitsPerPurchaser *int //nil means no limit
 }
@@ -2,8 +2,9 @@ package book_test
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
-	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +19,15 @@ var ctx context.Context = context.Background()
 
 const notificationsTimeout = 2 * time.Second
 
+var txConfig = book.TxConfig{}
+
+var authConfig = book.AuthConfig{
+	SigningKey:      []byte("test-signing-key-with-at-least-32-bytes"),
+	AccessTokenTTL:  15 * time.Minute,
+	RefreshTokenTTL: time.Hour,
+	CartTokenTTL:    time.Hour,
+}
+
 func TestCreateBook(t *testing.T) {
 
 	t.Run("creates a book without errors", func(t *testing.T) {
@@ -25,7 +35,12 @@ func TestCreateBook(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
 
 		reqBook := book.CreateBookRequest{
 			Name:      "Service tester book",
@@ -33,7 +48,8 @@ func TestCreateBook(t *testing.T) {
 			Inventory: toPointer(99),
 		}
 
-		mockRepo.EXPECT().CreateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, b book.Book) (book.Book, error) {
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CreateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, b book.Book) (book.Book, error) {
 			is.True(b.ID != uuid.Nil)
 			is.Equal(b.Name, reqBook.Name)
 			is.Equal(b.Price, reqBook.Price)
@@ -44,13 +60,16 @@ func TestCreateBook(t *testing.T) {
 			return b, nil
 		})
 
-		wg := sync.WaitGroup{}
-		wg.Add(1)
-		b := book.Book{}
-		mockNtfy.EXPECT().BookCreated(gomock.Any(), gomock.AssignableToTypeOf(b)).DoAndReturn(func(_ context.Context, _ book.Book) error {
-			defer wg.Done()
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.True(event.ID != uuid.Nil)
+			is.Equal(event.EventType, book.EventBookCreated)
+			var payload book.Book
+			is.NoErr(json.Unmarshal(event.Payload, &payload))
+			is.Equal(payload.Name, reqBook.Name)
 			return nil
 		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
 
 		createdBook, err := mS.CreateBook(ctx, reqBook)
 		is.NoErr(err)
@@ -59,8 +78,28 @@ func TestCreateBook(t *testing.T) {
 		is.Equal(createdBook.Price, reqBook.Price)
 		is.Equal(createdBook.Inventory, reqBook.Inventory)
 		is.True(!createdBook.Archived)
+	})
+
+	t.Run("expected error from database, with no event recorded", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		dbErr := errors.New("fake error from database")
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CreateBook(gomock.Any(), gomock.Any()).Return(book.Book{}, dbErr)
+		mockTx.EXPECT().Rollback().Return(nil)
 
-		wg.Wait()
+		_, err := mS.CreateBook(ctx, book.CreateBookRequest{Name: "Service tester book", Price: toPointer(float32(100.0)), Inventory: toPointer(99)})
+		is.True(errors.Is(err, dbErr))
 	})
 }
 
@@ -70,7 +109,12 @@ func TestUpdateBook(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
 
 		reqBook := book.UpdateBookRequest{
 			ID:        uuid.New(),
@@ -79,7 +123,9 @@ func TestUpdateBook(t *testing.T) {
 			Inventory: toPointer(99),
 		}
 
-		mockRepo.EXPECT().UpdateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, b book.Book) (book.Book, error) {
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), reqBook.ID).Return(book.Book{ID: reqBook.ID, Name: reqBook.Name, Price: reqBook.Price, Inventory: reqBook.Inventory}, nil)
+		mockTxRepo.EXPECT().UpdateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, b book.Book) (book.Book, error) {
 			is.Equal(b.ID, reqBook.ID)
 			is.Equal(b.Name, reqBook.Name)
 			is.Equal(b.Price, reqBook.Price)
@@ -87,6 +133,12 @@ func TestUpdateBook(t *testing.T) {
 			is.True(b.UpdatedAt.Compare(time.Now().Round(time.Millisecond)) <= 0)
 			return b, nil
 		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventBookUpdated)
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
 
 		updatedBook, err := mS.UpdateBook(ctx, reqBook)
 		is.NoErr(err)
@@ -96,6 +148,52 @@ func TestUpdateBook(t *testing.T) {
 		is.Equal(updatedBook.Inventory, reqBook.Inventory)
 		is.True(updatedBook.UpdatedAt.Compare(updatedBook.CreatedAt.Round(time.Millisecond)) > 0)
 	})
+
+	t.Run("records an inventory changed event when the inventory is set to a new value", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		reqBook := book.UpdateBookRequest{
+			ID:        uuid.New(),
+			Name:      "Restocked service tester book",
+			Price:     toPointer(float32(100.0)),
+			Inventory: toPointer(50),
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), reqBook.ID).Return(book.Book{ID: reqBook.ID, Name: reqBook.Name, Price: reqBook.Price, Inventory: toPointer(2)}, nil)
+		mockTxRepo.EXPECT().UpdateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, b book.Book) (book.Book, error) {
+			return b, nil
+		})
+		gomock.InOrder(
+			mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.EventType, book.EventBookUpdated)
+				return nil
+			}),
+			mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.EventType, book.EventBookInventoryChanged)
+				var payload book.InventoryChange
+				is.NoErr(json.Unmarshal(event.Payload, &payload))
+				is.Equal(payload.BookID, reqBook.ID)
+				is.Equal(*payload.PreviousInventory, 2)
+				is.Equal(*payload.Inventory, 50)
+				return nil
+			}),
+		)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		_, err := mS.UpdateBook(ctx, reqBook)
+		is.NoErr(err)
+	})
 }
 
 func TestArchiveBook(t *testing.T) {
@@ -104,11 +202,23 @@ func TestArchiveBook(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
 
 		id := uuid.New()
 
-		mockRepo.EXPECT().SetBookArchiveStatus(gomock.Any(), id, true)
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().SetBookArchiveStatus(gomock.Any(), id, true).Return(book.Book{ID: id, Archived: true}, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventBookArchived)
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
 
 		_, err := mS.ArchiveBook(ctx, id)
 		is.NoErr(err)
@@ -121,7 +231,10 @@ func TestGetBook(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
 
 		id := uuid.New()
 
@@ -137,7 +250,10 @@ func TestListBooks(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockRepo := bookmock.NewMockRepository(ctrl)
 	mockNtfy := bookmock.NewMockNotifier(ctrl)
-	mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+	mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+	mockPay := bookmock.NewMockPaymentGateway(ctrl)
+	mockHooks := bookmock.NewMockWebhookSender(ctrl)
+	mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
 	t.Run("list first page of stored books without errors, paginated with exact division", func(t *testing.T) {
 		//Setting specific subtest values:
 		reqBooks := book.ListBooksRequest{
@@ -0,0 +1,205 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* A signed token that opens a guest order to whoever holds it, until it expires or the order is claimed. */
+type CartToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+/* Creates an order with no purchaser, so guests can fill a cart before signing up, and signs the cart token that lets them see and change it. */
+func (s *Service) CreateGuestOrder(ctx context.Context) (Order, CartToken, error) {
+	o, err := s.createOrder(ctx, uuid.Nil)
+	if err != nil {
+		return Order{}, CartToken{}, err
+	}
+
+	expiresAt := time.Now().Add(s.authConfig.CartTokenTTL)
+	token, err := SignCartToken(s.authConfig.SigningKey, CartClaims{OrderID: o.OrderID, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return Order{}, CartToken{}, err
+	}
+
+	return o, CartToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+type ClaimOrderRequest struct {
+	CartToken string
+	UserID    uuid.UUID
+}
+
+/* Attaches the guest order of the cart token to the user, after they logged in. If the user already has an order accepting items, the guest items are merged into it and the guest order is canceled. Returns the order the items are at now. */
+func (s *Service) ClaimOrder(ctx context.Context, req ClaimOrderRequest) (Order, error) {
+	cart, err := ParseCartToken(s.authConfig.SigningKey, req.CartToken, time.Now())
+	if err != nil {
+		return Order{}, err
+	}
+
+	var claimedOrder Order
+	err = s.retryTx(ctx, func() error {
+		var err error
+		claimedOrder, err = s.claimOrder(ctx, cart.OrderID, req.UserID)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return claimedOrder, nil
+}
+
+/* Attaches the guest order to the user, or merges its items into the open order of the user. */
+func (s *Service) claimOrder(ctx context.Context, guestOrderID, userID uuid.UUID) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	status, err := txRepo.GetOrderStatusForUpdate(ctx, guestOrderID) //locks the guest order, so it can't be changed nor claimed twice meanwhile
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to GetOrderStatusForUpdate: %w ", err)
+	}
+	guestOrder, err := txRepo.ListOrderItems(ctx, guestOrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	if guestOrder.PurchaserID != uuid.Nil {
+		return Order{}, ErrResponseOrderAlreadyClaimed
+	}
+	if status != "accepting_items" {
+		return Order{}, ErrResponseOrderNotAcceptingItems
+	}
+
+	_, err = txRepo.GetUserByID(ctx, userID) //Only known users can purchase.
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to GetUserByID: %w ", err)
+	}
+
+	claimedOrderID := guestOrderID
+	openOrderID, err := txRepo.GetOpenOrderOfPurchaser(ctx, userID)
+	switch {
+	case errors.Is(err, ErrResponseOrderNotFound):
+		err = attachOrder(ctx, txRepo, guestOrder, userID)
+	case err != nil:
+		return Order{}, fmt.Errorf("error on call to GetOpenOrderOfPurchaser: %w ", err)
+	default:
+		claimedOrderID = openOrderID
+		err = mergeOrder(ctx, txRepo, guestOrder, openOrderID)
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	claimedOrder, err := txRepo.ListOrderItems(ctx, claimedOrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, claimedOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return claimedOrder, nil
+}
+
+/* Makes the user the purchaser of the guest order. Its items start counting on the purchase limits of the user, so the limits are checked again. */
+func attachOrder(ctx context.Context, txRepo Repository, guestOrder Order, userID uuid.UUID) error {
+	err := txRepo.SetOrderPurchaser(ctx, guestOrder.OrderID, userID)
+	if err != nil {
+		return fmt.Errorf("error on call to SetOrderPurchaser: %w ", err)
+	}
+
+	rejected := ErrOrderItemsRejected{}
+	for _, item := range guestOrder.Items {
+		usage, err := txRepo.GetPurchaseLimitUsage(ctx, guestOrder.OrderID, item.BookID)
+		if err != nil {
+			return fmt.Errorf("error on call to GetPurchaseLimitUsage: %w ", err)
+		}
+		err = usage.check(0) //The units at the order are already counted at the usage.
+		if err != nil {
+			var errR ErrResponse
+			if !errors.As(err, &errR) {
+				return err
+			}
+			rejected.Items = append(rejected.Items, OrderItemError{BookID: item.BookID, Err: errR})
+		}
+	}
+	if len(rejected.Items) > 0 {
+		return rejected
+	}
+	return nil
+}
+
+/* Cancels the guest order and adds its items to the open order of the user. The books go back to the inventory first, so the open order can take the same units again. The coupon of the guest order, if any, is given back: the open order may have its own. */
+func mergeOrder(ctx context.Context, txRepo Repository, guestOrder Order, openOrderID uuid.UUID) error {
+	err := txRepo.UpdateOrderRow(ctx, openOrderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
+	if err != nil {
+		return fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
+	}
+
+	err = txRepo.SetOrderStatus(ctx, guestOrder.OrderID, "canceled")
+	if err != nil {
+		return fmt.Errorf("error on call to SetOrderStatus: %w ", err)
+	}
+	err = recordStatusChange(ctx, txRepo, guestOrder.OrderID, "accepting_items", "canceled")
+	if err != nil {
+		return err
+	}
+
+	changes := []OrderItemChange{}
+	for _, item := range guestOrder.Items {
+		changes = append(changes, OrderItemChange{BookID: item.BookID, BookUnitsToAdd: item.BookUnits})
+	}
+	changes = mergeOrderItemChanges(changes) //Sorted by book, as at UpdateOrderItemsTx, so the book rows are locked in the same sequence.
+
+	for _, change := range changes {
+		err = txRepo.RestockBook(ctx, change.BookID, change.BookUnitsToAdd)
+		if err != nil {
+			return fmt.Errorf("error on call to RestockBook: %w ", err)
+		}
+	}
+	if guestOrder.CouponID != nil {
+		err = txRepo.DecrementCouponUsage(ctx, *guestOrder.CouponID)
+		if err != nil {
+			return fmt.Errorf("error on call to DecrementCouponUsage: %w ", err)
+		}
+	}
+
+	rejected := ErrOrderItemsRejected{}
+	for _, change := range changes {
+		err = updateOrderItem(ctx, txRepo, openOrderID, change.BookID, change.BookUnitsToAdd)
+		if err != nil {
+			var errR ErrResponse
+			if !errors.As(err, &errR) {
+				return err
+			}
+			rejected.Items = append(rejected.Items, OrderItemError{BookID: change.BookID, Err: errR})
+		}
+	}
+	if len(rejected.Items) > 0 {
+		return rejected
+	}
+	return nil
+}
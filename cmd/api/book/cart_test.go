@@ -0,0 +1,234 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestCreateGuestOrder(t *testing.T) {
+
+	t.Run("creates an order with no purchaser and a cart token of it", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, o book.Order) (book.Order, error) {
+			is.Equal(o.PurchaserID, uuid.Nil)
+			return o, nil
+		})
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		newOrder, cart, err := mS.CreateGuestOrder(ctx)
+		is.NoErr(err)
+		is.True(newOrder.OrderID != uuid.Nil)
+		is.True(cart.ExpiresAt.After(time.Now()))
+
+		cartClaims, err := book.ParseCartToken(authConfig.SigningKey, cart.Token, time.Now())
+		is.NoErr(err)
+		is.Equal(cartClaims.OrderID, newOrder.OrderID)
+
+		_, err = book.ParseAccessToken(authConfig.SigningKey, cart.Token, time.Now()) //A cart token never passes as an access token.
+		is.True(errors.Is(err, book.ErrResponseAccessTokenInvalid))
+	})
+
+	t.Run("expected user not found error creating an order with no purchaser through CreateOrder", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mS := book.NewService(bookmock.NewMockRepository(ctrl), bookmock.NewMockNotifier(ctrl), bookmock.NewMockPriceCalculator(ctrl), bookmock.NewMockPaymentGateway(ctrl), bookmock.NewMockWebhookSender(ctrl), notificationsTimeout, txConfig, authConfig)
+
+		_, err := mS.CreateOrder(ctx, uuid.Nil)
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
+}
+
+func TestClaimOrder(t *testing.T) {
+	user := book.User{UserID: uuid.New(), Name: "Some user", Role: book.UserRoleUser}
+	bookID := uuid.New()
+	guestOrder := book.Order{
+		OrderID:     uuid.New(),
+		OrderStatus: "accepting_items",
+		Items:       []book.OrderItem{{BookID: bookID, BookUnits: 2}},
+	}
+
+	cartToken := func(t *testing.T, orderID uuid.UUID) string {
+		token, err := book.SignCartToken(authConfig.SigningKey, book.CartClaims{OrderID: orderID, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	t.Run("attaches the guest order to a user with no open order", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		attached := guestOrder
+		attached.PurchaserID = user.UserID
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), guestOrder.OrderID).Return("accepting_items", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), guestOrder.OrderID).Return(guestOrder, nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), user.UserID).Return(user, nil)
+		mockTxRepo.EXPECT().GetOpenOrderOfPurchaser(gomock.Any(), user.UserID).Return(uuid.Nil, fmt.Errorf("searching open order of purchaser: %w", book.ErrResponseOrderNotFound))
+		mockTxRepo.EXPECT().SetOrderPurchaser(gomock.Any(), guestOrder.OrderID, user.UserID).Return(nil)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), guestOrder.OrderID, bookID).Return(book.PurchaseLimitUsage{UnitsAtOrder: 2, UnitsByPurchaser: 2}, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), guestOrder.OrderID).Return(attached, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		claimed, err := mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: cartToken(t, guestOrder.OrderID), UserID: user.UserID})
+		is.NoErr(err)
+		is.Equal(claimed.OrderID, guestOrder.OrderID)
+		is.Equal(claimed.PurchaserID, user.UserID)
+	})
+
+	t.Run("merges the guest order into the open order of the user, canceling it", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		openOrder := book.Order{OrderID: uuid.New(), PurchaserID: user.UserID, OrderStatus: "accepting_items", Items: []book.OrderItem{{BookID: bookID, BookUnits: 3}}}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), guestOrder.OrderID).Return("accepting_items", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), guestOrder.OrderID).Return(guestOrder, nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), user.UserID).Return(user, nil)
+		mockTxRepo.EXPECT().GetOpenOrderOfPurchaser(gomock.Any(), user.UserID).Return(openOrder.OrderID, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), openOrder.OrderID).Return(nil)
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), guestOrder.OrderID, "canceled").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, change book.StatusChange) error {
+			is.Equal(change.OrderID, guestOrder.OrderID)
+			is.Equal(change.ToStatus, "canceled")
+			return nil
+		})
+		mockTxRepo.EXPECT().RestockBook(gomock.Any(), bookID, 2).Return(nil)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), openOrder.OrderID, bookID).Return(book.PurchaseLimitUsage{UnitsAtOrder: 1, UnitsByPurchaser: 1}, nil)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), openOrder.OrderID, bookID, 2).Return(book.OrderItem{BookID: bookID, BookUnits: 3}, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), openOrder.OrderID).Return(openOrder, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		claimed, err := mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: cartToken(t, guestOrder.OrderID), UserID: user.UserID})
+		is.NoErr(err)
+		is.Equal(claimed.OrderID, openOrder.OrderID)
+		is.Equal(claimed.Items[0].BookUnits, 3)
+	})
+
+	t.Run("expected items rejected error when the guest items break the purchase limits of the user", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		maxUnits := 3
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), guestOrder.OrderID).Return("accepting_items", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), guestOrder.OrderID).Return(guestOrder, nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), user.UserID).Return(user, nil)
+		mockTxRepo.EXPECT().GetOpenOrderOfPurchaser(gomock.Any(), user.UserID).Return(uuid.Nil, book.ErrResponseOrderNotFound)
+		mockTxRepo.EXPECT().SetOrderPurchaser(gomock.Any(), guestOrder.OrderID, user.UserID).Return(nil)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), guestOrder.OrderID, bookID).Return(book.PurchaseLimitUsage{MaxUnitsPerPurchaser: &maxUnits, UnitsAtOrder: 2, UnitsByPurchaser: 4}, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: cartToken(t, guestOrder.OrderID), UserID: user.UserID})
+		var rejected book.ErrOrderItemsRejected
+		is.True(errors.As(err, &rejected))
+		is.Equal(rejected.Items[0].BookID, bookID)
+		is.Equal(rejected.Items[0].Err, book.ErrResponseMaxUnitsPerPurchaserExceeded)
+	})
+
+	t.Run("expected order already claimed error for an order that has a purchaser", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		claimedBefore := guestOrder
+		claimedBefore.PurchaserID = uuid.New()
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), guestOrder.OrderID).Return("accepting_items", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), guestOrder.OrderID).Return(claimedBefore, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: cartToken(t, guestOrder.OrderID), UserID: user.UserID})
+		is.True(errors.Is(err, book.ErrResponseOrderAlreadyClaimed))
+	})
+
+	t.Run("expected cart token invalid error for a token not signed by the service or expired", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mS := book.NewService(bookmock.NewMockRepository(ctrl), bookmock.NewMockNotifier(ctrl), bookmock.NewMockPriceCalculator(ctrl), bookmock.NewMockPaymentGateway(ctrl), bookmock.NewMockWebhookSender(ctrl), notificationsTimeout, txConfig, authConfig)
+
+		forged, err := book.SignCartToken([]byte("another-signing-key-with-32-bytes-or-more"), book.CartClaims{OrderID: guestOrder.OrderID, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		is.NoErr(err)
+		_, err = mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: forged, UserID: user.UserID})
+		is.True(errors.Is(err, book.ErrResponseCartTokenInvalid))
+
+		expired, err := book.SignCartToken(authConfig.SigningKey, book.CartClaims{OrderID: guestOrder.OrderID, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+		is.NoErr(err)
+		_, err = mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: expired, UserID: user.UserID})
+		is.True(errors.Is(err, book.ErrResponseCartTokenInvalid))
+
+		accessToken, err := book.SignAccessToken(authConfig.SigningKey, book.Claims{UserID: user.UserID, Role: user.Role, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		is.NoErr(err)
+		_, err = mS.ClaimOrder(ctx, book.ClaimOrderRequest{CartToken: accessToken, UserID: user.UserID}) //An access token never passes as a cart token.
+		is.True(errors.Is(err, book.ErrResponseCartTokenInvalid))
+	})
+}
@@ -0,0 +1,311 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DiscountTypePercentage  = "percentage"
+	DiscountTypeFixedAmount = "fixed_amount"
+)
+
+type Coupon struct {
+	ID            uuid.UUID
+	Code          string
+	DiscountType  string
+	DiscountValue float32
+	MinOrderTotal float32
+	ValidFrom     *time.Time
+	ValidUntil    *time.Time
+	UsageLimit    *int //nil means unlimited usage
+	TimesUsed     int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+/* Checks if the coupon can be used at the given moment. */
+func (c Coupon) ValidAt(t time.Time) bool {
+	if c.ValidFrom != nil && t.Before(*c.ValidFrom) {
+		return false
+	}
+	if c.ValidUntil != nil && t.After(*c.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+/* Calculates the discount given by the coupon to an order subtotal. Orders below the minimum total get no discount. */
+func (c Coupon) Discount(subtotal float32) float32 {
+	if subtotal < c.MinOrderTotal {
+		return 0
+	}
+
+	var discount float32
+	switch c.DiscountType {
+	case DiscountTypePercentage:
+		discount = subtotal * c.DiscountValue / 100
+	case DiscountTypeFixedAmount:
+		discount = c.DiscountValue
+	}
+	if discount > subtotal { //The discount never makes the total negative.
+		discount = subtotal
+	}
+
+	return float32(math.Round(float64(discount)*100) / 100)
+}
+
+type CreateCouponRequest struct {
+	Code          string
+	DiscountType  string
+	DiscountValue float32
+	MinOrderTotal float32
+	ValidFrom     *time.Time
+	ValidUntil    *time.Time
+	UsageLimit    *int
+}
+
+func (s *Service) CreateCoupon(ctx context.Context, req CreateCouponRequest) (Coupon, error) {
+	createdAt := time.Now().UTC().Round(time.Millisecond)
+	newCoupon := Coupon{
+		ID:            uuid.New(),
+		Code:          req.Code,
+		DiscountType:  req.DiscountType,
+		DiscountValue: req.DiscountValue,
+		MinOrderTotal: req.MinOrderTotal,
+		ValidFrom:     req.ValidFrom,
+		ValidUntil:    req.ValidUntil,
+		UsageLimit:    req.UsageLimit,
+		TimesUsed:     0,
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Coupon{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	c, err := txRepo.CreateCoupon(ctx, newCoupon)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventCouponCreated, c)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Coupon{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return c, nil
+}
+
+func (s *Service) GetCoupon(ctx context.Context, id uuid.UUID) (Coupon, error) {
+	return s.repo.GetCouponByID(ctx, id)
+}
+
+func (s *Service) ListCoupons(ctx context.Context) ([]Coupon, error) {
+	coupons, err := s.repo.ListCoupons(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListCoupons: %w", err)
+	}
+	return coupons, nil
+}
+
+type UpdateCouponRequest struct {
+	ID            uuid.UUID
+	Code          string
+	DiscountType  string
+	DiscountValue float32
+	MinOrderTotal float32
+	ValidFrom     *time.Time
+	ValidUntil    *time.Time
+	UsageLimit    *int
+}
+
+func (s *Service) UpdateCoupon(ctx context.Context, req UpdateCouponRequest) (Coupon, error) {
+	updateCoupon := Coupon{
+		ID:            req.ID,
+		Code:          req.Code,
+		DiscountType:  req.DiscountType,
+		DiscountValue: req.DiscountValue,
+		MinOrderTotal: req.MinOrderTotal,
+		ValidFrom:     req.ValidFrom,
+		ValidUntil:    req.ValidUntil,
+		UsageLimit:    req.UsageLimit,
+		//TimesUsed and CreatedAt will not change
+		UpdatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Coupon{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	c, err := txRepo.UpdateCoupon(ctx, updateCoupon)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventCouponUpdated, c)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Coupon{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return c, nil
+}
+
+func (s *Service) DeleteCoupon(ctx context.Context, id uuid.UUID) error {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = txRepo.DeleteCoupon(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, txRepo, EventCouponDeleted, DeletedResource{ID: id})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return nil
+}
+
+/* Applies a coupon to an order that is still accepting items, through a transaction. A coupon already at the order is replaced. Users only change their own orders; admins change any. */
+func (s *Service) ApplyCoupon(ctx context.Context, orderID uuid.UUID, code string) (Order, error) {
+	var updatedOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		updatedOrder, err = s.applyCoupon(ctx, orderID, code)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return updatedOrder, nil
+}
+
+/* Takes a use of the coupon, giving back the one it replaces, and sets it at the order. */
+func (s *Service) applyCoupon(ctx context.Context, orderID uuid.UUID, code string) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = authorizeOrderChange(ctx, txRepo, orderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = txRepo.UpdateOrderRow(ctx, orderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
+	}
+
+	coupon, err := txRepo.GetCouponByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, ErrResponseCouponNotFound) {
+			return Order{}, ErrResponseCouponNotFound
+		}
+		return Order{}, fmt.Errorf("error on call to GetCouponByCode: %w ", err)
+	}
+	if !coupon.ValidAt(time.Now().UTC()) {
+		return Order{}, ErrResponseCouponNotValid
+	}
+
+	order, err := txRepo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	if order.Subtotal < coupon.MinOrderTotal {
+		return Order{}, ErrResponseCouponMinOrderTotal
+	}
+	if order.CouponID != nil && *order.CouponID == coupon.ID { //Already applied, nothing changes.
+		return order, nil
+	}
+
+	err = txRepo.IncrementCouponUsage(ctx, coupon.ID) //fails if the usage limit was reached
+	if err != nil {
+		if errors.Is(err, ErrResponseCouponUsageLimitReached) {
+			return Order{}, ErrResponseCouponUsageLimitReached
+		}
+		return Order{}, fmt.Errorf("error on call to IncrementCouponUsage: %w ", err)
+	}
+	if order.CouponID != nil { //The replaced coupon is given back.
+		err = txRepo.DecrementCouponUsage(ctx, *order.CouponID)
+		if err != nil {
+			return Order{}, fmt.Errorf("error on call to DecrementCouponUsage: %w ", err)
+		}
+	}
+
+	err = txRepo.SetOrderCoupon(ctx, orderID, coupon.ID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to SetOrderCoupon: %w ", err)
+	}
+
+	updatedOrder, err := txRepo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, updatedOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return updatedOrder, nil
+}
@@ -0,0 +1,199 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestCouponDiscount(t *testing.T) {
+	testCases := []struct {
+		name     string
+		coupon   book.Coupon
+		subtotal float32
+		expected float32
+	}{
+		{
+			name:     "percentage discount",
+			coupon:   book.Coupon{DiscountType: book.DiscountTypePercentage, DiscountValue: 10},
+			subtotal: 155.50,
+			expected: 15.55,
+		},
+		{
+			name:     "fixed amount discount",
+			coupon:   book.Coupon{DiscountType: book.DiscountTypeFixedAmount, DiscountValue: 20},
+			subtotal: 100,
+			expected: 20,
+		},
+		{
+			name:     "fixed amount discount is never greater than the subtotal",
+			coupon:   book.Coupon{DiscountType: book.DiscountTypeFixedAmount, DiscountValue: 20},
+			subtotal: 15,
+			expected: 15,
+		},
+		{
+			name:     "no discount below the minimum order total",
+			coupon:   book.Coupon{DiscountType: book.DiscountTypePercentage, DiscountValue: 10, MinOrderTotal: 50},
+			subtotal: 49.99,
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tc.coupon.Discount(tc.subtotal), tc.expected)
+		})
+	}
+}
+
+func TestApplyCoupon(t *testing.T) {
+	orderID := uuid.New()
+	coupon := book.Coupon{
+		ID:            uuid.New(),
+		Code:          "TENOFF",
+		DiscountType:  book.DiscountTypePercentage,
+		DiscountValue: 10,
+		MinOrderTotal: 50,
+	}
+
+	t.Run("applies a coupon to an order without errors", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetCouponByCode(gomock.Any(), coupon.Code).Return(coupon, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, Subtotal: 100, TotalPrice: 100}, nil)
+		mockTxRepo.EXPECT().IncrementCouponUsage(gomock.Any(), coupon.ID).Return(nil)
+		mockTxRepo.EXPECT().SetOrderCoupon(gomock.Any(), orderID, coupon.ID).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, CouponID: &coupon.ID, CouponCode: coupon.Code, Subtotal: 100, Discount: 10, TotalPrice: 90}, nil)
+
+		updatedOrder, err := mS.ApplyCoupon(ctx, orderID, coupon.Code)
+		is.NoErr(err)
+		is.Equal(updatedOrder.CouponCode, coupon.Code)
+		is.Equal(updatedOrder.TotalPrice, float32(90))
+	})
+
+	t.Run("replaces the coupon already at the order, giving back its usage", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		oldCouponID := uuid.New()
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetCouponByCode(gomock.Any(), coupon.Code).Return(coupon, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, CouponID: &oldCouponID, Subtotal: 100}, nil)
+		mockTxRepo.EXPECT().IncrementCouponUsage(gomock.Any(), coupon.ID).Return(nil)
+		mockTxRepo.EXPECT().DecrementCouponUsage(gomock.Any(), oldCouponID).Return(nil)
+		mockTxRepo.EXPECT().SetOrderCoupon(gomock.Any(), orderID, coupon.ID).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, CouponID: &coupon.ID}, nil)
+
+		updatedOrder, err := mS.ApplyCoupon(ctx, orderID, coupon.Code)
+		is.NoErr(err)
+		is.Equal(*updatedOrder.CouponID, coupon.ID)
+	})
+
+	t.Run("expected usage limit reached error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetCouponByCode(gomock.Any(), coupon.Code).Return(coupon, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, Subtotal: 100}, nil)
+		mockTxRepo.EXPECT().IncrementCouponUsage(gomock.Any(), coupon.ID).Return(book.ErrResponseCouponUsageLimitReached)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updatedOrder, err := mS.ApplyCoupon(ctx, orderID, coupon.Code)
+		is.True(errors.Is(err, book.ErrResponseCouponUsageLimitReached))
+		is.Equal(updatedOrder, book.Order{})
+	})
+
+	t.Run("expected minimum order total error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetCouponByCode(gomock.Any(), coupon.Code).Return(coupon, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, Subtotal: 20}, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.ApplyCoupon(ctx, orderID, coupon.Code)
+		is.True(errors.Is(err, book.ErrResponseCouponMinOrderTotal))
+	})
+
+	t.Run("expected coupon not valid error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		expiredCoupon := coupon
+		expiredCoupon.ValidUntil = toPointer(time.Now().UTC().Add(-time.Hour))
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetCouponByCode(gomock.Any(), coupon.Code).DoAndReturn(func(context.Context, string) (book.Coupon, error) {
+			return expiredCoupon, nil
+		})
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.ApplyCoupon(ctx, orderID, coupon.Code)
+		is.True(errors.Is(err, book.ErrResponseCouponNotValid))
+	})
+}
@@ -0,0 +1,95 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+type ShippingAddress struct {
+	Recipient  string
+	Line1      string
+	Line2      string //optional: apartment, suite, etc.
+	City       string
+	State      string //optional, as not every country has states
+	PostalCode string
+	Country    string //ISO 3166-1 alpha-2 code
+}
+
+type UpdateOrderDetailsRequest struct {
+	OrderID         uuid.UUID
+	ShippingAddress *ShippingAddress
+	ContactEmail    string
+	ContactPhone    string
+	Notes           string
+}
+
+/* Replaces the shipping address, contact and notes of an order, through a transaction. They can only change while the order is accepting items, so they are frozen at checkout. Users only change their own orders; admins change any. */
+func (s *Service) UpdateOrderDetails(ctx context.Context, req UpdateOrderDetailsRequest) (Order, error) {
+	var updatedOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		updatedOrder, err = s.updateOrderDetails(ctx, req)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return updatedOrder, nil
+}
+
+/* Stores the new details while the order still accepts items. */
+func (s *Service) updateOrderDetails(ctx context.Context, req UpdateOrderDetailsRequest) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = authorizeOrderChange(ctx, txRepo, req.OrderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = txRepo.UpdateOrderRow(ctx, req.OrderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
+	}
+
+	err = txRepo.SetOrderDetails(ctx, Order{
+		OrderID:         req.OrderID,
+		ShippingAddress: req.ShippingAddress,
+		ContactEmail:    req.ContactEmail,
+		ContactPhone:    req.ContactPhone,
+		Notes:           req.Notes,
+	})
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to SetOrderDetails: %w ", err)
+	}
+
+	updatedOrder, err := txRepo.ListOrderItems(ctx, req.OrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, updatedOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return updatedOrder, nil
+}
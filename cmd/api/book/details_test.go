@@ -0,0 +1,87 @@
+package book_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestUpdateOrderDetails(t *testing.T) {
+	req := book.UpdateOrderDetailsRequest{
+		OrderID: uuid.New(),
+		ShippingAddress: &book.ShippingAddress{
+			Recipient:  "Maria Silva",
+			Line1:      "Rua das Flores, 100",
+			City:       "São Paulo",
+			State:      "SP",
+			PostalCode: "01000-000",
+			Country:    "BR",
+		},
+		ContactEmail: "maria@mail.com",
+		Notes:        "Leave it at the front desk",
+	}
+
+	t.Run("updates the details of an order accepting items", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		expected := book.Order{
+			OrderID:         req.OrderID,
+			OrderStatus:     "accepting_items",
+			ShippingAddress: req.ShippingAddress,
+			ContactEmail:    req.ContactEmail,
+			Notes:           req.Notes,
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), req.OrderID).Return(nil)
+		mockTxRepo.EXPECT().SetOrderDetails(gomock.Any(), book.Order{
+			OrderID:         req.OrderID,
+			ShippingAddress: req.ShippingAddress,
+			ContactEmail:    req.ContactEmail,
+			Notes:           req.Notes,
+		}).Return(nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), req.OrderID).Return(expected, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updatedOrder, err := mS.UpdateOrderDetails(ctx, req)
+		is.NoErr(err)
+		is.Equal(updatedOrder, expected)
+	})
+
+	t.Run("expected order not accepting items error after checkout", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), req.OrderID).Return(book.ErrResponseOrderNotAcceptingItems)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updatedOrder, err := mS.UpdateOrderDetails(ctx, req)
+		is.True(errors.Is(err, book.ErrResponseOrderNotAcceptingItems))
+		is.Equal(updatedOrder, book.Order{})
+	})
+}
@@ -2,6 +2,8 @@ package book
 
 import (
 	"fmt"
+
+	"github.com/google/uuid"
 )
 
 type ErrResponse struct {
@@ -30,6 +32,90 @@ var ErrResponseBookNotAtOrder = ErrResponse{114, "book is not at the order"}
 var ErrResponseUpdateOrderEntryBlankFields = ErrResponse{116, "all the fields - order_id, book_id and book_units_to_add - must be filled correctly."}
 var ErrResponseNewOrderEntryBlankFields = ErrResponse{117, "field user_id must be filled correctly."}
 var ErrResponseListOrderItemsEntryBlankFields = ErrResponse{118, "field order_id must be filled correctly."}
+var ErrResponseOrderItemsRejected = ErrResponse{119, "order items rejected, no changes were made to the order."}
+var ErrResponseUpdateOrderItemsEntryBlankFields = ErrResponse{120, "all the fields - order_id and items, each one with book_id and book_units_to_add - must be filled correctly."}
+var ErrResponseIdempotencyKeyNotFound = ErrResponse{121, "idempotency key not found"}
+var ErrResponseIdempotencyKeyReused = ErrResponse{122, "this Idempotency-Key was already used with a different request."}
+var ErrResponseIdempotencyKeyInvalid = ErrResponse{123, "header Idempotency-Key must have at most 255 characters."}
+var ErrResponseCouponNotFound = ErrResponse{124, "coupon not found"}
+var ErrResponseCouponEntryBlankFields = ErrResponse{125, "the fields code, discount_type ('percentage' or 'fixed_amount') and discount_value must be filled correctly. A percentage must not be greater than 100 and valid_from must be before valid_until."}
+var ErrResponseCouponCodeInUse = ErrResponse{126, "there is already a coupon with this code"}
+var ErrResponseCouponNotValid = ErrResponse{127, "coupon is not valid at this time"}
+var ErrResponseCouponUsageLimitReached = ErrResponse{128, "coupon usage limit was reached"}
+var ErrResponseCouponMinOrderTotal = ErrResponse{129, "order total is below the minimum required by the coupon"}
+var ErrResponseApplyCouponEntryBlankFields = ErrResponse{130, "field code must be filled correctly."}
+var ErrResponseCouponIdInvalidFormat = ErrResponse{131, "the endpoint is not a valid format ID. Must be /coupons/{uuid}"}
+var ErrResponseOrderIdInvalidFormat = ErrResponse{132, "the endpoint is not a valid format ID. Must be /orders/{uuid}"}
+var ErrResponseOrderIsEmpty = ErrResponse{133, "order has no items"}
+var ErrResponseTxRetriesExhausted = ErrResponse{135, "the request conflicted with concurrent changes, please try again."}
+var ErrResponseMaxUnitsPerOrderExceeded = ErrResponse{136, "the units of this book exceed the limit allowed per order"}
+var ErrResponseMaxUnitsPerPurchaserExceeded = ErrResponse{137, "the units of this book exceed the limit allowed per purchaser"}
+var ErrResponseBookEntryInvalidLimits = ErrResponse{138, "fields max_units_per_order and max_units_per_purchaser, when filled, must be greater than zero."}
+var ErrResponseUserIdInvalidFormat = ErrResponse{139, "the endpoint is not a valid format ID. Must be /users/{uuid}"}
+var ErrResponseWishlistEntryBlankFields = ErrResponse{140, "field book_id must be filled correctly."}
+var ErrResponseBookNotAtWishlist = ErrResponse{141, "book is not at the wishlist"}
+var ErrResponseMoveWishlistItemEntryBlankFields = ErrResponse{142, "field order_id must be filled correctly, and book_units, when filled, must be greater than zero."}
+var ErrResponseReturnEntryBlankFields = ErrResponse{143, "fields reason and items - each with book_id and book_units greater than zero - must be filled correctly."}
+var ErrResponseOrderNotReturnable = ErrResponse{144, "only paid orders can have books returned"}
+var ErrResponseReturnUnitsExceeded = ErrResponse{145, "the units to return exceed the units bought and not returned yet"}
+var ErrResponseReturnNotFound = ErrResponse{146, "return not found"}
+var ErrResponseReturnNotPending = ErrResponse{147, "return was already approved or rejected"}
+var ErrResponseReturnIdInvalidFormat = ErrResponse{148, "the endpoint is not a valid format ID. Must be /returns/{uuid}"}
+var ErrResponseShippingAddressInvalid = ErrResponse{149, "fields recipient, line1, city, postal_code and country - a two letters code - of shipping_address must be filled correctly."}
+var ErrResponseContactEmailInvalid = ErrResponse{150, "field contact_email, when filled, must be a valid email address."}
+var ErrResponseContactPhoneInvalid = ErrResponse{151, "field contact_phone, when filled, must have from 8 to 15 digits, optionally starting with '+'."}
+var ErrResponseOrderNotesTooLong = ErrResponse{152, "field notes must have at most 500 characters."}
+var ErrResponseShipmentEntryBlankFields = ErrResponse{153, "field items - each with book_id and book_units greater than zero - must be filled correctly."}
+var ErrResponseOrderNotFulfillable = ErrResponse{154, "only paid orders, with no returns asked, can have books picked and shipped"}
+var ErrResponseShipmentUnitsExceeded = ErrResponse{155, "the units to pick exceed the units bought and not picked yet"}
+var ErrResponseShipmentNotFound = ErrResponse{156, "shipment not found"}
+var ErrResponseShipmentStepInvalid = ErrResponse{157, "a shipment must be picked to be shipped, and shipped to be delivered"}
+var ErrResponseShipEntryBlankFields = ErrResponse{158, "fields carrier and tracking_number must be filled correctly."}
+var ErrResponseShipmentIdInvalidFormat = ErrResponse{159, "the endpoint is not a valid format ID. Must be /shipments/{uuid}"}
+var ErrResponseUserEntryBlankFields = ErrResponse{160, "fields name and role - 'user' or 'admin' - must be filled correctly."}
+var ErrResponseUserNotFound = ErrResponse{161, "user not found"}
+var ErrResponseUserHasOrders = ErrResponse{162, "users with orders can't be deleted"}
+var ErrResponseAccessTokenInvalid = ErrResponse{163, "a valid access token must be sent at the header 'Authorization: Bearer {token}'"}
+var ErrResponseCredentialsInvalid = ErrResponse{164, "email or password is incorrect"}
+var ErrResponseRefreshTokenInvalid = ErrResponse{165, "refresh token is invalid, expired or already used"}
+var ErrResponseLoginEntryBlankFields = ErrResponse{166, "fields email and password must be filled correctly."}
+var ErrResponseRefreshEntryBlankFields = ErrResponse{167, "field refresh_token must be filled correctly."}
+var ErrResponseUserEmailInvalid = ErrResponse{168, "field email, when filled, must be a valid email address."}
+var ErrResponseUserPasswordInvalid = ErrResponse{169, "field password, when filled, must have from 8 to 72 characters, and needs an email to log in with."}
+var ErrResponseUserEmailInUse = ErrResponse{170, "email already in use"}
+var ErrResponseForbidden = ErrResponse{171, "the role of the user does not allow this action"}
+var ErrResponseAPIKeyInvalid = ErrResponse{172, "a valid API key must be sent at the header 'Authorization: ApiKey {key}'"}
+var ErrResponseAPIKeyEntryBlankFields = ErrResponse{173, "fields name and scopes - each one of books:write, coupons:read, coupons:write, orders:read, orders:write, fulfillment:write, payments:write, returns:review, users:read or users:write - must be filled correctly."}
+var ErrResponseAPIKeyNotFound = ErrResponse{174, "api key not found"}
+var ErrResponseAPIKeyIdInvalidFormat = ErrResponse{175, "the endpoint is not a valid format ID. Must be /apikeys/{uuid}"}
+var ErrResponseRateLimited = ErrResponse{176, "too many requests, try again after the seconds at the header 'Retry-After'"}
+var ErrResponseCartTokenInvalid = ErrResponse{177, "a valid cart token must be sent at the header 'Authorization: Cart {token}', or at the field cart_token to claim its order"}
+var ErrResponseOrderAlreadyClaimed = ErrResponse{178, "the order of the cart token was already claimed"}
+var ErrResponseWebhookNotFound = ErrResponse{179, "webhook not found"}
+var ErrResponseWebhookEntryBlankFields = ErrResponse{180, "fields url - an absolute http or https URL - and event_types - each one a known event type - must be filled correctly. Field secret, when filled, must have at least 16 characters."}
+var ErrResponseWebhookIdInvalidFormat = ErrResponse{181, "the endpoint is not a valid format ID. Must be /webhooks/{uuid}"}
+var ErrResponseIdempotencyKeyInProgress = ErrResponse{182, "a request with this Idempotency-Key is still being processed. Retry it later."}
+var ErrResponseShippingAddressRequired = ErrResponse{183, "the order must have a shipping address, set at /orders/{id}/details, before checkout"}
+var ErrResponseTaxRegionNotSupported = ErrResponse{184, "the country and state of the shipping address are not a supported tax region"}
+var ErrResponseOrderNotWaitingPayment = ErrResponse{185, "only checked out orders waiting payment can have their payment confirmed"}
+
+type OrderItemError struct {
+	BookID uuid.UUID
+	Err    ErrResponse
+}
+
+/* Holds the errors of each rejected item of a batch update on an order. */
+type ErrOrderItemsRejected struct {
+	Items []OrderItemError
+}
+
+func (e ErrOrderItemsRejected) Error() string {
+	return fmt.Sprintf("%s (%d items rejected)", ErrResponseOrderItemsRejected.Message, len(e.Items))
+}
+
+func (e ErrOrderItemsRejected) Unwrap() error {
+	return ErrResponseOrderItemsRejected
+}
 
 type ErrNotificationFailed struct {
 	statusCode int
@@ -42,3 +128,15 @@ func (e ErrNotificationFailed) Error() string {
 func NewErrNotificationFailed(statusCode int) ErrNotificationFailed {
 	return ErrNotificationFailed{statusCode: statusCode}
 }
+
+type ErrWebhookFailed struct {
+	statusCode int
+}
+
+func (e ErrWebhookFailed) Error() string {
+	return fmt.Sprintf("webhook wrong response - want: 2xx, got: %d", e.statusCode)
+}
+
+func NewErrWebhookFailed(statusCode int) ErrWebhookFailed {
+	return ErrWebhookFailed{statusCode: statusCode}
+}
@@ -0,0 +1,91 @@
+package book
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* What happened at the service. Each mutation records an event of its type, in the same transaction as the change. Logins, idempotency keys, the outbox itself and webhook deliveries are bookkeeping and record none. */
+type EventType string
+
+const (
+	EventBookCreated          EventType = "book_created"
+	EventBookUpdated          EventType = "book_updated"
+	EventBookArchived         EventType = "book_archived"
+	EventBookInventoryChanged EventType = "book_inventory_changed"
+
+	EventOrderCreated EventType = "order_created"
+	EventOrderUpdated EventType = "order_updated"
+	EventOrderExpired EventType = "order_expired"
+
+	EventShipmentUpdated EventType = "shipment_updated"
+
+	EventReturnRequested EventType = "return_requested"
+	EventReturnApproved  EventType = "return_approved"
+	EventReturnRejected  EventType = "return_rejected"
+
+	EventCouponCreated EventType = "coupon_created"
+	EventCouponUpdated EventType = "coupon_updated"
+	EventCouponDeleted EventType = "coupon_deleted"
+
+	EventUserCreated EventType = "user_created"
+	EventUserUpdated EventType = "user_updated"
+	EventUserDeleted EventType = "user_deleted"
+	EventUserErased  EventType = "user_erased"
+
+	EventWishlistItemAdded   EventType = "wishlist_item_added"
+	EventWishlistItemRemoved EventType = "wishlist_item_removed"
+
+	EventAPIKeyCreated EventType = "api_key_created"
+	EventAPIKeyRevoked EventType = "api_key_revoked"
+
+	EventWebhookCreated EventType = "webhook_created"
+	EventWebhookUpdated EventType = "webhook_updated"
+	EventWebhookDeleted EventType = "webhook_deleted"
+)
+
+var eventTypes = map[EventType]bool{
+	EventBookCreated: true, EventBookUpdated: true, EventBookArchived: true, EventBookInventoryChanged: true,
+	EventOrderCreated: true, EventOrderUpdated: true, EventOrderExpired: true,
+	EventShipmentUpdated: true,
+	EventReturnRequested: true, EventReturnApproved: true, EventReturnRejected: true,
+	EventCouponCreated: true, EventCouponUpdated: true, EventCouponDeleted: true,
+	EventUserCreated: true, EventUserUpdated: true, EventUserDeleted: true, EventUserErased: true,
+	EventWishlistItemAdded: true, EventWishlistItemRemoved: true,
+	EventAPIKeyCreated: true, EventAPIKeyRevoked: true,
+	EventWebhookCreated: true, EventWebhookUpdated: true, EventWebhookDeleted: true,
+}
+
+/* Tells if webhooks can subscribe to the event type. */
+func ValidEventType(eventType EventType) bool {
+	return eventTypes[eventType]
+}
+
+/* The envelope events are handed to the Notifier in. The payload is the JSON of the resource the event happened to, as it was after the change: a Book, Order, Shipment, Return, Coupon, User, APIKey, Webhook or UserErasure, or one of the payloads below. */
+type Event struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       EventType       `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+/* The payload of book_inventory_changed, recorded when an admin sets the inventory of a book to a new value. The units taken and given back by orders are told by the order events instead. */
+type InventoryChange struct {
+	BookID            uuid.UUID
+	BookName          string
+	PreviousInventory *int
+	Inventory         *int
+}
+
+/* The payload of the wishlist events. */
+type WishlistChange struct {
+	UserID uuid.UUID
+	BookID uuid.UUID
+}
+
+/* The payload of the events of deleted resources, that can't be read anymore. */
+type DeletedResource struct {
+	ID uuid.UUID
+}
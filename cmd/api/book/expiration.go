@@ -0,0 +1,94 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* Cancels the orders left accepting items without any change for longer than maxIdle, giving their books back to the inventory. The purchasers are notified through the outbox. Returns how many orders were expired. */
+func (s *Service) ExpireAbandonedOrders(ctx context.Context, maxIdle time.Duration) (int, error) {
+	untouchedSince := time.Now().UTC().Add(-maxIdle)
+	ctx = ContextWithActor(ctx, ActorSystem)
+
+	orderIDs, err := s.repo.ListAbandonedOrders(ctx, untouchedSince)
+	if err != nil {
+		return 0, fmt.Errorf("error on call to ListAbandonedOrders: %w", err)
+	}
+
+	expired := 0
+	for _, orderID := range orderIDs {
+		err := s.retryTx(ctx, func() error {
+			return s.expireOrder(ctx, orderID, untouchedSince)
+		})
+		if err != nil {
+			if !errors.Is(err, ErrResponseOrderNotAcceptingItems) { //Otherwise the order was changed or checked out after being listed, so it is not abandoned anymore.
+				log.Printf("expiring order %v: %v", orderID, err)
+			}
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+/* Cancels a single abandoned order and restocks its items through a transaction. */
+func (s *Service) expireOrder(ctx context.Context, orderID uuid.UUID, untouchedSince time.Time) error {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = txRepo.CancelAbandonedOrder(ctx, orderID, untouchedSince) //locks the order row, so it can't get new items meanwhile
+	if err != nil {
+		return fmt.Errorf("error on call to CancelAbandonedOrder: %w ", err)
+	}
+	err = recordStatusChange(ctx, txRepo, orderID, "accepting_items", "canceled")
+	if err != nil {
+		return err
+	}
+
+	order, err := txRepo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	for _, item := range order.Items {
+		err = txRepo.RestockBook(ctx, item.BookID, item.BookUnits)
+		if err != nil {
+			return fmt.Errorf("error on call to RestockBook: %w ", err)
+		}
+	}
+
+	if order.CouponID != nil { //The coupon usage is given back too.
+		err = txRepo.DecrementCouponUsage(ctx, *order.CouponID)
+		if err != nil {
+			return fmt.Errorf("error on call to DecrementCouponUsage: %w ", err)
+		}
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderExpired, order)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return nil
+}
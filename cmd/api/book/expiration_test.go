@@ -0,0 +1,126 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestExpireAbandonedOrders(t *testing.T) {
+	maxIdle := time.Hour
+
+	t.Run("cancels an abandoned order, restocking its books and recording the event to notify the purchaser", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		couponID := uuid.New()
+		order := book.Order{
+			OrderID:     uuid.New(),
+			PurchaserID: uuid.New(),
+			OrderStatus: "canceled",
+			CouponID:    &couponID,
+			Items: []book.OrderItem{
+				{BookID: uuid.New(), BookUnits: 2},
+				{BookID: uuid.New(), BookUnits: 5},
+			},
+		}
+
+		mockRepo.EXPECT().ListAbandonedOrders(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, untouchedSince time.Time) ([]uuid.UUID, error) {
+			is.True(untouchedSince.Before(time.Now().UTC().Add(-maxIdle).Add(time.Second)))
+			return []uuid.UUID{order.OrderID}, nil
+		})
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CancelAbandonedOrder(gomock.Any(), order.OrderID, gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, change book.StatusChange) error {
+			is.Equal(change.OrderID, order.OrderID)
+			is.Equal(change.FromStatus, "accepting_items")
+			is.Equal(change.ToStatus, "canceled")
+			is.Equal(change.ChangedBy, book.ActorSystem)
+			return nil
+		})
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+		mockTxRepo.EXPECT().RestockBook(gomock.Any(), order.Items[0].BookID, 2).Return(nil)
+		mockTxRepo.EXPECT().RestockBook(gomock.Any(), order.Items[1].BookID, 5).Return(nil)
+		mockTxRepo.EXPECT().DecrementCouponUsage(gomock.Any(), couponID).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventOrderExpired)
+			var payload book.Order
+			is.NoErr(json.Unmarshal(event.Payload, &payload))
+			is.Equal(payload.OrderID, order.OrderID)
+			is.Equal(payload.PurchaserID, order.PurchaserID)
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		expired, err := mS.ExpireAbandonedOrders(ctx, maxIdle)
+		is.NoErr(err)
+		is.Equal(expired, 1)
+	})
+
+	t.Run("skips an order changed after being listed, expiring the others", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		touchedOrderID := uuid.New()
+		abandonedOrder := book.Order{OrderID: uuid.New(), PurchaserID: uuid.New(), OrderStatus: "canceled"}
+
+		mockRepo.EXPECT().ListAbandonedOrders(gomock.Any(), gomock.Any()).Return([]uuid.UUID{touchedOrderID, abandonedOrder.OrderID}, nil)
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil).Times(2)
+		mockTxRepo.EXPECT().CancelAbandonedOrder(gomock.Any(), touchedOrderID, gomock.Any()).Return(book.ErrResponseOrderNotAcceptingItems)
+		mockTx.EXPECT().Rollback().Return(nil)
+		mockTxRepo.EXPECT().CancelAbandonedOrder(gomock.Any(), abandonedOrder.OrderID, gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), abandonedOrder.OrderID).Return(abandonedOrder, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		expired, err := mS.ExpireAbandonedOrders(ctx, maxIdle)
+		is.NoErr(err)
+		is.Equal(expired, 1)
+	})
+
+	t.Run("expected error from database", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		dbErr := errors.New("fake error from database")
+		mockRepo.EXPECT().ListAbandonedOrders(gomock.Any(), gomock.Any()).Return(nil, dbErr)
+
+		expired, err := mS.ExpireAbandonedOrders(ctx, maxIdle)
+		is.True(errors.Is(err, dbErr))
+		is.Equal(expired, 0)
+	})
+}
@@ -0,0 +1,325 @@
+package book
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ShipmentStatusPicked    = "picked"
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusDelivered = "delivered"
+)
+
+/* A package of books of a paid order, picked at the warehouse and then shipped and delivered. An order can have many shipments, each one with part of its units. */
+type Shipment struct {
+	ShipmentID     uuid.UUID
+	OrderID        uuid.UUID
+	PurchaserID    uuid.UUID //of the order, so the purchaser can be notified
+	Status         string
+	Carrier        string //set when shipped
+	TrackingNumber string //set when shipped
+	Items          []ShipmentItem
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ShippedAt      *time.Time
+	DeliveredAt    *time.Time
+}
+
+type ShipmentItem struct {
+	BookID    uuid.UUID
+	BookUnits int
+}
+
+type PickShipmentRequest struct {
+	OrderID uuid.UUID
+	Items   []ShipmentItem
+}
+
+/* Records that units of a paid order were picked at the warehouse, through a transaction. The units not picked yet can go at later shipments. */
+func (s *Service) PickShipment(ctx context.Context, req PickShipmentRequest) (Shipment, error) {
+	var picked Shipment
+	err := s.retryTx(ctx, func() error {
+		var err error
+		picked, err = s.pickShipment(ctx, req)
+		return err
+	})
+	if err != nil {
+		return Shipment{}, err
+	}
+	return picked, nil
+}
+
+/* Creates the shipment, with the order locked so its units are only picked once. */
+func (s *Service) pickShipment(ctx context.Context, req PickShipmentRequest) (Shipment, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	status, err := txRepo.GetOrderStatusForUpdate(ctx, req.OrderID) //Locks the order, so concurrent picks can't take the same units twice.
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to GetOrderStatusForUpdate: %w ", err)
+	}
+	if status != "paid" && status != "partially_shipped" {
+		return Shipment{}, ErrResponseOrderNotFulfillable
+	}
+
+	order, err := txRepo.ListOrderItems(ctx, req.OrderID)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	shipments, err := txRepo.ListShipments(ctx, req.OrderID)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to ListShipments: %w ", err)
+	}
+
+	//Only the units bought and not at other shipments can be picked:
+	unpicked := map[uuid.UUID]int{}
+	for _, item := range order.Items {
+		unpicked[item.BookID] = item.BookUnits
+	}
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			unpicked[item.BookID] -= item.BookUnits
+		}
+	}
+	items := mergeShipmentItems(req.Items)
+	for _, item := range items {
+		units, found := unpicked[item.BookID]
+		if !found {
+			return Shipment{}, ErrResponseBookNotAtOrder
+		}
+		if item.BookUnits > units {
+			return Shipment{}, ErrResponseShipmentUnitsExceeded
+		}
+	}
+
+	createdAt := time.Now().UTC().Round(time.Millisecond)
+	picked, err := txRepo.CreateShipment(ctx, Shipment{
+		ShipmentID:  uuid.New(),
+		OrderID:     req.OrderID,
+		PurchaserID: order.PurchaserID,
+		Status:      ShipmentStatusPicked,
+		Items:       items,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	})
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to CreateShipment: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventShipmentUpdated, picked)
+	if err != nil {
+		return Shipment{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return picked, nil
+}
+
+type ShipShipmentRequest struct {
+	ShipmentID     uuid.UUID
+	Carrier        string
+	TrackingNumber string
+}
+
+/* Records that a picked shipment left the warehouse, with the carrier and tracking number given, through a transaction. */
+func (s *Service) ShipShipment(ctx context.Context, req ShipShipmentRequest) (Shipment, error) {
+	var shipped Shipment
+	err := s.retryTx(ctx, func() error {
+		var err error
+		shipped, err = s.advanceShipment(ctx, req.ShipmentID, func(shipment *Shipment, at time.Time) error {
+			if shipment.Status != ShipmentStatusPicked {
+				return ErrResponseShipmentStepInvalid
+			}
+			shipment.Status = ShipmentStatusShipped
+			shipment.Carrier = req.Carrier
+			shipment.TrackingNumber = req.TrackingNumber
+			shipment.ShippedAt = &at
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return Shipment{}, err
+	}
+	return shipped, nil
+}
+
+/* Records that a shipped shipment reached the purchaser, through a transaction. */
+func (s *Service) DeliverShipment(ctx context.Context, shipmentID uuid.UUID) (Shipment, error) {
+	var delivered Shipment
+	err := s.retryTx(ctx, func() error {
+		var err error
+		delivered, err = s.advanceShipment(ctx, shipmentID, func(shipment *Shipment, at time.Time) error {
+			if shipment.Status != ShipmentStatusShipped {
+				return ErrResponseShipmentStepInvalid
+			}
+			shipment.Status = ShipmentStatusDelivered
+			shipment.DeliveredAt = &at
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return Shipment{}, err
+	}
+	return delivered, nil
+}
+
+/* Moves a shipment a step forward: step changes the shipment, or refuses it if it is not at the expected step. The status of the order follows its shipments. */
+func (s *Service) advanceShipment(ctx context.Context, shipmentID uuid.UUID, step func(shipment *Shipment, at time.Time) error) (Shipment, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	shipment, err := txRepo.GetShipmentForUpdate(ctx, shipmentID)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to GetShipmentForUpdate: %w ", err)
+	}
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	err = step(&shipment, now)
+	if err != nil {
+		return Shipment{}, err
+	}
+	shipment.UpdatedAt = now
+
+	status, err := txRepo.GetOrderStatusForUpdate(ctx, shipment.OrderID)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to GetOrderStatusForUpdate: %w ", err)
+	}
+	if status != "paid" && status != "partially_shipped" && status != "shipped" { //Returns were asked meanwhile, so the order status now follows them.
+		return Shipment{}, ErrResponseOrderNotFulfillable
+	}
+	err = txRepo.UpdateShipment(ctx, shipment)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to UpdateShipment: %w ", err)
+	}
+
+	order, err := txRepo.ListOrderItems(ctx, shipment.OrderID)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	shipments, err := txRepo.ListShipments(ctx, shipment.OrderID)
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to ListShipments: %w ", err)
+	}
+
+	if status := fulfillmentStatus(order, shipments); status != order.OrderStatus {
+		err = txRepo.SetOrderStatus(ctx, order.OrderID, status)
+		if err != nil {
+			return Shipment{}, fmt.Errorf("error on call to SetOrderStatus: %w ", err)
+		}
+		err = recordStatusChange(ctx, txRepo, order.OrderID, order.OrderStatus, status)
+		if err != nil {
+			return Shipment{}, err
+		}
+	}
+
+	err = recordEvent(ctx, txRepo, EventShipmentUpdated, shipment)
+	if err != nil {
+		return Shipment{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Shipment{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return shipment, nil
+}
+
+/* Returns the shipments of an order. Users only see the shipments of their own orders; admins see any. */
+func (s *Service) ListShipments(ctx context.Context, orderID uuid.UUID) ([]Shipment, error) {
+	err := authorizeOrderRead(ctx, s.repo, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	shipments, err := s.repo.ListShipments(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListShipments: %w", err)
+	}
+	return shipments, nil
+}
+
+/* Tells the status of a paid order from its shipments: delivered once all the units bought were delivered, shipped once all of them left the warehouse, and partially shipped while only some did. */
+func fulfillmentStatus(order Order, shipments []Shipment) string {
+	shippedUnits, deliveredUnits := 0, 0
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			switch shipment.Status {
+			case ShipmentStatusDelivered:
+				deliveredUnits += item.BookUnits
+				shippedUnits += item.BookUnits
+			case ShipmentStatusShipped:
+				shippedUnits += item.BookUnits
+			}
+		}
+	}
+
+	boughtUnits := 0
+	for _, item := range order.Items {
+		boughtUnits += item.BookUnits
+	}
+
+	switch {
+	case boughtUnits > 0 && deliveredUnits == boughtUnits:
+		return "delivered"
+	case boughtUnits > 0 && shippedUnits == boughtUnits:
+		return "shipped"
+	case shippedUnits > 0:
+		return "partially_shipped"
+	default:
+		return "paid"
+	}
+}
+
+/* Sums the units of repeated books and sorts the items by book ID, dropping the ones with no units. */
+func mergeShipmentItems(items []ShipmentItem) []ShipmentItem {
+	unitsByBook := map[uuid.UUID]int{}
+	for _, item := range items {
+		unitsByBook[item.BookID] += item.BookUnits
+	}
+
+	merged := []ShipmentItem{}
+	for bookID, units := range unitsByBook {
+		if units <= 0 {
+			continue
+		}
+		merged = append(merged, ShipmentItem{BookID: bookID, BookUnits: units})
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return bytes.Compare(merged[i].BookID[:], merged[j].BookID[:]) < 0
+	})
+	return merged
+}
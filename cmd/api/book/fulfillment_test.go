@@ -0,0 +1,229 @@
+package book_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestPickShipment(t *testing.T) {
+	orderID := uuid.New()
+	purchaserID := uuid.New()
+	bookID := uuid.New()
+	order := book.Order{
+		OrderID:     orderID,
+		PurchaserID: purchaserID,
+		OrderStatus: "paid",
+		Items:       []book.OrderItem{{BookID: bookID, BookUnits: 3}},
+	}
+
+	t.Run("picks part of the units bought", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		previous := book.Shipment{OrderID: orderID, Status: book.ShipmentStatusShipped, Items: []book.ShipmentItem{{BookID: bookID, BookUnits: 1}}}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("partially_shipped", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{previous}, nil)
+		mockTxRepo.EXPECT().CreateShipment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, shipment book.Shipment) (book.Shipment, error) {
+			is.Equal(shipment.OrderID, orderID)
+			is.Equal(shipment.PurchaserID, purchaserID)
+			is.Equal(shipment.Status, book.ShipmentStatusPicked)
+			is.Equal(shipment.Items, []book.ShipmentItem{{BookID: bookID, BookUnits: 2}})
+			return shipment, nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		picked, err := mS.PickShipment(ctx, book.PickShipmentRequest{
+			OrderID: orderID,
+			Items:   []book.ShipmentItem{{BookID: bookID, BookUnits: 1}, {BookID: bookID, BookUnits: 1}},
+		})
+		is.NoErr(err)
+		is.Equal(picked.Status, book.ShipmentStatusPicked)
+	})
+
+	t.Run("expected order not fulfillable error before payment", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("waiting_payment", nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.PickShipment(ctx, book.PickShipmentRequest{
+			OrderID: orderID,
+			Items:   []book.ShipmentItem{{BookID: bookID, BookUnits: 1}},
+		})
+		is.True(errors.Is(err, book.ErrResponseOrderNotFulfillable))
+	})
+
+	t.Run("expected units exceeded error, counting the units at other shipments", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		previous := book.Shipment{OrderID: orderID, Status: book.ShipmentStatusPicked, Items: []book.ShipmentItem{{BookID: bookID, BookUnits: 2}}}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("paid", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{previous}, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.PickShipment(ctx, book.PickShipmentRequest{
+			OrderID: orderID,
+			Items:   []book.ShipmentItem{{BookID: bookID, BookUnits: 2}},
+		})
+		is.True(errors.Is(err, book.ErrResponseShipmentUnitsExceeded))
+	})
+}
+
+func TestAdvanceShipment(t *testing.T) {
+	orderID := uuid.New()
+	bookID := uuid.New()
+	otherBookID := uuid.New()
+	order := book.Order{
+		OrderID:     orderID,
+		OrderStatus: "paid",
+		Items:       []book.OrderItem{{BookID: bookID, BookUnits: 1}, {BookID: otherBookID, BookUnits: 1}},
+	}
+
+	t.Run("ships part of the order, which becomes partially shipped", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		shipment := book.Shipment{ShipmentID: uuid.New(), OrderID: orderID, Status: book.ShipmentStatusPicked, Items: []book.ShipmentItem{{BookID: bookID, BookUnits: 1}}}
+		shipped := shipment
+		shipped.Status = book.ShipmentStatusShipped
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetShipmentForUpdate(gomock.Any(), shipment.ShipmentID).Return(shipment, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("paid", nil)
+		mockTxRepo.EXPECT().UpdateShipment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, updated book.Shipment) error {
+			is.Equal(updated.Status, book.ShipmentStatusShipped)
+			is.Equal(updated.Carrier, "Correios")
+			is.Equal(updated.TrackingNumber, "BR123456789")
+			is.True(updated.ShippedAt != nil)
+			return nil
+		})
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{shipped}, nil)
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), orderID, "partially_shipped").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, change book.StatusChange) error {
+			is.Equal(change.FromStatus, "paid")
+			is.Equal(change.ToStatus, "partially_shipped")
+			return nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updated, err := mS.ShipShipment(ctx, book.ShipShipmentRequest{
+			ShipmentID:     shipment.ShipmentID,
+			Carrier:        "Correios",
+			TrackingNumber: "BR123456789",
+		})
+		is.NoErr(err)
+		is.Equal(updated.Status, book.ShipmentStatusShipped)
+	})
+
+	t.Run("delivers the last shipment, and the order becomes delivered", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		shippedOrder := order
+		shippedOrder.OrderStatus = "shipped"
+		shipment := book.Shipment{ShipmentID: uuid.New(), OrderID: orderID, Status: book.ShipmentStatusShipped, Items: []book.ShipmentItem{{BookID: bookID, BookUnits: 1}}}
+		other := book.Shipment{ShipmentID: uuid.New(), OrderID: orderID, Status: book.ShipmentStatusDelivered, Items: []book.ShipmentItem{{BookID: otherBookID, BookUnits: 1}}}
+		delivered := shipment
+		delivered.Status = book.ShipmentStatusDelivered
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetShipmentForUpdate(gomock.Any(), shipment.ShipmentID).Return(shipment, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("shipped", nil)
+		mockTxRepo.EXPECT().UpdateShipment(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(shippedOrder, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{delivered, other}, nil)
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), orderID, "delivered").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updated, err := mS.DeliverShipment(ctx, shipment.ShipmentID)
+		is.NoErr(err)
+		is.Equal(updated.Status, book.ShipmentStatusDelivered)
+		is.True(updated.DeliveredAt != nil)
+	})
+
+	t.Run("expected shipment step invalid error when delivering a picked shipment", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		shipment := book.Shipment{ShipmentID: uuid.New(), OrderID: orderID, Status: book.ShipmentStatusPicked}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetShipmentForUpdate(gomock.Any(), shipment.ShipmentID).Return(shipment, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.DeliverShipment(ctx, shipment.ShipmentID)
+		is.True(errors.Is(err, book.ErrResponseShipmentStepInvalid))
+	})
+}
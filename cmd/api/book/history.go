@@ -0,0 +1,72 @@
+package book
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ActorSystem    = "system"    //changes made by the service itself, like expiring abandoned orders
+	ActorAnonymous = "anonymous" //changes asked by requests with no known user
+)
+
+/* A change of the status of an order. FromStatus is blank for the status the order was created with. */
+type StatusChange struct {
+	OrderID    uuid.UUID
+	FromStatus string
+	ToStatus   string
+	ChangedBy  string
+	ChangedAt  time.Time
+}
+
+type actorKey struct{}
+
+/* Returns a copy of ctx telling who is asking for the changes, so they are recorded at the order status history. */
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+/* Tells who is asking for the changes, or ActorAnonymous if nobody was set at ctx. */
+func actorFromContext(ctx context.Context) string {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	if !ok || actor == "" {
+		return ActorAnonymous
+	}
+	return actor
+}
+
+/* Appends a status change to the history of the order, inside the transaction of txRepo. */
+func recordStatusChange(ctx context.Context, txRepo Repository, orderID uuid.UUID, from, to string) error {
+	change := StatusChange{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  actorFromContext(ctx),
+		ChangedAt:  time.Now().UTC().Round(time.Millisecond),
+	}
+	err := txRepo.InsertOrderStatusChange(ctx, change)
+	if err != nil {
+		return fmt.Errorf("error on call to InsertOrderStatusChange: %w ", err)
+	}
+	return nil
+}
+
+/* Returns the status changes of an order, the oldest first. Users only see the history of their own orders; admins see any. */
+func (s *Service) ListOrderHistory(ctx context.Context, orderID uuid.UUID) ([]StatusChange, error) {
+	err := authorizeOrderRead(ctx, s.repo, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.repo.ListOrderStatusHistory(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListOrderStatusHistory: %w", err)
+	}
+	if len(history) == 0 { //Every order has at least the status it was created with.
+		return nil, ErrResponseOrderNotFound
+	}
+	return history, nil
+}
@@ -0,0 +1,87 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestListOrderHistory(t *testing.T) {
+	orderID := uuid.New()
+
+	t.Run("lists the status changes of an order", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		history := []book.StatusChange{
+			{OrderID: orderID, ToStatus: "accepting_items", ChangedBy: book.ActorAnonymous, ChangedAt: time.Now().UTC().Add(-time.Hour)},
+			{OrderID: orderID, FromStatus: "accepting_items", ToStatus: "canceled", ChangedBy: book.ActorSystem, ChangedAt: time.Now().UTC()},
+		}
+		mockRepo.EXPECT().ListOrderStatusHistory(gomock.Any(), orderID).Return(history, nil)
+
+		fetchedHistory, err := mS.ListOrderHistory(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(fetchedHistory, history)
+	})
+
+	t.Run("expected order not found error with no status changes", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().ListOrderStatusHistory(gomock.Any(), orderID).Return([]book.StatusChange{}, nil)
+
+		_, err := mS.ListOrderHistory(ctx, orderID)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+
+	t.Run("records who changed the status", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		userID := uuid.New()
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), userID).Return(book.User{UserID: userID}, nil)
+		mockTxRepo.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o book.Order) (book.Order, error) {
+			return o, nil
+		})
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, change book.StatusChange) error {
+			is.Equal(change.ChangedBy, userID.String())
+			return nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		_, err := mS.CreateOrder(book.ContextWithActor(ctx, userID.String()), userID)
+		is.NoErr(err)
+	})
+}
@@ -0,0 +1,74 @@
+package book
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/* Response stored for a request sent with an 'Idempotency-Key' header, to be replayed when the same request is retried. Keys belong to the caller that sent them, so callers can't see each other's responses. */
+type IdempotencyKey struct {
+	Key            string
+	Caller         string //who sent the key: a user, an API key or a guest cart
+	RequestHash    string
+	ResponseStatus int
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	CompletedAt    *time.Time //nil while the request is still running
+}
+
+/* Gets a stored idempotency key of the caller. Expired keys are treated as not found. */
+func (s *Service) GetIdempotencyKey(ctx context.Context, caller, key string) (IdempotencyKey, error) {
+	idemKey, err := s.repo.GetIdempotencyKey(ctx, caller, key)
+	if err != nil {
+		return IdempotencyKey{}, fmt.Errorf("error on call to GetIdempotencyKey: %w", err)
+	}
+
+	return idemKey, nil
+}
+
+/* Claims a key for a request about to run, until idemKey.ExpiresAt. Only one request gets a key: while it is held, reserving it again returns ErrResponseIdempotencyKeyReused. */
+func (s *Service) ReserveIdempotencyKey(ctx context.Context, idemKey IdempotencyKey) error {
+	idemKey.CreatedAt = time.Now().UTC().Round(time.Millisecond)
+
+	err := s.repo.ReserveIdempotencyKey(ctx, idemKey)
+	if err != nil {
+		return fmt.Errorf("error on call to ReserveIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+/* Stores the response given to the request that reserved the key, so it can be replayed until the key expires. */
+func (s *Service) CompleteIdempotencyKey(ctx context.Context, idemKey IdempotencyKey) error {
+	completedAt := time.Now().UTC().Round(time.Millisecond)
+	idemKey.CompletedAt = &completedAt
+
+	err := s.repo.CompleteIdempotencyKey(ctx, idemKey)
+	if err != nil {
+		return fmt.Errorf("error on call to CompleteIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+/* Frees a reserved key whose request failed, so the caller can retry it. */
+func (s *Service) ReleaseIdempotencyKey(ctx context.Context, caller, key string) error {
+	err := s.repo.ReleaseIdempotencyKey(ctx, caller, key)
+	if err != nil {
+		return fmt.Errorf("error on call to ReleaseIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+/* Deletes the expired idempotency keys. Returns how many were deleted. */
+func (s *Service) PurgeExpiredIdempotencyKeys(ctx context.Context) (int, error) {
+	purged, err := s.repo.PurgeExpiredIdempotencyKeys(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error on call to PurgeExpiredIdempotencyKeys: %w", err)
+	}
+
+	return purged, nil
+}
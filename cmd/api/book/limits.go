@@ -0,0 +1,20 @@
+package book
+
+/* The purchase limits of a book, next to how many units of it a purchaser already holds. */
+type PurchaseLimitUsage struct {
+	MaxUnitsPerOrder     *int
+	MaxUnitsPerPurchaser *int
+	UnitsAtOrder         int //Units of the book at the order being updated.
+	UnitsByPurchaser     int //Units of the book at every non-canceled order of the purchaser, the order being updated included.
+}
+
+/* Tells if adding units of a book would break one of its purchase limits. */
+func (u PurchaseLimitUsage) check(unitsToAdd int) error {
+	if u.MaxUnitsPerOrder != nil && u.UnitsAtOrder+unitsToAdd > *u.MaxUnitsPerOrder {
+		return ErrResponseMaxUnitsPerOrderExceeded
+	}
+	if u.MaxUnitsPerPurchaser != nil && u.UnitsByPurchaser+unitsToAdd > *u.MaxUnitsPerPurchaser {
+		return ErrResponseMaxUnitsPerPurchaserExceeded
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package book_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestPurchaseLimits(t *testing.T) {
+	updtReq := book.UpdateOrderRequest{
+		OrderID:        uuid.New(),
+		BookID:         uuid.New(),
+		BookUnitsToAdd: 3,
+	}
+
+	testCases := []struct {
+		name     string
+		usage    book.PurchaseLimitUsage
+		expected error
+	}{
+		{
+			name:     "expected per order limit error",
+			usage:    book.PurchaseLimitUsage{MaxUnitsPerOrder: toPointer(4), UnitsAtOrder: 2, UnitsByPurchaser: 2},
+			expected: book.ErrResponseMaxUnitsPerOrderExceeded,
+		},
+		{
+			name:     "expected per purchaser limit error, counting the units at other orders",
+			usage:    book.PurchaseLimitUsage{MaxUnitsPerOrder: toPointer(5), MaxUnitsPerPurchaser: toPointer(6), UnitsAtOrder: 2, UnitsByPurchaser: 4},
+			expected: book.ErrResponseMaxUnitsPerPurchaserExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			mockRepo := bookmock.NewMockRepository(ctrl)
+			mockNtfy := bookmock.NewMockNotifier(ctrl)
+			mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+			mockPay := bookmock.NewMockPaymentGateway(ctrl)
+			mockHooks := bookmock.NewMockWebhookSender(ctrl)
+			mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+			mockTxRepo := bookmock.NewMockRepository(ctrl)
+			mockTx := bookmock.NewMockTx(ctrl)
+
+			mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+			mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(nil)
+			mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(tc.usage, nil)
+			if tc.usage.MaxUnitsPerPurchaser != nil {
+				mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), updtReq.BookID).Return(book.Book{ID: updtReq.BookID}, nil)
+				mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(tc.usage, nil)
+			}
+			mockTx.EXPECT().Rollback().Return(nil) //No inventory is reserved.
+
+			updatedOrder, err := mS.UpdateOrderTx(ctx, updtReq)
+			is.True(errors.Is(err, tc.expected))
+			is.Equal(updatedOrder, book.Order{})
+		})
+	}
+
+	t.Run("adds units up to the limits", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		usage := book.PurchaseLimitUsage{MaxUnitsPerOrder: toPointer(5), MaxUnitsPerPurchaser: toPointer(7), UnitsAtOrder: 2, UnitsByPurchaser: 4}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(nil)
+		gomock.InOrder(
+			mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(usage, nil),
+			mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), updtReq.BookID).Return(book.Book{ID: updtReq.BookID}, nil),
+			mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(usage, nil),
+		)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID, 3).Return(book.OrderItem{BookID: updtReq.BookID, BookUnits: 5}, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).Return(book.Order{OrderID: updtReq.OrderID}, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updatedOrder, err := mS.UpdateOrderTx(ctx, updtReq)
+		is.NoErr(err)
+		is.Equal(updatedOrder.OrderID, updtReq.OrderID)
+	})
+
+	t.Run("expected per purchaser limit error from the usage read again once the book is locked", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		before := book.PurchaseLimitUsage{MaxUnitsPerPurchaser: toPointer(7), UnitsByPurchaser: 4}
+		after := book.PurchaseLimitUsage{MaxUnitsPerPurchaser: toPointer(7), UnitsByPurchaser: 6} //Another order of the purchaser took 2 units meanwhile.
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(nil)
+		gomock.InOrder(
+			mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(before, nil),
+			mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), updtReq.BookID).Return(book.Book{ID: updtReq.BookID}, nil),
+			mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(after, nil),
+		)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		updatedOrder, err := mS.UpdateOrderTx(ctx, updtReq)
+		is.True(errors.Is(err, book.ErrResponseMaxUnitsPerPurchaserExceeded))
+		is.Equal(updatedOrder, book.Order{})
+	})
+}
@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/books-service/cmd/api/book (interfaces: Repository,Notifier)
+// Source: github.com/books-service/cmd/api/book (interfaces: Repository,Notifier,PriceCalculator,PaymentGateway,WebhookSender)
 //
 // Generated by this command:
 //
-//	mockgen.exe -destination ./cmd/api/book/mocks/mocks.go -package book github.com/books-service/cmd/api/book Repository,Notifier
+//	mockgen.exe -destination ./cmd/api/book/mocks/mocks.go -package book github.com/books-service/cmd/api/book Repository,Notifier,PriceCalculator,PaymentGateway,WebhookSender
 //
 // Package book is a generated GoMock package.
 package book
@@ -13,6 +13,7 @@ import (
 	sql "database/sql"
 	driver "database/sql/driver"
 	reflect "reflect"
+	time "time"
 
 	book "github.com/books-service/cmd/api/book"
 	uuid "github.com/google/uuid"
@@ -42,139 +43,1063 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddWishlistItem mocks base method.
+func (m *MockRepository) AddWishlistItem(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 time.Time) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWishlistItem", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWishlistItem indicates an expected call of AddWishlistItem.
+func (mr *MockRepositoryMockRecorder) AddWishlistItem(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWishlistItem", reflect.TypeOf((*MockRepository)(nil).AddWishlistItem), arg0, arg1, arg2, arg3)
+}
+
+// AnonymizePurchaserOrders mocks base method.
+func (m *MockRepository) AnonymizePurchaserOrders(arg0 context.Context, arg1 uuid.UUID) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizePurchaserOrders", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizePurchaserOrders indicates an expected call of AnonymizePurchaserOrders.
+func (mr *MockRepositoryMockRecorder) AnonymizePurchaserOrders(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizePurchaserOrders", reflect.TypeOf((*MockRepository)(nil).AnonymizePurchaserOrders), arg0, arg1)
+}
+
+// AnonymizeUser mocks base method.
+func (m *MockRepository) AnonymizeUser(arg0 context.Context, arg1 uuid.UUID, arg2 string, arg3 time.Time) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizeUser indicates an expected call of AnonymizeUser.
+func (mr *MockRepositoryMockRecorder) AnonymizeUser(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUser", reflect.TypeOf((*MockRepository)(nil).AnonymizeUser), arg0, arg1, arg2, arg3)
+}
+
+// AnonymizeUserEvents mocks base method.
+func (m *MockRepository) AnonymizeUserEvents(arg0 context.Context, arg1 uuid.UUID, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUserEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeUserEvents indicates an expected call of AnonymizeUserEvents.
+func (mr *MockRepositoryMockRecorder) AnonymizeUserEvents(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUserEvents", reflect.TypeOf((*MockRepository)(nil).AnonymizeUserEvents), arg0, arg1, arg2)
+}
+
+// AnonymizeUserWebhookDeliveries mocks base method.
+func (m *MockRepository) AnonymizeUserWebhookDeliveries(arg0 context.Context, arg1 uuid.UUID, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUserWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeUserWebhookDeliveries indicates an expected call of AnonymizeUserWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) AnonymizeUserWebhookDeliveries(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUserWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).AnonymizeUserWebhookDeliveries), arg0, arg1, arg2)
+}
+
 // BeginTx mocks base method.
 func (m *MockRepository) BeginTx(arg0 context.Context, arg1 *sql.TxOptions) (book.Repository, driver.Tx, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BeginTx", arg0, arg1)
-	ret0, _ := ret[0].(book.Repository)
-	ret1, _ := ret[1].(driver.Tx)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "BeginTx", arg0, arg1)
+	ret0, _ := ret[0].(book.Repository)
+	ret1, _ := ret[1].(driver.Tx)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// BeginTx indicates an expected call of BeginTx.
+func (mr *MockRepositoryMockRecorder) BeginTx(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTx", reflect.TypeOf((*MockRepository)(nil).BeginTx), arg0, arg1)
+}
+
+// CancelAbandonedOrder mocks base method.
+func (m *MockRepository) CancelAbandonedOrder(arg0 context.Context, arg1 uuid.UUID, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelAbandonedOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelAbandonedOrder indicates an expected call of CancelAbandonedOrder.
+func (mr *MockRepositoryMockRecorder) CancelAbandonedOrder(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelAbandonedOrder", reflect.TypeOf((*MockRepository)(nil).CancelAbandonedOrder), arg0, arg1, arg2)
+}
+
+// CheckoutOrder mocks base method.
+func (m *MockRepository) CheckoutOrder(arg0 context.Context, arg1 book.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckoutOrder", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckoutOrder indicates an expected call of CheckoutOrder.
+func (mr *MockRepositoryMockRecorder) CheckoutOrder(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutOrder", reflect.TypeOf((*MockRepository)(nil).CheckoutOrder), arg0, arg1)
+}
+
+// ClearWishlist mocks base method.
+func (m *MockRepository) ClearWishlist(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearWishlist", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearWishlist indicates an expected call of ClearWishlist.
+func (mr *MockRepositoryMockRecorder) ClearWishlist(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearWishlist", reflect.TypeOf((*MockRepository)(nil).ClearWishlist), arg0, arg1)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockRepository) CompleteIdempotencyKey(arg0 context.Context, arg1 book.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) CompleteIdempotencyKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).CompleteIdempotencyKey), arg0, arg1)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockRepository) CreateAPIKey(arg0 context.Context, arg1 book.APIKey) (book.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(book.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockRepositoryMockRecorder) CreateAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockRepository)(nil).CreateAPIKey), arg0, arg1)
+}
+
+// CreateBook mocks base method.
+func (m *MockRepository) CreateBook(arg0 context.Context, arg1 book.Book) (book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBook", arg0, arg1)
+	ret0, _ := ret[0].(book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBook indicates an expected call of CreateBook.
+func (mr *MockRepositoryMockRecorder) CreateBook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBook", reflect.TypeOf((*MockRepository)(nil).CreateBook), arg0, arg1)
+}
+
+// CreateCoupon mocks base method.
+func (m *MockRepository) CreateCoupon(arg0 context.Context, arg1 book.Coupon) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCoupon", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCoupon indicates an expected call of CreateCoupon.
+func (mr *MockRepositoryMockRecorder) CreateCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockRepository)(nil).CreateCoupon), arg0, arg1)
+}
+
+// CreateOrder mocks base method.
+func (m *MockRepository) CreateOrder(arg0 context.Context, arg1 book.Order) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrder", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrder indicates an expected call of CreateOrder.
+func (mr *MockRepositoryMockRecorder) CreateOrder(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockRepository)(nil).CreateOrder), arg0, arg1)
+}
+
+// CreateReturn mocks base method.
+func (m *MockRepository) CreateReturn(arg0 context.Context, arg1 book.Return) (book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateReturn", arg0, arg1)
+	ret0, _ := ret[0].(book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateReturn indicates an expected call of CreateReturn.
+func (mr *MockRepositoryMockRecorder) CreateReturn(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateReturn", reflect.TypeOf((*MockRepository)(nil).CreateReturn), arg0, arg1)
+}
+
+// CreateShipment mocks base method.
+func (m *MockRepository) CreateShipment(arg0 context.Context, arg1 book.Shipment) (book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateShipment", arg0, arg1)
+	ret0, _ := ret[0].(book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateShipment indicates an expected call of CreateShipment.
+func (mr *MockRepositoryMockRecorder) CreateShipment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShipment", reflect.TypeOf((*MockRepository)(nil).CreateShipment), arg0, arg1)
+}
+
+// CreateUser mocks base method.
+func (m *MockRepository) CreateUser(arg0 context.Context, arg1 book.User) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockRepositoryMockRecorder) CreateUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), arg0, arg1)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockRepository) CreateWebhook(arg0 context.Context, arg1 book.Webhook) (book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1)
+	ret0, _ := ret[0].(book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockRepositoryMockRecorder) CreateWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockRepository)(nil).CreateWebhook), arg0, arg1)
+}
+
+// DecrementCouponUsage mocks base method.
+func (m *MockRepository) DecrementCouponUsage(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecrementCouponUsage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecrementCouponUsage indicates an expected call of DecrementCouponUsage.
+func (mr *MockRepositoryMockRecorder) DecrementCouponUsage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecrementCouponUsage", reflect.TypeOf((*MockRepository)(nil).DecrementCouponUsage), arg0, arg1)
+}
+
+// DeleteCoupon mocks base method.
+func (m *MockRepository) DeleteCoupon(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCoupon", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCoupon indicates an expected call of DeleteCoupon.
+func (mr *MockRepositoryMockRecorder) DeleteCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCoupon", reflect.TypeOf((*MockRepository)(nil).DeleteCoupon), arg0, arg1)
+}
+
+// DeleteOrderItem mocks base method.
+func (m *MockRepository) DeleteOrderItem(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrderItem", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOrderItem indicates an expected call of DeleteOrderItem.
+func (mr *MockRepositoryMockRecorder) DeleteOrderItem(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrderItem", reflect.TypeOf((*MockRepository)(nil).DeleteOrderItem), arg0, arg1, arg2)
+}
+
+// DeleteUser mocks base method.
+func (m *MockRepository) DeleteUser(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockRepositoryMockRecorder) DeleteUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepository)(nil).DeleteUser), arg0, arg1)
+}
+
+// DeleteUserRefreshTokens mocks base method.
+func (m *MockRepository) DeleteUserRefreshTokens(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserRefreshTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserRefreshTokens indicates an expected call of DeleteUserRefreshTokens.
+func (mr *MockRepositoryMockRecorder) DeleteUserRefreshTokens(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserRefreshTokens", reflect.TypeOf((*MockRepository)(nil).DeleteUserRefreshTokens), arg0, arg1)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockRepository) DeleteWebhook(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockRepositoryMockRecorder) DeleteWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockRepository)(nil).DeleteWebhook), arg0, arg1)
+}
+
+// DeleteWishlistItem mocks base method.
+func (m *MockRepository) DeleteWishlistItem(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWishlistItem", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWishlistItem indicates an expected call of DeleteWishlistItem.
+func (mr *MockRepositoryMockRecorder) DeleteWishlistItem(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWishlistItem", reflect.TypeOf((*MockRepository)(nil).DeleteWishlistItem), arg0, arg1, arg2)
+}
+
+// EnqueueWebhookDeliveries mocks base method.
+func (m *MockRepository) EnqueueWebhookDeliveries(arg0 context.Context, arg1 book.WebhookDelivery) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueWebhookDeliveries indicates an expected call of EnqueueWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) EnqueueWebhookDeliveries(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).EnqueueWebhookDeliveries), arg0, arg1)
+}
+
+// GetAPIKeyByPrefix mocks base method.
+func (m *MockRepository) GetAPIKeyByPrefix(arg0 context.Context, arg1 string) (book.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByPrefix", arg0, arg1)
+	ret0, _ := ret[0].(book.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByPrefix indicates an expected call of GetAPIKeyByPrefix.
+func (mr *MockRepositoryMockRecorder) GetAPIKeyByPrefix(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByPrefix", reflect.TypeOf((*MockRepository)(nil).GetAPIKeyByPrefix), arg0, arg1)
+}
+
+// GetBookByID mocks base method.
+func (m *MockRepository) GetBookByID(arg0 context.Context, arg1 uuid.UUID) (book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookByID", arg0, arg1)
+	ret0, _ := ret[0].(book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookByID indicates an expected call of GetBookByID.
+func (mr *MockRepositoryMockRecorder) GetBookByID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookByID", reflect.TypeOf((*MockRepository)(nil).GetBookByID), arg0, arg1)
+}
+
+// GetBookByIDForUpdate mocks base method.
+func (m *MockRepository) GetBookByIDForUpdate(arg0 context.Context, arg1 uuid.UUID) (book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookByIDForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookByIDForUpdate indicates an expected call of GetBookByIDForUpdate.
+func (mr *MockRepositoryMockRecorder) GetBookByIDForUpdate(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookByIDForUpdate", reflect.TypeOf((*MockRepository)(nil).GetBookByIDForUpdate), arg0, arg1)
+}
+
+// GetCouponByCode mocks base method.
+func (m *MockRepository) GetCouponByCode(arg0 context.Context, arg1 string) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCouponByCode", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCouponByCode indicates an expected call of GetCouponByCode.
+func (mr *MockRepositoryMockRecorder) GetCouponByCode(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponByCode", reflect.TypeOf((*MockRepository)(nil).GetCouponByCode), arg0, arg1)
+}
+
+// GetCouponByID mocks base method.
+func (m *MockRepository) GetCouponByID(arg0 context.Context, arg1 uuid.UUID) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCouponByID", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCouponByID indicates an expected call of GetCouponByID.
+func (mr *MockRepositoryMockRecorder) GetCouponByID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCouponByID", reflect.TypeOf((*MockRepository)(nil).GetCouponByID), arg0, arg1)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockRepository) GetIdempotencyKey(arg0 context.Context, arg1, arg2 string) (book.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) GetIdempotencyKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).GetIdempotencyKey), arg0, arg1, arg2)
+}
+
+// GetOpenOrderOfPurchaser mocks base method.
+func (m *MockRepository) GetOpenOrderOfPurchaser(arg0 context.Context, arg1 uuid.UUID) (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpenOrderOfPurchaser", arg0, arg1)
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOpenOrderOfPurchaser indicates an expected call of GetOpenOrderOfPurchaser.
+func (mr *MockRepositoryMockRecorder) GetOpenOrderOfPurchaser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpenOrderOfPurchaser", reflect.TypeOf((*MockRepository)(nil).GetOpenOrderOfPurchaser), arg0, arg1)
+}
+
+// GetOrderItem mocks base method.
+func (m *MockRepository) GetOrderItem(arg0 context.Context, arg1, arg2 uuid.UUID) (book.OrderItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderItem", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.OrderItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderItem indicates an expected call of GetOrderItem.
+func (mr *MockRepositoryMockRecorder) GetOrderItem(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderItem", reflect.TypeOf((*MockRepository)(nil).GetOrderItem), arg0, arg1, arg2)
+}
+
+// GetOrderStatusForUpdate mocks base method.
+func (m *MockRepository) GetOrderStatusForUpdate(arg0 context.Context, arg1 uuid.UUID) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderStatusForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderStatusForUpdate indicates an expected call of GetOrderStatusForUpdate.
+func (mr *MockRepositoryMockRecorder) GetOrderStatusForUpdate(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderStatusForUpdate", reflect.TypeOf((*MockRepository)(nil).GetOrderStatusForUpdate), arg0, arg1)
+}
+
+// GetPurchaseLimitUsage mocks base method.
+func (m *MockRepository) GetPurchaseLimitUsage(arg0 context.Context, arg1, arg2 uuid.UUID) (book.PurchaseLimitUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPurchaseLimitUsage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.PurchaseLimitUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPurchaseLimitUsage indicates an expected call of GetPurchaseLimitUsage.
+func (mr *MockRepositoryMockRecorder) GetPurchaseLimitUsage(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPurchaseLimitUsage", reflect.TypeOf((*MockRepository)(nil).GetPurchaseLimitUsage), arg0, arg1, arg2)
+}
+
+// GetRefreshTokenForUpdate mocks base method.
+func (m *MockRepository) GetRefreshTokenForUpdate(arg0 context.Context, arg1 string) (book.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshTokenForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(book.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshTokenForUpdate indicates an expected call of GetRefreshTokenForUpdate.
+func (mr *MockRepositoryMockRecorder) GetRefreshTokenForUpdate(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokenForUpdate", reflect.TypeOf((*MockRepository)(nil).GetRefreshTokenForUpdate), arg0, arg1)
+}
+
+// GetReturnForUpdate mocks base method.
+func (m *MockRepository) GetReturnForUpdate(arg0 context.Context, arg1 uuid.UUID) (book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReturnForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReturnForUpdate indicates an expected call of GetReturnForUpdate.
+func (mr *MockRepositoryMockRecorder) GetReturnForUpdate(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReturnForUpdate", reflect.TypeOf((*MockRepository)(nil).GetReturnForUpdate), arg0, arg1)
+}
+
+// GetShipmentForUpdate mocks base method.
+func (m *MockRepository) GetShipmentForUpdate(arg0 context.Context, arg1 uuid.UUID) (book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShipmentForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShipmentForUpdate indicates an expected call of GetShipmentForUpdate.
+func (mr *MockRepositoryMockRecorder) GetShipmentForUpdate(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShipmentForUpdate", reflect.TypeOf((*MockRepository)(nil).GetShipmentForUpdate), arg0, arg1)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockRepository) GetUserByEmail(arg0 context.Context, arg1 string) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockRepositoryMockRecorder) GetUserByEmail(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockRepository)(nil).GetUserByEmail), arg0, arg1)
+}
+
+// GetUserByID mocks base method.
+func (m *MockRepository) GetUserByID(arg0 context.Context, arg1 uuid.UUID) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockRepositoryMockRecorder) GetUserByID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), arg0, arg1)
+}
+
+// GetWebhookByID mocks base method.
+func (m *MockRepository) GetWebhookByID(arg0 context.Context, arg1 uuid.UUID) (book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookByID", arg0, arg1)
+	ret0, _ := ret[0].(book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookByID indicates an expected call of GetWebhookByID.
+func (mr *MockRepositoryMockRecorder) GetWebhookByID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookByID", reflect.TypeOf((*MockRepository)(nil).GetWebhookByID), arg0, arg1)
+}
+
+// GetWishlistItem mocks base method.
+func (m *MockRepository) GetWishlistItem(arg0 context.Context, arg1, arg2 uuid.UUID) (book.WishlistItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWishlistItem", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.WishlistItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWishlistItem indicates an expected call of GetWishlistItem.
+func (mr *MockRepositoryMockRecorder) GetWishlistItem(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWishlistItem", reflect.TypeOf((*MockRepository)(nil).GetWishlistItem), arg0, arg1, arg2)
+}
+
+// IncrementCouponUsage mocks base method.
+func (m *MockRepository) IncrementCouponUsage(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementCouponUsage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementCouponUsage indicates an expected call of IncrementCouponUsage.
+func (mr *MockRepositoryMockRecorder) IncrementCouponUsage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementCouponUsage", reflect.TypeOf((*MockRepository)(nil).IncrementCouponUsage), arg0, arg1)
+}
+
+// InsertOrderStatusChange mocks base method.
+func (m *MockRepository) InsertOrderStatusChange(arg0 context.Context, arg1 book.StatusChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertOrderStatusChange", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertOrderStatusChange indicates an expected call of InsertOrderStatusChange.
+func (mr *MockRepositoryMockRecorder) InsertOrderStatusChange(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOrderStatusChange", reflect.TypeOf((*MockRepository)(nil).InsertOrderStatusChange), arg0, arg1)
+}
+
+// InsertOutboxEvent mocks base method.
+func (m *MockRepository) InsertOutboxEvent(arg0 context.Context, arg1 book.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertOutboxEvent", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertOutboxEvent indicates an expected call of InsertOutboxEvent.
+func (mr *MockRepositoryMockRecorder) InsertOutboxEvent(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOutboxEvent", reflect.TypeOf((*MockRepository)(nil).InsertOutboxEvent), arg0, arg1)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockRepository) ListAPIKeys(arg0 context.Context) ([]book.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", arg0)
+	ret0, _ := ret[0].([]book.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockRepositoryMockRecorder) ListAPIKeys(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockRepository)(nil).ListAPIKeys), arg0)
+}
+
+// ListAbandonedOrders mocks base method.
+func (m *MockRepository) ListAbandonedOrders(arg0 context.Context, arg1 time.Time) ([]uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAbandonedOrders", arg0, arg1)
+	ret0, _ := ret[0].([]uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAbandonedOrders indicates an expected call of ListAbandonedOrders.
+func (mr *MockRepositoryMockRecorder) ListAbandonedOrders(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAbandonedOrders", reflect.TypeOf((*MockRepository)(nil).ListAbandonedOrders), arg0, arg1)
+}
+
+// ListBooks mocks base method.
+func (m *MockRepository) ListBooks(arg0 context.Context, arg1 string, arg2, arg3 float32, arg4, arg5 string, arg6 bool, arg7, arg8 int) ([]book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBooks", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+	ret0, _ := ret[0].([]book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBooks indicates an expected call of ListBooks.
+func (mr *MockRepositoryMockRecorder) ListBooks(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBooks", reflect.TypeOf((*MockRepository)(nil).ListBooks), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+}
+
+// ListBooksTotals mocks base method.
+func (m *MockRepository) ListBooksTotals(arg0 context.Context, arg1 string, arg2, arg3 float32, arg4 bool) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBooksTotals", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBooksTotals indicates an expected call of ListBooksTotals.
+func (mr *MockRepositoryMockRecorder) ListBooksTotals(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBooksTotals", reflect.TypeOf((*MockRepository)(nil).ListBooksTotals), arg0, arg1, arg2, arg3, arg4)
+}
+
+// ListCoupons mocks base method.
+func (m *MockRepository) ListCoupons(arg0 context.Context) ([]book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCoupons", arg0)
+	ret0, _ := ret[0].([]book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCoupons indicates an expected call of ListCoupons.
+func (mr *MockRepositoryMockRecorder) ListCoupons(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoupons", reflect.TypeOf((*MockRepository)(nil).ListCoupons), arg0)
+}
+
+// ListOrderIDsOfPurchaser mocks base method.
+func (m *MockRepository) ListOrderIDsOfPurchaser(arg0 context.Context, arg1 uuid.UUID) ([]uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrderIDsOfPurchaser", arg0, arg1)
+	ret0, _ := ret[0].([]uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrderIDsOfPurchaser indicates an expected call of ListOrderIDsOfPurchaser.
+func (mr *MockRepositoryMockRecorder) ListOrderIDsOfPurchaser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderIDsOfPurchaser", reflect.TypeOf((*MockRepository)(nil).ListOrderIDsOfPurchaser), arg0, arg1)
+}
+
+// ListOrderItems mocks base method.
+func (m *MockRepository) ListOrderItems(arg0 context.Context, arg1 uuid.UUID) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrderItems", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrderItems indicates an expected call of ListOrderItems.
+func (mr *MockRepositoryMockRecorder) ListOrderItems(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderItems", reflect.TypeOf((*MockRepository)(nil).ListOrderItems), arg0, arg1)
+}
+
+// ListOrderStatusHistory mocks base method.
+func (m *MockRepository) ListOrderStatusHistory(arg0 context.Context, arg1 uuid.UUID) ([]book.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrderStatusHistory", arg0, arg1)
+	ret0, _ := ret[0].([]book.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrderStatusHistory indicates an expected call of ListOrderStatusHistory.
+func (mr *MockRepositoryMockRecorder) ListOrderStatusHistory(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderStatusHistory", reflect.TypeOf((*MockRepository)(nil).ListOrderStatusHistory), arg0, arg1)
+}
+
+// ListPendingOutboxEvents mocks base method.
+func (m *MockRepository) ListPendingOutboxEvents(arg0 context.Context, arg1 time.Time, arg2 int) ([]book.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingOutboxEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]book.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingOutboxEvents indicates an expected call of ListPendingOutboxEvents.
+func (mr *MockRepositoryMockRecorder) ListPendingOutboxEvents(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingOutboxEvents", reflect.TypeOf((*MockRepository)(nil).ListPendingOutboxEvents), arg0, arg1, arg2)
+}
+
+// ListPendingWebhookDeliveries mocks base method.
+func (m *MockRepository) ListPendingWebhookDeliveries(arg0 context.Context, arg1 time.Time, arg2 int) ([]book.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]book.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingWebhookDeliveries indicates an expected call of ListPendingWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ListPendingWebhookDeliveries(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ListPendingWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// ListReturns mocks base method.
+func (m *MockRepository) ListReturns(arg0 context.Context, arg1 uuid.UUID) ([]book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReturns", arg0, arg1)
+	ret0, _ := ret[0].([]book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReturns indicates an expected call of ListReturns.
+func (mr *MockRepositoryMockRecorder) ListReturns(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReturns", reflect.TypeOf((*MockRepository)(nil).ListReturns), arg0, arg1)
+}
+
+// ListShipments mocks base method.
+func (m *MockRepository) ListShipments(arg0 context.Context, arg1 uuid.UUID) ([]book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListShipments", arg0, arg1)
+	ret0, _ := ret[0].([]book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListShipments indicates an expected call of ListShipments.
+func (mr *MockRepositoryMockRecorder) ListShipments(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListShipments", reflect.TypeOf((*MockRepository)(nil).ListShipments), arg0, arg1)
+}
+
+// ListUsers mocks base method.
+func (m *MockRepository) ListUsers(arg0 context.Context) ([]book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0)
+	ret0, _ := ret[0].([]book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockRepositoryMockRecorder) ListUsers(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockRepository)(nil).ListUsers), arg0)
+}
+
+// ListWebhookDeliveries mocks base method.
+func (m *MockRepository) ListWebhookDeliveries(arg0 context.Context, arg1 uuid.UUID, arg2 int) ([]book.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]book.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ListWebhookDeliveries(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ListWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockRepository) ListWebhooks(arg0 context.Context) ([]book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", arg0)
+	ret0, _ := ret[0].([]book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockRepositoryMockRecorder) ListWebhooks(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockRepository)(nil).ListWebhooks), arg0)
+}
+
+// ListWishlistItems mocks base method.
+func (m *MockRepository) ListWishlistItems(arg0 context.Context, arg1 uuid.UUID) ([]book.WishlistItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWishlistItems", arg0, arg1)
+	ret0, _ := ret[0].([]book.WishlistItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// BeginTx indicates an expected call of BeginTx.
-func (mr *MockRepositoryMockRecorder) BeginTx(arg0, arg1 any) *gomock.Call {
+// ListWishlistItems indicates an expected call of ListWishlistItems.
+func (mr *MockRepositoryMockRecorder) ListWishlistItems(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTx", reflect.TypeOf((*MockRepository)(nil).BeginTx), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWishlistItems", reflect.TypeOf((*MockRepository)(nil).ListWishlistItems), arg0, arg1)
 }
 
-// CreateBook mocks base method.
-func (m *MockRepository) CreateBook(arg0 context.Context, arg1 book.Book) (book.Book, error) {
+// PurgeExpiredIdempotencyKeys mocks base method.
+func (m *MockRepository) PurgeExpiredIdempotencyKeys(arg0 context.Context, arg1 time.Time) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateBook", arg0, arg1)
-	ret0, _ := ret[0].(book.Book)
+	ret := m.ctrl.Call(m, "PurgeExpiredIdempotencyKeys", arg0, arg1)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateBook indicates an expected call of CreateBook.
-func (mr *MockRepositoryMockRecorder) CreateBook(arg0, arg1 any) *gomock.Call {
+// PurgeExpiredIdempotencyKeys indicates an expected call of PurgeExpiredIdempotencyKeys.
+func (mr *MockRepositoryMockRecorder) PurgeExpiredIdempotencyKeys(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBook", reflect.TypeOf((*MockRepository)(nil).CreateBook), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpiredIdempotencyKeys", reflect.TypeOf((*MockRepository)(nil).PurgeExpiredIdempotencyKeys), arg0, arg1)
 }
 
-// CreateOrder mocks base method.
-func (m *MockRepository) CreateOrder(arg0 context.Context, arg1 book.Order) (book.Order, error) {
+// PurgeSentOutboxEvents mocks base method.
+func (m *MockRepository) PurgeSentOutboxEvents(arg0 context.Context, arg1 time.Time) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateOrder", arg0, arg1)
-	ret0, _ := ret[0].(book.Order)
+	ret := m.ctrl.Call(m, "PurgeSentOutboxEvents", arg0, arg1)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateOrder indicates an expected call of CreateOrder.
-func (mr *MockRepositoryMockRecorder) CreateOrder(arg0, arg1 any) *gomock.Call {
+// PurgeSentOutboxEvents indicates an expected call of PurgeSentOutboxEvents.
+func (mr *MockRepositoryMockRecorder) PurgeSentOutboxEvents(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockRepository)(nil).CreateOrder), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeSentOutboxEvents", reflect.TypeOf((*MockRepository)(nil).PurgeSentOutboxEvents), arg0, arg1)
 }
 
-// DeleteOrderItem mocks base method.
-func (m *MockRepository) DeleteOrderItem(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockRepository) ReleaseIdempotencyKey(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteOrderItem", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteOrderItem indicates an expected call of DeleteOrderItem.
-func (mr *MockRepositoryMockRecorder) DeleteOrderItem(arg0, arg1, arg2 any) *gomock.Call {
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) ReleaseIdempotencyKey(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrderItem", reflect.TypeOf((*MockRepository)(nil).DeleteOrderItem), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).ReleaseIdempotencyKey), arg0, arg1, arg2)
 }
 
-// GetBookByID mocks base method.
-func (m *MockRepository) GetBookByID(arg0 context.Context, arg1 uuid.UUID) (book.Book, error) {
+// RemoveOrderCoupon mocks base method.
+func (m *MockRepository) RemoveOrderCoupon(arg0 context.Context, arg1 uuid.UUID) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBookByID", arg0, arg1)
-	ret0, _ := ret[0].(book.Book)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "RemoveOrderCoupon", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetBookByID indicates an expected call of GetBookByID.
-func (mr *MockRepositoryMockRecorder) GetBookByID(arg0, arg1 any) *gomock.Call {
+// RemoveOrderCoupon indicates an expected call of RemoveOrderCoupon.
+func (mr *MockRepositoryMockRecorder) RemoveOrderCoupon(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookByID", reflect.TypeOf((*MockRepository)(nil).GetBookByID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveOrderCoupon", reflect.TypeOf((*MockRepository)(nil).RemoveOrderCoupon), arg0, arg1)
 }
 
-// GetOrderItem mocks base method.
-func (m *MockRepository) GetOrderItem(arg0 context.Context, arg1, arg2 uuid.UUID) (book.OrderItem, error) {
+// ReserveIdempotencyKey mocks base method.
+func (m *MockRepository) ReserveIdempotencyKey(arg0 context.Context, arg1 book.IdempotencyKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrderItem", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) ReserveIdempotencyKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).ReserveIdempotencyKey), arg0, arg1)
+}
+
+// ReserveOrderItem mocks base method.
+func (m *MockRepository) ReserveOrderItem(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 int) (book.OrderItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveOrderItem", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(book.OrderItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetOrderItem indicates an expected call of GetOrderItem.
-func (mr *MockRepositoryMockRecorder) GetOrderItem(arg0, arg1, arg2 any) *gomock.Call {
+// ReserveOrderItem indicates an expected call of ReserveOrderItem.
+func (mr *MockRepositoryMockRecorder) ReserveOrderItem(arg0, arg1, arg2, arg3 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderItem", reflect.TypeOf((*MockRepository)(nil).GetOrderItem), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveOrderItem", reflect.TypeOf((*MockRepository)(nil).ReserveOrderItem), arg0, arg1, arg2, arg3)
 }
 
-// ListBooks mocks base method.
-func (m *MockRepository) ListBooks(arg0 context.Context, arg1 string, arg2, arg3 float32, arg4, arg5 string, arg6 bool, arg7, arg8 int) ([]book.Book, error) {
+// ResolveReturn mocks base method.
+func (m *MockRepository) ResolveReturn(arg0 context.Context, arg1 book.Return) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListBooks", arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
-	ret0, _ := ret[0].([]book.Book)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "ResolveReturn", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// ListBooks indicates an expected call of ListBooks.
-func (mr *MockRepositoryMockRecorder) ListBooks(arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8 any) *gomock.Call {
+// ResolveReturn indicates an expected call of ResolveReturn.
+func (mr *MockRepositoryMockRecorder) ResolveReturn(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBooks", reflect.TypeOf((*MockRepository)(nil).ListBooks), arg0, arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveReturn", reflect.TypeOf((*MockRepository)(nil).ResolveReturn), arg0, arg1)
 }
 
-// ListBooksTotals mocks base method.
-func (m *MockRepository) ListBooksTotals(arg0 context.Context, arg1 string, arg2, arg3 float32, arg4 bool) (int, error) {
+// RestockBook mocks base method.
+func (m *MockRepository) RestockBook(arg0 context.Context, arg1 uuid.UUID, arg2 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListBooksTotals", arg0, arg1, arg2, arg3, arg4)
-	ret0, _ := ret[0].(int)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "RestockBook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// ListBooksTotals indicates an expected call of ListBooksTotals.
-func (mr *MockRepositoryMockRecorder) ListBooksTotals(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+// RestockBook indicates an expected call of RestockBook.
+func (mr *MockRepositoryMockRecorder) RestockBook(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBooksTotals", reflect.TypeOf((*MockRepository)(nil).ListBooksTotals), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestockBook", reflect.TypeOf((*MockRepository)(nil).RestockBook), arg0, arg1, arg2)
 }
 
-// ListOrderItems mocks base method.
-func (m *MockRepository) ListOrderItems(arg0 context.Context, arg1 uuid.UUID) (book.Order, error) {
+// RevokeAPIKey mocks base method.
+func (m *MockRepository) RevokeAPIKey(arg0 context.Context, arg1 uuid.UUID, arg2 time.Time) (book.APIKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListOrderItems", arg0, arg1)
-	ret0, _ := ret[0].(book.Order)
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.APIKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListOrderItems indicates an expected call of ListOrderItems.
-func (mr *MockRepositoryMockRecorder) ListOrderItems(arg0, arg1 any) *gomock.Call {
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockRepositoryMockRecorder) RevokeAPIKey(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderItems", reflect.TypeOf((*MockRepository)(nil).ListOrderItems), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepository)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// RevokeRefreshToken mocks base method.
+func (m *MockRepository) RevokeRefreshToken(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshToken indicates an expected call of RevokeRefreshToken.
+func (mr *MockRepositoryMockRecorder) RevokeRefreshToken(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshToken", reflect.TypeOf((*MockRepository)(nil).RevokeRefreshToken), arg0, arg1, arg2)
 }
 
 // SetBookArchiveStatus mocks base method.
@@ -192,6 +1117,90 @@ func (mr *MockRepositoryMockRecorder) SetBookArchiveStatus(arg0, arg1, arg2 any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBookArchiveStatus", reflect.TypeOf((*MockRepository)(nil).SetBookArchiveStatus), arg0, arg1, arg2)
 }
 
+// SetOrderCoupon mocks base method.
+func (m *MockRepository) SetOrderCoupon(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetOrderCoupon", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOrderCoupon indicates an expected call of SetOrderCoupon.
+func (mr *MockRepositoryMockRecorder) SetOrderCoupon(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderCoupon", reflect.TypeOf((*MockRepository)(nil).SetOrderCoupon), arg0, arg1, arg2)
+}
+
+// SetOrderDetails mocks base method.
+func (m *MockRepository) SetOrderDetails(arg0 context.Context, arg1 book.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetOrderDetails", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOrderDetails indicates an expected call of SetOrderDetails.
+func (mr *MockRepositoryMockRecorder) SetOrderDetails(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderDetails", reflect.TypeOf((*MockRepository)(nil).SetOrderDetails), arg0, arg1)
+}
+
+// SetOrderPurchaser mocks base method.
+func (m *MockRepository) SetOrderPurchaser(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetOrderPurchaser", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOrderPurchaser indicates an expected call of SetOrderPurchaser.
+func (mr *MockRepositoryMockRecorder) SetOrderPurchaser(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderPurchaser", reflect.TypeOf((*MockRepository)(nil).SetOrderPurchaser), arg0, arg1, arg2)
+}
+
+// SetOrderStatus mocks base method.
+func (m *MockRepository) SetOrderStatus(arg0 context.Context, arg1 uuid.UUID, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetOrderStatus", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOrderStatus indicates an expected call of SetOrderStatus.
+func (mr *MockRepositoryMockRecorder) SetOrderStatus(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderStatus", reflect.TypeOf((*MockRepository)(nil).SetOrderStatus), arg0, arg1, arg2)
+}
+
+// StoreRefreshToken mocks base method.
+func (m *MockRepository) StoreRefreshToken(arg0 context.Context, arg1 book.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreRefreshToken indicates an expected call of StoreRefreshToken.
+func (mr *MockRepositoryMockRecorder) StoreRefreshToken(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreRefreshToken", reflect.TypeOf((*MockRepository)(nil).StoreRefreshToken), arg0, arg1)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockRepository) TouchAPIKey(arg0 context.Context, arg1 uuid.UUID, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockRepositoryMockRecorder) TouchAPIKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockRepository)(nil).TouchAPIKey), arg0, arg1, arg2)
+}
+
 // UpdateBook mocks base method.
 func (m *MockRepository) UpdateBook(arg0 context.Context, arg1 book.Book) (book.Book, error) {
 	m.ctrl.T.Helper()
@@ -207,6 +1216,21 @@ func (mr *MockRepositoryMockRecorder) UpdateBook(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBook", reflect.TypeOf((*MockRepository)(nil).UpdateBook), arg0, arg1)
 }
 
+// UpdateCoupon mocks base method.
+func (m *MockRepository) UpdateCoupon(arg0 context.Context, arg1 book.Coupon) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCoupon", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCoupon indicates an expected call of UpdateCoupon.
+func (mr *MockRepositoryMockRecorder) UpdateCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoupon", reflect.TypeOf((*MockRepository)(nil).UpdateCoupon), arg0, arg1)
+}
+
 // UpdateOrderRow mocks base method.
 func (m *MockRepository) UpdateOrderRow(arg0 context.Context, arg1 uuid.UUID) error {
 	m.ctrl.T.Helper()
@@ -221,6 +1245,78 @@ func (mr *MockRepositoryMockRecorder) UpdateOrderRow(arg0, arg1 any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderRow", reflect.TypeOf((*MockRepository)(nil).UpdateOrderRow), arg0, arg1)
 }
 
+// UpdateOutboxEvent mocks base method.
+func (m *MockRepository) UpdateOutboxEvent(arg0 context.Context, arg1 book.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOutboxEvent", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOutboxEvent indicates an expected call of UpdateOutboxEvent.
+func (mr *MockRepositoryMockRecorder) UpdateOutboxEvent(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOutboxEvent", reflect.TypeOf((*MockRepository)(nil).UpdateOutboxEvent), arg0, arg1)
+}
+
+// UpdateShipment mocks base method.
+func (m *MockRepository) UpdateShipment(arg0 context.Context, arg1 book.Shipment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateShipment", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateShipment indicates an expected call of UpdateShipment.
+func (mr *MockRepositoryMockRecorder) UpdateShipment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateShipment", reflect.TypeOf((*MockRepository)(nil).UpdateShipment), arg0, arg1)
+}
+
+// UpdateUser mocks base method.
+func (m *MockRepository) UpdateUser(arg0 context.Context, arg1 book.User) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockRepositoryMockRecorder) UpdateUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepository)(nil).UpdateUser), arg0, arg1)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockRepository) UpdateWebhook(arg0 context.Context, arg1 book.Webhook) (book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", arg0, arg1)
+	ret0, _ := ret[0].(book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockRepositoryMockRecorder) UpdateWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockRepository)(nil).UpdateWebhook), arg0, arg1)
+}
+
+// UpdateWebhookDelivery mocks base method.
+func (m *MockRepository) UpdateWebhookDelivery(arg0 context.Context, arg1 book.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookDelivery indicates an expected call of UpdateWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) UpdateWebhookDelivery(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).UpdateWebhookDelivery), arg0, arg1)
+}
+
 // UpsertOrderItem mocks base method.
 func (m *MockRepository) UpsertOrderItem(arg0 context.Context, arg1 uuid.UUID, arg2 book.OrderItem) (book.OrderItem, error) {
 	m.ctrl.T.Helper()
@@ -259,16 +1355,130 @@ func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
 	return m.recorder
 }
 
-// BookCreated mocks base method.
-func (m *MockNotifier) BookCreated(arg0 context.Context, arg1 book.Book) error {
+// Notify mocks base method.
+func (m *MockNotifier) Notify(arg0 context.Context, arg1 book.Event) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BookCreated", arg0, arg1)
+	ret := m.ctrl.Call(m, "Notify", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// BookCreated indicates an expected call of BookCreated.
-func (mr *MockNotifierMockRecorder) BookCreated(arg0, arg1 any) *gomock.Call {
+// Notify indicates an expected call of Notify.
+func (mr *MockNotifierMockRecorder) Notify(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockNotifier)(nil).Notify), arg0, arg1)
+}
+
+// MockPriceCalculator is a mock of PriceCalculator interface.
+type MockPriceCalculator struct {
+	ctrl     *gomock.Controller
+	recorder *MockPriceCalculatorMockRecorder
+}
+
+// MockPriceCalculatorMockRecorder is the mock recorder for MockPriceCalculator.
+type MockPriceCalculatorMockRecorder struct {
+	mock *MockPriceCalculator
+}
+
+// NewMockPriceCalculator creates a new mock instance.
+func NewMockPriceCalculator(ctrl *gomock.Controller) *MockPriceCalculator {
+	mock := &MockPriceCalculator{ctrl: ctrl}
+	mock.recorder = &MockPriceCalculatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPriceCalculator) EXPECT() *MockPriceCalculatorMockRecorder {
+	return m.recorder
+}
+
+// Calculate mocks base method.
+func (m *MockPriceCalculator) Calculate(arg0 book.Order, arg1 string) (book.OrderCharges, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Calculate", arg0, arg1)
+	ret0, _ := ret[0].(book.OrderCharges)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Calculate indicates an expected call of Calculate.
+func (mr *MockPriceCalculatorMockRecorder) Calculate(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Calculate", reflect.TypeOf((*MockPriceCalculator)(nil).Calculate), arg0, arg1)
+}
+
+// MockPaymentGateway is a mock of PaymentGateway interface.
+type MockPaymentGateway struct {
+	ctrl     *gomock.Controller
+	recorder *MockPaymentGatewayMockRecorder
+}
+
+// MockPaymentGatewayMockRecorder is the mock recorder for MockPaymentGateway.
+type MockPaymentGatewayMockRecorder struct {
+	mock *MockPaymentGateway
+}
+
+// NewMockPaymentGateway creates a new mock instance.
+func NewMockPaymentGateway(ctrl *gomock.Controller) *MockPaymentGateway {
+	mock := &MockPaymentGateway{ctrl: ctrl}
+	mock.recorder = &MockPaymentGatewayMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPaymentGateway) EXPECT() *MockPaymentGatewayMockRecorder {
+	return m.recorder
+}
+
+// Refund mocks base method.
+func (m *MockPaymentGateway) Refund(arg0 context.Context, arg1 book.Refund) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refund", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refund indicates an expected call of Refund.
+func (mr *MockPaymentGatewayMockRecorder) Refund(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refund", reflect.TypeOf((*MockPaymentGateway)(nil).Refund), arg0, arg1)
+}
+
+// MockWebhookSender is a mock of WebhookSender interface.
+type MockWebhookSender struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookSenderMockRecorder
+}
+
+// MockWebhookSenderMockRecorder is the mock recorder for MockWebhookSender.
+type MockWebhookSenderMockRecorder struct {
+	mock *MockWebhookSender
+}
+
+// NewMockWebhookSender creates a new mock instance.
+func NewMockWebhookSender(ctrl *gomock.Controller) *MockWebhookSender {
+	mock := &MockWebhookSender{ctrl: ctrl}
+	mock.recorder = &MockWebhookSenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookSender) EXPECT() *MockWebhookSenderMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockWebhookSender) Send(arg0 context.Context, arg1 book.Webhook, arg2 book.WebhookDelivery) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockWebhookSenderMockRecorder) Send(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BookCreated", reflect.TypeOf((*MockNotifier)(nil).BookCreated), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockWebhookSender)(nil).Send), arg0, arg1, arg2)
 }
@@ -1,11 +1,13 @@
 package book
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,28 +15,87 @@ import (
 
 type Order struct {
 	OrderID     uuid.UUID
-	PurchaserID uuid.UUID
+	PurchaserID uuid.UUID //uuid.Nil for guest orders not claimed yet
 	OrderStatus string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	TotalPrice  float32
+	CouponID    *uuid.UUID
+	CouponCode  string
+	Subtotal    float32 //sum of the prices of the items
+	Discount    float32 //given by the coupon, if any
+	TaxRegion   string
+	Tax         float32 //calculated at checkout
+	Shipping    float32 //calculated at checkout
+	TotalPrice  float32 //subtotal minus discount, plus tax and shipping
 	Items       []OrderItem
+
+	ShippingAddress *ShippingAddress
+	ContactEmail    string
+	ContactPhone    string
+	Notes           string
 }
 
 func (s *Service) CreateOrder(ctx context.Context, user_id uuid.UUID) (Order, error) {
+	if user_id == uuid.Nil { //Orders with no purchaser are only made by CreateGuestOrder.
+		return Order{}, ErrResponseUserNotFound
+	}
+	return s.createOrder(ctx, user_id)
+}
+
+/* Stores a new order accepting items through a transaction. Guest orders have no purchaserID. */
+func (s *Service) createOrder(ctx context.Context, purchaserID uuid.UUID) (Order, error) {
 
 	createdAt := time.Now().UTC().Round(time.Millisecond)
 
 	newOrder := Order{
 		OrderID:     uuid.New(),
-		PurchaserID: user_id,
+		PurchaserID: purchaserID,
 		OrderStatus: "accepting_items",
 		CreatedAt:   createdAt,
 		UpdatedAt:   createdAt,
 		TotalPrice:  0,
 		Items:       []OrderItem{},
 	}
-	return s.repo.CreateOrder(ctx, newOrder)
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	if purchaserID != uuid.Nil {
+		_, err = txRepo.GetUserByID(ctx, purchaserID) //Only known users can purchase.
+		if err != nil {
+			return Order{}, fmt.Errorf("error on call to GetUserByID: %w ", err)
+		}
+	}
+
+	o, err := txRepo.CreateOrder(ctx, newOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = recordStatusChange(ctx, txRepo, o.OrderID, "", o.OrderStatus)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderCreated, o)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return o, nil
 }
 
 type OrderItem struct {
@@ -46,6 +107,7 @@ type OrderItem struct {
 	UpdatedAt        time.Time
 }
 
+/* Returns the order with its items. Users only see their own orders, and guests the order of their cart; admins see any. */
 func (s *Service) ListOrderItems(ctx context.Context, order_id uuid.UUID) (Order, error) {
 
 	order, err := s.repo.ListOrderItems(ctx, order_id)
@@ -53,18 +115,149 @@ func (s *Service) ListOrderItems(ctx context.Context, order_id uuid.UUID) (Order
 		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w", err)
 	}
 
+	err = authorizeOrder(ctx, PermissionOrdersRead, order)
+	if err != nil {
+		return Order{}, err
+	}
+
 	return order, nil
 }
 
+/* Checks if the caller at ctx can act on the order: its purchaser, an admin, or a guest with the cart token of it while nobody claimed it. Others get a not found error, so the existence of the order isn't revealed. Calls with no caller, made by the service itself, are not checked. */
+func authorizeOrder(ctx context.Context, permission Permission, order Order) error {
+	claims, identified := ClaimsFromContext(ctx)
+	if !identified {
+		return nil
+	}
+	if claims.CartOrderID != uuid.Nil {
+		if claims.CartOrderID != order.OrderID || order.PurchaserID != uuid.Nil || AuthorizeOwned(claims, PermissionCart) != nil {
+			return ErrResponseOrderNotFound
+		}
+		return nil
+	}
+	if AuthorizeOwner(claims, permission, order.PurchaserID) != nil {
+		return ErrResponseOrderNotFound
+	}
+	return nil
+}
+
+/* Searches the order through repo and checks if the caller at ctx can change it, as authorizeOrder does. */
+func authorizeOrderChange(ctx context.Context, repo Repository, orderID uuid.UUID) error {
+	return authorizeStoredOrder(ctx, repo, PermissionOrdersWrite, orderID)
+}
+
+/* Searches the order through repo and checks if the caller at ctx can see it, with its history, returns and shipments, as authorizeOrder does. */
+func authorizeOrderRead(ctx context.Context, repo Repository, orderID uuid.UUID) error {
+	return authorizeStoredOrder(ctx, repo, PermissionOrdersRead, orderID)
+}
+
+func authorizeStoredOrder(ctx context.Context, repo Repository, permission Permission, orderID uuid.UUID) error {
+	if _, identified := ClaimsFromContext(ctx); !identified {
+		return nil
+	}
+	order, err := repo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	return authorizeOrder(ctx, permission, order)
+}
+
 type UpdateOrderRequest struct {
 	OrderID        uuid.UUID
 	BookID         uuid.UUID
 	BookUnitsToAdd int
 }
 
-/* Updates an order stored in database through a transaction, adding or removing items(books) from it. */
+/* Updates an order stored in database through a transaction, adding or removing items(books) from it. Users only change their own orders, and guests the order of their cart; admins change any. */
 func (s *Service) UpdateOrderTx(ctx context.Context, updtReq UpdateOrderRequest) (Order, error) {
-	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	var updatedOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		updatedOrder, err = s.updateOrderTx(ctx, updtReq)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return updatedOrder, nil
+}
+
+/* Changes the units of the book at the order, then lists the updated order. */
+func (s *Service) updateOrderTx(ctx context.Context, updtReq UpdateOrderRequest) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = authorizeOrderChange(ctx, txRepo, updtReq.OrderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = txRepo.UpdateOrderRow(ctx, updtReq.OrderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
+	}
+
+	err = updateOrderItem(ctx, txRepo, updtReq.OrderID, updtReq.BookID, updtReq.BookUnitsToAdd)
+	if err != nil {
+		return Order{}, err
+	}
+
+	updatedOrder, err := txRepo.ListOrderItems(ctx, updtReq.OrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, updatedOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return updatedOrder, nil
+}
+
+type OrderItemChange struct {
+	BookID         uuid.UUID
+	BookUnitsToAdd int
+}
+
+type UpdateOrderItemsRequest struct {
+	OrderID uuid.UUID
+	Items   []OrderItemChange
+}
+
+/* Updates many items of an order in a single transaction. If any of them is rejected, none of the changes are applied and the errors of each rejected item are returned. */
+func (s *Service) UpdateOrderItemsTx(ctx context.Context, updtReq UpdateOrderItemsRequest) (Order, error) {
+	var updatedOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		updatedOrder, err = s.updateOrderItemsTx(ctx, updtReq)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return updatedOrder, nil
+}
+
+/* Applies the changes sorted by book, collecting the errors of the rejected ones. */
+func (s *Service) updateOrderItemsTx(ctx context.Context, updtReq UpdateOrderItemsRequest) (Order, error) {
+	changes := mergeOrderItemChanges(updtReq.Items)
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
 	if err != nil {
 		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
 	}
@@ -76,44 +269,124 @@ func (s *Service) UpdateOrderTx(ctx context.Context, updtReq UpdateOrderRequest)
 		}
 	}()
 
+	err = authorizeOrderChange(ctx, txRepo, updtReq.OrderID)
+	if err != nil {
+		return Order{}, err
+	}
+
 	err = txRepo.UpdateOrderRow(ctx, updtReq.OrderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
 	if err != nil {
 		return Order{}, fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
 	}
 
-	//Testing if there are sufficient inventory of the book asked, and if is not archived:
-	bk, err := txRepo.GetBookByID(ctx, updtReq.BookID)
+	rejected := ErrOrderItemsRejected{}
+	for _, change := range changes { //Books are always changed in the same order, so concurrent batches lock their rows in the same sequence and can't deadlock.
+		err = updateOrderItem(ctx, txRepo, updtReq.OrderID, change.BookID, change.BookUnitsToAdd)
+		if err != nil {
+			var errR ErrResponse
+			if !errors.As(err, &errR) {
+				return Order{}, err
+			}
+			rejected.Items = append(rejected.Items, OrderItemError{BookID: change.BookID, Err: errR})
+		}
+	}
+	if len(rejected.Items) > 0 {
+		return Order{}, rejected
+	}
+
+	updatedOrder, err := txRepo.ListOrderItems(ctx, updtReq.OrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, updatedOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return updatedOrder, nil
+}
+
+/* Sums the units of repeated books and sorts the changes by book ID, dropping the ones that results in no change at all. */
+func mergeOrderItemChanges(items []OrderItemChange) []OrderItemChange {
+	unitsByBook := map[uuid.UUID]int{}
+	for _, item := range items {
+		unitsByBook[item.BookID] += item.BookUnitsToAdd
+	}
+
+	changes := []OrderItemChange{}
+	for bookID, units := range unitsByBook {
+		if units == 0 {
+			continue
+		}
+		changes = append(changes, OrderItemChange{BookID: bookID, BookUnitsToAdd: units})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return bytes.Compare(changes[i].BookID[:], changes[j].BookID[:]) < 0
+	})
+	return changes
+}
+
+/* Adds or removes units of a single book from an order, inside the transaction of txRepo. */
+func updateOrderItem(ctx context.Context, txRepo Repository, orderID, bookID uuid.UUID, unitsToAdd int) error {
+	if unitsToAdd > 0 { //Adding is the hot path: the inventory is taken and the item upserted in a single statement, that only succeeds if there is enough inventory.
+		//The purchase limits of the book are checked first, so resellers can't take a whole print run:
+		usage, err := getPurchaseLimitUsage(ctx, txRepo, orderID, bookID)
+		if err != nil {
+			return err
+		}
+		err = usage.check(unitsToAdd)
+		if err != nil {
+			return err
+		}
+
+		_, err = txRepo.ReserveOrderItem(ctx, orderID, bookID, unitsToAdd)
+		if err != nil {
+			var errR ErrResponse
+			if errors.As(err, &errR) {
+				return errR
+			}
+			return fmt.Errorf("error on call to ReserveOrderItem: %w ", err)
+		}
+		return nil
+	}
+
+	//Removing units. Testing if the book asked is not archived. The book row stays locked until the transaction ends, so concurrent changes to its inventory wait for this one:
+	bk, err := txRepo.GetBookByIDForUpdate(ctx, bookID)
 	if err != nil {
 		if errors.Is(err, ErrResponseBookNotFound) {
-			return Order{}, ErrResponseBookNotFound
+			return ErrResponseBookNotFound
 		}
-		return Order{}, fmt.Errorf("error on call to GetBookByID: %w ", err)
+		return fmt.Errorf("error on call to GetBookByIDForUpdate: %w ", err)
 	}
 	if bk.Archived {
-		return Order{}, ErrResponseBookIsArchived
-	}
-	if *bk.Inventory-updtReq.BookUnitsToAdd < 0 {
-		return Order{}, ErrResponseInsufficientInventory
+		return ErrResponseBookIsArchived
 	}
 
 	//Testing if the book is already at the order and, if it is, getting it:
-	bookAtOrder, err := txRepo.GetOrderItem(ctx, updtReq.OrderID, updtReq.BookID)
+	bookAtOrder, err := txRepo.GetOrderItem(ctx, orderID, bookID)
 	if err != nil {
-		if errors.Is(err, ErrResponseBookNotAtOrder) && updtReq.BookUnitsToAdd <= 0 { //But, if the book is not at order, a request attempting to decrease its units value can mean an error from client, so an error is returned.
-			return Order{}, ErrResponseBookNotAtOrder
+		if errors.Is(err, ErrResponseBookNotAtOrder) && unitsToAdd <= 0 { //But, if the book is not at order, a request attempting to decrease its units value can mean an error from client, so an error is returned.
+			return ErrResponseBookNotAtOrder
 		}
 		if !errors.Is(err, ErrResponseBookNotAtOrder) {
-			return Order{}, fmt.Errorf("error on call to GetOrderItem: %w ", err)
+			return fmt.Errorf("error on call to GetOrderItem: %w ", err)
 		}
 	}
 
 	//Calculating changes to order item:
-	updtBookUnits := bookAtOrder.BookUnits + updtReq.BookUnitsToAdd
+	updtBookUnits := bookAtOrder.BookUnits + unitsToAdd
 
 	if updtBookUnits > 0 { //This way means that the book is being added to the order or, after any changes, some units of it remain there.
 
 		//Adding or updating the book at the order:
-		bookAtOrder.BookID = updtReq.BookID
+		bookAtOrder.BookID = bookID
 		bookAtOrder.BookName = bk.Name
 		bookAtOrder.BookUnits = updtBookUnits
 		if bookAtOrder.BookPriceAtOrder == nil { //If the book is already at the order, this price must be maintenned.
@@ -121,41 +394,59 @@ func (s *Service) UpdateOrderTx(ctx context.Context, updtReq UpdateOrderRequest)
 		}
 		//Created_at and Updated_at fields will be set properly at database layer
 
-		bookAtOrder, err = txRepo.UpsertOrderItem(ctx, updtReq.OrderID, bookAtOrder)
+		bookAtOrder, err = txRepo.UpsertOrderItem(ctx, orderID, bookAtOrder)
 		if err != nil {
-			return Order{}, fmt.Errorf("error on call to UpsertOrderItem: %w ", err)
+			return fmt.Errorf("error on call to UpsertOrderItem: %w ", err)
 		}
 
 		//Updating book inventory acordingly at bookstable:
-		*bk.Inventory = *bk.Inventory - updtReq.BookUnitsToAdd
+		return returnToInventory(ctx, txRepo, bk, -unitsToAdd)
 
 	} else { //Case the book is already at the order, and book_units becomes zero from update, the book is excluded from the order. Even so, it must be updated at bookstable.
 
-		err = txRepo.DeleteOrderItem(ctx, updtReq.OrderID, updtReq.BookID)
+		err = txRepo.DeleteOrderItem(ctx, orderID, bookID)
 
 		if err != nil {
-			return Order{}, fmt.Errorf("error on call to DeleteOrderItem: %w ", err)
+			return fmt.Errorf("error on call to DeleteOrderItem: %w ", err)
 		}
 
 		//Updating book inventory acordingly at bookstable:
-		*bk.Inventory += bookAtOrder.BookUnits
+		return returnToInventory(ctx, txRepo, bk, bookAtOrder.BookUnits)
 	}
+}
 
-	bk.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
-	_, err = txRepo.UpdateBook(ctx, bk)
+/* Reads the purchase limits of a book and the units already held, locking the book first when it limits purchasers. */
+func getPurchaseLimitUsage(ctx context.Context, txRepo Repository, orderID, bookID uuid.UUID) (PurchaseLimitUsage, error) {
+	usage, err := txRepo.GetPurchaseLimitUsage(ctx, orderID, bookID)
 	if err != nil {
-		return Order{}, fmt.Errorf("error on call to UpdateBook: %w ", err)
+		if errors.Is(err, ErrResponseBookNotFound) {
+			return PurchaseLimitUsage{}, ErrResponseBookNotFound
+		}
+		return PurchaseLimitUsage{}, fmt.Errorf("error on call to GetPurchaseLimitUsage: %w ", err)
+	}
+	if usage.MaxUnitsPerPurchaser == nil {
+		return usage, nil
 	}
 
-	err = tx.Commit()
+	_, err = txRepo.GetBookByIDForUpdate(ctx, bookID)
 	if err != nil {
-		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+		return PurchaseLimitUsage{}, fmt.Errorf("error on call to GetBookByIDForUpdate: %w ", err)
+	}
+	usage, err = txRepo.GetPurchaseLimitUsage(ctx, orderID, bookID)
+	if err != nil {
+		return PurchaseLimitUsage{}, fmt.Errorf("error on call to GetPurchaseLimitUsage: %w ", err)
 	}
+	return usage, nil
+}
 
-	updatedOrder, err := s.repo.ListOrderItems(ctx, updtReq.OrderID)
+/* Gives units of a book back to its inventory. The book must have been read by GetBookByIDForUpdate, inside the transaction of txRepo. */
+func returnToInventory(ctx context.Context, txRepo Repository, bk Book, units int) error {
+	*bk.Inventory += units
+	bk.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
+	_, err := txRepo.UpdateBook(ctx, bk)
 	if err != nil {
-		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+		return fmt.Errorf("error on call to UpdateBook: %w ", err)
 	}
 
-	return updatedOrder, nil
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -22,11 +23,19 @@ func TestCreateOrder(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
 
 		someUser := uuid.New()
 
-		mockRepo.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, o book.Order) (book.Order, error) {
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), someUser).Return(book.User{UserID: someUser, Name: "Some user", Role: book.UserRoleUser}, nil)
+		mockTxRepo.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, o book.Order) (book.Order, error) {
 			is.True(o.OrderID != uuid.Nil)
 			is.Equal(o.PurchaserID, someUser)
 			is.True(o.OrderStatus == "accepting_items")
@@ -34,6 +43,18 @@ func TestCreateOrder(t *testing.T) {
 			is.True(o.UpdatedAt.Compare(time.Now().Round(time.Millisecond)) <= 0)
 			return o, nil
 		})
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, change book.StatusChange) error {
+			is.Equal(change.FromStatus, "") //The status the order was created with.
+			is.Equal(change.ToStatus, "accepting_items")
+			is.Equal(change.ChangedBy, book.ActorAnonymous)
+			return nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventOrderCreated)
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
 
 		newOrder, err := mS.CreateOrder(ctx, someUser)
 		is.NoErr(err)
@@ -43,6 +64,30 @@ func TestCreateOrder(t *testing.T) {
 		is.True(newOrder.CreatedAt.Compare(time.Now().Round(time.Millisecond)) <= 0)
 		is.True(newOrder.UpdatedAt.Compare(time.Now().Round(time.Millisecond)) <= 0)
 	})
+
+	t.Run("expected user not found error for an unknown purchaser", func(t *testing.T) {
+
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		unknownUser := uuid.New()
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetUserByID(gomock.Any(), unknownUser).Return(book.User{}, book.ErrResponseUserNotFound)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.CreateOrder(ctx, unknownUser)
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
 }
 
 func TestListOrderItems(t *testing.T) {
@@ -51,7 +96,10 @@ func TestListOrderItems(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
 
 		newOrderID := uuid.New()
 
@@ -68,7 +116,10 @@ func TestListOrderItems(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
 
 		newOrderID := uuid.New()
 		dbErr := errors.New("fake error from database")
@@ -86,7 +137,10 @@ func TestListOrderItems(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		mockRepo := bookmock.NewMockRepository(ctrl)
 		mockNtfy := bookmock.NewMockNotifier(ctrl)
-		mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
 
 		newOrderID := uuid.New()
 
@@ -99,11 +153,208 @@ func TestListOrderItems(t *testing.T) {
 	})
 }
 
+func TestOrderOwnership(t *testing.T) {
+	purchaser := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+	stranger := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+	admin := book.Claims{UserID: uuid.New(), Role: book.UserRoleAdmin}
+	order := book.Order{OrderID: uuid.New(), PurchaserID: purchaser.UserID, OrderStatus: "accepting_items"}
+
+	t.Run("lists the items of an order to its purchaser and to admins", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil).Times(2)
+
+		_, err := mS.ListOrderItems(book.ContextWithClaims(ctx, purchaser), order.OrderID)
+		is.NoErr(err)
+		_, err = mS.ListOrderItems(book.ContextWithClaims(ctx, admin), order.OrderID)
+		is.NoErr(err)
+	})
+
+	t.Run("expected order not found error listing the items of an order of another user", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+
+		listed, err := mS.ListOrderItems(book.ContextWithClaims(ctx, stranger), order.OrderID)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+		is.Equal(listed, book.Order{})
+	})
+
+	t.Run("expected order not found error updating an order of another user, with nothing changed", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.UpdateOrderTx(book.ContextWithClaims(ctx, stranger), book.UpdateOrderRequest{OrderID: order.OrderID, BookID: uuid.New(), BookUnitsToAdd: 1})
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+
+	t.Run("expected order not found error updating many items of an order of another user", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		req := book.UpdateOrderItemsRequest{OrderID: order.OrderID, Items: []book.OrderItemChange{{BookID: uuid.New(), BookUnitsToAdd: 1}}}
+		_, err := mS.UpdateOrderItemsTx(book.ContextWithClaims(ctx, stranger), req)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+
+	actionsOnOrder := []struct {
+		name string
+		inTx bool //the order is searched inside the transaction that would change it
+		call func(mS *book.Service, ctx context.Context) error
+	}{
+		{"checking out", true, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.Checkout(ctx, order.OrderID)
+			return err
+		}},
+		{"updating the details of", true, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.UpdateOrderDetails(ctx, book.UpdateOrderDetailsRequest{OrderID: order.OrderID, Notes: "leave it at the door"})
+			return err
+		}},
+		{"applying a coupon to", true, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.ApplyCoupon(ctx, order.OrderID, "TENOFF")
+			return err
+		}},
+		{"asking a return of", true, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.RequestReturn(ctx, book.CreateReturnRequest{OrderID: order.OrderID, Reason: "damaged", Items: []book.ReturnItem{{BookID: uuid.New(), BookUnits: 1}}})
+			return err
+		}},
+		{"listing the returns of", false, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.ListReturns(ctx, order.OrderID)
+			return err
+		}},
+		{"listing the history of", false, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.ListOrderHistory(ctx, order.OrderID)
+			return err
+		}},
+		{"listing the shipments of", false, func(mS *book.Service, ctx context.Context) error {
+			_, err := mS.ListShipments(ctx, order.OrderID)
+			return err
+		}},
+	}
+	for _, tc := range actionsOnOrder {
+		t.Run("expected order not found error "+tc.name+" an order of another user, with nothing else read or changed", func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			mockRepo := bookmock.NewMockRepository(ctrl)
+			mockNtfy := bookmock.NewMockNotifier(ctrl)
+			mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+			mockPay := bookmock.NewMockPaymentGateway(ctrl)
+			mockHooks := bookmock.NewMockWebhookSender(ctrl)
+			mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+			if tc.inTx {
+				mockTxRepo := bookmock.NewMockRepository(ctrl)
+				mockTx := bookmock.NewMockTx(ctrl)
+				mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+				mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+				mockTx.EXPECT().Rollback().Return(nil)
+			} else {
+				mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+			}
+
+			err := tc.call(mS, book.ContextWithClaims(ctx, stranger))
+			is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+		})
+	}
+
+	t.Run("lists the history, returns and shipments of an order to its purchaser", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		purchaserCtx := book.ContextWithClaims(ctx, purchaser)
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil).Times(3)
+		mockRepo.EXPECT().ListOrderStatusHistory(gomock.Any(), order.OrderID).Return([]book.StatusChange{{OrderID: order.OrderID, ToStatus: "accepting_items"}}, nil)
+		mockRepo.EXPECT().ListReturns(gomock.Any(), order.OrderID).Return([]book.Return{}, nil)
+		mockRepo.EXPECT().ListShipments(gomock.Any(), order.OrderID).Return([]book.Shipment{}, nil)
+
+		_, err := mS.ListOrderHistory(purchaserCtx, order.OrderID)
+		is.NoErr(err)
+		_, err = mS.ListReturns(purchaserCtx, order.OrderID)
+		is.NoErr(err)
+		_, err = mS.ListShipments(purchaserCtx, order.OrderID)
+		is.NoErr(err)
+	})
+
+	t.Run("guests only see the order of their cart, while nobody claimed it", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		guestOrder := book.Order{OrderID: uuid.New(), OrderStatus: "accepting_items"}
+		claimedOrder := book.Order{OrderID: uuid.New(), PurchaserID: purchaser.UserID, OrderStatus: "accepting_items"}
+		guestOf := func(orderID uuid.UUID) context.Context {
+			return book.ContextWithClaims(ctx, book.Claims{Role: book.RoleGuest, CartOrderID: orderID})
+		}
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), guestOrder.OrderID).Return(guestOrder, nil).Times(2)
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), claimedOrder.OrderID).Return(claimedOrder, nil)
+
+		_, err := mS.ListOrderItems(guestOf(guestOrder.OrderID), guestOrder.OrderID)
+		is.NoErr(err)
+		_, err = mS.ListOrderItems(guestOf(uuid.New()), guestOrder.OrderID)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+		_, err = mS.ListOrderItems(guestOf(claimedOrder.OrderID), claimedOrder.OrderID)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+}
+
 func TestUpdateOrderTX(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockRepo := bookmock.NewMockRepository(ctrl)
 	mockNtfy := bookmock.NewMockNotifier(ctrl)
-	mS := book.NewService(mockRepo, mockNtfy, notificationsTimeout)
+	mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+	mockPay := bookmock.NewMockPaymentGateway(ctrl)
+	mockHooks := bookmock.NewMockWebhookSender(ctrl)
+	mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
 	mockTxRepo := bookmock.NewMockRepository(ctrl)
 	mockTx := bookmock.NewMockTx(ctrl)
 
@@ -135,20 +386,16 @@ func TestUpdateOrderTX(t *testing.T) {
 			BookUnitsToAdd: 5,
 		}
 		createdNow := time.Now().UTC().Round(time.Millisecond)
-		newOrderItem := book.OrderItem{
-			BookID:           bkToAdd.ID,
-			BookUnits:        updtReq.BookUnitsToAdd,
-			BookPriceAtOrder: bkToAdd.Price,
-		}
+		var newOrderItem book.OrderItem
 
 		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
 		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).DoAndReturn(func(context.Context, uuid.UUID) error {
 			orderToUpdt.UpdatedAt = time.Now().UTC().Round(time.Millisecond).Add(time.Millisecond)
 			return nil
 		})
-		mockTxRepo.EXPECT().GetBookByID(gomock.Any(), updtReq.BookID).Return(bkToAdd, nil)
-		mockTxRepo.EXPECT().GetOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(book.OrderItem{}, book.ErrResponseBookNotAtOrder)
-		mockTxRepo.EXPECT().UpsertOrderItem(gomock.Any(), updtReq.OrderID, newOrderItem).DoAndReturn(func(context.Context, uuid.UUID, book.OrderItem) (book.OrderItem, error) {
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(book.PurchaseLimitUsage{}, nil)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID, updtReq.BookUnitsToAdd).DoAndReturn(func(context.Context, uuid.UUID, uuid.UUID, int) (book.OrderItem, error) {
+			*bkToAdd.Inventory -= updtReq.BookUnitsToAdd
 			newOrderItem = book.OrderItem{
 				BookID:           bkToAdd.ID,
 				BookUnits:        updtReq.BookUnitsToAdd,
@@ -158,19 +405,12 @@ func TestUpdateOrderTX(t *testing.T) {
 			}
 			return newOrderItem, nil
 		})
-
-		mockTxRepo.EXPECT().UpdateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, bookEntry book.Book) (book.Book, error) {
-			is.Equal(bookEntry.ID, bkToAdd.ID)
-			is.Equal(bookEntry.Price, bkToAdd.Price)
-			is.Equal(bookEntry.Inventory, bkToAdd.Inventory)
-			bkToAdd.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
-			return bkToAdd, nil
-		})
-		mockRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).DoAndReturn(func(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).DoAndReturn(func(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
 			orderToUpdt.Items = append(orderToUpdt.Items, newOrderItem)
 			orderToUpdt.TotalPrice = float32(updtReq.BookUnitsToAdd) * *bkToAdd.Price
 			return orderToUpdt, nil
 		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
 		mockTx.EXPECT().Commit().Return(nil)
 
 		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone) //THIS ERROR IS NEVER TESTED, ISN'T IT??
@@ -199,37 +439,19 @@ func TestUpdateOrderTX(t *testing.T) {
 			orderToUpdt.UpdatedAt = time.Now().UTC().Round(time.Millisecond).Add(time.Millisecond)
 			return nil
 		})
-		mockTxRepo.EXPECT().GetBookByID(gomock.Any(), updtReq.BookID).Return(bkToAdd, nil)
-		mockTxRepo.EXPECT().GetOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(orderToUpdt.Items[0], nil)
-
-		orderItemToUpdate := book.OrderItem{
-			BookID:           orderToUpdt.Items[0].BookID,
-			BookUnits:        orderToUpdt.Items[0].BookUnits + updtReq.BookUnitsToAdd, //Must result 10
-			BookPriceAtOrder: orderToUpdt.Items[0].BookPriceAtOrder,
-		}
-
-		mockTxRepo.EXPECT().UpsertOrderItem(gomock.Any(), updtReq.OrderID, gomock.Any()).DoAndReturn(func(ctx context.Context, id uuid.UUID, oItem book.OrderItem) (book.OrderItem, error) {
-			is.Equal(orderItemToUpdate.BookID, orderToUpdt.Items[0].BookID)
-			is.Equal(orderItemToUpdate.BookUnits, orderToUpdt.Items[0].BookUnits+updtReq.BookUnitsToAdd)
-			is.Equal(orderItemToUpdate.BookPriceAtOrder, orderToUpdt.Items[0].BookPriceAtOrder)
-
-			orderToUpdt.Items[0].BookUnits = orderItemToUpdate.BookUnits
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(book.PurchaseLimitUsage{}, nil)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID, updtReq.BookUnitsToAdd).DoAndReturn(func(context.Context, uuid.UUID, uuid.UUID, int) (book.OrderItem, error) {
+			*bkToAdd.Inventory -= updtReq.BookUnitsToAdd
+			orderToUpdt.Items[0].BookUnits += updtReq.BookUnitsToAdd //The price at order is kept.
 			orderToUpdt.Items[0].UpdatedAt = time.Now().UTC().Round(time.Millisecond).Add(time.Millisecond)
 			return orderToUpdt.Items[0], nil
 		})
-
-		mockTxRepo.EXPECT().UpdateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, bookEntry book.Book) (book.Book, error) {
-			is.Equal(bookEntry.ID, bkToAdd.ID)
-			is.Equal(bookEntry.Price, bkToAdd.Price)
-			is.Equal(bookEntry.Inventory, bkToAdd.Inventory)
-			bkToAdd.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
-			return bkToAdd, nil
-		})
-		mockRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).DoAndReturn(func(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).DoAndReturn(func(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
 			orderToUpdt.TotalPrice = float32(orderToUpdt.Items[0].BookUnits) * *orderToUpdt.Items[0].BookPriceAtOrder
 			return orderToUpdt, nil
 		})
 
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
 		mockTx.EXPECT().Commit().Return(nil)
 
 		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
@@ -259,7 +481,7 @@ func TestUpdateOrderTX(t *testing.T) {
 			orderToUpdt.UpdatedAt = time.Now().UTC().Round(time.Millisecond).Add(time.Millisecond)
 			return nil
 		})
-		mockTxRepo.EXPECT().GetBookByID(gomock.Any(), updtReq.BookID).Return(bkToAdd, nil)
+		mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), updtReq.BookID).Return(bkToAdd, nil)
 		mockTxRepo.EXPECT().GetOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(orderToUpdt.Items[0], nil)
 		mockTxRepo.EXPECT().DeleteOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(nil)
 
@@ -270,12 +492,13 @@ func TestUpdateOrderTX(t *testing.T) {
 			bkToAdd.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
 			return bkToAdd, nil
 		})
-		mockRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).DoAndReturn(func(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), updtReq.OrderID).DoAndReturn(func(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
 			orderToUpdt.Items = []book.OrderItem{}
 			orderToUpdt.TotalPrice = float32(0)
 			return orderToUpdt, nil
 		})
 
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
 		mockTx.EXPECT().Commit().Return(nil)
 
 		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
@@ -289,3 +512,98 @@ func TestUpdateOrderTX(t *testing.T) {
 		is.Equal(*bkToAdd.Inventory, 10)
 	})
 }
+
+func TestUpdateOrderItemsTx(t *testing.T) {
+	orderID := uuid.New()
+	bkA := book.Book{
+		ID:        uuid.MustParse("00000000-0000-0000-0000-00000000000a"),
+		Name:      "Book A",
+		Price:     toPointer(float32(10.00)),
+		Inventory: toPointer(10),
+	}
+	bkB := book.Book{
+		ID:        uuid.MustParse("00000000-0000-0000-0000-00000000000b"),
+		Name:      "Book B",
+		Price:     toPointer(float32(20.00)),
+		Inventory: toPointer(1),
+	}
+
+	t.Run("updates many items of an order, changing books sorted by ID", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		updtReq := book.UpdateOrderItemsRequest{
+			OrderID: orderID,
+			Items: []book.OrderItemChange{
+				{BookID: bkB.ID, BookUnitsToAdd: 1},
+				{BookID: bkA.ID, BookUnitsToAdd: 2},
+				{BookID: bkA.ID, BookUnitsToAdd: 1}, //Repeated books are merged: 3 units of book A.
+			},
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), orderID, gomock.Any()).Return(book.PurchaseLimitUsage{}, nil).Times(2)
+		gomock.InOrder(
+			mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), orderID, bkA.ID, 3).Return(book.OrderItem{BookID: bkA.ID, BookUnits: 3}, nil),
+			mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), orderID, bkB.ID, 1).Return(book.OrderItem{BookID: bkB.ID, BookUnits: 1}, nil),
+		)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID}, nil)
+
+		updatedOrder, err := mS.UpdateOrderItemsTx(ctx, updtReq)
+		is.NoErr(err)
+		is.Equal(updatedOrder.OrderID, orderID)
+	})
+
+	t.Run("rejects the whole batch returning the error of each rejected item", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		missingID := uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+		updtReq := book.UpdateOrderItemsRequest{
+			OrderID: orderID,
+			Items: []book.OrderItemChange{
+				{BookID: bkA.ID, BookUnitsToAdd: 1},
+				{BookID: bkB.ID, BookUnitsToAdd: 2},
+				{BookID: missingID, BookUnitsToAdd: 1},
+			},
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), orderID, gomock.Any()).Return(book.PurchaseLimitUsage{}, nil).Times(3)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), orderID, bkA.ID, 1).Return(book.OrderItem{BookID: bkA.ID, BookUnits: 1}, nil)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), orderID, bkB.ID, 2).Return(book.OrderItem{}, fmt.Errorf("reserving item at order on db: %w", book.ErrResponseInsufficientInventory))
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), orderID, missingID, 1).Return(book.OrderItem{}, fmt.Errorf("reserving item at order on db: %w", book.ErrResponseBookNotFound))
+		mockTx.EXPECT().Rollback().Return(nil) //There is no commit, so the transaction is rolled back.
+
+		updatedOrder, err := mS.UpdateOrderItemsTx(ctx, updtReq)
+		is.True(errors.Is(err, book.ErrResponseOrderItemsRejected))
+		var rejected book.ErrOrderItemsRejected
+		is.True(errors.As(err, &rejected))
+		is.Equal(rejected.Items, []book.OrderItemError{
+			{BookID: bkB.ID, Err: book.ErrResponseInsufficientInventory},
+			{BookID: missingID, Err: book.ErrResponseBookNotFound},
+		})
+		is.Equal(updatedOrder, book.Order{})
+	})
+}
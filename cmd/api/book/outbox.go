@@ -0,0 +1,161 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* An event stored in the same transaction as the change that caused it, to be delivered later by the dispatcher. */
+type OutboxEvent struct {
+	ID            uuid.UUID
+	EventType     EventType
+	Payload       []byte //JSON of the resource changed
+	CreatedAt     time.Time
+	SentAt        *time.Time
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	FailedAt      *time.Time //set when given up after OutboxMaxAttempts
+}
+
+const (
+	OutboxMaxAttempts = 8
+	outboxRetention   = 7 * 24 * time.Hour //how long sent events are kept before being purged
+)
+
+/* The envelope of the event, as handed to the Notifier. */
+func (e OutboxEvent) Event() Event {
+	return Event{ID: e.ID, Type: e.EventType, OccurredAt: e.CreatedAt, Payload: e.Payload}
+}
+
+/* Stores an event into the outbox, inside the transaction of txRepo. */
+func recordEvent(ctx context.Context, txRepo Repository, eventType EventType, payload any) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", eventType, err)
+	}
+
+	event := OutboxEvent{
+		ID:        uuid.New(),
+		EventType: eventType,
+		Payload:   payloadJSON,
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+	err = txRepo.InsertOutboxEvent(ctx, event)
+	if err != nil {
+		return fmt.Errorf("error on call to InsertOutboxEvent: %w ", err)
+	}
+	return nil
+}
+
+/* Delivers a batch of the events due through the Notifier, and queues them to the webhooks subscribed. The batch is claimed in a short transaction, leasing the events so other instances of the service skip them, and the Notifier is called after it commits, with no lock held. Events that fail are retried with an exponential backoff, each event being delivered at least once until it is given up after OutboxMaxAttempts. Returns how many events were sent. */
+func (s *Service) DispatchOutbox(ctx context.Context, batchSize int) (int, error) {
+	events, err := s.claimOutboxEvents(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, event := range events {
+		event = s.attemptEvent(ctx, event)
+		err = s.repo.UpdateOutboxEvent(ctx, event)
+		if err != nil {
+			return sent, fmt.Errorf("error on call to UpdateOutboxEvent: %w ", err)
+		}
+		if event.SentAt != nil {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+/* Takes the events due, queuing them to the webhooks and pushing their next attempt past the time needed to deliver them all. */
+func (s *Service) claimOutboxEvents(ctx context.Context, batchSize int) ([]OutboxEvent, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	events, err := txRepo.ListPendingOutboxEvents(ctx, now, batchSize) //locks the events, so other instances of the service skip them while they are leased
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListPendingOutboxEvents: %w ", err)
+	}
+
+	leasedUntil := now.Add(time.Duration(len(events)+1) * s.notificationsTimeout) //If the service stops while delivering, the events are taken again after the lease.
+	for i, event := range events {
+		err = enqueueWebhookDeliveries(ctx, txRepo, event.Event()) //Queued at every attempt, skipping the webhooks that already have it, so a failing Notifier does not hold webhooks back.
+		if err != nil {
+			return nil, err
+		}
+
+		leased := event
+		leased.NextAttemptAt = leasedUntil
+		err = txRepo.UpdateOutboxEvent(ctx, leased)
+		if err != nil {
+			return nil, fmt.Errorf("error on call to UpdateOutboxEvent: %w ", err)
+		}
+		events[i] = leased
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return events, nil
+}
+
+/* Delivers an event and returns it with the outcome: sent, scheduled for a retry or given up. */
+func (s *Service) attemptEvent(ctx context.Context, event OutboxEvent) OutboxEvent {
+	deliveryErr := s.deliverEvent(ctx, event)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	event.Attempts++
+	if deliveryErr == nil {
+		event.SentAt = &now
+		event.LastError = ""
+		return event
+	}
+
+	log.Printf("delivering outbox event %v: %v", event.ID, deliveryErr)
+	event.LastError = deliveryErr.Error()
+	if event.Attempts >= OutboxMaxAttempts {
+		event.FailedAt = &now
+		return event
+	}
+	event.NextAttemptAt = now.Add(time.Minute << (event.Attempts - 1))
+	return event
+}
+
+/* Deletes the events sent longer ago than the retention. Returns how many were deleted. */
+func (s *Service) PurgeSentOutboxEvents(ctx context.Context) (int, error) {
+	purged, err := s.repo.PurgeSentOutboxEvents(ctx, time.Now().UTC().Add(-outboxRetention))
+	if err != nil {
+		return 0, fmt.Errorf("error on call to PurgeSentOutboxEvents: %w", err)
+	}
+
+	return purged, nil
+}
+
+/* Hands the event to the Notifier, which tells apart the types it sends. */
+func (s *Service) deliverEvent(ctx context.Context, event OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, s.notificationsTimeout)
+	defer cancel()
+
+	return s.ntf.Notify(ctx, event.Event())
+}
@@ -0,0 +1,229 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestDispatchOutbox(t *testing.T) {
+	createdBook := book.Book{ID: uuid.New(), Name: "Outbox tester book", Price: toPointer(float32(10)), Inventory: toPointer(3)}
+	bookPayload, _ := json.Marshal(createdBook)
+	expiredOrder := book.Order{OrderID: uuid.New(), PurchaserID: uuid.New(), OrderStatus: "canceled"}
+	orderPayload, _ := json.Marshal(expiredOrder)
+
+	t.Run("claims the events due, queuing them to webhooks, and delivers them through the notifier once the claim is committed", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		events := []book.OutboxEvent{
+			{ID: uuid.New(), EventType: book.EventBookCreated, Payload: bookPayload, CreatedAt: time.Now().UTC().Round(time.Millisecond)},
+			{ID: uuid.New(), EventType: book.EventOrderExpired, Payload: orderPayload},
+		}
+
+		gomock.InOrder(
+			mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil),
+			mockTxRepo.EXPECT().ListPendingOutboxEvents(gomock.Any(), gomock.Any(), 10).Return(events, nil),
+			mockTxRepo.EXPECT().EnqueueWebhookDeliveries(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, delivery book.WebhookDelivery) (int, error) {
+				is.Equal(delivery.EventID, events[0].ID)
+				is.Equal(delivery.EventType, book.EventBookCreated)
+				is.Equal(delivery.Status, book.WebhookDeliveryPending)
+				var envelope book.Event
+				is.NoErr(json.Unmarshal(delivery.Payload, &envelope))
+				is.Equal(envelope.ID, events[0].ID)
+				is.Equal(envelope.Type, book.EventBookCreated)
+				return 2, nil
+			}),
+			mockTxRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.ID, events[0].ID)
+				is.True(event.NextAttemptAt.After(time.Now())) //Leased, so other instances skip it.
+				is.True(event.SentAt == nil)
+				return nil
+			}),
+			mockTxRepo.EXPECT().EnqueueWebhookDeliveries(gomock.Any(), gomock.Any()).Return(0, nil),
+			mockTxRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).Return(nil),
+			mockTx.EXPECT().Commit().Return(nil),
+			mockNtfy.EXPECT().Notify(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.Event) error {
+				is.Equal(event.ID, events[0].ID)
+				is.Equal(event.Type, book.EventBookCreated)
+				is.Equal(event.OccurredAt, events[0].CreatedAt)
+				var b book.Book
+				is.NoErr(json.Unmarshal(event.Payload, &b))
+				is.Equal(b.ID, createdBook.ID)
+				is.Equal(*b.Inventory, 3)
+				return nil
+			}),
+			mockRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.ID, events[0].ID)
+				is.True(event.SentAt != nil)
+				is.Equal(event.Attempts, 1)
+				return nil
+			}),
+			mockNtfy.EXPECT().Notify(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.Event) error {
+				is.Equal(event.Type, book.EventOrderExpired)
+				var o book.Order
+				is.NoErr(json.Unmarshal(event.Payload, &o))
+				is.Equal(o.OrderID, expiredOrder.OrderID)
+				is.Equal(o.PurchaserID, expiredOrder.PurchaserID)
+				return nil
+			}),
+			mockRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.ID, events[1].ID)
+				is.True(event.SentAt != nil)
+				return nil
+			}),
+		)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		sent, err := mS.DispatchOutbox(ctx, 10)
+		is.NoErr(err)
+		is.Equal(sent, 2)
+	})
+
+	t.Run("retries the events that failed with a backoff, giving up after the last attempt", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		events := []book.OutboxEvent{
+			{ID: uuid.New(), EventType: book.EventBookUpdated, Payload: bookPayload, Attempts: 2},
+			{ID: uuid.New(), EventType: book.EventOrderUpdated, Payload: orderPayload, Attempts: book.OutboxMaxAttempts - 1},
+			{ID: uuid.New(), EventType: book.EventOrderUpdated, Payload: orderPayload},
+		}
+		notificationErr := book.NewErrNotificationFailed(500)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListPendingOutboxEvents(gomock.Any(), gomock.Any(), 10).Return(events, nil)
+		mockTxRepo.EXPECT().EnqueueWebhookDeliveries(gomock.Any(), gomock.Any()).Return(0, nil).Times(3)
+		mockTxRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+		gomock.InOrder(
+			mockNtfy.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(notificationErr),
+			mockNtfy.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(errors.New("connection refused")),
+			mockNtfy.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(nil),
+		)
+		gomock.InOrder(
+			mockRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.ID, events[0].ID)
+				is.True(event.SentAt == nil)
+				is.True(event.FailedAt == nil)
+				is.Equal(event.Attempts, 3)
+				is.Equal(event.LastError, notificationErr.Error())
+				retryIn := time.Until(event.NextAttemptAt)
+				is.True(retryIn > 3*time.Minute && retryIn <= 4*time.Minute+time.Millisecond) //The third attempt waits four minutes, from a time rounded to milliseconds.
+				return nil
+			}),
+			mockRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.ID, events[1].ID)
+				is.True(event.SentAt == nil)
+				is.True(event.FailedAt != nil)
+				is.Equal(event.Attempts, book.OutboxMaxAttempts)
+				is.Equal(event.LastError, "connection refused")
+				return nil
+			}),
+			mockRepo.EXPECT().UpdateOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.ID, events[2].ID)
+				is.True(event.SentAt != nil)
+				return nil
+			}),
+		)
+
+		sent, err := mS.DispatchOutbox(ctx, 10)
+		is.NoErr(err)
+		is.Equal(sent, 1)
+	})
+
+	t.Run("expected error from database", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		dbErr := errors.New("fake error from database")
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListPendingOutboxEvents(gomock.Any(), gomock.Any(), 10).Return(nil, dbErr)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		sent, err := mS.DispatchOutbox(ctx, 10)
+		is.True(errors.Is(err, dbErr))
+		is.Equal(sent, 0)
+	})
+	t.Run("expected error from database when queuing webhook deliveries, with no event sent", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		dbErr := errors.New("fake error from database")
+		events := []book.OutboxEvent{{ID: uuid.New(), EventType: book.EventBookCreated, Payload: bookPayload}}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListPendingOutboxEvents(gomock.Any(), gomock.Any(), 10).Return(events, nil)
+		mockTxRepo.EXPECT().EnqueueWebhookDeliveries(gomock.Any(), gomock.Any()).Return(0, dbErr)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		sent, err := mS.DispatchOutbox(ctx, 10)
+		is.True(errors.Is(err, dbErr))
+		is.Equal(sent, 0)
+	})
+}
+
+func TestPurgeSentOutboxEvents(t *testing.T) {
+	t.Run("purges the events sent before the retention", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().PurgeSentOutboxEvents(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, sentBefore time.Time) (int, error) {
+			is.True(sentBefore.Before(time.Now().Add(-24 * time.Hour)))
+			return 3, nil
+		})
+
+		purged, err := mS.PurgeSentOutboxEvents(ctx)
+		is.NoErr(err)
+		is.Equal(purged, 3)
+	})
+}
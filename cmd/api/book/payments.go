@@ -0,0 +1,83 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+type Refund struct {
+	ReturnID uuid.UUID //Identifies the refund, so retrying it never pays twice.
+	OrderID  uuid.UUID
+	Amount   float32
+}
+
+type PaymentGateway interface {
+	Refund(ctx context.Context, refund Refund) (refundID string, err error)
+}
+
+/* Confirms that the total of a checked out order was paid, moving it from waiting_payment to paid, through a transaction. Payments are taken by the payment processor, outside of this service, which confirms them here once captured. Only then the order can be shipped or have books returned. */
+func (s *Service) ConfirmPayment(ctx context.Context, orderID uuid.UUID) (Order, error) {
+	var paidOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		paidOrder, err = s.confirmPayment(ctx, orderID)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return paidOrder, nil
+}
+
+/* Moves the order to paid, with the order locked so concurrent confirmations record a single change. */
+func (s *Service) confirmPayment(ctx context.Context, orderID uuid.UUID) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	status, err := txRepo.GetOrderStatusForUpdate(ctx, orderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to GetOrderStatusForUpdate: %w ", err)
+	}
+	if status != "waiting_payment" {
+		return Order{}, ErrResponseOrderNotWaitingPayment
+	}
+
+	err = txRepo.SetOrderStatus(ctx, orderID, "paid")
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to SetOrderStatus: %w ", err)
+	}
+	err = recordStatusChange(ctx, txRepo, orderID, status, "paid")
+	if err != nil {
+		return Order{}, err
+	}
+
+	paidOrder, err := txRepo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, paidOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return paidOrder, nil
+}
@@ -0,0 +1,73 @@
+package book_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestConfirmPayment(t *testing.T) {
+	orderID := uuid.New()
+
+	t.Run("confirms the payment of an order waiting payment", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("waiting_payment", nil)
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), orderID, "paid").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, change book.StatusChange) error {
+			is.Equal(change.FromStatus, "waiting_payment")
+			is.Equal(change.ToStatus, "paid")
+			return nil
+		})
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, OrderStatus: "paid"}, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventOrderUpdated)
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		paidOrder, err := mS.ConfirmPayment(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(paidOrder.OrderStatus, "paid")
+	})
+
+	for _, status := range []string{"accepting_items", "paid", "canceled"} {
+		t.Run("expected order not waiting payment error for an order "+status, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			mockRepo := bookmock.NewMockRepository(ctrl)
+			mockNtfy := bookmock.NewMockNotifier(ctrl)
+			mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+			mockPay := bookmock.NewMockPaymentGateway(ctrl)
+			mockHooks := bookmock.NewMockWebhookSender(ctrl)
+			mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+			mockTxRepo := bookmock.NewMockRepository(ctrl)
+			mockTx := bookmock.NewMockTx(ctrl)
+
+			mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+			mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return(status, nil)
+			mockTx.EXPECT().Rollback().Return(nil)
+
+			paidOrder, err := mS.ConfirmPayment(ctx, orderID)
+			is.True(errors.Is(err, book.ErrResponseOrderNotWaitingPayment))
+			is.Equal(paidOrder, book.Order{})
+		})
+	}
+}
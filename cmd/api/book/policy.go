@@ -0,0 +1,132 @@
+package book
+
+import (
+	"github.com/google/uuid"
+)
+
+/* Something a caller can be allowed to do. */
+type Permission string
+
+const (
+	PermissionBooksWrite       Permission = "books:write"       //create, update and archive books
+	PermissionCouponsRead      Permission = "coupons:read"      //list and see coupons
+	PermissionCouponsWrite     Permission = "coupons:write"     //create, update and delete coupons
+	PermissionOrdersRead       Permission = "orders:read"       //see orders, their history, returns and shipments
+	PermissionOrdersWrite      Permission = "orders:write"      //create orders, change their items and details, check out, ask returns
+	PermissionFulfillmentWrite Permission = "fulfillment:write" //pick, ship and deliver shipments
+	PermissionPaymentsWrite    Permission = "payments:write"    //confirm the payment of checked out orders
+	PermissionReturnsReview    Permission = "returns:review"    //approve and reject returns
+	PermissionUsersRead        Permission = "users:read"        //list and see users and their wishlists
+	PermissionUsersWrite       Permission = "users:write"       //update and delete users, change their wishlists
+	PermissionUsersRoles       Permission = "users:roles"       //give the admin role
+	PermissionUsersPrivacy     Permission = "users:privacy"     //export and erase the personal data of users
+	PermissionAPIKeysWrite     Permission = "apikeys:write"     //issue, list and revoke API keys
+	PermissionWebhooksWrite    Permission = "webhooks:write"    //create, list, update and delete webhooks, and see their deliveries
+	PermissionCart             Permission = "cart"              //see and change the items of the guest order of a cart token
+)
+
+/* The role of callers with a cart token. Guests are not users, so it is not at the access enum. */
+const RoleGuest = "guest"
+
+/* The permissions an API key can be given as scopes. Keys can't manage users' roles, their personal data, other keys, nor webhooks, that are sent the events of everything. */
+var apiKeyScopes = map[Permission]bool{
+	PermissionBooksWrite:       true,
+	PermissionCouponsRead:      true,
+	PermissionCouponsWrite:     true,
+	PermissionOrdersRead:       true,
+	PermissionOrdersWrite:      true,
+	PermissionFulfillmentWrite: true,
+	PermissionPaymentsWrite:    true,
+	PermissionReturnsReview:    true,
+	PermissionUsersRead:        true,
+	PermissionUsersWrite:       true,
+}
+
+/* Tells if the permission can be given to an API key. */
+func ValidAPIKeyScope(permission Permission) bool {
+	return apiKeyScopes[permission]
+}
+
+/* How far a permission goes: on everything, or only on what the caller owns. */
+type reach int
+
+const (
+	reachNone reach = iota
+	reachOwn
+	reachAll
+)
+
+/* Maps each role of the access enum to its permissions. Roles not listed can't do anything. */
+var rolePermissions = map[string]map[Permission]reach{
+	UserRoleAdmin: {
+		PermissionBooksWrite:       reachAll,
+		PermissionCouponsRead:      reachAll,
+		PermissionCouponsWrite:     reachAll,
+		PermissionOrdersRead:       reachAll,
+		PermissionOrdersWrite:      reachAll,
+		PermissionFulfillmentWrite: reachAll,
+		PermissionPaymentsWrite:    reachAll,
+		PermissionReturnsReview:    reachAll,
+		PermissionUsersRead:        reachAll,
+		PermissionUsersWrite:       reachAll,
+		PermissionUsersRoles:       reachAll,
+		PermissionUsersPrivacy:     reachAll,
+		PermissionAPIKeysWrite:     reachAll,
+		PermissionWebhooksWrite:    reachAll,
+	},
+	UserRoleUser: {
+		PermissionOrdersRead:  reachOwn,
+		PermissionOrdersWrite: reachOwn,
+		PermissionUsersRead:   reachOwn,
+		PermissionUsersWrite:  reachOwn,
+	},
+	RoleGuest: {
+		PermissionCart: reachOwn,
+	},
+}
+
+/* Checks if the role of the caller grants the permission on everything. */
+func Authorize(claims Claims, permission Permission) error {
+	if permissionReach(claims, permission) < reachAll {
+		return ErrResponseForbidden
+	}
+	return nil
+}
+
+/* Checks if the role of the caller grants the permission on a resource of ownerID: on everything, or only on its own resources. */
+func AuthorizeOwner(claims Claims, permission Permission, ownerID uuid.UUID) error {
+	switch permissionReach(claims, permission) {
+	case reachAll:
+		return nil
+	case reachOwn:
+		if claims.UserID != uuid.Nil && claims.UserID == ownerID {
+			return nil
+		}
+	}
+	return ErrResponseForbidden
+}
+
+/* Checks if the role of the caller grants the permission at least on its own resources. Used when the owner is only known later, so the ownership must still be checked. */
+func AuthorizeOwned(claims Claims, permission Permission) error {
+	if permissionReach(claims, permission) < reachOwn {
+		return ErrResponseForbidden
+	}
+	return nil
+}
+
+/* Tells how far the role of the caller grants the permission. Callers with an API key are also limited to its scopes. */
+func permissionReach(claims Claims, permission Permission) reach {
+	if claims.APIKeyID != uuid.Nil && !hasScope(claims.Scopes, permission) {
+		return reachNone
+	}
+	return rolePermissions[claims.Role][permission]
+}
+
+func hasScope(scopes []Permission, permission Permission) bool {
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package book_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestPolicy(t *testing.T) {
+	admin := book.Claims{UserID: uuid.New(), Role: book.UserRoleAdmin}
+	user := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+	unknownRole := book.Claims{UserID: uuid.New(), Role: "auditor"}
+
+	t.Run("admins can do everything", func(t *testing.T) {
+		is := is.New(t)
+
+		for _, permission := range []book.Permission{
+			book.PermissionBooksWrite,
+			book.PermissionCouponsRead,
+			book.PermissionCouponsWrite,
+			book.PermissionOrdersRead,
+			book.PermissionOrdersWrite,
+			book.PermissionFulfillmentWrite,
+			book.PermissionPaymentsWrite,
+			book.PermissionReturnsReview,
+			book.PermissionUsersRead,
+			book.PermissionUsersWrite,
+			book.PermissionUsersRoles,
+			book.PermissionUsersPrivacy,
+			book.PermissionAPIKeysWrite,
+			book.PermissionWebhooksWrite,
+		} {
+			is.NoErr(book.Authorize(admin, permission))
+			is.NoErr(book.AuthorizeOwner(admin, permission, uuid.New()))
+			is.NoErr(book.AuthorizeOwned(admin, permission))
+		}
+	})
+
+	t.Run("expected forbidden error for users at catalog, coupon and warehouse actions", func(t *testing.T) {
+		is := is.New(t)
+
+		for _, permission := range []book.Permission{
+			book.PermissionBooksWrite,
+			book.PermissionCouponsRead,
+			book.PermissionCouponsWrite,
+			book.PermissionFulfillmentWrite,
+			book.PermissionPaymentsWrite,
+			book.PermissionReturnsReview,
+			book.PermissionUsersRoles,
+			book.PermissionUsersPrivacy,
+			book.PermissionWebhooksWrite,
+		} {
+			is.True(errors.Is(book.Authorize(user, permission), book.ErrResponseForbidden))
+			is.True(errors.Is(book.AuthorizeOwner(user, permission, user.UserID), book.ErrResponseForbidden))
+			is.True(errors.Is(book.AuthorizeOwned(user, permission), book.ErrResponseForbidden))
+		}
+	})
+
+	t.Run("users can read and change only their own orders and account", func(t *testing.T) {
+		is := is.New(t)
+
+		for _, permission := range []book.Permission{
+			book.PermissionOrdersRead,
+			book.PermissionOrdersWrite,
+			book.PermissionUsersRead,
+			book.PermissionUsersWrite,
+		} {
+			is.NoErr(book.AuthorizeOwner(user, permission, user.UserID))
+			is.NoErr(book.AuthorizeOwned(user, permission))
+			is.True(errors.Is(book.AuthorizeOwner(user, permission, uuid.New()), book.ErrResponseForbidden))
+			is.True(errors.Is(book.Authorize(user, permission), book.ErrResponseForbidden))
+		}
+	})
+
+	t.Run("expected forbidden error for unknown roles", func(t *testing.T) {
+		is := is.New(t)
+
+		is.True(errors.Is(book.AuthorizeOwned(unknownRole, book.PermissionOrdersRead), book.ErrResponseForbidden))
+		is.True(errors.Is(book.AuthorizeOwner(unknownRole, book.PermissionUsersRead, unknownRole.UserID), book.ErrResponseForbidden))
+	})
+
+	t.Run("guests can only use their cart", func(t *testing.T) {
+		is := is.New(t)
+
+		guest := book.Claims{Role: book.RoleGuest, CartOrderID: uuid.New()}
+
+		is.NoErr(book.AuthorizeOwned(guest, book.PermissionCart))
+		is.True(errors.Is(book.AuthorizeOwned(guest, book.PermissionOrdersRead), book.ErrResponseForbidden))
+		is.True(errors.Is(book.AuthorizeOwned(guest, book.PermissionOrdersWrite), book.ErrResponseForbidden))
+		is.True(errors.Is(book.AuthorizeOwned(user, book.PermissionCart), book.ErrResponseForbidden))
+		is.True(!book.ValidAPIKeyScope(book.PermissionCart))
+	})
+
+	t.Run("api keys are limited to their scopes", func(t *testing.T) {
+		is := is.New(t)
+
+		key := book.Claims{UserID: admin.UserID, Role: book.UserRoleAdmin, APIKeyID: uuid.New(), Scopes: []book.Permission{book.PermissionBooksWrite}}
+
+		is.NoErr(book.Authorize(key, book.PermissionBooksWrite))
+		is.True(errors.Is(book.Authorize(key, book.PermissionOrdersRead), book.ErrResponseForbidden))
+		is.True(errors.Is(book.Authorize(key, book.PermissionAPIKeysWrite), book.ErrResponseForbidden))
+		is.True(!book.ValidAPIKeyScope(book.PermissionAPIKeysWrite))
+		is.True(!book.ValidAPIKeyScope(book.PermissionUsersRoles))
+		is.True(!book.ValidAPIKeyScope(book.PermissionUsersPrivacy))
+		is.True(!book.ValidAPIKeyScope(book.PermissionWebhooksWrite))
+		is.True(book.ValidAPIKeyScope(book.PermissionOrdersRead))
+	})
+
+	t.Run("expected forbidden error for a user with no ID", func(t *testing.T) {
+		is := is.New(t)
+
+		is.True(errors.Is(book.AuthorizeOwner(book.Claims{Role: book.UserRoleUser}, book.PermissionOrdersWrite, uuid.Nil), book.ErrResponseForbidden))
+	})
+}
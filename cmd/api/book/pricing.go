@@ -0,0 +1,229 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* Calculates the charges added to an order at checkout. */
+type PriceCalculator interface {
+	Calculate(order Order, region string) (OrderCharges, error)
+}
+
+type OrderCharges struct {
+	Tax      float32
+	Shipping float32
+}
+
+type ShippingTier struct {
+	MaxItems int     `json:"max_items"` //0 means no upper limit
+	Price    float32 `json:"price"`
+}
+
+type PricingRules struct {
+	DefaultTaxRate float32            `json:"default_tax_rate"` //percentage applied to regions without a specific rate, 0 refuses them
+	TaxRates       map[string]float32 `json:"tax_rates"`        //percentage by ISO 3166-2 region, like "BR-SP", or by country, like "BR"
+	ShippingTiers  []ShippingTier     `json:"shipping_tiers"`   //checked in order, the first that fits the number of items is used
+}
+
+/* Reads the pricing rules from a JSON file. */
+func LoadPricingRules(path string) (PricingRules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return PricingRules{}, fmt.Errorf("loading pricing rules: %w", err)
+	}
+	defer file.Close()
+
+	var rules PricingRules
+	err = json.NewDecoder(file).Decode(&rules)
+	if err != nil {
+		return PricingRules{}, fmt.Errorf("loading pricing rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+/* PriceCalculator that applies tax rates by region and shipping tiers by number of items. */
+type RulesCalculator struct {
+	rules PricingRules
+}
+
+func NewRulesCalculator(rules PricingRules) *RulesCalculator {
+	return &RulesCalculator{rules: rules}
+}
+
+/* The rate of the region is used, or else the rate of its country, or else the default rate. Without any of them the region is not supported. */
+func (c *RulesCalculator) Calculate(order Order, region string) (OrderCharges, error) {
+	region = strings.ToUpper(region)
+	taxRate, found := c.rules.TaxRates[region]
+	if !found {
+		country, _, _ := strings.Cut(region, "-")
+		taxRate, found = c.rules.TaxRates[country]
+	}
+	if !found {
+		if c.rules.DefaultTaxRate == 0 {
+			return OrderCharges{}, ErrResponseTaxRegionNotSupported
+		}
+		taxRate = c.rules.DefaultTaxRate
+	}
+	tax := (order.Subtotal - order.Discount) * taxRate / 100
+
+	units := 0
+	for _, item := range order.Items {
+		units += item.BookUnits
+	}
+	var shipping float32
+	for _, tier := range c.rules.ShippingTiers {
+		if tier.MaxItems == 0 || units <= tier.MaxItems {
+			shipping = tier.Price
+			break
+		}
+	}
+
+	return OrderCharges{
+		Tax:      roundCents(tax),
+		Shipping: roundCents(shipping),
+	}, nil
+}
+
+/* The ISO 3166-2 code of the region of a shipping address, like "BR-SP", or only its country when it has no state. */
+func taxRegion(address *ShippingAddress) (string, error) {
+	if address == nil {
+		return "", ErrResponseShippingAddressRequired
+	}
+	if address.State == "" {
+		return strings.ToUpper(address.Country), nil
+	}
+	return strings.ToUpper(address.Country + "-" + address.State), nil
+}
+
+func roundCents(value float32) float32 {
+	return float32(math.Round(float64(value)*100) / 100)
+}
+
+/* Closes an order to new items, calculating and storing its final prices. Tax follows the region of its shipping address. The order then waits for payment. Users only check out their own orders; admins check out any. */
+func (s *Service) Checkout(ctx context.Context, orderID uuid.UUID) (Order, error) {
+	var checkedOutOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		checkedOutOrder, err = s.checkout(ctx, orderID)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return checkedOutOrder, nil
+}
+
+/* Prices the order and moves it to waiting_payment. */
+func (s *Service) checkout(ctx context.Context, orderID uuid.UUID) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = authorizeOrderChange(ctx, txRepo, orderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = txRepo.UpdateOrderRow(ctx, orderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
+	}
+
+	order, err := txRepo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	if len(order.Items) == 0 {
+		return Order{}, ErrResponseOrderIsEmpty
+	}
+	if order.CouponID != nil {
+		order, err = dropInvalidCoupon(ctx, txRepo, order)
+		if err != nil {
+			return Order{}, err
+		}
+	}
+
+	region, err := taxRegion(order.ShippingAddress)
+	if err != nil {
+		return Order{}, err
+	}
+	charges, err := s.calc.Calculate(order, region)
+	if err != nil {
+		return Order{}, err
+	}
+	order.TaxRegion = region
+	order.Tax = charges.Tax
+	order.Shipping = charges.Shipping
+	order.TotalPrice = roundCents(order.Subtotal - order.Discount + order.Tax + order.Shipping)
+	order.OrderStatus = "waiting_payment"
+
+	err = txRepo.CheckoutOrder(ctx, order)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to CheckoutOrder: %w ", err)
+	}
+	err = recordStatusChange(ctx, txRepo, orderID, "accepting_items", order.OrderStatus)
+	if err != nil {
+		return Order{}, err
+	}
+
+	checkedOutOrder, err := txRepo.ListOrderItems(ctx, orderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, checkedOutOrder)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return checkedOutOrder, nil
+}
+
+/* Takes off the order its coupon when it expired, or when the order no longer reaches its minimum total, since it was applied. The use of the coupon is given back. */
+func dropInvalidCoupon(ctx context.Context, txRepo Repository, order Order) (Order, error) {
+	coupon, err := txRepo.GetCouponByID(ctx, *order.CouponID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to GetCouponByID: %w ", err)
+	}
+	if coupon.ValidAt(time.Now().UTC()) && order.Subtotal >= coupon.MinOrderTotal {
+		return order, nil
+	}
+
+	err = txRepo.RemoveOrderCoupon(ctx, order.OrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to RemoveOrderCoupon: %w ", err)
+	}
+	err = txRepo.DecrementCouponUsage(ctx, coupon.ID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to DecrementCouponUsage: %w ", err)
+	}
+
+	order.CouponID = nil
+	order.CouponCode = ""
+	order.Discount = 0
+	return order, nil
+}
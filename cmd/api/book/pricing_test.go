@@ -0,0 +1,285 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestRulesCalculator(t *testing.T) {
+	rules := book.PricingRules{
+		DefaultTaxRate: 5,
+		TaxRates:       map[string]float32{"BR-SP": 18, "PT": 23},
+		ShippingTiers: []book.ShippingTier{
+			{MaxItems: 1, Price: 10},
+			{MaxItems: 5, Price: 15},
+			{MaxItems: 0, Price: 25},
+		},
+	}
+	calc := book.NewRulesCalculator(rules)
+
+	testCases := []struct {
+		name     string
+		order    book.Order
+		region   string
+		expected book.OrderCharges
+	}{
+		{
+			name:     "tax rate of the region and first shipping tier",
+			order:    book.Order{Subtotal: 100, Items: []book.OrderItem{{BookUnits: 1}}},
+			region:   "br-sp",
+			expected: book.OrderCharges{Tax: 18, Shipping: 10},
+		},
+		{
+			name:     "tax rate of the country when its region has none",
+			order:    book.Order{Subtotal: 100, Items: []book.OrderItem{{BookUnits: 1}}},
+			region:   "PT-11",
+			expected: book.OrderCharges{Tax: 23, Shipping: 10},
+		},
+		{
+			name:     "tax is calculated after the discount",
+			order:    book.Order{Subtotal: 100, Discount: 50, Items: []book.OrderItem{{BookUnits: 2}, {BookUnits: 3}}},
+			region:   "BR-SP",
+			expected: book.OrderCharges{Tax: 9, Shipping: 15},
+		},
+		{
+			name:     "default tax rate for other regions and last shipping tier",
+			order:    book.Order{Subtotal: 33.33, Items: []book.OrderItem{{BookUnits: 6}}},
+			region:   "BR-RJ",
+			expected: book.OrderCharges{Tax: 1.67, Shipping: 25},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			charges, err := calc.Calculate(tc.order, tc.region)
+			is.NoErr(err)
+			is.Equal(charges, tc.expected)
+		})
+	}
+
+	t.Run("expected tax region not supported error without a default tax rate", func(t *testing.T) {
+		is := is.New(t)
+		rules.DefaultTaxRate = 0
+		calc := book.NewRulesCalculator(rules)
+
+		_, err := calc.Calculate(book.Order{Subtotal: 100, Items: []book.OrderItem{{BookUnits: 1}}}, "BR-RJ")
+		is.True(errors.Is(err, book.ErrResponseTaxRegionNotSupported))
+	})
+}
+
+func TestLoadPricingRules(t *testing.T) {
+	t.Run("loads the pricing rules shipped with the service", func(t *testing.T) {
+		is := is.New(t)
+
+		rules, err := book.LoadPricingRules("../../../config/pricing_rules.json")
+		is.NoErr(err)
+		is.True(len(rules.TaxRates) > 0)
+		is.True(len(rules.ShippingTiers) > 0)
+	})
+
+	t.Run("expected error from a missing file", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := book.LoadPricingRules("missing_rules.json")
+		is.True(err != nil)
+	})
+}
+
+func TestCheckout(t *testing.T) {
+	orderID := uuid.New()
+
+	t.Run("checks out an order, storing its final prices", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		order := book.Order{
+			OrderID:     orderID,
+			OrderStatus: "accepting_items",
+			Subtotal:    100,
+			Discount:    10,
+			TotalPrice:  90,
+			Items:       []book.OrderItem{{BookID: uuid.New(), BookUnits: 2}},
+			ShippingAddress: &book.ShippingAddress{
+				Recipient: "Ana", Line1: "Rua A, 1", City: "Campinas", State: "sp", PostalCode: "13000-000", Country: "br",
+			},
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockCalc.EXPECT().Calculate(order, "BR-SP").Return(book.OrderCharges{Tax: 16.2, Shipping: 15}, nil)
+		mockTxRepo.EXPECT().CheckoutOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o book.Order) error {
+			is.Equal(o.OrderStatus, "waiting_payment")
+			is.Equal(o.TaxRegion, "BR-SP")
+			is.Equal(o.Tax, float32(16.2))
+			is.Equal(o.Shipping, float32(15))
+			is.Equal(o.TotalPrice, float32(121.2)) //100 - 10 + 16.2 + 15
+			order = o
+			return nil
+		})
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, change book.StatusChange) error {
+			is.Equal(change.FromStatus, "accepting_items")
+			is.Equal(change.ToStatus, "waiting_payment")
+			return nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).DoAndReturn(func(_ context.Context, _ uuid.UUID) (book.Order, error) {
+			return order, nil
+		})
+
+		checkedOutOrder, err := mS.Checkout(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(checkedOutOrder.OrderStatus, "waiting_payment")
+		is.Equal(checkedOutOrder.TotalPrice, float32(121.2))
+	})
+
+	expiredAt := time.Now().UTC().Add(-time.Hour)
+	couponsDropped := []struct {
+		name   string
+		coupon book.Coupon
+	}{
+		{"that expired", book.Coupon{DiscountType: book.DiscountTypeFixedAmount, DiscountValue: 10, ValidUntil: &expiredAt}},
+		{"whose minimum total the order no longer reaches", book.Coupon{DiscountType: book.DiscountTypeFixedAmount, DiscountValue: 10, MinOrderTotal: 150}},
+	}
+	for _, tc := range couponsDropped {
+		t.Run("checks out with no discount, dropping a coupon "+tc.name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			mockRepo := bookmock.NewMockRepository(ctrl)
+			mockNtfy := bookmock.NewMockNotifier(ctrl)
+			mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+			mockPay := bookmock.NewMockPaymentGateway(ctrl)
+			mockHooks := bookmock.NewMockWebhookSender(ctrl)
+			mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+			mockTxRepo := bookmock.NewMockRepository(ctrl)
+			mockTx := bookmock.NewMockTx(ctrl)
+
+			coupon := tc.coupon
+			coupon.ID = uuid.New()
+			order := book.Order{
+				OrderID:     orderID,
+				OrderStatus: "accepting_items",
+				CouponID:    &coupon.ID,
+				CouponCode:  "TENOFF",
+				Subtotal:    100,
+				Discount:    10,
+				Items:       []book.OrderItem{{BookID: uuid.New(), BookUnits: 2}},
+				ShippingAddress: &book.ShippingAddress{
+					Recipient: "Ana", Line1: "Rua A, 1", City: "Lisboa", PostalCode: "1000-001", Country: "PT",
+				},
+			}
+
+			mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+			mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+			mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+			mockTxRepo.EXPECT().GetCouponByID(gomock.Any(), coupon.ID).Return(coupon, nil)
+			mockTxRepo.EXPECT().RemoveOrderCoupon(gomock.Any(), orderID).Return(nil)
+			mockTxRepo.EXPECT().DecrementCouponUsage(gomock.Any(), coupon.ID).Return(nil)
+			mockCalc.EXPECT().Calculate(gomock.Any(), "PT").Return(book.OrderCharges{Shipping: 15}, nil)
+			mockTxRepo.EXPECT().CheckoutOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o book.Order) error {
+				is.Equal(o.CouponID, nil)
+				is.Equal(o.Discount, float32(0))
+				is.Equal(o.TotalPrice, float32(115)) //100 + 15
+				order = o
+				return nil
+			})
+			mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).Return(nil)
+			mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+			mockTx.EXPECT().Commit().Return(nil)
+			mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+			mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).DoAndReturn(func(_ context.Context, _ uuid.UUID) (book.Order, error) {
+				return order, nil
+			})
+
+			checkedOutOrder, err := mS.Checkout(ctx, orderID)
+			is.NoErr(err)
+			is.Equal(checkedOutOrder.TotalPrice, float32(115))
+		})
+	}
+
+	t.Run("expected empty order error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID}, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		checkedOutOrder, err := mS.Checkout(ctx, orderID)
+		is.True(errors.Is(err, book.ErrResponseOrderIsEmpty))
+		is.Equal(checkedOutOrder, book.Order{})
+	})
+
+	taxRegionErrors := []struct {
+		name    string
+		address *book.ShippingAddress
+		calcErr error
+		want    error
+	}{
+		{"shipping address required", nil, nil, book.ErrResponseShippingAddressRequired},
+		{"tax region not supported", &book.ShippingAddress{Country: "US", State: "CA"}, book.ErrResponseTaxRegionNotSupported, book.ErrResponseTaxRegionNotSupported},
+	}
+	for _, tc := range taxRegionErrors {
+		t.Run("expected "+tc.name+" error", func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			mockRepo := bookmock.NewMockRepository(ctrl)
+			mockNtfy := bookmock.NewMockNotifier(ctrl)
+			mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+			mockPay := bookmock.NewMockPaymentGateway(ctrl)
+			mockHooks := bookmock.NewMockWebhookSender(ctrl)
+			mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+			mockTxRepo := bookmock.NewMockRepository(ctrl)
+			mockTx := bookmock.NewMockTx(ctrl)
+
+			order := book.Order{
+				OrderID:         orderID,
+				OrderStatus:     "accepting_items",
+				Subtotal:        100,
+				Items:           []book.OrderItem{{BookID: uuid.New(), BookUnits: 1}},
+				ShippingAddress: tc.address,
+			}
+
+			mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+			mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), orderID).Return(nil)
+			mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+			if tc.address != nil {
+				mockCalc.EXPECT().Calculate(order, "US-CA").Return(book.OrderCharges{}, tc.calcErr)
+			}
+			mockTx.EXPECT().Rollback().Return(nil)
+
+			checkedOutOrder, err := mS.Checkout(ctx, orderID)
+			is.True(errors.Is(err, tc.want))
+			is.Equal(checkedOutOrder, book.Order{})
+		})
+	}
+}
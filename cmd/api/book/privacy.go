@@ -0,0 +1,128 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* The name erased users are left with, as the orders still point to them. */
+const ErasedUserName = "erased user"
+
+/* All the personal data kept of a user: its profile, and its orders with their items. */
+type UserDataExport struct {
+	User       User
+	Orders     []Order
+	ExportedAt time.Time
+}
+
+/* Gathers the personal data of a user, so it can be handed to them. */
+func (s *Service) ExportUserData(ctx context.Context, userID uuid.UUID) (UserDataExport, error) {
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return UserDataExport{}, fmt.Errorf("error on call to GetUserByID: %w", err)
+	}
+	u.PasswordHash = "" //Not personal data to hand out, and never shown.
+
+	orderIDs, err := s.repo.ListOrderIDsOfPurchaser(ctx, userID)
+	if err != nil {
+		return UserDataExport{}, fmt.Errorf("error on call to ListOrderIDsOfPurchaser: %w", err)
+	}
+
+	orders := []Order{}
+	for _, orderID := range orderIDs {
+		o, err := s.repo.ListOrderItems(ctx, orderID)
+		if err != nil {
+			return UserDataExport{}, fmt.Errorf("error on call to ListOrderItems: %w", err)
+		}
+		orders = append(orders, o)
+	}
+
+	return UserDataExport{User: u, Orders: orders, ExportedAt: time.Now().UTC().Round(time.Millisecond)}, nil
+}
+
+/* What was left of a user after its erasure. */
+type UserErasure struct {
+	User             User
+	OrdersAnonymized int
+	ErasedAt         time.Time
+}
+
+/* Erases the personal data of a user through a transaction: its profile is anonymized, it can't log in anymore, its wishlist is dropped and the addresses, contacts and notes of its orders are cleared, also from the events about them. The orders themselves, with their items and prices, are kept for the financial records. */
+func (s *Service) EraseUserData(ctx context.Context, userID uuid.UUID) (UserErasure, error) {
+	var erasure UserErasure
+	err := s.retryTx(ctx, func() error {
+		var err error
+		erasure, err = s.eraseUserData(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return UserErasure{}, err
+	}
+	return erasure, nil
+}
+
+/* Anonymizes the user and clears the personal data of its orders and events. */
+func (s *Service) eraseUserData(ctx context.Context, userID uuid.UUID) (UserErasure, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	erasedAt := time.Now().UTC().Round(time.Millisecond)
+
+	erased, err := txRepo.AnonymizeUser(ctx, userID, ErasedUserName, erasedAt) //Its role goes back to user, so API keys it owned stop working too.
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to AnonymizeUser: %w ", err)
+	}
+
+	err = txRepo.DeleteUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to DeleteUserRefreshTokens: %w ", err)
+	}
+
+	err = txRepo.ClearWishlist(ctx, userID)
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to ClearWishlist: %w ", err)
+	}
+
+	anonymized, err := txRepo.AnonymizePurchaserOrders(ctx, userID)
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to AnonymizePurchaserOrders: %w ", err)
+	}
+
+	//The events about the user and its orders are kept, to be delivered or in the delivery log of webhooks, but with no personal data:
+	err = txRepo.AnonymizeUserEvents(ctx, userID, ErasedUserName)
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to AnonymizeUserEvents: %w ", err)
+	}
+	err = txRepo.AnonymizeUserWebhookDeliveries(ctx, userID, ErasedUserName)
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to AnonymizeUserWebhookDeliveries: %w ", err)
+	}
+
+	erased.PasswordHash = ""
+	erasure := UserErasure{User: erased, OrdersAnonymized: anonymized, ErasedAt: erasedAt}
+	err = recordEvent(ctx, txRepo, EventUserErased, erasure)
+	if err != nil {
+		return UserErasure{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return UserErasure{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return erasure, nil
+}
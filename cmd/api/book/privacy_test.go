@@ -0,0 +1,118 @@
+package book_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestExportUserData(t *testing.T) {
+	user := book.User{UserID: uuid.New(), Name: "Some user", Role: book.UserRoleUser, Email: "some@user.com", PasswordHash: "hash"}
+
+	t.Run("exports the profile and the orders of the user, with their items", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		order := book.Order{OrderID: uuid.New(), PurchaserID: user.UserID, OrderStatus: "delivered", Items: []book.OrderItem{{BookID: uuid.New(), BookUnits: 1}}}
+
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), user.UserID).Return(user, nil)
+		mockRepo.EXPECT().ListOrderIDsOfPurchaser(gomock.Any(), user.UserID).Return([]uuid.UUID{order.OrderID}, nil)
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+
+		export, err := mS.ExportUserData(ctx, user.UserID)
+		is.NoErr(err)
+		is.Equal(export.User.Email, user.Email)
+		is.Equal(export.User.PasswordHash, "") //Never handed out.
+		is.Equal(export.Orders, []book.Order{order})
+		is.True(!export.ExportedAt.IsZero())
+	})
+
+	t.Run("expected user not found error for an unknown user", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), user.UserID).Return(book.User{}, book.ErrResponseUserNotFound)
+
+		_, err := mS.ExportUserData(ctx, user.UserID)
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
+}
+
+func TestEraseUserData(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("anonymizes the user and its orders, ending its sessions", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		erased := book.User{UserID: userID, Name: book.ErasedUserName, Role: book.UserRoleUser}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().AnonymizeUser(gomock.Any(), userID, book.ErasedUserName, gomock.Any()).Return(erased, nil)
+		mockTxRepo.EXPECT().DeleteUserRefreshTokens(gomock.Any(), userID).Return(nil)
+		mockTxRepo.EXPECT().ClearWishlist(gomock.Any(), userID).Return(nil)
+		mockTxRepo.EXPECT().AnonymizePurchaserOrders(gomock.Any(), userID).Return(2, nil)
+		mockTxRepo.EXPECT().AnonymizeUserEvents(gomock.Any(), userID, book.ErasedUserName).Return(nil)
+		mockTxRepo.EXPECT().AnonymizeUserWebhookDeliveries(gomock.Any(), userID, book.ErasedUserName).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventUserErased)
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		erasure, err := mS.EraseUserData(ctx, userID)
+		is.NoErr(err)
+		is.Equal(erasure.User, erased)
+		is.Equal(erasure.OrdersAnonymized, 2)
+		is.True(!erasure.ErasedAt.IsZero())
+	})
+
+	t.Run("expected user not found error for an unknown user, with nothing changed", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().AnonymizeUser(gomock.Any(), userID, book.ErasedUserName, gomock.Any()).Return(book.User{}, book.ErrResponseUserNotFound)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.EraseUserData(ctx, userID)
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
+}
@@ -0,0 +1,57 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+/* Returned by the repository when a transaction fails because of a concurrent one, like a serialization failure or a deadlock. Worth retrying. */
+var ErrTxConflict = errors.New("transaction conflicts with a concurrent one")
+
+/* Configures the transactions of multi-step operations. */
+type TxConfig struct {
+	Isolation      sql.IsolationLevel //sql.LevelDefault keeps the default level of the database
+	MaxRetries     int                //how many times a transaction is run again after a serialization failure or a deadlock
+	RetryBaseDelay time.Duration      //the wait before each retry doubles from this value, with jitter
+}
+
+/* Options to begin the transactions of multi-step operations. Nil keeps the defaults of the database. */
+func (s *Service) txOptions() *sql.TxOptions {
+	if s.txConfig.Isolation == sql.LevelDefault {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: s.txConfig.Isolation}
+}
+
+/* Runs a transaction, running it again while it fails with ErrTxConflict, up to the configured limit and waiting longer before each retry. Every multi-step operation of the service goes through it: its exported method wraps the unexported one that runs a single attempt. */
+func (s *Service) retryTx(ctx context.Context, runTx func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := runTx()
+		if err == nil || !errors.Is(err, ErrTxConflict) {
+			return err
+		}
+		if attempt >= s.txConfig.MaxRetries {
+			log.Printf("giving up transaction after %d retries: %v", attempt, err)
+			return ErrResponseTxRetriesExhausted
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(s.txConfig.RetryBaseDelay, attempt)):
+		}
+	}
+}
+
+/* Exponential backoff with full jitter, so transactions that conflicted don't retry at the same moment. */
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	maxDelay := base << attempt
+	if maxDelay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(maxDelay)) + 1)
+}
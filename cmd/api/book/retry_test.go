@@ -0,0 +1,123 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestRetryTx(t *testing.T) {
+	serializableConfig := book.TxConfig{
+		Isolation:      sql.LevelSerializable,
+		MaxRetries:     2,
+		RetryBaseDelay: time.Millisecond,
+	}
+	serializableOpts := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	updtReq := book.UpdateOrderRequest{OrderID: uuid.New(), BookID: uuid.New(), BookUnitsToAdd: 1}
+
+	t.Run("runs the transaction again after a serialization failure, with the configured isolation level", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, serializableConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		serializationFailure := fmt.Errorf("updating order on db: %w", book.ErrTxConflict)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), serializableOpts).Return(mockTxRepo, mockTx, nil).Times(2)
+		gomock.InOrder(
+			mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(serializationFailure),
+			mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(book.ErrResponseOrderNotAcceptingItems), //Not retryable, so it is returned.
+		)
+		mockTx.EXPECT().Rollback().Return(nil).Times(2)
+
+		_, err := mS.UpdateOrderTx(ctx, updtReq)
+		is.True(errors.Is(err, book.ErrResponseOrderNotAcceptingItems))
+	})
+
+	t.Run("expected retries exhausted error after repeated deadlocks", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, serializableConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		deadlock := fmt.Errorf("reserving item at order on db: %w", book.ErrTxConflict)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), serializableOpts).Return(mockTxRepo, mockTx, nil).Times(3) //the first attempt and 2 retries
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(nil).Times(3)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), updtReq.OrderID, updtReq.BookID).Return(book.PurchaseLimitUsage{}, nil).Times(3)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), updtReq.OrderID, updtReq.BookID, 1).Return(book.OrderItem{}, deadlock).Times(3)
+		mockTx.EXPECT().Rollback().Return(nil).Times(3)
+
+		updatedOrder, err := mS.UpdateOrderTx(ctx, updtReq)
+		is.True(errors.Is(err, book.ErrResponseTxRetriesExhausted))
+		is.Equal(updatedOrder, book.Order{})
+	})
+
+	t.Run("other errors from database are not retried", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, serializableConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		uniqueViolation := fmt.Errorf("upserting item at order on db: %w", errors.New("duplicate key value violates unique constraint"))
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), serializableOpts).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(uniqueViolation)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.UpdateOrderTx(ctx, updtReq)
+		is.True(errors.Is(err, uniqueViolation))
+	})
+
+	t.Run("expected context error when canceled while waiting to retry", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		slowRetries := serializableConfig
+		slowRetries.RetryBaseDelay = time.Hour
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, slowRetries, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), serializableOpts).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), updtReq.OrderID).Return(book.ErrTxConflict)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.UpdateOrderTx(ctxTimeout, updtReq)
+		is.True(errors.Is(err, context.DeadlineExceeded))
+	})
+}
@@ -0,0 +1,372 @@
+package book
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+)
+
+type Return struct {
+	ReturnID     uuid.UUID
+	OrderID      uuid.UUID
+	Status       string
+	Reason       string
+	Items        []ReturnItem
+	RefundAmount float32 //set when approved
+	RefundID     string  //given by the payment gateway when approved
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ResolvedAt   *time.Time
+}
+
+type ReturnItem struct {
+	BookID    uuid.UUID
+	BookUnits int
+}
+
+type CreateReturnRequest struct {
+	OrderID uuid.UUID
+	Reason  string
+	Items   []ReturnItem
+}
+
+/* Asks to return books of a paid order, through a transaction. The books only go back to the inventory once an admin approves the return. Users only return books of their own orders. */
+func (s *Service) RequestReturn(ctx context.Context, req CreateReturnRequest) (Return, error) {
+	var requested Return
+	err := s.retryTx(ctx, func() error {
+		var err error
+		requested, err = s.requestReturn(ctx, req)
+		return err
+	})
+	if err != nil {
+		return Return{}, err
+	}
+	return requested, nil
+}
+
+/* Creates the return, with the order locked so its units are only returned once. */
+func (s *Service) requestReturn(ctx context.Context, req CreateReturnRequest) (Return, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = authorizeOrderChange(ctx, txRepo, req.OrderID)
+	if err != nil {
+		return Return{}, err
+	}
+
+	status, err := txRepo.GetOrderStatusForUpdate(ctx, req.OrderID) //Locks the order, so concurrent returns can't return the same units twice.
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to GetOrderStatusForUpdate: %w ", err)
+	}
+	if status != "paid" && status != "delivered" && status != "return_requested" && status != "partially_returned" { //Orders on the way can only have books returned once delivered.
+		return Return{}, ErrResponseOrderNotReturnable
+	}
+
+	order, err := txRepo.ListOrderItems(ctx, req.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+	returns, err := txRepo.ListReturns(ctx, req.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ListReturns: %w ", err)
+	}
+	shipments, err := txRepo.ListShipments(ctx, req.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ListShipments: %w ", err)
+	}
+
+	//Only the units bought and not at other returns can be returned:
+	returnable := map[uuid.UUID]int{}
+	for _, item := range order.Items {
+		returnable[item.BookID] = item.BookUnits
+	}
+	for _, r := range returns {
+		if r.Status == ReturnStatusRejected {
+			continue
+		}
+		for _, item := range r.Items {
+			returnable[item.BookID] -= item.BookUnits
+		}
+	}
+	items := mergeReturnItems(req.Items)
+	for _, item := range items {
+		units, found := returnable[item.BookID]
+		if !found {
+			return Return{}, ErrResponseBookNotAtOrder
+		}
+		if item.BookUnits > units {
+			return Return{}, ErrResponseReturnUnitsExceeded
+		}
+	}
+
+	createdAt := time.Now().UTC().Round(time.Millisecond)
+	requested, err := txRepo.CreateReturn(ctx, Return{
+		ReturnID:  uuid.New(),
+		OrderID:   req.OrderID,
+		Status:    ReturnStatusRequested,
+		Reason:    req.Reason,
+		Items:     items,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	})
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to CreateReturn: %w ", err)
+	}
+
+	err = s.settleOrderReturns(ctx, txRepo, order, append(returns, requested), shipments)
+	if err != nil {
+		return Return{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventReturnRequested, requested)
+	if err != nil {
+		return Return{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return requested, nil
+}
+
+/* Approves a requested return through a transaction: its books go back to the inventory, the same way as when removed from an order, and their price is refunded. */
+func (s *Service) ApproveReturn(ctx context.Context, returnID uuid.UUID) (Return, error) {
+	var approved Return
+	err := s.retryTx(ctx, func() error {
+		var err error
+		approved, err = s.resolveReturn(ctx, returnID, ReturnStatusApproved)
+		return err
+	})
+	if err != nil {
+		return Return{}, err
+	}
+	return approved, nil
+}
+
+/* Rejects a requested return through a transaction. Nothing is restocked nor refunded. */
+func (s *Service) RejectReturn(ctx context.Context, returnID uuid.UUID) (Return, error) {
+	var rejected Return
+	err := s.retryTx(ctx, func() error {
+		var err error
+		rejected, err = s.resolveReturn(ctx, returnID, ReturnStatusRejected)
+		return err
+	})
+	if err != nil {
+		return Return{}, err
+	}
+	return rejected, nil
+}
+
+/* Moves a requested return to status, restocking and refunding it when approved. */
+func (s *Service) resolveReturn(ctx context.Context, returnID uuid.UUID, status string) (Return, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	ret, err := txRepo.GetReturnForUpdate(ctx, returnID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to GetReturnForUpdate: %w ", err)
+	}
+	if ret.Status != ReturnStatusRequested {
+		return Return{}, ErrResponseReturnNotPending
+	}
+
+	_, err = txRepo.GetOrderStatusForUpdate(ctx, ret.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to GetOrderStatusForUpdate: %w ", err)
+	}
+	order, err := txRepo.ListOrderItems(ctx, ret.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	if status == ReturnStatusApproved {
+		for _, item := range ret.Items { //Sorted by book ID, like the changes of an order, so books are locked in the same sequence.
+			bk, err := txRepo.GetBookByIDForUpdate(ctx, item.BookID)
+			if err != nil {
+				return Return{}, fmt.Errorf("error on call to GetBookByIDForUpdate: %w ", err)
+			}
+			err = returnToInventory(ctx, txRepo, bk, item.BookUnits)
+			if err != nil {
+				return Return{}, err
+			}
+		}
+
+		ret.RefundAmount = refundAmount(order, ret.Items)
+		ret.RefundID, err = s.payments.Refund(ctx, Refund{ReturnID: ret.ReturnID, OrderID: ret.OrderID, Amount: ret.RefundAmount})
+		if err != nil {
+			return Return{}, fmt.Errorf("error on call to Refund: %w ", err)
+		}
+	}
+
+	resolvedAt := time.Now().UTC().Round(time.Millisecond)
+	ret.Status = status
+	ret.UpdatedAt = resolvedAt
+	ret.ResolvedAt = &resolvedAt
+	err = txRepo.ResolveReturn(ctx, ret)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ResolveReturn: %w ", err)
+	}
+
+	returns, err := txRepo.ListReturns(ctx, ret.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ListReturns: %w ", err)
+	}
+	shipments, err := txRepo.ListShipments(ctx, ret.OrderID)
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to ListShipments: %w ", err)
+	}
+	err = s.settleOrderReturns(ctx, txRepo, order, returns, shipments)
+	if err != nil {
+		return Return{}, err
+	}
+
+	eventType := EventReturnRejected
+	if status == ReturnStatusApproved {
+		eventType = EventReturnApproved
+	}
+	err = recordEvent(ctx, txRepo, eventType, ret)
+	if err != nil {
+		return Return{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Return{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return ret, nil
+}
+
+/* Returns the returns of an order. Users only see the returns of their own orders; admins see any. */
+func (s *Service) ListReturns(ctx context.Context, orderID uuid.UUID) ([]Return, error) {
+	err := authorizeOrderRead(ctx, s.repo, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	returns, err := s.repo.ListReturns(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListReturns: %w", err)
+	}
+	return returns, nil
+}
+
+/* Sets the status of the order from its returns and shipments, inside the transaction of txRepo, and tells the purchaser about it. */
+func (s *Service) settleOrderReturns(ctx context.Context, txRepo Repository, order Order, returns []Return, shipments []Shipment) error {
+	previousStatus := order.OrderStatus
+	order.OrderStatus = orderStatusFromReturns(order, returns, shipments)
+	err := txRepo.SetOrderStatus(ctx, order.OrderID, order.OrderStatus)
+	if err != nil {
+		return fmt.Errorf("error on call to SetOrderStatus: %w ", err)
+	}
+	if order.OrderStatus != previousStatus { //A second pending return keeps the order as it was.
+		err = recordStatusChange(ctx, txRepo, order.OrderID, previousStatus, order.OrderStatus)
+		if err != nil {
+			return err
+		}
+	}
+	return recordEvent(ctx, txRepo, EventOrderUpdated, order)
+}
+
+/* Tells the status of a paid order from its returns: a pending return comes first, then how many of the units bought were returned. With none returned, the order goes back to the status given by its shipments. */
+func orderStatusFromReturns(order Order, returns []Return, shipments []Shipment) string {
+	returnedUnits := 0
+	for _, r := range returns {
+		switch r.Status {
+		case ReturnStatusRequested:
+			return "return_requested"
+		case ReturnStatusApproved:
+			for _, item := range r.Items {
+				returnedUnits += item.BookUnits
+			}
+		}
+	}
+
+	boughtUnits := 0
+	for _, item := range order.Items {
+		boughtUnits += item.BookUnits
+	}
+
+	switch {
+	case returnedUnits == 0:
+		return fulfillmentStatus(order, shipments)
+	case returnedUnits < boughtUnits:
+		return "partially_returned"
+	default:
+		return "returned"
+	}
+}
+
+/* Calculates how much is paid back for returned items: their prices at order, with their share of the discount and the tax. Shipping is not refunded. */
+func refundAmount(order Order, items []ReturnItem) float32 {
+	if order.Subtotal == 0 {
+		return 0
+	}
+
+	prices := map[uuid.UUID]float32{}
+	for _, item := range order.Items {
+		if item.BookPriceAtOrder != nil {
+			prices[item.BookID] = *item.BookPriceAtOrder
+		}
+	}
+	var returnedValue float32
+	for _, item := range items {
+		returnedValue += prices[item.BookID] * float32(item.BookUnits)
+	}
+
+	share := returnedValue / order.Subtotal
+	return roundCents(share * (order.Subtotal - order.Discount + order.Tax))
+}
+
+/* Sums the units of repeated books and sorts the items by book ID, dropping the ones with no units. */
+func mergeReturnItems(items []ReturnItem) []ReturnItem {
+	unitsByBook := map[uuid.UUID]int{}
+	for _, item := range items {
+		unitsByBook[item.BookID] += item.BookUnits
+	}
+
+	merged := []ReturnItem{}
+	for bookID, units := range unitsByBook {
+		if units <= 0 {
+			continue
+		}
+		merged = append(merged, ReturnItem{BookID: bookID, BookUnits: units})
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return bytes.Compare(merged[i].BookID[:], merged[j].BookID[:]) < 0
+	})
+	return merged
+}
@@ -0,0 +1,246 @@
+package book_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestRequestReturn(t *testing.T) {
+	orderID := uuid.New()
+	bookID := uuid.New()
+	order := book.Order{
+		OrderID:     orderID,
+		OrderStatus: "paid",
+		Subtotal:    60,
+		Items:       []book.OrderItem{{BookID: bookID, BookUnits: 2, BookPriceAtOrder: toPointer(float32(30))}},
+	}
+
+	t.Run("requests a return of part of the units bought", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		req := book.CreateReturnRequest{
+			OrderID: orderID,
+			Reason:  "damaged",
+			Items:   []book.ReturnItem{{BookID: bookID, BookUnits: 1}},
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("paid", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().ListReturns(gomock.Any(), orderID).Return([]book.Return{}, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{}, nil)
+		mockTxRepo.EXPECT().CreateReturn(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, r book.Return) (book.Return, error) {
+			return r, nil
+		})
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), orderID, "return_requested").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, change book.StatusChange) error {
+			is.Equal(change.FromStatus, "paid")
+			is.Equal(change.ToStatus, "return_requested")
+			return nil
+		})
+		eventTypes := []book.EventType{}
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, event book.OutboxEvent) error {
+			eventTypes = append(eventTypes, event.EventType)
+			return nil
+		}).Times(2)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		requested, err := mS.RequestReturn(ctx, req)
+		is.NoErr(err)
+		is.Equal(requested.OrderID, orderID)
+		is.Equal(requested.Status, book.ReturnStatusRequested)
+		is.Equal(requested.Items, []book.ReturnItem{{BookID: bookID, BookUnits: 1}})
+		is.Equal(eventTypes, []book.EventType{book.EventOrderUpdated, book.EventReturnRequested})
+	})
+
+	t.Run("expected order not returnable error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("accepting_items", nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.RequestReturn(ctx, book.CreateReturnRequest{OrderID: orderID, Reason: "damaged", Items: []book.ReturnItem{{BookID: bookID, BookUnits: 1}}})
+		is.True(errors.Is(err, book.ErrResponseOrderNotReturnable))
+	})
+
+	t.Run("expected units exceeded error, counting the units at other returns", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		previous := []book.Return{
+			{Status: book.ReturnStatusApproved, Items: []book.ReturnItem{{BookID: bookID, BookUnits: 1}}},
+			{Status: book.ReturnStatusRejected, Items: []book.ReturnItem{{BookID: bookID, BookUnits: 2}}},
+		}
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("partially_returned", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().ListReturns(gomock.Any(), orderID).Return(previous, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{}, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.RequestReturn(ctx, book.CreateReturnRequest{OrderID: orderID, Reason: "damaged", Items: []book.ReturnItem{{BookID: bookID, BookUnits: 2}}})
+		is.True(errors.Is(err, book.ErrResponseReturnUnitsExceeded))
+	})
+}
+
+func TestResolveReturn(t *testing.T) {
+	orderID := uuid.New()
+	bookID := uuid.New()
+	returnID := uuid.New()
+	order := book.Order{
+		OrderID:     orderID,
+		OrderStatus: "return_requested",
+		Subtotal:    60,
+		Discount:    10,
+		Tax:         9,
+		Shipping:    15,
+		Items:       []book.OrderItem{{BookID: bookID, BookUnits: 2, BookPriceAtOrder: toPointer(float32(30))}},
+	}
+	requested := book.Return{
+		ReturnID: returnID,
+		OrderID:  orderID,
+		Status:   book.ReturnStatusRequested,
+		Reason:   "damaged",
+		Items:    []book.ReturnItem{{BookID: bookID, BookUnits: 1}},
+	}
+
+	t.Run("approves a return, restocking and refunding its books", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		approved := requested
+		approved.Status = book.ReturnStatusApproved
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetReturnForUpdate(gomock.Any(), returnID).Return(requested, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("return_requested", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().GetBookByIDForUpdate(gomock.Any(), bookID).Return(book.Book{ID: bookID, Inventory: toPointer(4)}, nil)
+		mockTxRepo.EXPECT().UpdateBook(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, bk book.Book) (book.Book, error) {
+			is.Equal(*bk.Inventory, 5)
+			return bk, nil
+		})
+		//A half of the subtotal, with a half of the discount and the tax, but no shipping:
+		mockPay.EXPECT().Refund(gomock.Any(), book.Refund{ReturnID: returnID, OrderID: orderID, Amount: 29.5}).Return("refund_1", nil)
+		mockTxRepo.EXPECT().ResolveReturn(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().ListReturns(gomock.Any(), orderID).Return([]book.Return{approved}, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{}, nil)
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), orderID, "partially_returned").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).Return(nil)
+		eventTypes := []book.EventType{}
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, event book.OutboxEvent) error {
+			eventTypes = append(eventTypes, event.EventType)
+			return nil
+		}).Times(2)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		ret, err := mS.ApproveReturn(ctx, returnID)
+		is.NoErr(err)
+		is.Equal(ret.Status, book.ReturnStatusApproved)
+		is.Equal(ret.RefundAmount, float32(29.5))
+		is.Equal(ret.RefundID, "refund_1")
+		is.True(ret.ResolvedAt != nil)
+		is.Equal(eventTypes, []book.EventType{book.EventOrderUpdated, book.EventReturnApproved})
+	})
+
+	t.Run("rejects a return, with no restock nor refund", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		rejected := requested
+		rejected.Status = book.ReturnStatusRejected
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetReturnForUpdate(gomock.Any(), returnID).Return(requested, nil)
+		mockTxRepo.EXPECT().GetOrderStatusForUpdate(gomock.Any(), orderID).Return("return_requested", nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(order, nil)
+		mockTxRepo.EXPECT().ResolveReturn(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().ListReturns(gomock.Any(), orderID).Return([]book.Return{rejected}, nil)
+		mockTxRepo.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{}, nil)
+		mockTxRepo.EXPECT().SetOrderStatus(gomock.Any(), orderID, "paid").Return(nil)
+		mockTxRepo.EXPECT().InsertOrderStatusChange(gomock.Any(), gomock.Any()).Return(nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		ret, err := mS.RejectReturn(ctx, returnID)
+		is.NoErr(err)
+		is.Equal(ret.Status, book.ReturnStatusRejected)
+		is.Equal(ret.RefundAmount, float32(0))
+	})
+
+	t.Run("expected return not pending error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		approved := requested
+		approved.Status = book.ReturnStatusApproved
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().GetReturnForUpdate(gomock.Any(), returnID).Return(approved, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		_, err := mS.ApproveReturn(ctx, returnID)
+		is.True(errors.Is(err, book.ErrResponseReturnNotPending))
+	})
+}
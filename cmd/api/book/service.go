@@ -18,15 +18,65 @@ type ServiceAPI interface {
 	GetBook(ctx context.Context, id uuid.UUID) (Book, error)
 	ListBooks(ctx context.Context, params ListBooksRequest) (PagedBooks, error)
 	CreateOrder(ctx context.Context, user_id uuid.UUID) (Order, error)
+	CreateGuestOrder(ctx context.Context) (Order, CartToken, error)
+	ClaimOrder(ctx context.Context, req ClaimOrderRequest) (Order, error)
 	UpdateBook(ctx context.Context, req UpdateBookRequest) (Book, error)
 	UpdateOrderTx(ctx context.Context, updtReq UpdateOrderRequest) (Order, error)
+	UpdateOrderItemsTx(ctx context.Context, updtReq UpdateOrderItemsRequest) (Order, error)
 	ListOrderItems(ctx context.Context, order_id uuid.UUID) (Order, error)
+	GetIdempotencyKey(ctx context.Context, caller, key string) (IdempotencyKey, error)
+	ReserveIdempotencyKey(ctx context.Context, idemKey IdempotencyKey) error
+	CompleteIdempotencyKey(ctx context.Context, idemKey IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, caller, key string) error
+	CreateCoupon(ctx context.Context, req CreateCouponRequest) (Coupon, error)
+	GetCoupon(ctx context.Context, id uuid.UUID) (Coupon, error)
+	ListCoupons(ctx context.Context) ([]Coupon, error)
+	UpdateCoupon(ctx context.Context, req UpdateCouponRequest) (Coupon, error)
+	DeleteCoupon(ctx context.Context, id uuid.UUID) error
+	ApplyCoupon(ctx context.Context, orderID uuid.UUID, code string) (Order, error)
+	Checkout(ctx context.Context, orderID uuid.UUID) (Order, error)
+	ConfirmPayment(ctx context.Context, orderID uuid.UUID) (Order, error)
+	AddToWishlist(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) (WishlistItem, error)
+	RemoveFromWishlist(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) error
+	ListWishlist(ctx context.Context, userID uuid.UUID) ([]WishlistItem, error)
+	MoveWishlistItemToOrder(ctx context.Context, req MoveWishlistItemRequest) (Order, error)
+	RequestReturn(ctx context.Context, req CreateReturnRequest) (Return, error)
+	ApproveReturn(ctx context.Context, returnID uuid.UUID) (Return, error)
+	RejectReturn(ctx context.Context, returnID uuid.UUID) (Return, error)
+	ListReturns(ctx context.Context, orderID uuid.UUID) ([]Return, error)
+	UpdateOrderDetails(ctx context.Context, req UpdateOrderDetailsRequest) (Order, error)
+	ListOrderHistory(ctx context.Context, orderID uuid.UUID) ([]StatusChange, error)
+	PickShipment(ctx context.Context, req PickShipmentRequest) (Shipment, error)
+	ShipShipment(ctx context.Context, req ShipShipmentRequest) (Shipment, error)
+	DeliverShipment(ctx context.Context, shipmentID uuid.UUID) (Shipment, error)
+	ListShipments(ctx context.Context, orderID uuid.UUID) ([]Shipment, error)
+	CreateUser(ctx context.Context, req CreateUserRequest) (User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (User, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	UpdateUser(ctx context.Context, req UpdateUserRequest) (User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	ExportUserData(ctx context.Context, userID uuid.UUID) (UserDataExport, error)
+	EraseUserData(ctx context.Context, userID uuid.UUID) (UserErasure, error)
+	Login(ctx context.Context, email, password string) (AuthTokens, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (AuthTokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (APIKey, string, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID uuid.UUID) (APIKey, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (Claims, error)
+	CreateWebhook(ctx context.Context, req CreateWebhookRequest) (Webhook, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, req UpdateWebhookRequest) (Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID) ([]WebhookDelivery, error)
 }
 
 type Repository interface {
 	SetBookArchiveStatus(ctx context.Context, id uuid.UUID, archived bool) (Book, error)
 	CreateBook(ctx context.Context, bookEntry Book) (Book, error)
 	GetBookByID(ctx context.Context, id uuid.UUID) (Book, error)
+	GetBookByIDForUpdate(ctx context.Context, id uuid.UUID) (Book, error)
 	ListBooks(ctx context.Context, name string, minPrice32, maxPrice32 float32, sortBy, sortDirection string, archived bool, page, pageSize int) ([]Book, error)
 	ListBooksTotals(ctx context.Context, name string, minPrice32, maxPrice32 float32, archived bool) (int, error)
 	UpdateBook(ctx context.Context, bookEntry Book) (Book, error)
@@ -36,83 +86,263 @@ type Repository interface {
 	GetOrderItem(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID) (OrderItem, error)
 	UpdateOrderRow(ctx context.Context, orderID uuid.UUID) error
 	UpsertOrderItem(ctx context.Context, orderID uuid.UUID, itemToUpdt OrderItem) (OrderItem, error)
+	ReserveOrderItem(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID, units int) (OrderItem, error)
+	GetPurchaseLimitUsage(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID) (PurchaseLimitUsage, error)
 	DeleteOrderItem(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID) error
+	GetIdempotencyKey(ctx context.Context, caller, key string) (IdempotencyKey, error)
+	ReserveIdempotencyKey(ctx context.Context, idemKey IdempotencyKey) error
+	CompleteIdempotencyKey(ctx context.Context, idemKey IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, caller, key string) error
+	PurgeExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error)
+	CreateCoupon(ctx context.Context, newCoupon Coupon) (Coupon, error)
+	GetCouponByID(ctx context.Context, id uuid.UUID) (Coupon, error)
+	GetCouponByCode(ctx context.Context, code string) (Coupon, error)
+	ListCoupons(ctx context.Context) ([]Coupon, error)
+	UpdateCoupon(ctx context.Context, couponEntry Coupon) (Coupon, error)
+	DeleteCoupon(ctx context.Context, id uuid.UUID) error
+	IncrementCouponUsage(ctx context.Context, id uuid.UUID) error
+	DecrementCouponUsage(ctx context.Context, id uuid.UUID) error
+	SetOrderCoupon(ctx context.Context, orderID uuid.UUID, couponID uuid.UUID) error
+	RemoveOrderCoupon(ctx context.Context, orderID uuid.UUID) error
+	CheckoutOrder(ctx context.Context, order Order) error
+	ListAbandonedOrders(ctx context.Context, untouchedSince time.Time) ([]uuid.UUID, error)
+	CancelAbandonedOrder(ctx context.Context, orderID uuid.UUID, untouchedSince time.Time) error
+	RestockBook(ctx context.Context, bookID uuid.UUID, units int) error
+	InsertOutboxEvent(ctx context.Context, event OutboxEvent) error
+	ListPendingOutboxEvents(ctx context.Context, now time.Time, limit int) ([]OutboxEvent, error)
+	UpdateOutboxEvent(ctx context.Context, event OutboxEvent) error
+	PurgeSentOutboxEvents(ctx context.Context, sentBefore time.Time) (int, error)
+	AddWishlistItem(ctx context.Context, userID uuid.UUID, bookID uuid.UUID, addedAt time.Time) (time.Time, error)
+	GetWishlistItem(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) (WishlistItem, error)
+	ListWishlistItems(ctx context.Context, userID uuid.UUID) ([]WishlistItem, error)
+	DeleteWishlistItem(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) error
+	GetOrderStatusForUpdate(ctx context.Context, orderID uuid.UUID) (string, error)
+	GetOpenOrderOfPurchaser(ctx context.Context, purchaserID uuid.UUID) (uuid.UUID, error)
+	SetOrderPurchaser(ctx context.Context, orderID uuid.UUID, purchaserID uuid.UUID) error
+	SetOrderStatus(ctx context.Context, orderID uuid.UUID, status string) error
+	CreateReturn(ctx context.Context, newReturn Return) (Return, error)
+	GetReturnForUpdate(ctx context.Context, returnID uuid.UUID) (Return, error)
+	ListReturns(ctx context.Context, orderID uuid.UUID) ([]Return, error)
+	ResolveReturn(ctx context.Context, ret Return) error
+	SetOrderDetails(ctx context.Context, order Order) error
+	InsertOrderStatusChange(ctx context.Context, change StatusChange) error
+	ListOrderStatusHistory(ctx context.Context, orderID uuid.UUID) ([]StatusChange, error)
+	CreateShipment(ctx context.Context, newShipment Shipment) (Shipment, error)
+	GetShipmentForUpdate(ctx context.Context, shipmentID uuid.UUID) (Shipment, error)
+	ListShipments(ctx context.Context, orderID uuid.UUID) ([]Shipment, error)
+	UpdateShipment(ctx context.Context, shipment Shipment) error
+	CreateUser(ctx context.Context, newUser User) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	UpdateUser(ctx context.Context, userEntry User) (User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	AnonymizeUser(ctx context.Context, userID uuid.UUID, name string, erasedAt time.Time) (User, error)
+	ListOrderIDsOfPurchaser(ctx context.Context, purchaserID uuid.UUID) ([]uuid.UUID, error)
+	AnonymizePurchaserOrders(ctx context.Context, purchaserID uuid.UUID) (int, error)
+	AnonymizeUserEvents(ctx context.Context, userID uuid.UUID, name string) error
+	AnonymizeUserWebhookDeliveries(ctx context.Context, userID uuid.UUID, name string) error
+	DeleteUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+	ClearWishlist(ctx context.Context, userID uuid.UUID) error
+	StoreRefreshToken(ctx context.Context, token RefreshToken) error
+	GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string, revokedAt time.Time) error
+	CreateAPIKey(ctx context.Context, newKey APIKey) (APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID uuid.UUID, revokedAt time.Time) (APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time) error
+	CreateWebhook(ctx context.Context, newWebhook Webhook) (Webhook, error)
+	GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook Webhook) (Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	EnqueueWebhookDeliveries(ctx context.Context, delivery WebhookDelivery) (int, error)
+	ListPendingWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error)
 }
 
+/* Tells the world about events of the service. Event types a Notifier has nothing to say about are skipped, returning nil. */
 type Notifier interface {
-	BookCreated(ctx context.Context, createdBook Book) error
+	Notify(ctx context.Context, event Event) error
 }
 
 type Service struct {
 	repo                 Repository
 	ntf                  Notifier
+	calc                 PriceCalculator
+	payments             PaymentGateway
+	webhooks             WebhookSender
 	notificationsTimeout time.Duration
+	txConfig             TxConfig
+	authConfig           AuthConfig
 }
 
-func NewService(repo Repository, ntf Notifier, notificationsTimeout time.Duration) *Service {
+func NewService(repo Repository, ntf Notifier, calc PriceCalculator, payments PaymentGateway, webhooks WebhookSender, notificationsTimeout time.Duration, txConfig TxConfig, authConfig AuthConfig) *Service {
 	return &Service{
 		repo:                 repo,
 		ntf:                  ntf,
+		calc:                 calc,
+		payments:             payments,
+		webhooks:             webhooks,
 		notificationsTimeout: notificationsTimeout,
+		txConfig:             txConfig,
+		authConfig:           authConfig,
 	}
 }
 
 func (s *Service) ArchiveBook(ctx context.Context, id uuid.UUID) (Book, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Book{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
 	archived := true
-	return s.repo.SetBookArchiveStatus(ctx, id, archived)
+	b, err := txRepo.SetBookArchiveStatus(ctx, id, archived)
+	if err != nil {
+		return Book{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventBookArchived, b)
+	if err != nil {
+		return Book{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Book{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return b, nil
 }
 
 type CreateBookRequest struct {
-	Name      string
-	Price     *float32
-	Inventory *int
+	Name                 string
+	Price                *float32
+	Inventory            *int
+	MaxUnitsPerOrder     *int
+	MaxUnitsPerPurchaser *int
 }
 
 func (s *Service) CreateBook(ctx context.Context, req CreateBookRequest) (Book, error) {
 	createdAt := time.Now().UTC().Round(time.Millisecond) //Atribute creating and updating time to the new entry. UpdateAt can change later.
 	newBook := Book{
-		ID:        uuid.New(), //Atribute an ID to the entry
-		Name:      req.Name,
-		Price:     req.Price,
-		Inventory: req.Inventory,
-		CreatedAt: createdAt,
-		UpdatedAt: createdAt,
+		ID:                   uuid.New(), //Atribute an ID to the entry
+		Name:                 req.Name,
+		Price:                req.Price,
+		Inventory:            req.Inventory,
+		MaxUnitsPerOrder:     req.MaxUnitsPerOrder,
+		MaxUnitsPerPurchaser: req.MaxUnitsPerPurchaser,
+		CreatedAt:            createdAt,
+		UpdatedAt:            createdAt,
 		//Archived is set to false by defalut inside database
 	}
 
-	b, err := s.repo.CreateBook(ctx, newBook)
-	if err == nil {
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), s.notificationsTimeout)
-			defer cancel()
-			err := s.ntf.BookCreated(ctx, newBook)
-			if err != nil {
-				log.Println(err)
-			}
-		}()
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Book{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	b, err := txRepo.CreateBook(ctx, newBook)
+	if err != nil {
+		return Book{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventBookCreated, b) //The notification is sent later by the outbox dispatcher.
+	if err != nil {
+		return Book{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Book{}, fmt.Errorf("error on call to Commit: %w ", err)
 	}
-	return b, err
+	return b, nil
 }
 
 type UpdateBookRequest struct {
-	ID        uuid.UUID
-	Name      string
-	Price     *float32
-	Inventory *int
+	ID                   uuid.UUID
+	Name                 string
+	Price                *float32
+	Inventory            *int
+	MaxUnitsPerOrder     *int
+	MaxUnitsPerPurchaser *int
 }
 
 func (s *Service) UpdateBook(ctx context.Context, req UpdateBookRequest) (Book, error) {
 	updatedAt := time.Now().UTC().Round(time.Millisecond) //Atribute a new updating time to the new entry.
 	updateBook := Book{
-		ID:        req.ID,
-		Name:      req.Name,
-		Price:     req.Price,
-		Inventory: req.Inventory,
+		ID:                   req.ID,
+		Name:                 req.Name,
+		Price:                req.Price,
+		Inventory:            req.Inventory,
+		MaxUnitsPerOrder:     req.MaxUnitsPerOrder,
+		MaxUnitsPerPurchaser: req.MaxUnitsPerPurchaser,
 		//CreatedAt will not change
 		UpdatedAt: updatedAt,
 		//Archived will not change
 	}
-	return s.repo.UpdateBook(ctx, updateBook)
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Book{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	previous, err := txRepo.GetBookByIDForUpdate(ctx, req.ID) //locks the book, so the inventory read is the one replaced
+	if err != nil {
+		return Book{}, err
+	}
+
+	b, err := txRepo.UpdateBook(ctx, updateBook)
+	if err != nil {
+		return Book{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventBookUpdated, b)
+	if err != nil {
+		return Book{}, err
+	}
+
+	if !sameInventory(previous.Inventory, b.Inventory) {
+		err = recordEvent(ctx, txRepo, EventBookInventoryChanged, InventoryChange{BookID: b.ID, BookName: b.Name, PreviousInventory: previous.Inventory, Inventory: b.Inventory})
+		if err != nil {
+			return Book{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Book{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return b, nil
+}
+
+func sameInventory(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
 func (s *Service) GetBook(ctx context.Context, id uuid.UUID) (Book, error) {
@@ -0,0 +1,152 @@
+package book
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* The claims of an access token: who the user is, its role, and until when the token is valid. */
+type Claims struct {
+	UserID    uuid.UUID `json:"sub"`
+	Role      string    `json:"role"`
+	IssuedAt  int64     `json:"iat"` //unix seconds
+	ExpiresAt int64     `json:"exp"` //unix seconds
+
+	APIKeyID    uuid.UUID    `json:"-"` //set when the caller sent an API key, not an access token
+	Scopes      []Permission `json:"-"` //what the API key is limited to
+	CartOrderID uuid.UUID    `json:"-"` //set when the caller sent a cart token: the guest order it opens
+}
+
+/* The claims of a cart token: the guest order it opens, and until when the token is valid. */
+type CartClaims struct {
+	OrderID   uuid.UUID `json:"cart"`
+	ExpiresAt int64     `json:"exp"` //unix seconds
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+var encodedJWTHeader = mustEncodeSegment(jwtHeader{Alg: "HS256", Typ: "JWT"})
+
+/* Signs the claims as a JWT with HMAC-SHA256. */
+func SignAccessToken(key []byte, claims Claims) (string, error) {
+	payload, err := encodeSegment(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding access token claims: %w", err)
+	}
+	unsigned := encodedJWTHeader + "." + payload
+	return unsigned + "." + signSegment(key, unsigned), nil
+}
+
+/* Checks the signature and expiration of a JWT signed by SignAccessToken and returns its claims. Any other algorithm, 'none' included, is refused. */
+func ParseAccessToken(key []byte, token string, now time.Time) (Claims, error) {
+	var claims Claims
+	err := verifyToken(key, token, &claims)
+	if err != nil || claims.UserID == uuid.Nil {
+		return Claims{}, ErrResponseAccessTokenInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrResponseAccessTokenInvalid
+	}
+
+	return claims, nil
+}
+
+/* Signs the claims of a cart as a JWT with HMAC-SHA256. Its payload has no 'sub', so it is never taken as an access token. */
+func SignCartToken(key []byte, claims CartClaims) (string, error) {
+	payload, err := encodeSegment(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding cart token claims: %w", err)
+	}
+	unsigned := encodedJWTHeader + "." + payload
+	return unsigned + "." + signSegment(key, unsigned), nil
+}
+
+/* Checks the signature and expiration of a JWT signed by SignCartToken and returns its claims. */
+func ParseCartToken(key []byte, token string, now time.Time) (CartClaims, error) {
+	var claims CartClaims
+	err := verifyToken(key, token, &claims)
+	if err != nil || claims.OrderID == uuid.Nil {
+		return CartClaims{}, ErrResponseCartTokenInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return CartClaims{}, ErrResponseCartTokenInvalid
+	}
+
+	return claims, nil
+}
+
+/* Checks the algorithm and signature of a JWT, then decodes its payload into v. */
+func verifyToken(key []byte, token string, v any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil || header.Alg != "HS256" {
+		return errors.New("unexpected token header")
+	}
+
+	expected := signSegment(key, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return errors.New("wrong token signature")
+	}
+
+	return decodeSegment(parts[1], v)
+}
+
+type claimsKey struct{}
+
+/* Returns a copy of ctx carrying the claims of the authenticated user. */
+func ContextWithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+/* Returns the claims of the authenticated user, if the request had a valid access token. */
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+func signSegment(key []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func encodeSegment(v any) (string, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func mustEncodeSegment(v any) string {
+	segment, err := encodeSegment(v)
+	if err != nil {
+		panic(err)
+	}
+	return segment
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,181 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+type User struct {
+	UserID       uuid.UUID
+	Name         string
+	Role         string
+	Email        string //used to log in, unique among users
+	PasswordHash string //bcrypt hash, blank for users that can't log in
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type CreateUserRequest struct {
+	Name     string
+	Role     string
+	Email    string
+	Password string
+}
+
+func (s *Service) CreateUser(ctx context.Context, req CreateUserRequest) (User, error) {
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return User{}, err
+	}
+
+	createdAt := time.Now().UTC().Round(time.Millisecond)
+	newUser := User{
+		UserID:       uuid.New(),
+		Name:         req.Name,
+		Role:         req.Role,
+		Email:        req.Email,
+		PasswordHash: passwordHash,
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	u, err := txRepo.CreateUser(ctx, newUser)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventUserCreated, withoutPasswordHash(u))
+	if err != nil {
+		return User{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return User{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return u, nil
+}
+
+func (s *Service) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	return s.repo.GetUserByID(ctx, id)
+}
+
+func (s *Service) ListUsers(ctx context.Context) ([]User, error) {
+	users, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListUsers: %w", err)
+	}
+	return users, nil
+}
+
+type UpdateUserRequest struct {
+	UserID   uuid.UUID
+	Name     string
+	Role     string
+	Email    string
+	Password string //the current password is kept when blank
+}
+
+func (s *Service) UpdateUser(ctx context.Context, req UpdateUserRequest) (User, error) {
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return User{}, err
+	}
+
+	updateUser := User{
+		UserID:       req.UserID,
+		Name:         req.Name,
+		Role:         req.Role,
+		Email:        req.Email,
+		PasswordHash: passwordHash,
+		//CreatedAt will not change
+		UpdatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	u, err := txRepo.UpdateUser(ctx, updateUser)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = recordEvent(ctx, txRepo, EventUserUpdated, withoutPasswordHash(u))
+	if err != nil {
+		return User{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return User{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return u, nil
+}
+
+/* Deletes a user. Users with orders are kept, so the orders keep their purchaser. */
+func (s *Service) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = txRepo.DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, txRepo, EventUserDeleted, DeletedResource{ID: id})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return nil
+}
+
+/* The user as told at events, that leave its password hash out. */
+func withoutPasswordHash(u User) User {
+	u.PasswordHash = ""
+	return u
+}
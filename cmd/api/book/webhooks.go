@@ -0,0 +1,334 @@
+package book
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/* A subscription of a partner endpoint to events of the service. Each event of the types subscribed is posted to URL, signed with Secret. */
+type Webhook struct {
+	WebhookID  uuid.UUID
+	URL        string
+	EventTypes []EventType
+	Secret     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" //given up after WebhookMaxAttempts
+)
+
+/* How many times a delivery is posted before it is given up. The attempts are spaced by a doubling delay, from a minute. */
+const WebhookMaxAttempts = 8
+
+/* How many deliveries of a webhook its log shows, the newest first. */
+const webhookDeliveriesShown = 100
+
+/* An event to be posted, or already posted, to a webhook, with the outcome of its last attempt. The deliveries of a webhook are its delivery log. */
+type WebhookDelivery struct {
+	DeliveryID     uuid.UUID
+	WebhookID      uuid.UUID
+	EventID        uuid.UUID
+	EventType      EventType
+	Payload        []byte //JSON of the Event envelope, as posted
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode *int //nil while no endpoint answered
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+/* Posts deliveries to the URL of their webhook. Returns the status code answered, if any; answers out of 2xx are errors. */
+type WebhookSender interface {
+	Send(ctx context.Context, webhook Webhook, delivery WebhookDelivery) (statusCode int, err error)
+}
+
+type CreateWebhookRequest struct {
+	URL        string
+	EventTypes []EventType
+	Secret     string //a random one is generated when blank
+}
+
+/* Subscribes an endpoint to the event types asked. The secret is only shown here; later reads leave it out. */
+func (s *Service) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (Webhook, error) {
+	secret := req.Secret
+	if secret == "" {
+		random := make([]byte, 32)
+		_, err := rand.Read(random)
+		if err != nil {
+			return Webhook{}, fmt.Errorf("generating webhook secret: %w", err)
+		}
+		secret = "whsec_" + base64.RawURLEncoding.EncodeToString(random)
+	}
+
+	createdAt := time.Now().UTC().Round(time.Millisecond)
+	newWebhook := Webhook{
+		WebhookID:  uuid.New(),
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     secret,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	wh, err := txRepo.CreateWebhook(ctx, newWebhook)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to CreateWebhook: %w", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventWebhookCreated, withoutSecret(wh))
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return wh, nil
+}
+
+func (s *Service) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	wh, err := s.repo.GetWebhookByID(ctx, id)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to GetWebhookByID: %w", err)
+	}
+	return withoutSecret(wh), nil
+}
+
+func (s *Service) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	webhooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListWebhooks: %w", err)
+	}
+	for i := range webhooks {
+		webhooks[i] = withoutSecret(webhooks[i])
+	}
+	return webhooks, nil
+}
+
+type UpdateWebhookRequest struct {
+	WebhookID  uuid.UUID
+	URL        string
+	EventTypes []EventType
+	Secret     string //the current secret is kept when blank
+}
+
+/* Changes the URL and event types of a webhook, and its secret when one is given. Deliveries already pending go to the new URL. */
+func (s *Service) UpdateWebhook(ctx context.Context, req UpdateWebhookRequest) (Webhook, error) {
+	updateWebhook := Webhook{
+		WebhookID:  req.WebhookID,
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     req.Secret,
+		//CreatedAt will not change
+		UpdatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	wh, err := txRepo.UpdateWebhook(ctx, updateWebhook)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to UpdateWebhook: %w", err)
+	}
+	wh = withoutSecret(wh)
+
+	err = recordEvent(ctx, txRepo, EventWebhookUpdated, wh)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Webhook{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return wh, nil
+}
+
+/* Deletes a webhook with its delivery log. Deliveries still pending are dropped. */
+func (s *Service) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = txRepo.DeleteWebhook(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error on call to DeleteWebhook: %w", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventWebhookDeleted, DeletedResource{ID: id})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return nil
+}
+
+/* Returns the delivery log of a webhook: its latest deliveries, the newest first. */
+func (s *Service) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID) ([]WebhookDelivery, error) {
+	_, err := s.repo.GetWebhookByID(ctx, webhookID) //The log of an unknown webhook is not found, rather than empty.
+	if err != nil {
+		return nil, fmt.Errorf("error on call to GetWebhookByID: %w", err)
+	}
+
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, webhookID, webhookDeliveriesShown)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListWebhookDeliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+/* Queues a delivery of the event to each webhook subscribed to its type, inside the transaction of txRepo. An event dispatched again is not queued twice. */
+func enqueueWebhookDeliveries(ctx context.Context, txRepo Repository, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", event.Type, err)
+	}
+
+	queuedAt := time.Now().UTC().Round(time.Millisecond)
+	_, err = txRepo.EnqueueWebhookDeliveries(ctx, WebhookDelivery{
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       body,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: queuedAt,
+		CreatedAt:     queuedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error on call to EnqueueWebhookDeliveries: %w ", err)
+	}
+	return nil
+}
+
+/* Posts a batch of the deliveries due through the WebhookSender, logging the outcome of each attempt. Failed deliveries are tried again later, until WebhookMaxAttempts. Returns how many deliveries were delivered. */
+func (s *Service) DeliverWebhooks(ctx context.Context, batchSize int) (int, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	deliveries, err := txRepo.ListPendingWebhookDeliveries(ctx, time.Now().UTC(), batchSize) //locks the deliveries, so other instances of the service skip them
+	if err != nil {
+		return 0, fmt.Errorf("error on call to ListPendingWebhookDeliveries: %w ", err)
+	}
+
+	webhooks := map[uuid.UUID]Webhook{}
+	delivered := 0
+	for _, delivery := range deliveries {
+		wh, found := webhooks[delivery.WebhookID]
+		if !found {
+			wh, err = txRepo.GetWebhookByID(ctx, delivery.WebhookID)
+			if err != nil {
+				return delivered, fmt.Errorf("error on call to GetWebhookByID: %w ", err)
+			}
+			webhooks[wh.WebhookID] = wh
+		}
+
+		delivery = s.attemptDelivery(ctx, wh, delivery)
+		err = txRepo.UpdateWebhookDelivery(ctx, delivery)
+		if err != nil {
+			return delivered, fmt.Errorf("error on call to UpdateWebhookDelivery: %w ", err)
+		}
+		if delivery.Status == WebhookDeliveryDelivered {
+			delivered++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return delivered, nil
+}
+
+/* Posts the delivery once and returns it with the outcome: delivered, due again after a delay, or given up. */
+func (s *Service) attemptDelivery(ctx context.Context, wh Webhook, delivery WebhookDelivery) WebhookDelivery {
+	ctx, cancel := context.WithTimeout(ctx, s.notificationsTimeout)
+	defer cancel()
+
+	statusCode, sendErr := s.webhooks.Send(ctx, wh, delivery)
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = nil
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	if sendErr == nil {
+		delivery.Status = WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return delivery
+	}
+
+	log.Printf("delivering webhook delivery %v: %v", delivery.DeliveryID, sendErr)
+	delivery.LastError = sendErr.Error()
+	if delivery.Attempts >= WebhookMaxAttempts {
+		delivery.Status = WebhookDeliveryFailed
+		return delivery
+	}
+	delivery.NextAttemptAt = now.Add(time.Minute << (delivery.Attempts - 1))
+	return delivery
+}
+
+/* The webhook as told at events and reads, that leave its secret out. */
+func withoutSecret(wh Webhook) Webhook {
+	wh.Secret = ""
+	return wh
+}
@@ -0,0 +1,162 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	req := book.CreateWebhookRequest{URL: "https://partner.example.com/hooks", EventTypes: []book.EventType{book.EventOrderUpdated}}
+
+	t.Run("subscribes an endpoint with a generated secret, recording an event with no secret", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, wh book.Webhook) (book.Webhook, error) {
+			is.True(wh.WebhookID != uuid.Nil)
+			is.Equal(wh.URL, req.URL)
+			is.True(strings.HasPrefix(wh.Secret, "whsec_"))
+			return wh, nil
+		})
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventWebhookCreated)
+			var payload book.Webhook
+			is.NoErr(json.Unmarshal(event.Payload, &payload))
+			is.Equal(payload.URL, req.URL)
+			is.Equal(payload.Secret, "") //the secret is not told at events
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		created, err := mS.CreateWebhook(ctx, req)
+		is.NoErr(err)
+		is.True(created.Secret != "") //Only shown at creation.
+	})
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	webhook := book.Webhook{WebhookID: uuid.New(), URL: "https://partner.example.com/hooks", Secret: "whsec_test_secret_of_the_partner"}
+
+	t.Run("posts the deliveries due, logging the outcome of each one", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		deliveries := []book.WebhookDelivery{
+			{DeliveryID: uuid.New(), WebhookID: webhook.WebhookID, EventType: book.EventOrderUpdated, Status: book.WebhookDeliveryPending},
+			{DeliveryID: uuid.New(), WebhookID: webhook.WebhookID, EventType: book.EventOrderUpdated, Status: book.WebhookDeliveryPending, Attempts: 2},
+			{DeliveryID: uuid.New(), WebhookID: webhook.WebhookID, EventType: book.EventOrderUpdated, Status: book.WebhookDeliveryPending, Attempts: book.WebhookMaxAttempts - 1},
+		}
+		sendErr := book.NewErrWebhookFailed(503)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListPendingWebhookDeliveries(gomock.Any(), gomock.Any(), 10).Return(deliveries, nil)
+		mockTxRepo.EXPECT().GetWebhookByID(gomock.Any(), webhook.WebhookID).Return(webhook, nil) //Once for all its deliveries.
+		gomock.InOrder(
+			mockHooks.EXPECT().Send(gomock.Any(), webhook, deliveries[0]).Return(204, nil),
+			mockHooks.EXPECT().Send(gomock.Any(), webhook, deliveries[1]).Return(503, sendErr),
+			mockHooks.EXPECT().Send(gomock.Any(), webhook, deliveries[2]).Return(0, errors.New("connection refused")),
+		)
+		gomock.InOrder(
+			mockTxRepo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, d book.WebhookDelivery) error {
+				is.Equal(d.Status, book.WebhookDeliveryDelivered)
+				is.Equal(d.Attempts, 1)
+				is.Equal(*d.LastStatusCode, 204)
+				is.True(d.DeliveredAt != nil)
+				return nil
+			}),
+			mockTxRepo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, d book.WebhookDelivery) error {
+				is.Equal(d.Status, book.WebhookDeliveryPending)
+				is.Equal(d.Attempts, 3)
+				is.Equal(*d.LastStatusCode, 503)
+				is.Equal(d.LastError, sendErr.Error())
+				retryIn := time.Until(d.NextAttemptAt)
+				is.True(retryIn > 3*time.Minute && retryIn <= 4*time.Minute+time.Millisecond) //The third attempt waits four minutes, from a time rounded to milliseconds.
+				return nil
+			}),
+			mockTxRepo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, d book.WebhookDelivery) error {
+				is.Equal(d.Status, book.WebhookDeliveryFailed)
+				is.Equal(d.Attempts, book.WebhookMaxAttempts)
+				is.True(d.LastStatusCode == nil)
+				is.Equal(d.LastError, "connection refused")
+				return nil
+			}),
+		)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		delivered, err := mS.DeliverWebhooks(ctx, 10)
+		is.NoErr(err)
+		is.Equal(delivered, 1)
+	})
+
+	t.Run("expected error from database", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		dbErr := errors.New("fake error from database")
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListPendingWebhookDeliveries(gomock.Any(), gomock.Any(), 10).Return(nil, dbErr)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		delivered, err := mS.DeliverWebhooks(ctx, 10)
+		is.True(errors.Is(err, dbErr))
+		is.Equal(delivered, 0)
+	})
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	t.Run("expected webhook not found error for an unknown webhook", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		webhookID := uuid.New()
+		mockRepo.EXPECT().GetWebhookByID(gomock.Any(), webhookID).Return(book.Webhook{}, book.ErrResponseWebhookNotFound)
+
+		_, err := mS.ListWebhookDeliveries(ctx, webhookID)
+		is.True(errors.Is(err, book.ErrResponseWebhookNotFound))
+	})
+}
@@ -0,0 +1,173 @@
+package book
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WishlistItem struct {
+	Book    Book //Read from bookstable, so its current price and inventory are shown.
+	AddedAt time.Time
+}
+
+/* Adds a book to the wishlist of a user. Adding a book that is already there changes nothing. */
+func (s *Service) AddToWishlist(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) (WishlistItem, error) {
+	bk, err := s.repo.GetBookByID(ctx, bookID)
+	if err != nil {
+		return WishlistItem{}, fmt.Errorf("error on call to GetBookByID: %w", err)
+	}
+	if bk.Archived {
+		return WishlistItem{}, ErrResponseBookIsArchived
+	}
+
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return WishlistItem{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	addedAt, err := txRepo.AddWishlistItem(ctx, userID, bookID, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return WishlistItem{}, fmt.Errorf("error on call to AddWishlistItem: %w", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventWishlistItemAdded, WishlistChange{UserID: userID, BookID: bookID})
+	if err != nil {
+		return WishlistItem{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return WishlistItem{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return WishlistItem{Book: bk, AddedAt: addedAt}, nil
+}
+
+func (s *Service) RemoveFromWishlist(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) error {
+	txRepo, tx, err := s.repo.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	err = txRepo.DeleteWishlistItem(ctx, userID, bookID)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, txRepo, EventWishlistItemRemoved, WishlistChange{UserID: userID, BookID: bookID})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on call to Commit: %w ", err)
+	}
+	return nil
+}
+
+func (s *Service) ListWishlist(ctx context.Context, userID uuid.UUID) ([]WishlistItem, error) {
+	items, err := s.repo.ListWishlistItems(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error on call to ListWishlistItems: %w", err)
+	}
+	return items, nil
+}
+
+type MoveWishlistItemRequest struct {
+	UserID    uuid.UUID
+	BookID    uuid.UUID
+	OrderID   uuid.UUID
+	BookUnits int
+}
+
+/* Adds a book from the wishlist of a user to one of its open orders, checking inventory and purchase limits as UpdateOrderTx does. The book leaves the wishlist in the same transaction, so it is either at the order or still at the wishlist. */
+func (s *Service) MoveWishlistItemToOrder(ctx context.Context, req MoveWishlistItemRequest) (Order, error) {
+	var updatedOrder Order
+	err := s.retryTx(ctx, func() error {
+		var err error
+		updatedOrder, err = s.moveWishlistItemToOrder(ctx, req)
+		return err
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return updatedOrder, nil
+}
+
+/* Reserves the book at the order and drops it from the wishlist. */
+func (s *Service) moveWishlistItemToOrder(ctx context.Context, req MoveWishlistItemRequest) (Order, error) {
+	txRepo, tx, err := s.repo.BeginTx(ctx, s.txOptions())
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to BeginTx: %w ", err)
+	}
+
+	defer func() {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			log.Println(rollbackErr)
+		}
+	}()
+
+	order, err := txRepo.ListOrderItems(ctx, req.OrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w", err)
+	}
+	if order.PurchaserID != req.UserID { //Orders of other users are not revealed.
+		return Order{}, ErrResponseOrderNotFound
+	}
+
+	err = txRepo.DeleteWishlistItem(ctx, req.UserID, req.BookID) //Deleted first, so concurrent moves of the same book wait for each other and only one of them adds it.
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to DeleteWishlistItem: %w", err)
+	}
+
+	err = txRepo.UpdateOrderRow(ctx, req.OrderID) //changes field 'updated_at' and checks if the order is 'accepting_items'
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to UpdateOrderRow: %w ", err)
+	}
+
+	err = updateOrderItem(ctx, txRepo, req.OrderID, req.BookID, req.BookUnits)
+	if err != nil {
+		return Order{}, err
+	}
+
+	updatedOrder, err := txRepo.ListOrderItems(ctx, req.OrderID)
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to ListOrderItems: %w ", err)
+	}
+
+	err = recordEvent(ctx, txRepo, EventOrderUpdated, updatedOrder)
+	if err != nil {
+		return Order{}, err
+	}
+	err = recordEvent(ctx, txRepo, EventWishlistItemRemoved, WishlistChange{UserID: req.UserID, BookID: req.BookID})
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Order{}, fmt.Errorf("error on call to Commit: %w ", err)
+	}
+
+	return updatedOrder, nil
+}
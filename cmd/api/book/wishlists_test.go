@@ -0,0 +1,184 @@
+package book_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestAddToWishlist(t *testing.T) {
+	userID := uuid.New()
+	bk := book.Book{ID: uuid.New(), Name: "Wished book", Price: toPointer(float32(30)), Inventory: toPointer(5)}
+
+	t.Run("adds a book to the wishlist", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		addedAt := time.Now().UTC().Round(time.Millisecond)
+		mockRepo.EXPECT().GetBookByID(gomock.Any(), bk.ID).Return(bk, nil)
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().AddWishlistItem(gomock.Any(), userID, bk.ID, gomock.Any()).Return(addedAt, nil)
+		mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+			is.Equal(event.EventType, book.EventWishlistItemAdded)
+			var payload book.WishlistChange
+			is.NoErr(json.Unmarshal(event.Payload, &payload))
+			is.Equal(payload, book.WishlistChange{UserID: userID, BookID: bk.ID})
+			return nil
+		})
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Rollback().Return(sql.ErrTxDone)
+
+		item, err := mS.AddToWishlist(ctx, userID, bk.ID)
+		is.NoErr(err)
+		is.Equal(item, book.WishlistItem{Book: bk, AddedAt: addedAt})
+	})
+
+	t.Run("expected archived book error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+
+		archivedBook := bk
+		archivedBook.Archived = true
+		mockRepo.EXPECT().GetBookByID(gomock.Any(), bk.ID).Return(archivedBook, nil)
+
+		item, err := mS.AddToWishlist(ctx, userID, bk.ID)
+		is.True(errors.Is(err, book.ErrResponseBookIsArchived))
+		is.Equal(item, book.WishlistItem{})
+	})
+}
+
+func TestMoveWishlistItemToOrder(t *testing.T) {
+	req := book.MoveWishlistItemRequest{UserID: uuid.New(), BookID: uuid.New(), OrderID: uuid.New(), BookUnits: 2}
+
+	t.Run("adds the book to the order and removes it from the wishlist in the same transaction", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		updatedOrder := book.Order{OrderID: req.OrderID, PurchaserID: req.UserID, Items: []book.OrderItem{{BookID: req.BookID, BookUnits: 2}}}
+
+		gomock.InOrder(
+			mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil),
+			mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), req.OrderID).Return(book.Order{OrderID: req.OrderID, PurchaserID: req.UserID}, nil),
+			mockTxRepo.EXPECT().DeleteWishlistItem(gomock.Any(), req.UserID, req.BookID).Return(nil),
+			mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), req.OrderID).Return(nil),
+			mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), req.OrderID, req.BookID).Return(book.PurchaseLimitUsage{}, nil),
+			mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), req.OrderID, req.BookID, 2).Return(updatedOrder.Items[0], nil),
+			mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), req.OrderID).Return(updatedOrder, nil),
+			mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.EventType, book.EventOrderUpdated)
+				return nil
+			}),
+			mockTxRepo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, event book.OutboxEvent) error {
+				is.Equal(event.EventType, book.EventWishlistItemRemoved)
+				return nil
+			}),
+			mockTx.EXPECT().Commit().Return(nil),
+			mockTx.EXPECT().Rollback().Return(sql.ErrTxDone),
+		)
+
+		order, err := mS.MoveWishlistItemToOrder(ctx, req)
+		is.NoErr(err)
+		is.Equal(order, updatedOrder)
+	})
+
+	t.Run("expected order not found error from an order of another user", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), req.OrderID).Return(book.Order{OrderID: req.OrderID, PurchaserID: uuid.New()}, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		order, err := mS.MoveWishlistItemToOrder(ctx, req)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+		is.Equal(order, book.Order{})
+	})
+
+	t.Run("expected book not at wishlist error", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), req.OrderID).Return(book.Order{OrderID: req.OrderID, PurchaserID: req.UserID}, nil)
+		mockTxRepo.EXPECT().DeleteWishlistItem(gomock.Any(), req.UserID, req.BookID).Return(fmt.Errorf("deleting wishlist item on db: %w", book.ErrResponseBookNotAtWishlist))
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		order, err := mS.MoveWishlistItemToOrder(ctx, req)
+		is.True(errors.Is(err, book.ErrResponseBookNotAtWishlist))
+		is.Equal(order, book.Order{})
+	})
+
+	t.Run("keeps the book at the wishlist when the order can't take it, rolling back its removal", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockRepo := bookmock.NewMockRepository(ctrl)
+		mockNtfy := bookmock.NewMockNotifier(ctrl)
+		mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+		mockPay := bookmock.NewMockPaymentGateway(ctrl)
+		mockHooks := bookmock.NewMockWebhookSender(ctrl)
+		mS := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, notificationsTimeout, txConfig, authConfig)
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), req.OrderID).Return(book.Order{OrderID: req.OrderID, PurchaserID: req.UserID}, nil)
+		mockTxRepo.EXPECT().DeleteWishlistItem(gomock.Any(), req.UserID, req.BookID).Return(nil)
+		mockTxRepo.EXPECT().UpdateOrderRow(gomock.Any(), req.OrderID).Return(nil)
+		mockTxRepo.EXPECT().GetPurchaseLimitUsage(gomock.Any(), req.OrderID, req.BookID).Return(book.PurchaseLimitUsage{}, nil)
+		mockTxRepo.EXPECT().ReserveOrderItem(gomock.Any(), req.OrderID, req.BookID, 2).Return(book.OrderItem{}, fmt.Errorf("reserving item at order on db: %w", book.ErrResponseInsufficientInventory))
+		mockTx.EXPECT().Rollback().Return(nil) //No commit, so the book is still at the wishlist.
+
+		order, err := mS.MoveWishlistItemToOrder(ctx, req)
+		is.True(errors.Is(err, book.ErrResponseInsufficientInventory))
+		is.Equal(order, book.Order{})
+	})
+}
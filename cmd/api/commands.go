@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/books-service/cmd/api/book"
+	bookhttp "github.com/books-service/cmd/api/http"
+	"github.com/google/uuid"
+)
+
+const commandsUsage = `usage:
+  api                        serves the http api
+  api export-user <user-id>  prints the personal data of the user as json
+  api erase-user <user-id>   anonymizes the user and the personal fields of its orders`
+
+/* Runs a maintenance command given at the command line, instead of serving the api. The results are written to out as json, as the admin endpoints give them. */
+func runCommand(ctx context.Context, bookService *book.Service, args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New(commandsUsage)
+	}
+	userID, err := uuid.Parse(args[1])
+	if err != nil {
+		return fmt.Errorf("parsing user id: %w", err)
+	}
+
+	var result any
+	switch args[0] {
+	case "export-user":
+		export, err := bookService.ExportUserData(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("exporting user data: %w", err)
+		}
+		result = bookhttp.UserDataExportToResponse(export)
+	case "erase-user":
+		erasure, err := bookService.EraseUserData(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("erasing user data: %w", err)
+		}
+		result = bookhttp.UserErasureToResponse(erasure)
+	default:
+		return errors.New(commandsUsage)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -13,9 +14,9 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/google/uuid"
 
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/lib/pq"
 
-	_ "github.com/lib/pq"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 type DBTX interface {
@@ -45,6 +46,47 @@ func NewExc(dbtx DBTX) *Exectuor {
 	return &Exectuor{DBTX: dbtx}
 }
 
+func (e *Exectuor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	result, err := e.DBTX.ExecContext(ctx, query, args...)
+	return result, markTxConflict(err)
+}
+
+func (e *Exectuor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := e.DBTX.QueryContext(ctx, query, args...)
+	return rows, markTxConflict(err)
+}
+
+func (e *Exectuor) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	return &Row{row: e.DBTX.QueryRowContext(ctx, query, args...)}
+}
+
+/* A *sql.Row whose errors are marked by markTxConflict. */
+type Row struct {
+	row *sql.Row
+}
+
+func (r *Row) Scan(dest ...any) error {
+	return markTxConflict(r.row.Scan(dest...))
+}
+
+/* A *sql.Tx whose commit errors are marked by markTxConflict. */
+type conflictTx struct {
+	*sql.Tx
+}
+
+func (tx conflictTx) Commit() error {
+	return markTxConflict(tx.Tx.Commit())
+}
+
+/* Marks serialization failures (40001) and deadlocks (40P01) on postgres as book.ErrTxConflict, so the service can retry them. */
+func markTxConflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01") {
+		return fmt.Errorf("%w: %w", book.ErrTxConflict, err)
+	}
+	return err
+}
+
 func (store *Store) BeginTx(ctx context.Context, opts *sql.TxOptions) (book.Repository, driver.Tx, error) {
 	tx, err := store.db.BeginTx(ctx, opts)
 	if err != nil {
@@ -53,7 +95,7 @@ func (store *Store) BeginTx(ctx context.Context, opts *sql.TxOptions) (book.Repo
 
 	txRepo := NewStore(store.db)
 	txRepo.exc = NewExc(tx)
-	return txRepo, tx, nil
+	return txRepo, conflictTx{tx}, nil
 }
 
 /* Connects to the database trought a connection string and returns a pointer to a valid DB object (*sql.DB). */
@@ -102,7 +144,7 @@ func (store *Store) SetBookArchiveStatus(ctx context.Context, id uuid.UUID, arch
 	RETURNING *`
 	updatedRow := store.exc.QueryRowContext(ctx, sqlStatement, id, archived)
 	var bookToReturn book.Book
-	err := updatedRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived)
+	err := updatedRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived, &bookToReturn.MaxUnitsPerOrder, &bookToReturn.MaxUnitsPerPurchaser)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -118,12 +160,12 @@ func (store *Store) SetBookArchiveStatus(ctx context.Context, id uuid.UUID, arch
 /* Stores the book into the database, checks and returns it if succeed. */
 func (store *Store) CreateBook(ctx context.Context, bookEntry book.Book) (book.Book, error) {
 	sqlStatement := `
-	INSERT INTO bookstable (id, name, price, inventory, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6)
+	INSERT INTO bookstable (id, name, price, inventory, created_at, updated_at, max_units_per_order, max_units_per_purchaser)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	RETURNING *`
-	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, bookEntry.ID, bookEntry.Name, *bookEntry.Price, *bookEntry.Inventory, bookEntry.CreatedAt, bookEntry.UpdatedAt)
+	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, bookEntry.ID, bookEntry.Name, *bookEntry.Price, *bookEntry.Inventory, bookEntry.CreatedAt, bookEntry.UpdatedAt, bookEntry.MaxUnitsPerOrder, bookEntry.MaxUnitsPerPurchaser)
 	var bookToReturn book.Book
-	err := createdRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived)
+	err := createdRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived, &bookToReturn.MaxUnitsPerOrder, &bookToReturn.MaxUnitsPerPurchaser)
 	if err != nil {
 		return book.Book{}, fmt.Errorf("storing book on db: %w", err)
 	}
@@ -133,12 +175,12 @@ func (store *Store) CreateBook(ctx context.Context, bookEntry book.Book) (book.B
 
 /* Searches a book in database based on ID and returns it if succeed. */
 func (store *Store) GetBookByID(ctx context.Context, id uuid.UUID) (book.Book, error) {
-	sqlStatement := `SELECT id, name, price, inventory, created_at, updated_at, archived
+	sqlStatement := `SELECT id, name, price, inventory, created_at, updated_at, archived, max_units_per_order, max_units_per_purchaser
 	FROM bookstable 
 	WHERE id=$1;`
 	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, id)
 	var bookToReturn book.Book
-	err := foundRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived)
+	err := foundRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived, &bookToReturn.MaxUnitsPerOrder, &bookToReturn.MaxUnitsPerPurchaser)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -151,6 +193,27 @@ func (store *Store) GetBookByID(ctx context.Context, id uuid.UUID) (book.Book, e
 	return bookToReturn, nil
 }
 
+/* Searches a book by ID like GetBookByID, locking its row until the end of the transaction. Other transactions trying to lock the same book wait, so they always read its latest inventory. */
+func (store *Store) GetBookByIDForUpdate(ctx context.Context, id uuid.UUID) (book.Book, error) {
+	sqlStatement := `SELECT id, name, price, inventory, created_at, updated_at, archived, max_units_per_order, max_units_per_purchaser
+	FROM bookstable 
+	WHERE id=$1
+	FOR UPDATE;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, id)
+	var bookToReturn book.Book
+	err := foundRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived, &bookToReturn.MaxUnitsPerOrder, &bookToReturn.MaxUnitsPerPurchaser)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Book{}, fmt.Errorf("searching by ID for update: %w", book.ErrResponseBookNotFound)
+		default:
+			return book.Book{}, fmt.Errorf("searching by ID for update: %w", err)
+		}
+	}
+
+	return bookToReturn, nil
+}
+
 /* Returns filtered content of database in a list of books*/
 func (store *Store) ListBooks(ctx context.Context, name string, minPrice32, maxPrice32 float32, sortBy, sortDirection string, archived bool, page, pageSize int) ([]book.Book, error) {
 	if name != "" {
@@ -177,7 +240,7 @@ func (store *Store) ListBooks(ctx context.Context, name string, minPrice32, maxP
 	bookslist := []book.Book{}
 	var bookToReturn book.Book
 	for rows.Next() {
-		err = rows.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived)
+		err = rows.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived, &bookToReturn.MaxUnitsPerOrder, &bookToReturn.MaxUnitsPerPurchaser)
 		if err != nil {
 			return nil, fmt.Errorf("listing books from db: %w", err)
 		}
@@ -197,12 +260,12 @@ func (store *Store) ListBooks(ctx context.Context, name string, minPrice32, maxP
 func (store *Store) UpdateBook(ctx context.Context, bookEntry book.Book) (book.Book, error) {
 	sqlStatement := `
 	UPDATE bookstable 
-	SET name = $2, price = $3, inventory = $4, updated_at = $5
+	SET name = $2, price = $3, inventory = $4, updated_at = $5, max_units_per_order = $6, max_units_per_purchaser = $7
 	WHERE id = $1
 	RETURNING *`
-	updatedRow := store.exc.QueryRowContext(ctx, sqlStatement, bookEntry.ID, bookEntry.Name, *bookEntry.Price, *bookEntry.Inventory, bookEntry.UpdatedAt)
+	updatedRow := store.exc.QueryRowContext(ctx, sqlStatement, bookEntry.ID, bookEntry.Name, *bookEntry.Price, *bookEntry.Inventory, bookEntry.UpdatedAt, bookEntry.MaxUnitsPerOrder, bookEntry.MaxUnitsPerPurchaser)
 	var bookToReturn book.Book
-	err := updatedRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived)
+	err := updatedRow.Scan(&bookToReturn.ID, &bookToReturn.Name, &bookToReturn.Price, &bookToReturn.Inventory, &bookToReturn.CreatedAt, &bookToReturn.UpdatedAt, &bookToReturn.Archived, &bookToReturn.MaxUnitsPerOrder, &bookToReturn.MaxUnitsPerPurchaser)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -243,8 +306,8 @@ func (store *Store) CreateOrder(ctx context.Context, newOrder book.Order) (book.
 	sqlStatement := `
 	INSERT INTO orders (order_id, purchaser_id, order_status, created_at, updated_at)
 	VALUES ($1, $2, $3, $4, $5)
-	RETURNING *`
-	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, newOrder.OrderID, newOrder.PurchaserID, newOrder.OrderStatus, newOrder.CreatedAt, newOrder.UpdatedAt)
+	RETURNING order_id, purchaser_id, order_status, created_at, updated_at`
+	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, newOrder.OrderID, nullUUID(newOrder.PurchaserID), newOrder.OrderStatus, newOrder.CreatedAt, newOrder.UpdatedAt)
 	var orderToReturn book.Order
 	err := createdRow.Scan(&orderToReturn.OrderID, &orderToReturn.PurchaserID, &orderToReturn.OrderStatus, &orderToReturn.CreatedAt, &orderToReturn.UpdatedAt)
 	if err != nil {
@@ -254,13 +317,20 @@ func (store *Store) CreateOrder(ctx context.Context, newOrder book.Order) (book.
 	return orderToReturn, nil
 }
 
+/* Gets an order with all its items, calculating its subtotal, discount and total price. */
 func (store *Store) ListOrderItems(ctx context.Context, order_id uuid.UUID) (book.Order, error) {
-	sqlStatement := `SELECT order_id, purchaser_id, order_status, created_at, updated_at
+	sqlStatement := `SELECT order_id, purchaser_id, order_status, created_at, updated_at, coupon_id, tax_region, subtotal, discount, tax, shipping, total_price,
+		ship_recipient, ship_line1, ship_line2, ship_city, ship_state, ship_postal_code, ship_country, contact_email, contact_phone, notes
 	FROM orders 
 	WHERE order_id=$1;`
 	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, order_id)
 	var orderToReturn book.Order
-	err := foundRow.Scan(&orderToReturn.OrderID, &orderToReturn.PurchaserID, &orderToReturn.OrderStatus, &orderToReturn.CreatedAt, &orderToReturn.UpdatedAt)
+	var taxRegion sql.NullString
+	var subtotal, discount, tax, shipping, totalPrice *float32 //Only filled after checkout.
+	var address nullShippingAddress
+	var contactEmail, contactPhone, notes sql.NullString
+	err := foundRow.Scan(&orderToReturn.OrderID, &orderToReturn.PurchaserID, &orderToReturn.OrderStatus, &orderToReturn.CreatedAt, &orderToReturn.UpdatedAt, &orderToReturn.CouponID, &taxRegion, &subtotal, &discount, &tax, &shipping, &totalPrice,
+		&address.Recipient, &address.Line1, &address.Line2, &address.City, &address.State, &address.PostalCode, &address.Country, &contactEmail, &contactPhone, &notes)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -289,7 +359,7 @@ func (store *Store) ListOrderItems(ctx context.Context, order_id uuid.UUID) (boo
 
 		orderToReturn.Items = append(orderToReturn.Items, itemAtOrder)
 
-		orderToReturn.TotalPrice = orderToReturn.TotalPrice + (*itemAtOrder.BookPriceAtOrder * float32(itemAtOrder.BookUnits))
+		orderToReturn.Subtotal = orderToReturn.Subtotal + (*itemAtOrder.BookPriceAtOrder * float32(itemAtOrder.BookUnits))
 	}
 
 	err = rows.Err()
@@ -297,6 +367,30 @@ func (store *Store) ListOrderItems(ctx context.Context, order_id uuid.UUID) (boo
 		return book.Order{}, fmt.Errorf("listing order items from db: %w", err)
 	}
 
+	if orderToReturn.CouponID != nil {
+		coupon, err := store.GetCouponByID(ctx, *orderToReturn.CouponID)
+		if err != nil {
+			return book.Order{}, fmt.Errorf("listing order items from db: %w", err)
+		}
+		orderToReturn.CouponCode = coupon.Code
+		orderToReturn.Discount = coupon.Discount(orderToReturn.Subtotal)
+	}
+	orderToReturn.TotalPrice = orderToReturn.Subtotal - orderToReturn.Discount
+
+	orderToReturn.ShippingAddress = address.toShippingAddress()
+	orderToReturn.ContactEmail = contactEmail.String
+	orderToReturn.ContactPhone = contactPhone.String
+	orderToReturn.Notes = notes.String
+
+	if totalPrice != nil { //After checkout, the stored prices are returned, so they don't change when prices, coupons or rules do.
+		orderToReturn.TaxRegion = taxRegion.String
+		orderToReturn.Subtotal = *subtotal
+		orderToReturn.Discount = *discount
+		orderToReturn.Tax = *tax
+		orderToReturn.Shipping = *shipping
+		orderToReturn.TotalPrice = *totalPrice
+	}
+
 	return orderToReturn, nil
 }
 
@@ -364,6 +458,72 @@ func (store *Store) UpsertOrderItem(ctx context.Context, orderID uuid.UUID, item
 	return itemToReturn, nil
 }
 
+/* Takes units of a book from its inventory and adds them to the order in a single statement. The inventory is only taken if it is enough and the book is not archived; the price of a book already at the order is kept. */
+func (store *Store) ReserveOrderItem(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID, units int) (book.OrderItem, error) {
+	sqlStatement := `
+	WITH reserved AS (
+		UPDATE bookstable
+		SET inventory = inventory - $3, updated_at = $4
+		WHERE id = $2 AND inventory >= $3 AND NOT archived
+		RETURNING id, name, price
+	)
+	INSERT INTO books_orders (order_id, book_id, book_units, book_price_at_order, created_at, updated_at, book_name)
+	SELECT $1, id, $3, price, $4, $4, name FROM reserved
+	ON CONFLICT ON CONSTRAINT books_orders_pkey DO UPDATE
+	SET book_units = books_orders.book_units + EXCLUDED.book_units, updated_at = EXCLUDED.updated_at
+	RETURNING book_id, book_units, book_price_at_order, created_at, updated_at, book_name`
+
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, orderID, bookID, units, time.Now().UTC().Round(time.Millisecond))
+	var itemToReturn book.OrderItem
+	err := foundRow.Scan(&itemToReturn.BookID, &itemToReturn.BookUnits, &itemToReturn.BookPriceAtOrder, &itemToReturn.CreatedAt, &itemToReturn.UpdatedAt, &itemToReturn.BookName)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return book.OrderItem{}, fmt.Errorf("reserving item at order on db: %w", err)
+		}
+		//Nothing was reserved. Only now the book is read, to tell why:
+		return book.OrderItem{}, fmt.Errorf("reserving item at order on db: %w", store.reserveRejection(ctx, bookID))
+	}
+	return itemToReturn, nil
+}
+
+/* Reads the purchase limits of a book, with the units of it already at the order and at every non-canceled order of the same purchaser. */
+func (store *Store) GetPurchaseLimitUsage(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID) (book.PurchaseLimitUsage, error) {
+	sqlStatement := `
+	SELECT b.max_units_per_order, b.max_units_per_purchaser,
+		COALESCE((SELECT bo.book_units FROM books_orders bo WHERE bo.order_id = $1 AND bo.book_id = b.id), 0),
+		COALESCE((SELECT SUM(bo.book_units) FROM books_orders bo
+			JOIN orders o ON o.order_id = bo.order_id
+			WHERE bo.book_id = b.id
+			AND o.order_status <> 'canceled'
+			AND o.purchaser_id = (SELECT purchaser_id FROM orders WHERE order_id = $1)), 0)
+	FROM bookstable b
+	WHERE b.id = $2;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, orderID, bookID)
+	var usage book.PurchaseLimitUsage
+	err := foundRow.Scan(&usage.MaxUnitsPerOrder, &usage.MaxUnitsPerPurchaser, &usage.UnitsAtOrder, &usage.UnitsByPurchaser)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.PurchaseLimitUsage{}, fmt.Errorf("getting purchase limit usage from db: %w", book.ErrResponseBookNotFound)
+		default:
+			return book.PurchaseLimitUsage{}, fmt.Errorf("getting purchase limit usage from db: %w", err)
+		}
+	}
+	return usage, nil
+}
+
+/* Finds out why a book could not be reserved. */
+func (store *Store) reserveRejection(ctx context.Context, bookID uuid.UUID) error {
+	bk, err := store.GetBookByID(ctx, bookID)
+	if err != nil {
+		return err
+	}
+	if bk.Archived {
+		return book.ErrResponseBookIsArchived
+	}
+	return book.ErrResponseInsufficientInventory
+}
+
 func (store *Store) DeleteOrderItem(ctx context.Context, orderID uuid.UUID, bookID uuid.UUID) error {
 	sqlStatement := `
 DELETE FROM books_orders
@@ -374,3 +534,1669 @@ WHERE order_id = $1 AND book_id = $2;`
 	}
 	return nil
 }
+
+/* Searches an idempotency key of the caller that has not expired yet. */
+func (store *Store) GetIdempotencyKey(ctx context.Context, caller, key string) (book.IdempotencyKey, error) {
+	sqlStatement := `SELECT idempotency_key, caller, request_hash, response_status, response_body, created_at, expires_at, completed_at
+	FROM idempotency_keys
+	WHERE caller = $1 AND idempotency_key = $2 AND expires_at > $3;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, caller, key, time.Now().UTC())
+	var keyToReturn book.IdempotencyKey
+	err := foundRow.Scan(&keyToReturn.Key, &keyToReturn.Caller, &keyToReturn.RequestHash, &keyToReturn.ResponseStatus, &keyToReturn.ResponseBody, &keyToReturn.CreatedAt, &keyToReturn.ExpiresAt, &keyToReturn.CompletedAt)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.IdempotencyKey{}, fmt.Errorf("getting idempotency key from db: %w", book.ErrResponseIdempotencyKeyNotFound)
+		default:
+			return book.IdempotencyKey{}, fmt.Errorf("getting idempotency key from db: %w", err)
+		}
+	}
+
+	return keyToReturn, nil
+}
+
+/* Stores an idempotency key with no response yet, claiming it for a request. An expired key of the caller with the same value is overwritten, but a valid one is kept and ErrResponseIdempotencyKeyReused is returned. */
+func (store *Store) ReserveIdempotencyKey(ctx context.Context, idemKey book.IdempotencyKey) error {
+	sqlStatement := `
+	INSERT INTO idempotency_keys (idempotency_key, caller, request_hash, response_status, response_body, created_at, expires_at, completed_at)
+	VALUES ($1, $2, $3, 0, NULL, $4, $5, NULL)
+	ON CONFLICT (caller, idempotency_key) DO UPDATE
+	SET request_hash = $3, response_status = 0, response_body = NULL, created_at = $4, expires_at = $5, completed_at = NULL
+	WHERE idempotency_keys.expires_at <= $4;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, idemKey.Key, idemKey.Caller, idemKey.RequestHash, idemKey.CreatedAt, idemKey.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("reserving idempotency key on db: %w", err)
+	}
+	reserved, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("reserving idempotency key on db: %w", err)
+	}
+	if reserved == 0 { //The key is already held and still valid.
+		return fmt.Errorf("reserving idempotency key on db: %w", book.ErrResponseIdempotencyKeyReused)
+	}
+	return nil
+}
+
+/* Stores the response of the request that reserved the key, and until when it is replayed. */
+func (store *Store) CompleteIdempotencyKey(ctx context.Context, idemKey book.IdempotencyKey) error {
+	sqlStatement := `
+	UPDATE idempotency_keys
+	SET response_status = $3, response_body = $4, expires_at = $5, completed_at = $6
+	WHERE caller = $1 AND idempotency_key = $2 AND completed_at IS NULL;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, idemKey.Caller, idemKey.Key, idemKey.ResponseStatus, idemKey.ResponseBody, idemKey.ExpiresAt, idemKey.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("completing idempotency key on db: %w", err)
+	}
+	completed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("completing idempotency key on db: %w", err)
+	}
+	if completed == 0 {
+		return fmt.Errorf("completing idempotency key on db: %w", book.ErrResponseIdempotencyKeyNotFound)
+	}
+	return nil
+}
+
+/* Deletes a key reserved by a request that did not complete. Completed keys are kept. */
+func (store *Store) ReleaseIdempotencyKey(ctx context.Context, caller, key string) error {
+	sqlStatement := `
+	DELETE FROM idempotency_keys
+	WHERE caller = $1 AND idempotency_key = $2 AND completed_at IS NULL;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, caller, key)
+	if err != nil {
+		return fmt.Errorf("releasing idempotency key on db: %w", err)
+	}
+	return nil
+}
+
+/* Deletes the idempotency keys expired by now and returns how many were deleted. */
+func (store *Store) PurgeExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	sqlStatement := `
+	DELETE FROM idempotency_keys
+	WHERE expires_at <= $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, now)
+	if err != nil {
+		return 0, fmt.Errorf("purging idempotency keys on db: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purging idempotency keys on db: %w", err)
+	}
+	return int(purged), nil
+}
+
+/* Stores a new coupon into the database, checks and returns it if succeed. */
+func (store *Store) CreateCoupon(ctx context.Context, newCoupon book.Coupon) (book.Coupon, error) {
+	sqlStatement := `
+	INSERT INTO coupons (coupon_id, code, discount_type, discount_value, min_order_total, valid_from, valid_until, usage_limit, times_used, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING coupon_id, code, discount_type, discount_value, min_order_total, valid_from, valid_until, usage_limit, times_used, created_at, updated_at`
+	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, newCoupon.ID, newCoupon.Code, newCoupon.DiscountType, newCoupon.DiscountValue, newCoupon.MinOrderTotal, newCoupon.ValidFrom, newCoupon.ValidUntil, newCoupon.UsageLimit, newCoupon.TimesUsed, newCoupon.CreatedAt, newCoupon.UpdatedAt)
+	couponToReturn, err := scanCoupon(createdRow)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return book.Coupon{}, fmt.Errorf("storing coupon on db: %w", book.ErrResponseCouponCodeInUse)
+		}
+		return book.Coupon{}, fmt.Errorf("storing coupon on db: %w", err)
+	}
+
+	return couponToReturn, nil
+}
+
+/* Searches a coupon in database based on ID and returns it if succeed. */
+func (store *Store) GetCouponByID(ctx context.Context, id uuid.UUID) (book.Coupon, error) {
+	sqlStatement := `SELECT coupon_id, code, discount_type, discount_value, min_order_total, valid_from, valid_until, usage_limit, times_used, created_at, updated_at
+	FROM coupons 
+	WHERE coupon_id=$1;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, id)
+	couponToReturn, err := scanCoupon(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Coupon{}, fmt.Errorf("searching coupon by ID: %w", book.ErrResponseCouponNotFound)
+		default:
+			return book.Coupon{}, fmt.Errorf("searching coupon by ID: %w", err)
+		}
+	}
+
+	return couponToReturn, nil
+}
+
+/* Searches a coupon in database based on its code and returns it if succeed. */
+func (store *Store) GetCouponByCode(ctx context.Context, code string) (book.Coupon, error) {
+	sqlStatement := `SELECT coupon_id, code, discount_type, discount_value, min_order_total, valid_from, valid_until, usage_limit, times_used, created_at, updated_at
+	FROM coupons 
+	WHERE code=$1;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, code)
+	couponToReturn, err := scanCoupon(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Coupon{}, fmt.Errorf("searching coupon by code: %w", book.ErrResponseCouponNotFound)
+		default:
+			return book.Coupon{}, fmt.Errorf("searching coupon by code: %w", err)
+		}
+	}
+
+	return couponToReturn, nil
+}
+
+/* Returns all the coupons, ordered by code. */
+func (store *Store) ListCoupons(ctx context.Context) ([]book.Coupon, error) {
+	sqlStatement := `SELECT coupon_id, code, discount_type, discount_value, min_order_total, valid_from, valid_until, usage_limit, times_used, created_at, updated_at
+	FROM coupons 
+	ORDER BY code ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, fmt.Errorf("listing coupons from db: %w", err)
+	}
+	defer rows.Close()
+	couponsList := []book.Coupon{}
+	for rows.Next() {
+		couponToReturn, err := scanCoupon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing coupons from db: %w", err)
+		}
+
+		couponsList = append(couponsList, couponToReturn)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing coupons from db: %w", err)
+	}
+
+	return couponsList, nil
+}
+
+/* Updates a coupon on database, checks and returns it if succeed. Its usage count is not changed. */
+func (store *Store) UpdateCoupon(ctx context.Context, couponEntry book.Coupon) (book.Coupon, error) {
+	sqlStatement := `
+	UPDATE coupons 
+	SET code = $2, discount_type = $3, discount_value = $4, min_order_total = $5, valid_from = $6, valid_until = $7, usage_limit = $8, updated_at = $9
+	WHERE coupon_id = $1
+	RETURNING coupon_id, code, discount_type, discount_value, min_order_total, valid_from, valid_until, usage_limit, times_used, created_at, updated_at`
+	updatedRow := store.exc.QueryRowContext(ctx, sqlStatement, couponEntry.ID, couponEntry.Code, couponEntry.DiscountType, couponEntry.DiscountValue, couponEntry.MinOrderTotal, couponEntry.ValidFrom, couponEntry.ValidUntil, couponEntry.UsageLimit, couponEntry.UpdatedAt)
+	couponToReturn, err := scanCoupon(updatedRow)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return book.Coupon{}, fmt.Errorf("updating coupon on db: %w", book.ErrResponseCouponNotFound)
+		case isUniqueViolation(err):
+			return book.Coupon{}, fmt.Errorf("updating coupon on db: %w", book.ErrResponseCouponCodeInUse)
+		default:
+			return book.Coupon{}, fmt.Errorf("updating coupon on db: %w", err)
+		}
+	}
+
+	return couponToReturn, nil
+}
+
+/* Deletes a coupon from database. Orders using it stay without a coupon. */
+func (store *Store) DeleteCoupon(ctx context.Context, id uuid.UUID) error {
+	sqlStatement := `
+	DELETE FROM coupons
+	WHERE coupon_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, id)
+	if err != nil {
+		return fmt.Errorf("deleting coupon on db: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting coupon on db: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("deleting coupon on db: %w", book.ErrResponseCouponNotFound)
+	}
+	return nil
+}
+
+/* Counts one more use of the coupon, if its usage limit was not reached yet. */
+func (store *Store) IncrementCouponUsage(ctx context.Context, id uuid.UUID) error {
+	sqlStatement := `
+	UPDATE coupons
+	SET times_used = times_used + 1
+	WHERE coupon_id = $1 AND (usage_limit IS NULL OR times_used < usage_limit);`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, id)
+	if err != nil {
+		return fmt.Errorf("incrementing coupon usage on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("incrementing coupon usage on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("incrementing coupon usage on db: %w", book.ErrResponseCouponUsageLimitReached)
+	}
+	return nil
+}
+
+/* Gives back one use of the coupon. */
+func (store *Store) DecrementCouponUsage(ctx context.Context, id uuid.UUID) error {
+	sqlStatement := `
+	UPDATE coupons
+	SET times_used = times_used - 1
+	WHERE coupon_id = $1 AND times_used > 0;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, id)
+	if err != nil {
+		return fmt.Errorf("decrementing coupon usage on db: %w", err)
+	}
+	return nil
+}
+
+/* Stores the final prices of an order and its new status. */
+func (store *Store) CheckoutOrder(ctx context.Context, order book.Order) error {
+	sqlStatement := `
+	UPDATE orders
+	SET order_status = $2, tax_region = $3, subtotal = $4, discount = $5, tax = $6, shipping = $7, total_price = $8, updated_at = $9
+	WHERE order_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, order.OrderID, order.OrderStatus, order.TaxRegion, order.Subtotal, order.Discount, order.Tax, order.Shipping, order.TotalPrice, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("checking out order on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking out order on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("checking out order on db: %w", book.ErrResponseOrderNotFound)
+	}
+	return nil
+}
+
+/* Lists the orders still accepting items that were not changed since the given moment. */
+func (store *Store) ListAbandonedOrders(ctx context.Context, untouchedSince time.Time) ([]uuid.UUID, error) {
+	sqlStatement := `
+	SELECT order_id FROM orders
+	WHERE order_status = 'accepting_items' AND updated_at < $1
+	ORDER BY updated_at;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, untouchedSince)
+	if err != nil {
+		return nil, fmt.Errorf("listing abandoned orders from db: %w", err)
+	}
+	defer rows.Close()
+
+	orderIDs := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("listing abandoned orders from db: %w", err)
+		}
+		orderIDs = append(orderIDs, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing abandoned orders from db: %w", err)
+	}
+
+	return orderIDs, nil
+}
+
+/* Cancels an order, only if it is still accepting items and was not changed since the given moment. */
+func (store *Store) CancelAbandonedOrder(ctx context.Context, orderID uuid.UUID, untouchedSince time.Time) error {
+	sqlStatement := `
+	UPDATE orders
+	SET order_status = 'canceled', updated_at = $3
+	WHERE order_id = $1 AND order_status = 'accepting_items' AND updated_at < $2;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, orderID, untouchedSince, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("canceling order on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("canceling order on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("canceling order on db: %w", book.ErrResponseOrderNotAcceptingItems)
+	}
+	return nil
+}
+
+/* Gives units of a book back to its inventory. */
+func (store *Store) RestockBook(ctx context.Context, bookID uuid.UUID, units int) error {
+	sqlStatement := `
+	UPDATE bookstable
+	SET inventory = inventory + $2, updated_at = $3
+	WHERE id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, bookID, units, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("restocking book on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("restocking book on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("restocking book on db: %w", book.ErrResponseBookNotFound)
+	}
+	return nil
+}
+
+/* Sets the coupon applied to an order. */
+func (store *Store) SetOrderCoupon(ctx context.Context, orderID uuid.UUID, couponID uuid.UUID) error {
+	sqlStatement := `
+	UPDATE orders
+	SET coupon_id = $2
+	WHERE order_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, orderID, couponID)
+	if err != nil {
+		return fmt.Errorf("setting order coupon on db: %w", err)
+	}
+	return nil
+}
+
+/* Takes the coupon off an order. */
+func (store *Store) RemoveOrderCoupon(ctx context.Context, orderID uuid.UUID) error {
+	sqlStatement := `
+	UPDATE orders
+	SET coupon_id = NULL
+	WHERE order_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, orderID)
+	if err != nil {
+		return fmt.Errorf("removing order coupon on db: %w", err)
+	}
+	return nil
+}
+
+/* Stores an event into the outbox, due to be sent right away. */
+func (store *Store) InsertOutboxEvent(ctx context.Context, event book.OutboxEvent) error {
+	sqlStatement := `
+	INSERT INTO outbox (event_id, event_type, payload, created_at, next_attempt_at)
+	VALUES ($1, $2, $3, $4, $4);`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, event.ID, event.EventType, event.Payload, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("storing outbox event on db: %w", err)
+	}
+	return nil
+}
+
+/* Lists the events not sent nor given up whose next attempt is due by now, the most overdue first, locking them until the end of the transaction. Events locked by another transaction are skipped. */
+func (store *Store) ListPendingOutboxEvents(ctx context.Context, now time.Time, limit int) ([]book.OutboxEvent, error) {
+	sqlStatement := `
+	SELECT event_id, event_type, payload, created_at, sent_at, attempts, next_attempt_at, last_error, failed_at
+	FROM outbox
+	WHERE sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= $1
+	ORDER BY next_attempt_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing outbox events from db: %w", err)
+	}
+	defer rows.Close()
+
+	events := []book.OutboxEvent{}
+	for rows.Next() {
+		var e book.OutboxEvent
+		err := rows.Scan(&e.ID, &e.EventType, &e.Payload, &e.CreatedAt, &e.SentAt, &e.Attempts, &e.NextAttemptAt, &e.LastError, &e.FailedAt)
+		if err != nil {
+			return nil, fmt.Errorf("listing outbox events from db: %w", err)
+		}
+		events = append(events, e)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing outbox events from db: %w", err)
+	}
+
+	return events, nil
+}
+
+/* Stores the state of the delivery of an event: when it was sent, when to try it again or when it was given up. */
+func (store *Store) UpdateOutboxEvent(ctx context.Context, event book.OutboxEvent) error {
+	sqlStatement := `
+	UPDATE outbox
+	SET sent_at = $2, attempts = $3, next_attempt_at = $4, last_error = $5, failed_at = $6
+	WHERE event_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, event.ID, event.SentAt, event.Attempts, event.NextAttemptAt, event.LastError, event.FailedAt)
+	if err != nil {
+		return fmt.Errorf("updating outbox event on db: %w", err)
+	}
+	return nil
+}
+
+/* Deletes the events sent before sentBefore. Returns how many were deleted. */
+func (store *Store) PurgeSentOutboxEvents(ctx context.Context, sentBefore time.Time) (int, error) {
+	sqlStatement := `
+	DELETE FROM outbox
+	WHERE sent_at < $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, sentBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purging sent outbox events on db: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purging sent outbox events on db: %w", err)
+	}
+	return int(purged), nil
+}
+
+/* Adds a book to the wishlist of a user, returning when it was added. A book already at the wishlist keeps its original time. */
+func (store *Store) AddWishlistItem(ctx context.Context, userID uuid.UUID, bookID uuid.UUID, addedAt time.Time) (time.Time, error) {
+	sqlStatement := `
+	INSERT INTO wishlists (user_id, book_id, added_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT ON CONSTRAINT wishlists_pkey DO UPDATE
+	SET added_at = wishlists.added_at
+	RETURNING added_at`
+	var storedAddedAt time.Time
+	err := store.exc.QueryRowContext(ctx, sqlStatement, userID, bookID, addedAt).Scan(&storedAddedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("adding book to wishlist on db: %w", err)
+	}
+	return storedAddedAt, nil
+}
+
+/* Searches a book at the wishlist of a user. */
+func (store *Store) GetWishlistItem(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) (book.WishlistItem, error) {
+	sqlStatement := `SELECT b.id, b.name, b.price, b.inventory, b.created_at, b.updated_at, b.archived, b.max_units_per_order, b.max_units_per_purchaser, w.added_at
+	FROM wishlists w
+	JOIN bookstable b ON b.id = w.book_id
+	WHERE w.user_id = $1 AND w.book_id = $2;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, userID, bookID)
+	itemToReturn, err := scanWishlistItem(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.WishlistItem{}, fmt.Errorf("searching wishlist item: %w", book.ErrResponseBookNotAtWishlist)
+		default:
+			return book.WishlistItem{}, fmt.Errorf("searching wishlist item: %w", err)
+		}
+	}
+	return itemToReturn, nil
+}
+
+/* Returns the books at the wishlist of a user, the most recently added first. */
+func (store *Store) ListWishlistItems(ctx context.Context, userID uuid.UUID) ([]book.WishlistItem, error) {
+	sqlStatement := `SELECT b.id, b.name, b.price, b.inventory, b.created_at, b.updated_at, b.archived, b.max_units_per_order, b.max_units_per_purchaser, w.added_at
+	FROM wishlists w
+	JOIN bookstable b ON b.id = w.book_id
+	WHERE w.user_id = $1
+	ORDER BY w.added_at DESC, b.id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing wishlist from db: %w", err)
+	}
+	defer rows.Close()
+	items := []book.WishlistItem{}
+	for rows.Next() {
+		itemToReturn, err := scanWishlistItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing wishlist from db: %w", err)
+		}
+
+		items = append(items, itemToReturn)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing wishlist from db: %w", err)
+	}
+
+	return items, nil
+}
+
+/* Removes a book from the wishlist of a user. */
+func (store *Store) DeleteWishlistItem(ctx context.Context, userID uuid.UUID, bookID uuid.UUID) error {
+	sqlStatement := `
+	DELETE FROM wishlists
+	WHERE user_id = $1 AND book_id = $2;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, userID, bookID)
+	if err != nil {
+		return fmt.Errorf("deleting wishlist item on db: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting wishlist item on db: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("deleting wishlist item on db: %w", book.ErrResponseBookNotAtWishlist)
+	}
+	return nil
+}
+
+/* Deletes every book of the wishlist of a user. */
+func (store *Store) ClearWishlist(ctx context.Context, userID uuid.UUID) error {
+	sqlStatement := `
+	DELETE FROM wishlists
+	WHERE user_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, userID)
+	if err != nil {
+		return fmt.Errorf("clearing wishlist on db: %w", err)
+	}
+	return nil
+}
+
+/* Reads the status of an order, locking its row until the end of the transaction. */
+func (store *Store) GetOrderStatusForUpdate(ctx context.Context, orderID uuid.UUID) (string, error) {
+	sqlStatement := `SELECT order_status
+	FROM orders
+	WHERE order_id = $1
+	FOR UPDATE;`
+	var status string
+	err := store.exc.QueryRowContext(ctx, sqlStatement, orderID).Scan(&status)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return "", fmt.Errorf("searching order status for update: %w", book.ErrResponseOrderNotFound)
+		default:
+			return "", fmt.Errorf("searching order status for update: %w", err)
+		}
+	}
+	return status, nil
+}
+
+/* Changes the status of an order. */
+func (store *Store) SetOrderStatus(ctx context.Context, orderID uuid.UUID, status string) error {
+	sqlStatement := `
+	UPDATE orders
+	SET order_status = $2, updated_at = $3
+	WHERE order_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, orderID, status, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("setting order status on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("setting order status on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("setting order status on db: %w", book.ErrResponseOrderNotFound)
+	}
+	return nil
+}
+
+/* Finds the order of the purchaser still accepting items, the last changed one if there are many, and locks it. */
+func (store *Store) GetOpenOrderOfPurchaser(ctx context.Context, purchaserID uuid.UUID) (uuid.UUID, error) {
+	sqlStatement := `SELECT order_id
+	FROM orders
+	WHERE purchaser_id = $1 AND order_status = 'accepting_items'
+	ORDER BY updated_at DESC
+	LIMIT 1
+	FOR UPDATE;`
+	var orderID uuid.UUID
+	err := store.exc.QueryRowContext(ctx, sqlStatement, purchaserID).Scan(&orderID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return uuid.Nil, fmt.Errorf("searching open order of purchaser: %w", book.ErrResponseOrderNotFound)
+		default:
+			return uuid.Nil, fmt.Errorf("searching open order of purchaser: %w", err)
+		}
+	}
+	return orderID, nil
+}
+
+/* Gives a guest order its purchaser. Orders that already have one are not changed. */
+func (store *Store) SetOrderPurchaser(ctx context.Context, orderID uuid.UUID, purchaserID uuid.UUID) error {
+	sqlStatement := `
+	UPDATE orders
+	SET purchaser_id = $2, updated_at = $3
+	WHERE order_id = $1 AND purchaser_id IS NULL;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, orderID, purchaserID, time.Now().UTC().Round(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("setting order purchaser on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("setting order purchaser on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("setting order purchaser on db: %w", book.ErrResponseOrderAlreadyClaimed)
+	}
+	return nil
+}
+
+/* Returns the IDs of every order of the purchaser, the oldest first. */
+func (store *Store) ListOrderIDsOfPurchaser(ctx context.Context, purchaserID uuid.UUID) ([]uuid.UUID, error) {
+	sqlStatement := `SELECT order_id
+	FROM orders
+	WHERE purchaser_id = $1
+	ORDER BY created_at ASC, order_id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, purchaserID)
+	if err != nil {
+		return nil, fmt.Errorf("listing orders of purchaser: %w", err)
+	}
+	defer rows.Close()
+
+	orderIDs := []uuid.UUID{}
+	for rows.Next() {
+		var orderID uuid.UUID
+		err := rows.Scan(&orderID)
+		if err != nil {
+			return nil, fmt.Errorf("scanning orders of purchaser: %w", err)
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing orders of purchaser: %w", err)
+	}
+	return orderIDs, nil
+}
+
+/* Clears the shipping address, contacts and notes of every order of the purchaser. Items, prices and statuses are kept. Returns how many orders had personal data cleared. */
+func (store *Store) AnonymizePurchaserOrders(ctx context.Context, purchaserID uuid.UUID) (int, error) {
+	sqlStatement := `
+	UPDATE orders
+	SET ship_recipient = NULL, ship_line1 = NULL, ship_line2 = NULL, ship_city = NULL, ship_state = NULL, ship_postal_code = NULL, ship_country = NULL,
+		contact_email = NULL, contact_phone = NULL, notes = NULL
+	WHERE purchaser_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, purchaserID)
+	if err != nil {
+		return 0, fmt.Errorf("anonymizing orders of purchaser on db: %w", err)
+	}
+	anonymized, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("anonymizing orders of purchaser on db: %w", err)
+	}
+	return int(anonymized), nil
+}
+
+/* Clears the personal fields from the payloads of the outbox events about a user: the name and email of user events, and the shipping address, contact and notes of the events of its orders. Events keep their shape, with the values an erased user and its orders have. */
+func (store *Store) AnonymizeUserEvents(ctx context.Context, userID uuid.UUID, name string) error {
+	sqlStatement := `
+	UPDATE outbox
+	SET payload = CASE
+		WHEN payload ? 'Email' THEN payload || jsonb_build_object('Name', $2::text, 'Email', '')
+		ELSE payload || '{"ShippingAddress": null, "ContactEmail": "", "ContactPhone": "", "Notes": ""}'::jsonb
+		END
+	WHERE (payload ? 'Email' AND payload->>'UserID' = $1)
+	OR (payload ? 'ContactEmail' AND payload->>'PurchaserID' = $1);`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, userID.String(), name)
+	if err != nil {
+		return fmt.Errorf("anonymizing outbox events of user on db: %w", err)
+	}
+	return nil
+}
+
+/* Clears the personal fields from the events about a user queued or delivered to webhooks, as AnonymizeUserEvents does with the outbox. Their payload is the envelope of the event, with the resource under 'payload'. */
+func (store *Store) AnonymizeUserWebhookDeliveries(ctx context.Context, userID uuid.UUID, name string) error {
+	sqlStatement := `
+	UPDATE webhook_deliveries
+	SET payload = jsonb_set(payload, '{payload}', CASE
+		WHEN payload->'payload' ? 'Email' THEN (payload->'payload') || jsonb_build_object('Name', $2::text, 'Email', '')
+		ELSE (payload->'payload') || '{"ShippingAddress": null, "ContactEmail": "", "ContactPhone": "", "Notes": ""}'::jsonb
+		END)
+	WHERE (payload->'payload' ? 'Email' AND payload->'payload'->>'UserID' = $1)
+	OR (payload->'payload' ? 'ContactEmail' AND payload->'payload'->>'PurchaserID' = $1);`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, userID.String(), name)
+	if err != nil {
+		return fmt.Errorf("anonymizing webhook deliveries of user on db: %w", err)
+	}
+	return nil
+}
+
+/* Stores a return with its items. Must run inside a transaction, so a return is never stored without them. */
+func (store *Store) CreateReturn(ctx context.Context, newReturn book.Return) (book.Return, error) {
+	sqlStatement := `
+	INSERT INTO returns (return_id, order_id, return_status, reason, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING return_id, order_id, return_status, reason, refund_amount, refund_id, created_at, updated_at, resolved_at`
+	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, newReturn.ReturnID, newReturn.OrderID, newReturn.Status, newReturn.Reason, newReturn.CreatedAt, newReturn.UpdatedAt)
+	returnToReturn, err := scanReturn(createdRow)
+	if err != nil {
+		return book.Return{}, fmt.Errorf("storing return on db: %w", err)
+	}
+
+	sqlStatement = `
+	INSERT INTO return_items (return_id, book_id, book_units)
+	VALUES ($1, $2, $3);`
+	for _, item := range newReturn.Items {
+		_, err = store.exc.ExecContext(ctx, sqlStatement, newReturn.ReturnID, item.BookID, item.BookUnits)
+		if err != nil {
+			return book.Return{}, fmt.Errorf("storing return items on db: %w", err)
+		}
+	}
+	returnToReturn.Items = newReturn.Items
+
+	return returnToReturn, nil
+}
+
+/* Searches a return with its items, locking its row until the end of the transaction. */
+func (store *Store) GetReturnForUpdate(ctx context.Context, returnID uuid.UUID) (book.Return, error) {
+	sqlStatement := `SELECT return_id, order_id, return_status, reason, refund_amount, refund_id, created_at, updated_at, resolved_at
+	FROM returns
+	WHERE return_id = $1
+	FOR UPDATE;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, returnID)
+	returnToReturn, err := scanReturn(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Return{}, fmt.Errorf("searching return for update: %w", book.ErrResponseReturnNotFound)
+		default:
+			return book.Return{}, fmt.Errorf("searching return for update: %w", err)
+		}
+	}
+
+	sqlStatement = `SELECT book_id, book_units
+	FROM return_items
+	WHERE return_id = $1
+	ORDER BY book_id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, returnID)
+	if err != nil {
+		return book.Return{}, fmt.Errorf("searching return items: %w", err)
+	}
+	defer rows.Close()
+	returnToReturn.Items = []book.ReturnItem{}
+	for rows.Next() {
+		var item book.ReturnItem
+		err = rows.Scan(&item.BookID, &item.BookUnits)
+		if err != nil {
+			return book.Return{}, fmt.Errorf("searching return items: %w", err)
+		}
+		returnToReturn.Items = append(returnToReturn.Items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return book.Return{}, fmt.Errorf("searching return items: %w", err)
+	}
+
+	return returnToReturn, nil
+}
+
+/* Returns all the returns of an order with their items, the oldest first. */
+func (store *Store) ListReturns(ctx context.Context, orderID uuid.UUID) ([]book.Return, error) {
+	sqlStatement := `SELECT return_id, order_id, return_status, reason, refund_amount, refund_id, created_at, updated_at, resolved_at
+	FROM returns
+	WHERE order_id = $1
+	ORDER BY created_at ASC, return_id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("listing returns from db: %w", err)
+	}
+	defer rows.Close()
+	returnsList := []book.Return{}
+	positions := map[uuid.UUID]int{}
+	for rows.Next() {
+		returnToReturn, err := scanReturn(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing returns from db: %w", err)
+		}
+		returnToReturn.Items = []book.ReturnItem{}
+
+		positions[returnToReturn.ReturnID] = len(returnsList)
+		returnsList = append(returnsList, returnToReturn)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing returns from db: %w", err)
+	}
+
+	sqlStatement = `SELECT ri.return_id, ri.book_id, ri.book_units
+	FROM return_items ri
+	JOIN returns r ON r.return_id = ri.return_id
+	WHERE r.order_id = $1
+	ORDER BY ri.book_id ASC;`
+	itemRows, err := store.exc.QueryContext(ctx, sqlStatement, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("listing return items from db: %w", err)
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var returnID uuid.UUID
+		var item book.ReturnItem
+		err = itemRows.Scan(&returnID, &item.BookID, &item.BookUnits)
+		if err != nil {
+			return nil, fmt.Errorf("listing return items from db: %w", err)
+		}
+		i := positions[returnID]
+		returnsList[i].Items = append(returnsList[i].Items, item)
+	}
+	err = itemRows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing return items from db: %w", err)
+	}
+
+	return returnsList, nil
+}
+
+/* Stores how a return was resolved: its status and, if approved, its refund. */
+func (store *Store) ResolveReturn(ctx context.Context, ret book.Return) error {
+	var refundAmount *float32 //Rejected returns have no refund.
+	var refundID *string
+	if ret.Status == book.ReturnStatusApproved {
+		refundAmount = &ret.RefundAmount
+		refundID = &ret.RefundID
+	}
+	sqlStatement := `
+	UPDATE returns
+	SET return_status = $2, refund_amount = $3, refund_id = $4, updated_at = $5, resolved_at = $6
+	WHERE return_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, ret.ReturnID, ret.Status, refundAmount, refundID, ret.UpdatedAt, ret.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("resolving return on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("resolving return on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("resolving return on db: %w", book.ErrResponseReturnNotFound)
+	}
+	return nil
+}
+
+/* Stores the shipping address, contact and notes of an order. Blank fields are stored as NULL. */
+func (store *Store) SetOrderDetails(ctx context.Context, order book.Order) error {
+	var address nullShippingAddress
+	if order.ShippingAddress != nil {
+		address = nullShippingAddress{
+			Recipient:  nullString(order.ShippingAddress.Recipient),
+			Line1:      nullString(order.ShippingAddress.Line1),
+			Line2:      nullString(order.ShippingAddress.Line2),
+			City:       nullString(order.ShippingAddress.City),
+			State:      nullString(order.ShippingAddress.State),
+			PostalCode: nullString(order.ShippingAddress.PostalCode),
+			Country:    nullString(order.ShippingAddress.Country),
+		}
+	}
+	sqlStatement := `
+	UPDATE orders
+	SET ship_recipient = $2, ship_line1 = $3, ship_line2 = $4, ship_city = $5, ship_state = $6, ship_postal_code = $7, ship_country = $8,
+		contact_email = $9, contact_phone = $10, notes = $11
+	WHERE order_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, order.OrderID, address.Recipient, address.Line1, address.Line2, address.City, address.State, address.PostalCode, address.Country,
+		nullString(order.ContactEmail), nullString(order.ContactPhone), nullString(order.Notes))
+	if err != nil {
+		return fmt.Errorf("setting order details on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("setting order details on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("setting order details on db: %w", book.ErrResponseOrderNotFound)
+	}
+	return nil
+}
+
+/* Stores a shipment with its items. Must run inside a transaction, so a shipment is never stored without them. */
+func (store *Store) CreateShipment(ctx context.Context, newShipment book.Shipment) (book.Shipment, error) {
+	sqlStatement := `
+	INSERT INTO shipments (shipment_id, order_id, shipment_status, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING shipment_id, order_id, (SELECT purchaser_id FROM orders WHERE order_id = $2), shipment_status, carrier, tracking_number, created_at, updated_at, shipped_at, delivered_at`
+	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, newShipment.ShipmentID, newShipment.OrderID, newShipment.Status, newShipment.CreatedAt, newShipment.UpdatedAt)
+	shipmentToReturn, err := scanShipment(createdRow)
+	if err != nil {
+		return book.Shipment{}, fmt.Errorf("storing shipment on db: %w", err)
+	}
+
+	sqlStatement = `
+	INSERT INTO shipment_items (shipment_id, order_id, book_id, book_units)
+	VALUES ($1, $2, $3, $4);`
+	for _, item := range newShipment.Items {
+		_, err = store.exc.ExecContext(ctx, sqlStatement, newShipment.ShipmentID, newShipment.OrderID, item.BookID, item.BookUnits)
+		if err != nil {
+			return book.Shipment{}, fmt.Errorf("storing shipment items on db: %w", err)
+		}
+	}
+	shipmentToReturn.Items = newShipment.Items
+
+	return shipmentToReturn, nil
+}
+
+/* Searches a shipment with its items, locking its row until the end of the transaction. */
+func (store *Store) GetShipmentForUpdate(ctx context.Context, shipmentID uuid.UUID) (book.Shipment, error) {
+	sqlStatement := `SELECT s.shipment_id, s.order_id, o.purchaser_id, s.shipment_status, s.carrier, s.tracking_number, s.created_at, s.updated_at, s.shipped_at, s.delivered_at
+	FROM shipments s
+	JOIN orders o ON o.order_id = s.order_id
+	WHERE s.shipment_id = $1
+	FOR UPDATE OF s;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, shipmentID)
+	shipmentToReturn, err := scanShipment(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Shipment{}, fmt.Errorf("searching shipment for update: %w", book.ErrResponseShipmentNotFound)
+		default:
+			return book.Shipment{}, fmt.Errorf("searching shipment for update: %w", err)
+		}
+	}
+
+	sqlStatement = `SELECT book_id, book_units
+	FROM shipment_items
+	WHERE shipment_id = $1
+	ORDER BY book_id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, shipmentID)
+	if err != nil {
+		return book.Shipment{}, fmt.Errorf("searching shipment items: %w", err)
+	}
+	defer rows.Close()
+	shipmentToReturn.Items = []book.ShipmentItem{}
+	for rows.Next() {
+		var item book.ShipmentItem
+		err = rows.Scan(&item.BookID, &item.BookUnits)
+		if err != nil {
+			return book.Shipment{}, fmt.Errorf("searching shipment items: %w", err)
+		}
+		shipmentToReturn.Items = append(shipmentToReturn.Items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return book.Shipment{}, fmt.Errorf("searching shipment items: %w", err)
+	}
+
+	return shipmentToReturn, nil
+}
+
+/* Returns all the shipments of an order with their items, the oldest first. */
+func (store *Store) ListShipments(ctx context.Context, orderID uuid.UUID) ([]book.Shipment, error) {
+	sqlStatement := `SELECT s.shipment_id, s.order_id, o.purchaser_id, s.shipment_status, s.carrier, s.tracking_number, s.created_at, s.updated_at, s.shipped_at, s.delivered_at
+	FROM shipments s
+	JOIN orders o ON o.order_id = s.order_id
+	WHERE s.order_id = $1
+	ORDER BY s.created_at ASC, s.shipment_id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("listing shipments from db: %w", err)
+	}
+	defer rows.Close()
+	shipmentsList := []book.Shipment{}
+	positions := map[uuid.UUID]int{}
+	for rows.Next() {
+		shipmentToReturn, err := scanShipment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing shipments from db: %w", err)
+		}
+		shipmentToReturn.Items = []book.ShipmentItem{}
+
+		positions[shipmentToReturn.ShipmentID] = len(shipmentsList)
+		shipmentsList = append(shipmentsList, shipmentToReturn)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing shipments from db: %w", err)
+	}
+
+	sqlStatement = `SELECT shipment_id, book_id, book_units
+	FROM shipment_items
+	WHERE order_id = $1
+	ORDER BY book_id ASC;`
+	itemRows, err := store.exc.QueryContext(ctx, sqlStatement, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("listing shipment items from db: %w", err)
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var shipmentID uuid.UUID
+		var item book.ShipmentItem
+		err = itemRows.Scan(&shipmentID, &item.BookID, &item.BookUnits)
+		if err != nil {
+			return nil, fmt.Errorf("listing shipment items from db: %w", err)
+		}
+		i := positions[shipmentID]
+		shipmentsList[i].Items = append(shipmentsList[i].Items, item)
+	}
+	err = itemRows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing shipment items from db: %w", err)
+	}
+
+	return shipmentsList, nil
+}
+
+/* Stores the step a shipment is at, with its carrier and tracking number once shipped. */
+func (store *Store) UpdateShipment(ctx context.Context, shipment book.Shipment) error {
+	sqlStatement := `
+	UPDATE shipments
+	SET shipment_status = $2, carrier = $3, tracking_number = $4, updated_at = $5, shipped_at = $6, delivered_at = $7
+	WHERE shipment_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, shipment.ShipmentID, shipment.Status, nullString(shipment.Carrier), nullString(shipment.TrackingNumber), shipment.UpdatedAt, shipment.ShippedAt, shipment.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("updating shipment on db: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("updating shipment on db: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("updating shipment on db: %w", book.ErrResponseShipmentNotFound)
+	}
+	return nil
+}
+
+/* Appends a status change to the history of an order. */
+func (store *Store) InsertOrderStatusChange(ctx context.Context, change book.StatusChange) error {
+	sqlStatement := `
+	INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, changed_at)
+	VALUES ($1, $2, $3, $4, $5);`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, change.OrderID, nullString(change.FromStatus), change.ToStatus, change.ChangedBy, change.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("storing order status change on db: %w", err)
+	}
+	return nil
+}
+
+/* Returns the status changes of an order, the oldest first. */
+func (store *Store) ListOrderStatusHistory(ctx context.Context, orderID uuid.UUID) ([]book.StatusChange, error) {
+	sqlStatement := `SELECT order_id, from_status, to_status, changed_by, changed_at
+	FROM order_status_history
+	WHERE order_id = $1
+	ORDER BY changed_at ASC, id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("listing order status history from db: %w", err)
+	}
+	defer rows.Close()
+	history := []book.StatusChange{}
+	for rows.Next() {
+		var change book.StatusChange
+		var fromStatus sql.NullString //NULL for the status the order was created with.
+		err = rows.Scan(&change.OrderID, &fromStatus, &change.ToStatus, &change.ChangedBy, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("listing order status history from db: %w", err)
+		}
+		change.FromStatus = fromStatus.String
+		history = append(history, change)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing order status history from db: %w", err)
+	}
+
+	return history, nil
+}
+
+/* The columns of a shipping address, all of them NULL while the order has no address. */
+type nullShippingAddress struct {
+	Recipient, Line1, Line2, City, State, PostalCode, Country sql.NullString
+}
+
+func (a nullShippingAddress) toShippingAddress() *book.ShippingAddress {
+	if !a.Line1.Valid {
+		return nil
+	}
+	return &book.ShippingAddress{
+		Recipient:  a.Recipient.String,
+		Line1:      a.Line1.String,
+		Line2:      a.Line2.String,
+		City:       a.City.String,
+		State:      a.State.String,
+		PostalCode: a.PostalCode.String,
+		Country:    a.Country.String,
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+/* Stores uuid.Nil as NULL, as for the purchaser of guest orders. NULL is read back as uuid.Nil. */
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCoupon(row scanner) (book.Coupon, error) {
+	var c book.Coupon
+	err := row.Scan(&c.ID, &c.Code, &c.DiscountType, &c.DiscountValue, &c.MinOrderTotal, &c.ValidFrom, &c.ValidUntil, &c.UsageLimit, &c.TimesUsed, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+func scanWishlistItem(row scanner) (book.WishlistItem, error) {
+	var i book.WishlistItem
+	err := row.Scan(&i.Book.ID, &i.Book.Name, &i.Book.Price, &i.Book.Inventory, &i.Book.CreatedAt, &i.Book.UpdatedAt, &i.Book.Archived, &i.Book.MaxUnitsPerOrder, &i.Book.MaxUnitsPerPurchaser, &i.AddedAt)
+	return i, err
+}
+
+func scanReturn(row scanner) (book.Return, error) {
+	var r book.Return
+	var refundAmount *float32 //Only filled when approved.
+	var refundID sql.NullString
+	err := row.Scan(&r.ReturnID, &r.OrderID, &r.Status, &r.Reason, &refundAmount, &refundID, &r.CreatedAt, &r.UpdatedAt, &r.ResolvedAt)
+	if refundAmount != nil {
+		r.RefundAmount = *refundAmount
+	}
+	r.RefundID = refundID.String
+	return r, err
+}
+
+func scanShipment(row scanner) (book.Shipment, error) {
+	var sh book.Shipment
+	var carrier, trackingNumber sql.NullString //Only filled when shipped.
+	err := row.Scan(&sh.ShipmentID, &sh.OrderID, &sh.PurchaserID, &sh.Status, &carrier, &trackingNumber, &sh.CreatedAt, &sh.UpdatedAt, &sh.ShippedAt, &sh.DeliveredAt)
+	sh.Carrier = carrier.String
+	sh.TrackingNumber = trackingNumber.String
+	return sh, err
+}
+
+/* Stores a new user into the database, checks and returns it if succeed. */
+func (store *Store) CreateUser(ctx context.Context, newUser book.User) (book.User, error) {
+	sqlStatement := `
+	INSERT INTO users (user_id, name, user_role, email, password_hash, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING user_id, name, user_role, email, password_hash, created_at, updated_at`
+	createdRow := store.exc.QueryRowContext(ctx, sqlStatement, newUser.UserID, newUser.Name, newUser.Role, nullString(newUser.Email), nullString(newUser.PasswordHash), newUser.CreatedAt, newUser.UpdatedAt)
+	userToReturn, err := scanUser(createdRow)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return book.User{}, fmt.Errorf("storing user on db: %w", book.ErrResponseUserEmailInUse)
+		}
+		return book.User{}, fmt.Errorf("storing user on db: %w", err)
+	}
+
+	return userToReturn, nil
+}
+
+/* Searches a user in database based on ID and returns it if succeed. */
+func (store *Store) GetUserByID(ctx context.Context, id uuid.UUID) (book.User, error) {
+	sqlStatement := `SELECT user_id, name, user_role, email, password_hash, created_at, updated_at
+	FROM users
+	WHERE user_id = $1;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, id)
+	userToReturn, err := scanUser(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.User{}, fmt.Errorf("searching user by ID: %w", book.ErrResponseUserNotFound)
+		default:
+			return book.User{}, fmt.Errorf("searching user by ID: %w", err)
+		}
+	}
+
+	return userToReturn, nil
+}
+
+/* Returns all the users, ordered by name. */
+func (store *Store) ListUsers(ctx context.Context) ([]book.User, error) {
+	sqlStatement := `SELECT user_id, name, user_role, email, password_hash, created_at, updated_at
+	FROM users
+	ORDER BY name ASC, user_id ASC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, fmt.Errorf("listing users from db: %w", err)
+	}
+	defer rows.Close()
+	usersList := []book.User{}
+	for rows.Next() {
+		userToReturn, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing users from db: %w", err)
+		}
+
+		usersList = append(usersList, userToReturn)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing users from db: %w", err)
+	}
+
+	return usersList, nil
+}
+
+/* Updates a user on database, checks and returns it if succeed. A blank password hash keeps the stored one. */
+func (store *Store) UpdateUser(ctx context.Context, userEntry book.User) (book.User, error) {
+	sqlStatement := `
+	UPDATE users
+	SET name = $2, user_role = $3, email = $4, password_hash = COALESCE($5, password_hash), updated_at = $6
+	WHERE user_id = $1
+	RETURNING user_id, name, user_role, email, password_hash, created_at, updated_at`
+	updatedRow := store.exc.QueryRowContext(ctx, sqlStatement, userEntry.UserID, userEntry.Name, userEntry.Role, nullString(userEntry.Email), nullString(userEntry.PasswordHash), userEntry.UpdatedAt)
+	userToReturn, err := scanUser(updatedRow)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return book.User{}, fmt.Errorf("updating user on db: %w", book.ErrResponseUserNotFound)
+		case isUniqueViolation(err):
+			return book.User{}, fmt.Errorf("updating user on db: %w", book.ErrResponseUserEmailInUse)
+		default:
+			return book.User{}, fmt.Errorf("updating user on db: %w", err)
+		}
+	}
+
+	return userToReturn, nil
+}
+
+/* Deletes a user from database. The foreign key of the orders keeps users with orders from being deleted. */
+func (store *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	sqlStatement := `
+	DELETE FROM users
+	WHERE user_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("deleting user on db: %w", book.ErrResponseUserHasOrders)
+		}
+		return fmt.Errorf("deleting user on db: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting user on db: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("deleting user on db: %w", book.ErrResponseUserNotFound)
+	}
+	return nil
+}
+
+/* Searches a user in database based on its email and returns it if succeed. */
+func (store *Store) GetUserByEmail(ctx context.Context, email string) (book.User, error) {
+	sqlStatement := `SELECT user_id, name, user_role, email, password_hash, created_at, updated_at
+	FROM users
+	WHERE email = $1;`
+	foundRow := store.exc.QueryRowContext(ctx, sqlStatement, email)
+	userToReturn, err := scanUser(foundRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.User{}, fmt.Errorf("searching user by email: %w", book.ErrResponseUserNotFound)
+		default:
+			return book.User{}, fmt.Errorf("searching user by email: %w", err)
+		}
+	}
+
+	return userToReturn, nil
+}
+
+/* Stores the hash of a new refresh token. */
+func (store *Store) StoreRefreshToken(ctx context.Context, token book.RefreshToken) error {
+	sqlStatement := `
+	INSERT INTO refresh_tokens (token_hash, user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4);`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, token.TokenHash, token.UserID, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("storing refresh token on db: %w", err)
+	}
+	return nil
+}
+
+/* Searches a refresh token by its hash, locking it until the end of the transaction. */
+func (store *Store) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (book.RefreshToken, error) {
+	sqlStatement := `SELECT token_hash, user_id, expires_at, created_at, revoked_at
+	FROM refresh_tokens
+	WHERE token_hash = $1
+	FOR UPDATE;`
+	var token book.RefreshToken
+	err := store.exc.QueryRowContext(ctx, sqlStatement, tokenHash).Scan(&token.TokenHash, &token.UserID, &token.ExpiresAt, &token.CreatedAt, &token.RevokedAt)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.RefreshToken{}, fmt.Errorf("searching refresh token for update: %w", book.ErrResponseRefreshTokenInvalid)
+		default:
+			return book.RefreshToken{}, fmt.Errorf("searching refresh token for update: %w", err)
+		}
+	}
+
+	return token, nil
+}
+
+/* Marks a refresh token as revoked. Tokens already revoked keep their first revoking time. */
+func (store *Store) RevokeRefreshToken(ctx context.Context, tokenHash string, revokedAt time.Time) error {
+	sqlStatement := `
+	UPDATE refresh_tokens
+	SET revoked_at = COALESCE(revoked_at, $2)
+	WHERE token_hash = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, tokenHash, revokedAt)
+	if err != nil {
+		return fmt.Errorf("revoking refresh token on db: %w", err)
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("revoking refresh token on db: %w", err)
+	}
+	if revoked == 0 {
+		return fmt.Errorf("revoking refresh token on db: %w", book.ErrResponseRefreshTokenInvalid)
+	}
+	return nil
+}
+
+/* Deletes every refresh token of a user, so no session of it can be renewed. */
+func (store *Store) DeleteUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	sqlStatement := `
+	DELETE FROM refresh_tokens
+	WHERE user_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, userID)
+	if err != nil {
+		return fmt.Errorf("deleting refresh tokens of user on db: %w", err)
+	}
+	return nil
+}
+
+/* Replaces the name of a user and drops its email and password, so it can't be told apart nor log in. Its role goes back to user. */
+func (store *Store) AnonymizeUser(ctx context.Context, userID uuid.UUID, name string, erasedAt time.Time) (book.User, error) {
+	sqlStatement := `
+	UPDATE users
+	SET name = $2, user_role = $3, email = NULL, password_hash = NULL, updated_at = $4
+	WHERE user_id = $1
+	RETURNING user_id, name, user_role, email, password_hash, created_at, updated_at`
+	updatedRow := store.exc.QueryRowContext(ctx, sqlStatement, userID, name, book.UserRoleUser, erasedAt)
+	userToReturn, err := scanUser(updatedRow)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.User{}, fmt.Errorf("anonymizing user on db: %w", book.ErrResponseUserNotFound)
+		default:
+			return book.User{}, fmt.Errorf("anonymizing user on db: %w", err)
+		}
+	}
+
+	return userToReturn, nil
+}
+
+/* Stores a new API key on database and returns it. */
+func (store *Store) CreateAPIKey(ctx context.Context, newKey book.APIKey) (book.APIKey, error) {
+	sqlStatement := `
+	INSERT INTO api_keys (key_id, user_id, name, prefix, key_hash, scopes, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING key_id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at;`
+	scopes := []string{}
+	for _, scope := range newKey.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+	row := store.exc.QueryRowContext(ctx, sqlStatement, newKey.KeyID, newKey.UserID, newKey.Name, newKey.Prefix, newKey.KeyHash, pq.Array(scopes), newKey.CreatedAt)
+	storedKey, err := scanAPIKey(row)
+	if err != nil {
+		return book.APIKey{}, fmt.Errorf("storing api key on db: %w", err)
+	}
+	return storedKey, nil
+}
+
+/* Searches an API key by the prefix it is looked up with. */
+func (store *Store) GetAPIKeyByPrefix(ctx context.Context, prefix string) (book.APIKey, error) {
+	sqlStatement := `SELECT key_id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+	FROM api_keys
+	WHERE prefix = $1;`
+	key, err := scanAPIKey(store.exc.QueryRowContext(ctx, sqlStatement, prefix))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.APIKey{}, fmt.Errorf("searching api key by prefix: %w", book.ErrResponseAPIKeyNotFound)
+		default:
+			return book.APIKey{}, fmt.Errorf("searching api key by prefix: %w", err)
+		}
+	}
+	return key, nil
+}
+
+/* Lists all the API keys, revoked ones included, the newest first. */
+func (store *Store) ListAPIKeys(ctx context.Context) ([]book.APIKey, error) {
+	sqlStatement := `SELECT key_id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+	FROM api_keys
+	ORDER BY created_at DESC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, fmt.Errorf("listing api keys from db: %w", err)
+	}
+	defer rows.Close()
+	keysList := []book.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing api keys from db: %w", err)
+		}
+		keysList = append(keysList, key)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing api keys from db: %w", err)
+	}
+
+	return keysList, nil
+}
+
+/* Marks an API key as revoked and returns it. Keys already revoked keep their first revoking time. */
+func (store *Store) RevokeAPIKey(ctx context.Context, keyID uuid.UUID, revokedAt time.Time) (book.APIKey, error) {
+	sqlStatement := `
+	UPDATE api_keys
+	SET revoked_at = COALESCE(revoked_at, $2)
+	WHERE key_id = $1
+	RETURNING key_id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at;`
+	key, err := scanAPIKey(store.exc.QueryRowContext(ctx, sqlStatement, keyID, revokedAt))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.APIKey{}, fmt.Errorf("revoking api key on db: %w", book.ErrResponseAPIKeyNotFound)
+		default:
+			return book.APIKey{}, fmt.Errorf("revoking api key on db: %w", err)
+		}
+	}
+	return key, nil
+}
+
+/* Records when an API key was last used. */
+func (store *Store) TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time) error {
+	sqlStatement := `
+	UPDATE api_keys
+	SET last_used_at = $2
+	WHERE key_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, keyID, usedAt)
+	if err != nil {
+		return fmt.Errorf("touching api key on db: %w", err)
+	}
+	return nil
+}
+
+/* Stores a new webhook on database and returns it. */
+func (store *Store) CreateWebhook(ctx context.Context, newWebhook book.Webhook) (book.Webhook, error) {
+	sqlStatement := `
+	INSERT INTO webhooks (webhook_id, url, event_types, secret, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING webhook_id, url, event_types, secret, created_at, updated_at;`
+	row := store.exc.QueryRowContext(ctx, sqlStatement, newWebhook.WebhookID, newWebhook.URL, pq.Array(eventTypesToStrings(newWebhook.EventTypes)), newWebhook.Secret, newWebhook.CreatedAt, newWebhook.UpdatedAt)
+	storedWebhook, err := scanWebhook(row)
+	if err != nil {
+		return book.Webhook{}, fmt.Errorf("storing webhook on db: %w", err)
+	}
+	return storedWebhook, nil
+}
+
+/* Searches a webhook by its ID. */
+func (store *Store) GetWebhookByID(ctx context.Context, id uuid.UUID) (book.Webhook, error) {
+	sqlStatement := `SELECT webhook_id, url, event_types, secret, created_at, updated_at
+	FROM webhooks
+	WHERE webhook_id = $1;`
+	wh, err := scanWebhook(store.exc.QueryRowContext(ctx, sqlStatement, id))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Webhook{}, fmt.Errorf("searching webhook by ID: %w", book.ErrResponseWebhookNotFound)
+		default:
+			return book.Webhook{}, fmt.Errorf("searching webhook by ID: %w", err)
+		}
+	}
+	return wh, nil
+}
+
+/* Lists all the webhooks, the newest first. */
+func (store *Store) ListWebhooks(ctx context.Context) ([]book.Webhook, error) {
+	sqlStatement := `SELECT webhook_id, url, event_types, secret, created_at, updated_at
+	FROM webhooks
+	ORDER BY created_at DESC;`
+	rows, err := store.exc.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, fmt.Errorf("listing webhooks from db: %w", err)
+	}
+	defer rows.Close()
+	webhooksList := []book.Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing webhooks from db: %w", err)
+		}
+		webhooksList = append(webhooksList, wh)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("listing webhooks from db: %w", err)
+	}
+
+	return webhooksList, nil
+}
+
+/* Updates the URL and event types of a webhook, and its secret when a new one is given, and returns it. */
+func (store *Store) UpdateWebhook(ctx context.Context, webhook book.Webhook) (book.Webhook, error) {
+	sqlStatement := `
+	UPDATE webhooks
+	SET url = $2, event_types = $3, secret = COALESCE(NULLIF($4, ''), secret), updated_at = $5
+	WHERE webhook_id = $1
+	RETURNING webhook_id, url, event_types, secret, created_at, updated_at;`
+	row := store.exc.QueryRowContext(ctx, sqlStatement, webhook.WebhookID, webhook.URL, pq.Array(eventTypesToStrings(webhook.EventTypes)), webhook.Secret, webhook.UpdatedAt)
+	wh, err := scanWebhook(row)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return book.Webhook{}, fmt.Errorf("updating webhook on db: %w", book.ErrResponseWebhookNotFound)
+		default:
+			return book.Webhook{}, fmt.Errorf("updating webhook on db: %w", err)
+		}
+	}
+	return wh, nil
+}
+
+/* Deletes a webhook, and its deliveries with it. */
+func (store *Store) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	sqlStatement := `
+	DELETE FROM webhooks
+	WHERE webhook_id = $1;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, id)
+	if err != nil {
+		return fmt.Errorf("deleting webhook on db: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting webhook on db: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("deleting webhook on db: %w", book.ErrResponseWebhookNotFound)
+	}
+	return nil
+}
+
+/* Stores a copy of the delivery for each webhook subscribed to its event type, and returns how many were stored. Webhooks that already have a delivery of the event are skipped. */
+func (store *Store) EnqueueWebhookDeliveries(ctx context.Context, delivery book.WebhookDelivery) (int, error) {
+	sqlStatement := `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+	SELECT webhook_id, $1, $2, $3, $4, $5, $6
+	FROM webhooks
+	WHERE $2 = ANY(event_types)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING;`
+	result, err := store.exc.ExecContext(ctx, sqlStatement, delivery.EventID, delivery.EventType, delivery.Payload, delivery.Status, delivery.NextAttemptAt, delivery.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("enqueuing webhook deliveries on db: %w", err)
+	}
+	enqueued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("enqueuing webhook deliveries on db: %w", err)
+	}
+	return int(enqueued), nil
+}
+
+/* Lists the oldest pending deliveries due by now, locking them until the end of the transaction. Deliveries locked by another transaction are skipped. */
+func (store *Store) ListPendingWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]book.WebhookDelivery, error) {
+	sqlStatement := `
+	SELECT delivery_id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+	FROM webhook_deliveries
+	WHERE status = $1 AND next_attempt_at <= $2
+	ORDER BY created_at
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED;`
+	return store.listWebhookDeliveries(ctx, "listing pending webhook deliveries from db", sqlStatement, book.WebhookDeliveryPending, now, limit)
+}
+
+/* Stores the outcome of the last attempt of a delivery. */
+func (store *Store) UpdateWebhookDelivery(ctx context.Context, delivery book.WebhookDelivery) error {
+	sqlStatement := `
+	UPDATE webhook_deliveries
+	SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, delivered_at = $7
+	WHERE delivery_id = $1;`
+	_, err := store.exc.ExecContext(ctx, sqlStatement, delivery.DeliveryID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("updating webhook delivery on db: %w", err)
+	}
+	return nil
+}
+
+/* Lists the latest deliveries of a webhook, the newest first. */
+func (store *Store) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]book.WebhookDelivery, error) {
+	sqlStatement := `
+	SELECT delivery_id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY created_at DESC
+	LIMIT $2;`
+	return store.listWebhookDeliveries(ctx, "listing webhook deliveries from db", sqlStatement, webhookID, limit)
+}
+
+func (store *Store) listWebhookDeliveries(ctx context.Context, action string, sqlStatement string, args ...any) ([]book.WebhookDelivery, error) {
+	rows, err := store.exc.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", action, err)
+	}
+	defer rows.Close()
+
+	deliveries := []book.WebhookDelivery{}
+	for rows.Next() {
+		var d book.WebhookDelivery
+		err := rows.Scan(&d.DeliveryID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", action, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", action, err)
+	}
+	return deliveries, nil
+}
+
+func scanAPIKey(row scanner) (book.APIKey, error) {
+	var k book.APIKey
+	var scopes []string
+	err := row.Scan(&k.KeyID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, pq.Array(&scopes), &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	for _, scope := range scopes {
+		k.Scopes = append(k.Scopes, book.Permission(scope))
+	}
+	return k, err
+}
+
+func scanWebhook(row scanner) (book.Webhook, error) {
+	var wh book.Webhook
+	var eventTypes []string
+	err := row.Scan(&wh.WebhookID, &wh.URL, pq.Array(&eventTypes), &wh.Secret, &wh.CreatedAt, &wh.UpdatedAt)
+	for _, eventType := range eventTypes {
+		wh.EventTypes = append(wh.EventTypes, book.EventType(eventType))
+	}
+	return wh, err
+}
+
+func eventTypesToStrings(eventTypes []book.EventType) []string {
+	strs := []string{}
+	for _, eventType := range eventTypes {
+		strs = append(strs, string(eventType))
+	}
+	return strs
+}
+
+func scanUser(row scanner) (book.User, error) {
+	var u book.User
+	var email, passwordHash sql.NullString //Users made before logins have neither.
+	err := row.Scan(&u.UserID, &u.Name, &u.Role, &email, &passwordHash, &u.CreatedAt, &u.UpdatedAt)
+	u.Email = email.String
+	u.PasswordHash = passwordHash.String
+	return u, err
+}
+
+/* Checks if the error is a violation of an unique constraint on postgres. */
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+/* Checks if the error is a violation of a foreign key constraint on postgres. */
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
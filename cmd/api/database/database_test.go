@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -423,7 +425,7 @@ func TestCreateOrder(t *testing.T) {
 
 		o := book.Order{
 			OrderID:     uuid.New(),
-			PurchaserID: uuid.New(),
+			PurchaserID: createUser(t),
 			OrderStatus: "accepting_items",
 			CreatedAt:   time.Now().UTC().Round(time.Millisecond),
 			UpdatedAt:   time.Now().UTC().Round(time.Millisecond),
@@ -467,7 +469,7 @@ func TestListOrderItems(t *testing.T) {
 		//creating order to be fetched:
 		o := book.Order{
 			OrderID:     uuid.New(),
-			PurchaserID: uuid.New(),
+			PurchaserID: createUser(t),
 			OrderStatus: "accepting_items",
 			CreatedAt:   time.Now().UTC().Round(time.Millisecond),
 			UpdatedAt:   time.Now().UTC().Round(time.Millisecond),
@@ -493,7 +495,8 @@ func TestListOrderItems(t *testing.T) {
 			storedList = append(storedList, bookAtOrder)
 		}
 		o.Items = storedList
-		o.TotalPrice = 10 //Each item at list has 1 unit with price 2. List size is 5. So total price should result 10.
+		o.Subtotal = 10 //Each item at list has 1 unit with price 2. List size is 5. So total price should result 10.
+		o.TotalPrice = 10
 
 		//testing if it returns a valid list:
 		fetchedOrder, err := store.ListOrderItems(ctx, o.OrderID)
@@ -542,7 +545,7 @@ func TestUpdateOrderTx(t *testing.T) {
 	//creating order to be fetched:
 	o := book.Order{
 		OrderID:     uuid.New(),
-		PurchaserID: uuid.New(),
+		PurchaserID: createUser(t),
 		OrderStatus: "accepting_items",
 		CreatedAt:   createdNow,
 		UpdatedAt:   createdNow,
@@ -572,7 +575,7 @@ func TestUpdateOrderTx(t *testing.T) {
 		is.NoErr(err)
 
 		//Testing if there are sufficient inventory of the book asked, and if is not archived:
-		bk, err := txRepo.GetBookByID(ctx, BookID)
+		bk, err := txRepo.GetBookByIDForUpdate(ctx, BookID)
 		is.NoErr(err)
 		is.True(!bk.Archived)
 		balance := *bk.Inventory - BookUnitsToAdd
@@ -644,7 +647,7 @@ func TestUpdateOrderTx(t *testing.T) {
 		is.NoErr(err)
 
 		//Testing if there are sufficient inventory of the book asked, and if is not archived:
-		bk, err := txRepo.GetBookByID(ctx, BookID)
+		bk, err := txRepo.GetBookByIDForUpdate(ctx, BookID)
 		is.NoErr(err)
 		is.True(!bk.Archived)
 		balance := *bk.Inventory - BookUnitsToAdd
@@ -710,7 +713,7 @@ func TestUpdateOrderTx(t *testing.T) {
 		is.NoErr(err)
 
 		//Testing if there are sufficient inventory of the book asked, and if is not archived:
-		bk, err := txRepo.GetBookByID(ctx, BookID)
+		bk, err := txRepo.GetBookByIDForUpdate(ctx, BookID)
 		is.NoErr(err)
 		is.True(!bk.Archived)
 		balance := *bk.Inventory - BookUnitsToAdd
@@ -750,6 +753,1816 @@ func TestUpdateOrderTx(t *testing.T) {
 	})
 }
 
+func TestIdempotencyKeys(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	k := book.IdempotencyKey{
+		Key:         "a-key-to-test",
+		Caller:      "user:" + uuid.NewString(),
+		RequestHash: "a-hash",
+		CreatedAt:   createdNow,
+		ExpiresAt:   createdNow.Add(time.Minute),
+	}
+
+	t.Run("reserves a key only once, until it is released", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(store.ReserveIdempotencyKey(ctx, k))
+
+		err := store.ReserveIdempotencyKey(ctx, k) //A concurrent retry.
+		is.True(errors.Is(err, book.ErrResponseIdempotencyKeyReused))
+
+		reserved, err := store.GetIdempotencyKey(ctx, k.Caller, k.Key)
+		is.NoErr(err)
+		is.Equal(reserved.CompletedAt, nil)
+
+		is.NoErr(store.ReleaseIdempotencyKey(ctx, k.Caller, k.Key))
+		is.NoErr(store.ReserveIdempotencyKey(ctx, k))
+	})
+
+	t.Run("stores the response of a reserved key, that another caller can't see", func(t *testing.T) {
+		is := is.New(t)
+
+		completedAt := createdNow.Add(time.Second)
+		completed := k
+		completed.ResponseStatus = 201
+		completed.ResponseBody = []byte(`{"id":"some-id"}`)
+		completed.ExpiresAt = createdNow.Add(time.Hour)
+		completed.CompletedAt = &completedAt
+		is.NoErr(store.CompleteIdempotencyKey(ctx, completed))
+
+		fetchedKey, err := store.GetIdempotencyKey(ctx, k.Caller, k.Key)
+		is.NoErr(err)
+		is.Equal(fetchedKey.ResponseStatus, completed.ResponseStatus)
+		is.Equal(fetchedKey.ResponseBody, completed.ResponseBody)
+		is.True(fetchedKey.ExpiresAt.Equal(completed.ExpiresAt))
+		is.True(fetchedKey.CompletedAt.Equal(completedAt))
+
+		is.NoErr(store.ReleaseIdempotencyKey(ctx, k.Caller, k.Key)) //Completed keys are kept.
+		_, err = store.GetIdempotencyKey(ctx, k.Caller, k.Key)
+		is.NoErr(err)
+
+		_, err = store.GetIdempotencyKey(ctx, "user:"+uuid.NewString(), k.Key)
+		is.True(errors.Is(err, book.ErrResponseIdempotencyKeyNotFound))
+
+		otherCaller := k
+		otherCaller.Caller = "user:" + uuid.NewString()
+		is.NoErr(store.ReserveIdempotencyKey(ctx, otherCaller))
+	})
+
+	t.Run("an expired key is not found, can be reserved again and is purged", func(t *testing.T) {
+		is := is.New(t)
+
+		expiredKey := k
+		expiredKey.Key = "an-expired-key"
+		expiredKey.ExpiresAt = createdNow.Add(-time.Hour)
+		is.NoErr(store.ReserveIdempotencyKey(ctx, expiredKey))
+
+		_, err := store.GetIdempotencyKey(ctx, expiredKey.Caller, expiredKey.Key)
+		is.True(errors.Is(err, book.ErrResponseIdempotencyKeyNotFound))
+
+		purged, err := store.PurgeExpiredIdempotencyKeys(ctx, createdNow)
+		is.NoErr(err)
+		is.Equal(purged, 1)
+
+		expiredKey.ExpiresAt = createdNow.Add(-time.Minute)
+		is.NoErr(store.ReserveIdempotencyKey(ctx, expiredKey))
+		expiredKey.ExpiresAt = createdNow.Add(time.Hour)
+		is.NoErr(store.ReserveIdempotencyKey(ctx, expiredKey)) //Overwrites the expired one.
+	})
+}
+
+func TestCoupons(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	c := book.Coupon{
+		ID:            uuid.New(),
+		Code:          "TENOFF",
+		DiscountType:  book.DiscountTypePercentage,
+		DiscountValue: 10,
+		MinOrderTotal: 5,
+		UsageLimit:    toPointer(1),
+		CreatedAt:     createdNow,
+		UpdatedAt:     createdNow,
+	}
+
+	t.Run("creates and gets a coupon without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		newCoupon, err := store.CreateCoupon(ctx, c)
+		is.NoErr(err)
+		is.Equal(newCoupon.Code, c.Code)
+
+		fetchedCoupon, err := store.GetCouponByCode(ctx, c.Code)
+		is.NoErr(err)
+		is.Equal(fetchedCoupon.ID, c.ID)
+		is.Equal(*fetchedCoupon.UsageLimit, 1)
+	})
+
+	t.Run("creates a coupon with a code already in use should return a code in use error", func(t *testing.T) {
+		is := is.New(t)
+
+		repeated := c
+		repeated.ID = uuid.New()
+		_, err := store.CreateCoupon(ctx, repeated)
+		is.True(errors.Is(err, book.ErrResponseCouponCodeInUse))
+	})
+
+	t.Run("applies the coupon to an order and lists it with the discount", func(t *testing.T) {
+		is := is.New(t)
+
+		b := book.Book{
+			ID:        uuid.New(),
+			Name:      "Book with discount",
+			Price:     toPointer(float32(25)),
+			Inventory: toPointer(10),
+			CreatedAt: createdNow,
+			UpdatedAt: createdNow,
+		}
+		_, err := store.CreateBook(ctx, b)
+		is.NoErr(err)
+
+		o := book.Order{
+			OrderID:     uuid.New(),
+			PurchaserID: createUser(t),
+			OrderStatus: "accepting_items",
+			CreatedAt:   createdNow,
+			UpdatedAt:   createdNow,
+		}
+		_, err = store.CreateOrder(ctx, o)
+		is.NoErr(err)
+		_, err = store.UpsertOrderItem(ctx, o.OrderID, book.OrderItem{BookID: b.ID, BookName: b.Name, BookUnits: 2, BookPriceAtOrder: b.Price})
+		is.NoErr(err)
+
+		err = store.IncrementCouponUsage(ctx, c.ID)
+		is.NoErr(err)
+		err = store.SetOrderCoupon(ctx, o.OrderID, c.ID)
+		is.NoErr(err)
+
+		fetchedOrder, err := store.ListOrderItems(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(fetchedOrder.CouponCode, c.Code)
+		is.Equal(fetchedOrder.Subtotal, float32(50))
+		is.Equal(fetchedOrder.Discount, float32(5))
+		is.Equal(fetchedOrder.TotalPrice, float32(45))
+	})
+
+	t.Run("using a coupon over its usage limit should return a usage limit error", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.IncrementCouponUsage(ctx, c.ID)
+		is.True(errors.Is(err, book.ErrResponseCouponUsageLimitReached))
+	})
+
+	t.Run("deletes a coupon without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.DeleteCoupon(ctx, c.ID)
+		is.NoErr(err)
+
+		_, err = store.GetCouponByID(ctx, c.ID)
+		is.True(errors.Is(err, book.ErrResponseCouponNotFound))
+	})
+}
+
+func TestGetBookByIDForUpdate(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Book to lock",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(10),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("a locked book waits for the transaction that locked it", func(t *testing.T) {
+		is := is.New(t)
+
+		txRepo1, tx1, err := store.BeginTx(ctx, nil)
+		is.NoErr(err)
+		defer tx1.Rollback()
+		txRepo2, tx2, err := store.BeginTx(ctx, nil)
+		is.NoErr(err)
+		defer tx2.Rollback()
+
+		bk, err := txRepo1.GetBookByIDForUpdate(ctx, b.ID)
+		is.NoErr(err)
+		compareBooks(is, b, bk)
+
+		//While the first transaction holds the lock, the second one can't get the book:
+		waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		_, err = txRepo2.GetBookByIDForUpdate(waitCtx, b.ID)
+		is.True(err != nil)
+
+		*bk.Inventory = 7
+		_, err = txRepo1.UpdateBook(ctx, bk)
+		is.NoErr(err)
+		is.NoErr(tx1.Commit())
+
+		//After the commit, a new transaction reads the latest inventory:
+		txRepo3, tx3, err := store.BeginTx(ctx, nil)
+		is.NoErr(err)
+		defer tx3.Rollback()
+		lockedBook, err := txRepo3.GetBookByIDForUpdate(ctx, b.ID)
+		is.NoErr(err)
+		is.Equal(*lockedBook.Inventory, 7)
+	})
+
+	t.Run("locks an inexistent book should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.GetBookByIDForUpdate(ctx, uuid.New())
+		is.True(errors.Is(err, book.ErrResponseBookNotFound))
+	})
+}
+
+func TestReserveOrderItem(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Limited edition",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(5),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	archivedBook := book.Book{
+		ID:        uuid.New(),
+		Name:      "Archived edition",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(5),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	o := book.Order{OrderID: uuid.New(), PurchaserID: createUser(t), OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow}
+	for _, bk := range []book.Book{b, archivedBook} {
+		_, err := store.CreateBook(ctx, bk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := store.SetBookArchiveStatus(ctx, archivedBook.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("adds a book to the order, taking its inventory", func(t *testing.T) {
+		is := is.New(t)
+
+		item, err := store.ReserveOrderItem(ctx, o.OrderID, b.ID, 3)
+		is.NoErr(err)
+		is.Equal(item.BookID, b.ID)
+		is.Equal(item.BookName, b.Name)
+		is.Equal(item.BookUnits, 3)
+		is.Equal(*item.BookPriceAtOrder, float32(30))
+
+		fetchedBook, err := store.GetBookByID(ctx, b.ID)
+		is.NoErr(err)
+		is.Equal(*fetchedBook.Inventory, 2) //5 - 3
+	})
+
+	t.Run("adds units to a book already at the order, keeping its price at order", func(t *testing.T) {
+		is := is.New(t)
+
+		b.Price = toPointer(float32(45))
+		_, err := store.UpdateBook(ctx, book.Book{ID: b.ID, Name: b.Name, Price: b.Price, Inventory: toPointer(2), UpdatedAt: time.Now().UTC()})
+		is.NoErr(err)
+
+		item, err := store.ReserveOrderItem(ctx, o.OrderID, b.ID, 2)
+		is.NoErr(err)
+		is.Equal(item.BookUnits, 5)
+		is.Equal(*item.BookPriceAtOrder, float32(30))
+
+		fetchedBook, err := store.GetBookByID(ctx, b.ID)
+		is.NoErr(err)
+		is.Equal(*fetchedBook.Inventory, 0)
+	})
+
+	t.Run("expected insufficient inventory error, taking nothing", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.ReserveOrderItem(ctx, o.OrderID, b.ID, 1)
+		is.True(errors.Is(err, book.ErrResponseInsufficientInventory))
+
+		item, err := store.GetOrderItem(ctx, o.OrderID, b.ID)
+		is.NoErr(err)
+		is.Equal(item.BookUnits, 5)
+	})
+
+	t.Run("expected archived book error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.ReserveOrderItem(ctx, o.OrderID, archivedBook.ID, 1)
+		is.True(errors.Is(err, book.ErrResponseBookIsArchived))
+	})
+
+	t.Run("expected book not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.ReserveOrderItem(ctx, o.OrderID, uuid.New(), 1)
+		is.True(errors.Is(err, book.ErrResponseBookNotFound))
+	})
+}
+
+// BenchmarkAddOrderItem compares the old read-check-write path of UpdateOrderTx with the single statement of ReserveOrderItem,
+// with many orders adding units of the same book concurrently. Run with: go test -run XXX -bench AddOrderItem ./cmd/api/database
+func BenchmarkAddOrderItem(b *testing.B) {
+	b.Cleanup(func() {
+		teardownDB(b)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	hotBook := book.Book{
+		ID:        uuid.New(),
+		Name:      "Limited edition drop",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(1_000_000_000),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, hotBook)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	addUnits := map[string]func(txRepo book.Repository, orderID uuid.UUID) error{
+		"read-check-write": func(txRepo book.Repository, orderID uuid.UUID) error {
+			bk, err := txRepo.GetBookByIDForUpdate(ctx, hotBook.ID)
+			if err != nil {
+				return err
+			}
+			if bk.Archived || *bk.Inventory < 1 {
+				return book.ErrResponseInsufficientInventory
+			}
+			item, err := txRepo.GetOrderItem(ctx, orderID, hotBook.ID)
+			if err != nil && !errors.Is(err, book.ErrResponseBookNotAtOrder) {
+				return err
+			}
+			item.BookID = hotBook.ID
+			item.BookName = bk.Name
+			item.BookUnits++
+			if item.BookPriceAtOrder == nil {
+				item.BookPriceAtOrder = bk.Price
+			}
+			_, err = txRepo.UpsertOrderItem(ctx, orderID, item)
+			if err != nil {
+				return err
+			}
+			*bk.Inventory--
+			bk.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
+			_, err = txRepo.UpdateBook(ctx, bk)
+			return err
+		},
+		"conditional-update": func(txRepo book.Repository, orderID uuid.UUID) error {
+			_, err := txRepo.ReserveOrderItem(ctx, orderID, hotBook.ID, 1)
+			return err
+		},
+	}
+
+	purchaserID := createUser(b)
+	for _, name := range []string{"read-check-write", "conditional-update"} {
+		add := addUnits[name]
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				o := book.Order{OrderID: uuid.New(), PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow}
+				_, err := store.CreateOrder(ctx, o)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+
+				for pb.Next() {
+					txRepo, tx, err := store.BeginTx(ctx, nil)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					err = txRepo.UpdateOrderRow(ctx, o.OrderID)
+					if err == nil {
+						err = add(txRepo, o.OrderID)
+					}
+					if err != nil {
+						tx.Rollback()
+						b.Error(err)
+						return
+					}
+					err = tx.Commit()
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestGetPurchaseLimitUsage(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	purchaserID := createUser(t)
+	b := book.Book{
+		ID:                   uuid.New(),
+		Name:                 "Limited edition",
+		Price:                toPointer(float32(30)),
+		Inventory:            toPointer(20),
+		CreatedAt:            createdNow,
+		UpdatedAt:            createdNow,
+		MaxUnitsPerOrder:     toPointer(3),
+		MaxUnitsPerPurchaser: toPointer(5),
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := []book.Order{
+		{OrderID: uuid.New(), PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow},
+		{OrderID: uuid.New(), PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow},
+		{OrderID: uuid.New(), PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow},
+		{OrderID: uuid.New(), PurchaserID: createUser(t), OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow},
+	}
+	for i, o := range orders {
+		_, err = store.CreateOrder(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.ReserveOrderItem(ctx, o.OrderID, b.ID, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = store.CancelAbandonedOrder(ctx, orders[2].OrderID, time.Now().UTC().Add(time.Hour)) //The units of canceled orders are not counted.
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reads the limits of the book and the units already held by the purchaser", func(t *testing.T) {
+		is := is.New(t)
+
+		usage, err := store.GetPurchaseLimitUsage(ctx, orders[1].OrderID, b.ID)
+		is.NoErr(err)
+		is.Equal(*usage.MaxUnitsPerOrder, 3)
+		is.Equal(*usage.MaxUnitsPerPurchaser, 5)
+		is.Equal(usage.UnitsAtOrder, 2)
+		is.Equal(usage.UnitsByPurchaser, 3) //1 + 2, from the orders not canceled
+	})
+
+	t.Run("reads no limits from a book without them", func(t *testing.T) {
+		is := is.New(t)
+
+		unlimited := book.Book{ID: uuid.New(), Name: "Regular edition", Price: toPointer(float32(30)), Inventory: toPointer(20), CreatedAt: createdNow, UpdatedAt: createdNow}
+		_, err := store.CreateBook(ctx, unlimited)
+		is.NoErr(err)
+
+		usage, err := store.GetPurchaseLimitUsage(ctx, orders[0].OrderID, unlimited.ID)
+		is.NoErr(err)
+		is.Equal(usage, book.PurchaseLimitUsage{})
+	})
+
+	t.Run("expected book not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.GetPurchaseLimitUsage(ctx, orders[0].OrderID, uuid.New())
+		is.True(errors.Is(err, book.ErrResponseBookNotFound))
+	})
+}
+
+func TestPurchaseLimitConcurrentOrders(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	const maxUnits = 5
+	const orders = 10
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	purchaserID := createUser(t)
+	b := book.Book{
+		ID:                   uuid.New(),
+		Name:                 "Limited edition",
+		Price:                toPointer(float32(30)),
+		Inventory:            toPointer(100),
+		CreatedAt:            createdNow,
+		UpdatedAt:            createdNow,
+		MaxUnitsPerPurchaser: toPointer(maxUnits),
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderIDs := make([]uuid.UUID, orders)
+	for i := range orderIDs {
+		orderIDs[i] = uuid.New()
+		_, err = store.CreateOrder(ctx, book.Order{OrderID: orderIDs[i], PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("orders of the same purchaser adding a book concurrently never exceed its limit", func(t *testing.T) {
+		is := is.New(t)
+
+		bookService := book.NewService(store, nil, nil, nil, nil, time.Second, book.TxConfig{}, book.AuthConfig{})
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded, rejected := 0, 0
+		for _, orderID := range orderIDs {
+			wg.Add(1)
+			go func(orderID uuid.UUID) {
+				defer wg.Done()
+				_, err := bookService.UpdateOrderTx(ctx, book.UpdateOrderRequest{OrderID: orderID, BookID: b.ID, BookUnitsToAdd: 1})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					succeeded++
+				case errors.Is(err, book.ErrResponseMaxUnitsPerPurchaserExceeded):
+					rejected++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(orderID)
+		}
+		wg.Wait()
+
+		is.Equal(succeeded, maxUnits)
+		is.Equal(rejected, orders-maxUnits)
+
+		usage, err := store.GetPurchaseLimitUsage(ctx, orderIDs[0], b.ID)
+		is.NoErr(err)
+		is.Equal(usage.UnitsByPurchaser, maxUnits)
+	})
+}
+
+func TestInventoryConcurrentOrders(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	const inventory = 5
+	const orders = 10
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	purchaserID := createUser(t)
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Last copies",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(inventory),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderIDs := make([]uuid.UUID, orders)
+	for i := range orderIDs {
+		orderIDs[i] = uuid.New()
+		_, err = store.CreateOrder(ctx, book.Order{OrderID: orderIDs[i], PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("orders adding a book concurrently never take more units than its inventory", func(t *testing.T) {
+		is := is.New(t)
+
+		bookService := book.NewService(store, nil, nil, nil, nil, time.Second, book.TxConfig{}, book.AuthConfig{})
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded, rejected := 0, 0
+		for _, orderID := range orderIDs {
+			wg.Add(1)
+			go func(orderID uuid.UUID) {
+				defer wg.Done()
+				_, err := bookService.UpdateOrderTx(ctx, book.UpdateOrderRequest{OrderID: orderID, BookID: b.ID, BookUnitsToAdd: 1})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					succeeded++
+				case errors.Is(err, book.ErrResponseInsufficientInventory):
+					rejected++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(orderID)
+		}
+		wg.Wait()
+
+		is.Equal(succeeded, inventory)
+		is.Equal(rejected, orders-inventory)
+
+		foundBook, err := store.GetBookByID(ctx, b.ID)
+		is.NoErr(err)
+		is.Equal(*foundBook.Inventory, 0)
+	})
+}
+
+func TestCheckoutOrder(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	t.Run("checks out an order and lists it with the stored prices", func(t *testing.T) {
+		is := is.New(t)
+
+		createdNow := time.Now().UTC().Round(time.Millisecond)
+		b := book.Book{
+			ID:        uuid.New(),
+			Name:      "Book to checkout",
+			Price:     toPointer(float32(30)),
+			Inventory: toPointer(10),
+			CreatedAt: createdNow,
+			UpdatedAt: createdNow,
+		}
+		_, err := store.CreateBook(ctx, b)
+		is.NoErr(err)
+
+		o := book.Order{
+			OrderID:     uuid.New(),
+			PurchaserID: createUser(t),
+			OrderStatus: "accepting_items",
+			CreatedAt:   createdNow,
+			UpdatedAt:   createdNow,
+		}
+		_, err = store.CreateOrder(ctx, o)
+		is.NoErr(err)
+		_, err = store.UpsertOrderItem(ctx, o.OrderID, book.OrderItem{BookID: b.ID, BookName: b.Name, BookUnits: 2, BookPriceAtOrder: b.Price})
+		is.NoErr(err)
+
+		o.OrderStatus = "waiting_payment"
+		o.TaxRegion = "SP"
+		o.Subtotal = 60
+		o.Tax = 10.8
+		o.Shipping = 15
+		o.TotalPrice = 85.8
+		err = store.CheckoutOrder(ctx, o)
+		is.NoErr(err)
+
+		fetchedOrder, err := store.ListOrderItems(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(fetchedOrder.OrderStatus, "waiting_payment")
+		is.Equal(fetchedOrder.TaxRegion, "SP")
+		is.Equal(fetchedOrder.Subtotal, float32(60))
+		is.Equal(fetchedOrder.Tax, float32(10.8))
+		is.Equal(fetchedOrder.Shipping, float32(15))
+		is.Equal(fetchedOrder.TotalPrice, float32(85.8))
+	})
+
+	t.Run("checks out an inexistent order should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.CheckoutOrder(ctx, book.Order{OrderID: uuid.New(), OrderStatus: "waiting_payment"})
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+}
+
+func TestPaidOrderFulfillment(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	purchaserID := createUser(t)
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Book to pay",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(10),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calc := book.NewRulesCalculator(book.PricingRules{
+		TaxRates:      map[string]float32{"BR-SP": 18},
+		ShippingTiers: []book.ShippingTier{{MaxItems: 0, Price: 10}},
+	})
+	bookService := book.NewService(store, nil, calc, nil, nil, time.Second, book.TxConfig{}, book.AuthConfig{})
+
+	//Fills an order with 2 units of the book, checks it out and confirms its payment, as the payment processor would.
+	paidOrder := func(t *testing.T) uuid.UUID {
+		is := is.New(t)
+
+		orderID := uuid.New()
+		_, err := store.CreateOrder(ctx, book.Order{OrderID: orderID, PurchaserID: purchaserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow})
+		is.NoErr(err)
+		_, err = bookService.UpdateOrderTx(ctx, book.UpdateOrderRequest{OrderID: orderID, BookID: b.ID, BookUnitsToAdd: 2})
+		is.NoErr(err)
+		_, err = bookService.UpdateOrderDetails(ctx, book.UpdateOrderDetailsRequest{
+			OrderID:         orderID,
+			ShippingAddress: &book.ShippingAddress{Recipient: "Ana", Line1: "Rua A, 1", City: "Campinas", State: "SP", PostalCode: "13000-000", Country: "BR"},
+		})
+		is.NoErr(err)
+
+		checkedOutOrder, err := bookService.Checkout(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(checkedOutOrder.OrderStatus, "waiting_payment")
+		is.Equal(checkedOutOrder.TaxRegion, "BR-SP")
+		is.Equal(checkedOutOrder.TotalPrice, float32(80.8)) //60 + 10.8 + 10
+
+		confirmedOrder, err := bookService.ConfirmPayment(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(confirmedOrder.OrderStatus, "paid")
+
+		_, err = bookService.ConfirmPayment(ctx, orderID)
+		is.True(errors.Is(err, book.ErrResponseOrderNotWaitingPayment))
+
+		history, err := bookService.ListOrderHistory(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(history[len(history)-1].FromStatus, "waiting_payment")
+		is.Equal(history[len(history)-1].ToStatus, "paid")
+		return orderID
+	}
+
+	t.Run("a paid order has books picked and shipped", func(t *testing.T) {
+		is := is.New(t)
+		orderID := paidOrder(t)
+
+		picked, err := bookService.PickShipment(ctx, book.PickShipmentRequest{OrderID: orderID, Items: []book.ShipmentItem{{BookID: b.ID, BookUnits: 1}}})
+		is.NoErr(err)
+		_, err = bookService.ShipShipment(ctx, book.ShipShipmentRequest{ShipmentID: picked.ShipmentID, Carrier: "Correios", TrackingNumber: "BR123"})
+		is.NoErr(err)
+
+		foundOrder, err := store.ListOrderItems(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(foundOrder.OrderStatus, "partially_shipped")
+	})
+
+	t.Run("a paid order has books returned", func(t *testing.T) {
+		is := is.New(t)
+		orderID := paidOrder(t)
+
+		_, err := bookService.RequestReturn(ctx, book.CreateReturnRequest{OrderID: orderID, Reason: "damaged", Items: []book.ReturnItem{{BookID: b.ID, BookUnits: 1}}})
+		is.NoErr(err)
+
+		foundOrder, err := store.ListOrderItems(ctx, orderID)
+		is.NoErr(err)
+		is.Equal(foundOrder.OrderStatus, "return_requested")
+	})
+}
+
+func TestSetOrderDetails(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	o := book.Order{
+		OrderID:     uuid.New(),
+		PurchaserID: createUser(t),
+		OrderStatus: "accepting_items",
+		CreatedAt:   createdNow,
+		UpdatedAt:   createdNow,
+	}
+	_, err := store.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("stores the details and lists them with the order", func(t *testing.T) {
+		is := is.New(t)
+
+		o.ShippingAddress = &book.ShippingAddress{
+			Recipient:  "Maria Silva",
+			Line1:      "Rua das Flores, 100",
+			City:       "São Paulo",
+			PostalCode: "01000-000",
+			Country:    "BR",
+		}
+		o.ContactPhone = "+55 11 99999-0000"
+		o.Notes = "Leave it at the front desk"
+		err := store.SetOrderDetails(ctx, o)
+		is.NoErr(err)
+
+		fetchedOrder, err := store.ListOrderItems(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(fetchedOrder.ShippingAddress, o.ShippingAddress)
+		is.Equal(fetchedOrder.ContactEmail, "")
+		is.Equal(fetchedOrder.ContactPhone, o.ContactPhone)
+		is.Equal(fetchedOrder.Notes, o.Notes)
+	})
+
+	t.Run("clears the details", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.SetOrderDetails(ctx, book.Order{OrderID: o.OrderID})
+		is.NoErr(err)
+
+		fetchedOrder, err := store.ListOrderItems(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(fetchedOrder.ShippingAddress, nil)
+		is.Equal(fetchedOrder.Notes, "")
+	})
+
+	t.Run("sets the details of an inexistent order should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.SetOrderDetails(ctx, book.Order{OrderID: uuid.New()})
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+}
+
+func TestOrderStatusHistory(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	o := book.Order{
+		OrderID:     uuid.New(),
+		PurchaserID: createUser(t),
+		OrderStatus: "accepting_items",
+		CreatedAt:   createdNow,
+		UpdatedAt:   createdNow,
+	}
+	_, err := store.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("appends status changes and lists them, the oldest first", func(t *testing.T) {
+		is := is.New(t)
+
+		created := book.StatusChange{OrderID: o.OrderID, ToStatus: "accepting_items", ChangedBy: book.ActorAnonymous, ChangedAt: createdNow}
+		canceled := book.StatusChange{OrderID: o.OrderID, FromStatus: "accepting_items", ToStatus: "canceled", ChangedBy: book.ActorSystem, ChangedAt: createdNow.Add(time.Hour)}
+		is.NoErr(store.InsertOrderStatusChange(ctx, canceled))
+		is.NoErr(store.InsertOrderStatusChange(ctx, created))
+
+		history, err := store.ListOrderStatusHistory(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(len(history), 2)
+		is.Equal(history[0].FromStatus, "")
+		is.Equal(history[0].ToStatus, "accepting_items")
+		is.True(history[0].ChangedAt.Equal(createdNow))
+		is.Equal(history[1].FromStatus, "accepting_items")
+		is.Equal(history[1].ToStatus, "canceled")
+		is.Equal(history[1].ChangedBy, book.ActorSystem)
+	})
+
+	t.Run("lists the history of an inexistent order should return no changes", func(t *testing.T) {
+		is := is.New(t)
+
+		history, err := store.ListOrderStatusHistory(ctx, uuid.New())
+		is.NoErr(err)
+		is.Equal(history, []book.StatusChange{})
+	})
+}
+
+func TestAbandonedOrders(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	longAgo := time.Now().UTC().Add(-48 * time.Hour).Round(time.Millisecond)
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Book at an abandoned order",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(10),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abandonedOrder := book.Order{OrderID: uuid.New(), PurchaserID: createUser(t), OrderStatus: "accepting_items", CreatedAt: longAgo, UpdatedAt: longAgo}
+	activeOrder := book.Order{OrderID: uuid.New(), PurchaserID: createUser(t), OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow}
+	paidOrder := book.Order{OrderID: uuid.New(), PurchaserID: createUser(t), OrderStatus: "paid", CreatedAt: longAgo, UpdatedAt: longAgo}
+	for _, o := range []book.Order{abandonedOrder, activeOrder, paidOrder} {
+		_, err = store.CreateOrder(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	untouchedSince := time.Now().UTC().Add(-24 * time.Hour)
+
+	t.Run("lists only the orders accepting items not changed since the given moment", func(t *testing.T) {
+		is := is.New(t)
+
+		orderIDs, err := store.ListAbandonedOrders(ctx, untouchedSince)
+		is.NoErr(err)
+		is.Equal(orderIDs, []uuid.UUID{abandonedOrder.OrderID})
+	})
+
+	t.Run("cancels an abandoned order", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.CancelAbandonedOrder(ctx, abandonedOrder.OrderID, untouchedSince)
+		is.NoErr(err)
+
+		fetchedOrder, err := store.ListOrderItems(ctx, abandonedOrder.OrderID)
+		is.NoErr(err)
+		is.Equal(fetchedOrder.OrderStatus, "canceled")
+	})
+
+	t.Run("cancels an order changed recently should return a not accepting items error", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.CancelAbandonedOrder(ctx, activeOrder.OrderID, untouchedSince)
+		is.True(errors.Is(err, book.ErrResponseOrderNotAcceptingItems))
+	})
+
+	t.Run("restocks a book", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.RestockBook(ctx, b.ID, 3)
+		is.NoErr(err)
+
+		fetchedBook, err := store.GetBookByID(ctx, b.ID)
+		is.NoErr(err)
+		is.Equal(*fetchedBook.Inventory, 13)
+	})
+
+	t.Run("restocks an inexistent book should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.RestockBook(ctx, uuid.New(), 3)
+		is.True(errors.Is(err, book.ErrResponseBookNotFound))
+	})
+}
+
+func TestOutbox(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	olderEvent := book.OutboxEvent{
+		ID:        uuid.New(),
+		EventType: book.EventBookCreated,
+		Payload:   []byte(`{"Name":"Outbox tester book"}`),
+		CreatedAt: time.Now().UTC().Add(-time.Minute).Round(time.Millisecond),
+	}
+	newerEvent := book.OutboxEvent{
+		ID:        uuid.New(),
+		EventType: book.EventOrderUpdated,
+		Payload:   []byte(`{"OrderStatus":"waiting_payment"}`),
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+
+	t.Run("stores events and lists the ones due, oldest first", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(store.InsertOutboxEvent(ctx, newerEvent))
+		is.NoErr(store.InsertOutboxEvent(ctx, olderEvent))
+
+		events, err := store.ListPendingOutboxEvents(ctx, time.Now().UTC(), 10)
+		is.NoErr(err)
+		is.Equal(len(events), 2)
+		is.Equal(events[0].ID, olderEvent.ID)
+		is.Equal(events[0].EventType, book.EventBookCreated)
+		is.Equal(string(events[0].Payload), string(olderEvent.Payload))
+		is.Equal(events[1].ID, newerEvent.ID)
+	})
+
+	t.Run("events retried later are not listed until their next attempt is due", func(t *testing.T) {
+		is := is.New(t)
+
+		retried := olderEvent
+		retried.Attempts = 1
+		retried.LastError = "fake delivery error"
+		retried.NextAttemptAt = time.Now().UTC().Add(time.Minute).Round(time.Millisecond)
+		is.NoErr(store.UpdateOutboxEvent(ctx, retried))
+
+		events, err := store.ListPendingOutboxEvents(ctx, time.Now().UTC(), 10)
+		is.NoErr(err)
+		is.Equal(len(events), 1)
+		is.Equal(events[0].ID, newerEvent.ID) //A failing event does not hold the newer ones back.
+
+		events, err = store.ListPendingOutboxEvents(ctx, retried.NextAttemptAt, 10)
+		is.NoErr(err)
+		is.Equal(len(events), 2)
+		is.Equal(events[1].ID, olderEvent.ID)
+		is.Equal(events[1].Attempts, 1)
+		is.Equal(events[1].LastError, "fake delivery error")
+	})
+
+	t.Run("sent and given up events are not listed anymore", func(t *testing.T) {
+		is := is.New(t)
+
+		now := time.Now().UTC().Round(time.Millisecond)
+		sent := olderEvent
+		sent.Attempts = 2
+		sent.SentAt = &now
+		is.NoErr(store.UpdateOutboxEvent(ctx, sent))
+		givenUp := newerEvent
+		givenUp.Attempts = book.OutboxMaxAttempts
+		givenUp.FailedAt = &now
+		is.NoErr(store.UpdateOutboxEvent(ctx, givenUp))
+
+		events, err := store.ListPendingOutboxEvents(ctx, now.Add(time.Hour), 10)
+		is.NoErr(err)
+		is.Equal(len(events), 0)
+	})
+
+	t.Run("purges the sent events only", func(t *testing.T) {
+		is := is.New(t)
+
+		purged, err := store.PurgeSentOutboxEvents(ctx, time.Now().UTC().Add(time.Minute))
+		is.NoErr(err)
+		is.Equal(purged, 1) //the given up event is kept
+
+		purged, err = store.PurgeSentOutboxEvents(ctx, time.Now().UTC().Add(time.Minute))
+		is.NoErr(err)
+		is.Equal(purged, 0)
+	})
+}
+
+func TestWishlists(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	userID := uuid.New()
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Wished book",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(5),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("adds a book to the wishlist, keeping the first time it was added", func(t *testing.T) {
+		is := is.New(t)
+
+		addedAt, err := store.AddWishlistItem(ctx, userID, b.ID, createdNow)
+		is.NoErr(err)
+		is.True(addedAt.Equal(createdNow))
+
+		addedAt, err = store.AddWishlistItem(ctx, userID, b.ID, createdNow.Add(time.Hour))
+		is.NoErr(err)
+		is.True(addedAt.Equal(createdNow))
+	})
+
+	t.Run("lists the wishlist with the current price and inventory of the books", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.UpdateBook(ctx, book.Book{ID: b.ID, Name: b.Name, Price: toPointer(float32(25)), Inventory: toPointer(2), UpdatedAt: time.Now().UTC()})
+		is.NoErr(err)
+
+		items, err := store.ListWishlistItems(ctx, userID)
+		is.NoErr(err)
+		is.Equal(len(items), 1)
+		is.Equal(items[0].Book.ID, b.ID)
+		is.Equal(*items[0].Book.Price, float32(25))
+		is.Equal(*items[0].Book.Inventory, 2)
+
+		item, err := store.GetWishlistItem(ctx, userID, b.ID)
+		is.NoErr(err)
+		is.Equal(item.Book.ID, b.ID)
+
+		otherUserItems, err := store.ListWishlistItems(ctx, uuid.New())
+		is.NoErr(err)
+		is.Equal(otherUserItems, []book.WishlistItem{})
+	})
+
+	t.Run("removes a book from the wishlist", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.DeleteWishlistItem(ctx, userID, b.ID)
+		is.NoErr(err)
+
+		_, err = store.GetWishlistItem(ctx, userID, b.ID)
+		is.True(errors.Is(err, book.ErrResponseBookNotAtWishlist))
+
+		err = store.DeleteWishlistItem(ctx, userID, b.ID)
+		is.True(errors.Is(err, book.ErrResponseBookNotAtWishlist))
+	})
+}
+
+func TestReturns(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Book to return",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(10),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := book.Order{
+		OrderID:     uuid.New(),
+		PurchaserID: createUser(t),
+		OrderStatus: "accepting_items",
+		CreatedAt:   createdNow,
+		UpdatedAt:   createdNow,
+	}
+	_, err = store.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sqlDB.Exec(`UPDATE orders SET order_status = 'paid' WHERE order_id = $1`, o.OrderID) //Nothing at the service pays orders yet.
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret := book.Return{
+		ReturnID:  uuid.New(),
+		OrderID:   o.OrderID,
+		Status:    book.ReturnStatusRequested,
+		Reason:    "damaged",
+		Items:     []book.ReturnItem{{BookID: b.ID, BookUnits: 1}},
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+
+	t.Run("creates a return and sets the order status", func(t *testing.T) {
+		is := is.New(t)
+
+		status, err := store.GetOrderStatusForUpdate(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(status, "paid")
+
+		created, err := store.CreateReturn(ctx, ret)
+		is.NoErr(err)
+		is.Equal(created.ReturnID, ret.ReturnID)
+		is.Equal(created.Status, book.ReturnStatusRequested)
+		is.Equal(created.Items, ret.Items)
+		is.Equal(created.ResolvedAt, nil)
+
+		err = store.SetOrderStatus(ctx, o.OrderID, "return_requested")
+		is.NoErr(err)
+		status, err = store.GetOrderStatusForUpdate(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(status, "return_requested")
+	})
+
+	t.Run("resolves a return, storing its refund", func(t *testing.T) {
+		is := is.New(t)
+
+		found, err := store.GetReturnForUpdate(ctx, ret.ReturnID)
+		is.NoErr(err)
+		is.Equal(found.Items, ret.Items)
+
+		resolvedAt := time.Now().UTC().Round(time.Millisecond)
+		found.Status = book.ReturnStatusApproved
+		found.RefundAmount = 30
+		found.RefundID = "manual_" + ret.ReturnID.String()
+		found.UpdatedAt = resolvedAt
+		found.ResolvedAt = &resolvedAt
+		err = store.ResolveReturn(ctx, found)
+		is.NoErr(err)
+
+		returns, err := store.ListReturns(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(len(returns), 1)
+		is.Equal(returns[0].Status, book.ReturnStatusApproved)
+		is.Equal(returns[0].RefundAmount, float32(30))
+		is.Equal(returns[0].RefundID, found.RefundID)
+		is.True(returns[0].ResolvedAt.Equal(resolvedAt))
+		is.Equal(returns[0].Items, ret.Items)
+	})
+
+	t.Run("searches an inexistent return should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.GetReturnForUpdate(ctx, uuid.New())
+		is.True(errors.Is(err, book.ErrResponseReturnNotFound))
+
+		err = store.ResolveReturn(ctx, book.Return{ReturnID: uuid.New(), Status: book.ReturnStatusRejected})
+		is.True(errors.Is(err, book.ErrResponseReturnNotFound))
+	})
+}
+
+func TestShipments(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	b := book.Book{
+		ID:        uuid.New(),
+		Name:      "Book to ship",
+		Price:     toPointer(float32(30)),
+		Inventory: toPointer(10),
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+	_, err := store.CreateBook(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := book.Order{
+		OrderID:     uuid.New(),
+		PurchaserID: createUser(t),
+		OrderStatus: "accepting_items",
+		CreatedAt:   createdNow,
+		UpdatedAt:   createdNow,
+	}
+	_, err = store.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.UpsertOrderItem(ctx, o.OrderID, book.OrderItem{BookID: b.ID, BookName: b.Name, BookUnits: 2, BookPriceAtOrder: b.Price})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sqlDB.Exec(`UPDATE orders SET order_status = 'paid' WHERE order_id = $1`, o.OrderID) //Nothing at the service pays orders yet.
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := book.Shipment{
+		ShipmentID: uuid.New(),
+		OrderID:    o.OrderID,
+		Status:     book.ShipmentStatusPicked,
+		Items:      []book.ShipmentItem{{BookID: b.ID, BookUnits: 1}},
+		CreatedAt:  createdNow,
+		UpdatedAt:  createdNow,
+	}
+
+	t.Run("creates a shipment with the purchaser of the order", func(t *testing.T) {
+		is := is.New(t)
+
+		created, err := store.CreateShipment(ctx, s)
+		is.NoErr(err)
+		is.Equal(created.ShipmentID, s.ShipmentID)
+		is.Equal(created.PurchaserID, o.PurchaserID)
+		is.Equal(created.Status, book.ShipmentStatusPicked)
+		is.Equal(created.Items, s.Items)
+		is.Equal(created.ShippedAt, nil)
+	})
+
+	t.Run("ships a shipment and lists it with its items", func(t *testing.T) {
+		is := is.New(t)
+
+		found, err := store.GetShipmentForUpdate(ctx, s.ShipmentID)
+		is.NoErr(err)
+		is.Equal(found.Items, s.Items)
+
+		shippedAt := time.Now().UTC().Round(time.Millisecond)
+		found.Status = book.ShipmentStatusShipped
+		found.Carrier = "Correios"
+		found.TrackingNumber = "BR123456789"
+		found.UpdatedAt = shippedAt
+		found.ShippedAt = &shippedAt
+		err = store.UpdateShipment(ctx, found)
+		is.NoErr(err)
+
+		shipments, err := store.ListShipments(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(len(shipments), 1)
+		is.Equal(shipments[0].Status, book.ShipmentStatusShipped)
+		is.Equal(shipments[0].Carrier, "Correios")
+		is.Equal(shipments[0].TrackingNumber, "BR123456789")
+		is.True(shipments[0].ShippedAt.Equal(shippedAt))
+		is.Equal(shipments[0].DeliveredAt, nil)
+		is.Equal(shipments[0].Items, s.Items)
+
+		err = store.SetOrderStatus(ctx, o.OrderID, "partially_shipped")
+		is.NoErr(err)
+	})
+
+	t.Run("creates a shipment with a book not at the order should fail", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.CreateShipment(ctx, book.Shipment{
+			ShipmentID: uuid.New(),
+			OrderID:    o.OrderID,
+			Status:     book.ShipmentStatusPicked,
+			Items:      []book.ShipmentItem{{BookID: uuid.New(), BookUnits: 1}},
+			CreatedAt:  createdNow,
+			UpdatedAt:  createdNow,
+		})
+		is.True(err != nil)
+	})
+
+	t.Run("searches an inexistent shipment should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.GetShipmentForUpdate(ctx, uuid.New())
+		is.True(errors.Is(err, book.ErrResponseShipmentNotFound))
+
+		err = store.UpdateShipment(ctx, book.Shipment{ShipmentID: uuid.New(), Status: book.ShipmentStatusShipped})
+		is.True(errors.Is(err, book.ErrResponseShipmentNotFound))
+	})
+}
+
+func TestUsers(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	u := book.User{
+		UserID:    uuid.New(),
+		Name:      "Maria Silva",
+		Role:      book.UserRoleUser,
+		CreatedAt: createdNow,
+		UpdatedAt: createdNow,
+	}
+
+	t.Run("creates a user and searches it", func(t *testing.T) {
+		is := is.New(t)
+
+		created, err := store.CreateUser(ctx, u)
+		is.NoErr(err)
+		is.Equal(created.UserID, u.UserID)
+		is.Equal(created.Name, u.Name)
+		is.Equal(created.Role, book.UserRoleUser)
+
+		found, err := store.GetUserByID(ctx, u.UserID)
+		is.NoErr(err)
+		is.Equal(found.Name, u.Name)
+		is.True(found.CreatedAt.Equal(createdNow))
+
+		users, err := store.ListUsers(ctx)
+		is.NoErr(err)
+		is.Equal(len(users), 1)
+	})
+
+	t.Run("updates a user", func(t *testing.T) {
+		is := is.New(t)
+
+		u.Name = "Maria Souza"
+		u.Role = book.UserRoleAdmin
+		u.UpdatedAt = time.Now().UTC().Round(time.Millisecond)
+		updated, err := store.UpdateUser(ctx, u)
+		is.NoErr(err)
+		is.Equal(updated.Name, "Maria Souza")
+		is.Equal(updated.Role, book.UserRoleAdmin)
+		is.True(updated.CreatedAt.Equal(createdNow))
+	})
+
+	t.Run("creates an order of an inexistent purchaser should fail", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.CreateOrder(ctx, book.Order{OrderID: uuid.New(), PurchaserID: uuid.New(), OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow})
+		is.True(err != nil)
+	})
+
+	t.Run("deletes a user with orders should return a user has orders error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.CreateOrder(ctx, book.Order{OrderID: uuid.New(), PurchaserID: u.UserID, OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow})
+		is.NoErr(err)
+
+		err = store.DeleteUser(ctx, u.UserID)
+		is.True(errors.Is(err, book.ErrResponseUserHasOrders))
+	})
+
+	t.Run("deletes a user with no orders", func(t *testing.T) {
+		is := is.New(t)
+
+		userID := createUser(t)
+		err := store.DeleteUser(ctx, userID)
+		is.NoErr(err)
+
+		_, err = store.GetUserByID(ctx, userID)
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
+
+	t.Run("searches a user by email, with its password hash", func(t *testing.T) {
+		is := is.New(t)
+
+		u.Email = "maria@mail.com"
+		u.PasswordHash = "$2a$10$hash"
+		_, err := store.UpdateUser(ctx, u)
+		is.NoErr(err)
+
+		found, err := store.GetUserByEmail(ctx, "maria@mail.com")
+		is.NoErr(err)
+		is.Equal(found.UserID, u.UserID)
+		is.Equal(found.PasswordHash, "$2a$10$hash")
+
+		u.PasswordHash = "" //A blank hash keeps the stored password.
+		_, err = store.UpdateUser(ctx, u)
+		is.NoErr(err)
+		found, err = store.GetUserByEmail(ctx, "maria@mail.com")
+		is.NoErr(err)
+		is.Equal(found.PasswordHash, "$2a$10$hash")
+	})
+
+	t.Run("creates a user with an email in use should return an email in use error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.CreateUser(ctx, book.User{UserID: uuid.New(), Name: "Other Maria", Role: book.UserRoleUser, Email: "maria@mail.com", CreatedAt: createdNow, UpdatedAt: createdNow})
+		is.True(errors.Is(err, book.ErrResponseUserEmailInUse))
+	})
+
+	t.Run("stores, searches and revokes a refresh token", func(t *testing.T) {
+		is := is.New(t)
+
+		token := book.RefreshToken{TokenHash: "hash-of-a-token", UserID: u.UserID, ExpiresAt: createdNow.Add(time.Hour), CreatedAt: createdNow}
+		err := store.StoreRefreshToken(ctx, token)
+		is.NoErr(err)
+
+		found, err := store.GetRefreshTokenForUpdate(ctx, token.TokenHash)
+		is.NoErr(err)
+		is.Equal(found.UserID, u.UserID)
+		is.Equal(found.RevokedAt, nil)
+
+		revokedAt := time.Now().UTC().Round(time.Millisecond)
+		err = store.RevokeRefreshToken(ctx, token.TokenHash, revokedAt)
+		is.NoErr(err)
+		found, err = store.GetRefreshTokenForUpdate(ctx, token.TokenHash)
+		is.NoErr(err)
+		is.True(found.RevokedAt.Equal(revokedAt))
+
+		_, err = store.GetRefreshTokenForUpdate(ctx, "unknown")
+		is.True(errors.Is(err, book.ErrResponseRefreshTokenInvalid))
+	})
+
+	t.Run("searches an inexistent user should return a not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := store.GetUserByEmail(ctx, "nobody@mail.com")
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+
+		_, err = store.UpdateUser(ctx, book.User{UserID: uuid.New(), Name: "Nobody", Role: book.UserRoleUser})
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+
+		err = store.DeleteUser(ctx, uuid.New())
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	ownerID := createUser(t)
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	newKey := book.APIKey{
+		KeyID:     uuid.New(),
+		UserID:    ownerID,
+		Name:      "warehouse",
+		Prefix:    "0a1b2c3d4e5f",
+		KeyHash:   "hash-of-a-secret",
+		Scopes:    []book.Permission{book.PermissionOrdersRead, book.PermissionFulfillmentWrite},
+		CreatedAt: createdNow,
+	}
+
+	t.Run("stores an api key and searches it by its prefix", func(t *testing.T) {
+		is := is.New(t)
+
+		storedKey, err := store.CreateAPIKey(ctx, newKey)
+		is.NoErr(err)
+		is.Equal(storedKey.Scopes, newKey.Scopes)
+
+		found, err := store.GetAPIKeyByPrefix(ctx, newKey.Prefix)
+		is.NoErr(err)
+		is.Equal(found.KeyID, newKey.KeyID)
+		is.Equal(found.KeyHash, newKey.KeyHash)
+		is.Equal(found.LastUsedAt, nil)
+
+		_, err = store.GetAPIKeyByPrefix(ctx, "unknown")
+		is.True(errors.Is(err, book.ErrResponseAPIKeyNotFound))
+	})
+
+	t.Run("records the last use and revokes an api key", func(t *testing.T) {
+		is := is.New(t)
+
+		usedAt := time.Now().UTC().Round(time.Millisecond)
+		err := store.TouchAPIKey(ctx, newKey.KeyID, usedAt)
+		is.NoErr(err)
+
+		revokedAt := usedAt.Add(time.Minute)
+		revoked, err := store.RevokeAPIKey(ctx, newKey.KeyID, revokedAt)
+		is.NoErr(err)
+		is.True(revoked.LastUsedAt.Equal(usedAt))
+		is.True(revoked.RevokedAt.Equal(revokedAt))
+
+		revoked, err = store.RevokeAPIKey(ctx, newKey.KeyID, revokedAt.Add(time.Hour)) //The first revoking time is kept.
+		is.NoErr(err)
+		is.True(revoked.RevokedAt.Equal(revokedAt))
+
+		keys, err := store.ListAPIKeys(ctx)
+		is.NoErr(err)
+		is.Equal(len(keys), 1)
+
+		_, err = store.RevokeAPIKey(ctx, uuid.New(), revokedAt)
+		is.True(errors.Is(err, book.ErrResponseAPIKeyNotFound))
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	newWebhook := book.Webhook{
+		WebhookID:  uuid.New(),
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []book.EventType{book.EventOrderUpdated, book.EventShipmentUpdated},
+		Secret:     "whsec_test_secret_of_the_partner",
+		CreatedAt:  createdNow,
+		UpdatedAt:  createdNow,
+	}
+	orderEvent := book.WebhookDelivery{
+		EventID:       uuid.New(),
+		EventType:     book.EventOrderUpdated,
+		Payload:       []byte(`{"type": "order_updated"}`),
+		Status:        book.WebhookDeliveryPending,
+		NextAttemptAt: createdNow,
+		CreatedAt:     createdNow,
+	}
+
+	t.Run("stores a webhook, keeping its secret when updated with none", func(t *testing.T) {
+		is := is.New(t)
+
+		stored, err := store.CreateWebhook(ctx, newWebhook)
+		is.NoErr(err)
+		is.Equal(stored.EventTypes, newWebhook.EventTypes)
+
+		update := newWebhook
+		update.URL = "https://partner.example.com/v2/hooks"
+		update.Secret = ""
+		update.UpdatedAt = createdNow.Add(time.Minute)
+		updated, err := store.UpdateWebhook(ctx, update)
+		is.NoErr(err)
+		is.Equal(updated.URL, update.URL)
+		is.Equal(updated.Secret, newWebhook.Secret)
+
+		webhooks, err := store.ListWebhooks(ctx)
+		is.NoErr(err)
+		is.Equal(len(webhooks), 1)
+
+		_, err = store.GetWebhookByID(ctx, uuid.New())
+		is.True(errors.Is(err, book.ErrResponseWebhookNotFound))
+	})
+
+	t.Run("queues an event once for each webhook subscribed to its type", func(t *testing.T) {
+		is := is.New(t)
+
+		enqueued, err := store.EnqueueWebhookDeliveries(ctx, orderEvent)
+		is.NoErr(err)
+		is.Equal(enqueued, 1)
+
+		enqueued, err = store.EnqueueWebhookDeliveries(ctx, orderEvent) //Dispatched again.
+		is.NoErr(err)
+		is.Equal(enqueued, 0)
+
+		bookEvent := orderEvent
+		bookEvent.EventID = uuid.New()
+		bookEvent.EventType = book.EventBookCreated
+		enqueued, err = store.EnqueueWebhookDeliveries(ctx, bookEvent)
+		is.NoErr(err)
+		is.Equal(enqueued, 0)
+	})
+
+	t.Run("lists the deliveries due and logs their attempts", func(t *testing.T) {
+		is := is.New(t)
+
+		pending, err := store.ListPendingWebhookDeliveries(ctx, createdNow, 10)
+		is.NoErr(err)
+		is.Equal(len(pending), 1)
+		is.Equal(pending[0].WebhookID, newWebhook.WebhookID)
+		is.Equal(pending[0].EventID, orderEvent.EventID)
+		is.Equal(pending[0].LastStatusCode, nil)
+
+		statusCode := 503
+		retry := pending[0]
+		retry.Attempts = 1
+		retry.LastStatusCode = &statusCode
+		retry.LastError = "webhook wrong response - want: 2xx, got: 503"
+		retry.NextAttemptAt = createdNow.Add(time.Minute)
+		is.NoErr(store.UpdateWebhookDelivery(ctx, retry))
+
+		pending, err = store.ListPendingWebhookDeliveries(ctx, createdNow, 10) //Not due yet.
+		is.NoErr(err)
+		is.Equal(len(pending), 0)
+
+		deliveries, err := store.ListWebhookDeliveries(ctx, newWebhook.WebhookID, 10)
+		is.NoErr(err)
+		is.Equal(len(deliveries), 1)
+		is.Equal(*deliveries[0].LastStatusCode, 503)
+		is.Equal(deliveries[0].Attempts, 1)
+	})
+
+	t.Run("deletes a webhook with its deliveries", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(store.DeleteWebhook(ctx, newWebhook.WebhookID))
+
+		deliveries, err := store.ListWebhookDeliveries(ctx, newWebhook.WebhookID, 10)
+		is.NoErr(err)
+		is.Equal(len(deliveries), 0)
+
+		err = store.DeleteWebhook(ctx, newWebhook.WebhookID)
+		is.True(errors.Is(err, book.ErrResponseWebhookNotFound))
+	})
+}
+
+func TestGuestOrders(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	purchaserID := createUser(t)
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	guestOrder := book.Order{OrderID: uuid.New(), OrderStatus: "accepting_items", CreatedAt: createdNow, UpdatedAt: createdNow}
+
+	t.Run("stores an order with no purchaser", func(t *testing.T) {
+		is := is.New(t)
+
+		created, err := store.CreateOrder(ctx, guestOrder)
+		is.NoErr(err)
+		is.Equal(created.PurchaserID, uuid.Nil)
+
+		found, err := store.ListOrderItems(ctx, guestOrder.OrderID)
+		is.NoErr(err)
+		is.Equal(found.PurchaserID, uuid.Nil)
+
+		_, err = store.GetOpenOrderOfPurchaser(ctx, purchaserID)
+		is.True(errors.Is(err, book.ErrResponseOrderNotFound))
+	})
+
+	t.Run("gives the guest order a purchaser only once", func(t *testing.T) {
+		is := is.New(t)
+
+		err := store.SetOrderPurchaser(ctx, guestOrder.OrderID, purchaserID)
+		is.NoErr(err)
+
+		openOrderID, err := store.GetOpenOrderOfPurchaser(ctx, purchaserID)
+		is.NoErr(err)
+		is.Equal(openOrderID, guestOrder.OrderID)
+
+		err = store.SetOrderPurchaser(ctx, guestOrder.OrderID, createUser(t))
+		is.True(errors.Is(err, book.ErrResponseOrderAlreadyClaimed))
+	})
+}
+
+func TestUserErasure(t *testing.T) {
+	t.Cleanup(func() {
+		teardownDB(t)
+	})
+
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	u, err := store.CreateUser(ctx, book.User{UserID: uuid.New(), Name: "Maria Silva", Role: book.UserRoleAdmin, Email: "maria@silva.com", PasswordHash: "hash", CreatedAt: createdNow, UpdatedAt: createdNow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := book.Order{
+		OrderID:         uuid.New(),
+		PurchaserID:     u.UserID,
+		OrderStatus:     "accepting_items",
+		CreatedAt:       createdNow,
+		UpdatedAt:       createdNow,
+		ShippingAddress: &book.ShippingAddress{Recipient: "Maria Silva", Line1: "Rua das Flores, 100", City: "São Paulo", PostalCode: "01000-000", Country: "BR"},
+		ContactEmail:    "maria@silva.com",
+		Notes:           "Leave it at the front desk",
+	}
+	_, err = store.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SetOrderDetails(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.StoreRefreshToken(ctx, book.RefreshToken{TokenHash: "hash-of-a-token", UserID: u.UserID, ExpiresAt: createdNow.Add(time.Hour), CreatedAt: createdNow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("lists the orders of the purchaser", func(t *testing.T) {
+		is := is.New(t)
+
+		orderIDs, err := store.ListOrderIDsOfPurchaser(ctx, u.UserID)
+		is.NoErr(err)
+		is.Equal(orderIDs, []uuid.UUID{o.OrderID})
+	})
+
+	t.Run("anonymizes the user, dropping its credentials and sessions", func(t *testing.T) {
+		is := is.New(t)
+
+		erasedAt := createdNow.Add(time.Minute)
+		erased, err := store.AnonymizeUser(ctx, u.UserID, book.ErasedUserName, erasedAt)
+		is.NoErr(err)
+		is.Equal(erased.Name, book.ErasedUserName)
+		is.Equal(erased.Role, book.UserRoleUser)
+		is.Equal(erased.Email, "")
+		is.Equal(erased.PasswordHash, "")
+		is.True(erased.UpdatedAt.Equal(erasedAt))
+
+		err = store.DeleteUserRefreshTokens(ctx, u.UserID)
+		is.NoErr(err)
+		_, err = store.GetRefreshTokenForUpdate(ctx, "hash-of-a-token")
+		is.True(errors.Is(err, book.ErrResponseRefreshTokenInvalid))
+
+		_, err = store.AnonymizeUser(ctx, uuid.New(), book.ErasedUserName, erasedAt)
+		is.True(errors.Is(err, book.ErrResponseUserNotFound))
+	})
+
+	t.Run("clears the personal fields of the orders, keeping the orders", func(t *testing.T) {
+		is := is.New(t)
+
+		anonymized, err := store.AnonymizePurchaserOrders(ctx, u.UserID)
+		is.NoErr(err)
+		is.Equal(anonymized, 1)
+
+		fetchedOrder, err := store.ListOrderItems(ctx, o.OrderID)
+		is.NoErr(err)
+		is.Equal(fetchedOrder.PurchaserID, u.UserID)
+		is.Equal(fetchedOrder.ShippingAddress, nil)
+		is.Equal(fetchedOrder.ContactEmail, "")
+		is.Equal(fetchedOrder.Notes, "")
+	})
+
+	t.Run("clears the personal fields from the events about the user and its orders, at the outbox and at webhooks", func(t *testing.T) {
+		is := is.New(t)
+
+		userPayload := fmt.Sprintf(`{"UserID": "%s", "Name": "Maria Silva", "Role": "admin", "Email": "maria@silva.com"}`, u.UserID)
+		orderPayload := fmt.Sprintf(`{"OrderID": "%s", "PurchaserID": "%s", "ShippingAddress": {"Recipient": "Maria Silva"}, "ContactEmail": "maria@silva.com", "ContactPhone": "+5511999999999", "Notes": "Leave it at the front desk"}`, o.OrderID, u.UserID)
+		otherPayload := fmt.Sprintf(`{"UserID": "%s", "Name": "João Souza", "Role": "user", "Email": "joao@souza.com"}`, uuid.New())
+		events := []book.OutboxEvent{
+			{ID: uuid.New(), EventType: book.EventUserCreated, Payload: []byte(userPayload), CreatedAt: createdNow},
+			{ID: uuid.New(), EventType: book.EventOrderUpdated, Payload: []byte(orderPayload), CreatedAt: createdNow.Add(time.Second)},
+			{ID: uuid.New(), EventType: book.EventUserCreated, Payload: []byte(otherPayload), CreatedAt: createdNow.Add(2 * time.Second)},
+		}
+		wh := book.Webhook{WebhookID: uuid.New(), URL: "https://partner.example.com/hooks", EventTypes: []book.EventType{book.EventUserCreated, book.EventOrderUpdated}, Secret: "whsec_test_secret_of_the_partner", CreatedAt: createdNow, UpdatedAt: createdNow}
+		_, err := store.CreateWebhook(ctx, wh)
+		is.NoErr(err)
+		for _, event := range events {
+			is.NoErr(store.InsertOutboxEvent(ctx, event))
+			envelope := fmt.Sprintf(`{"id": "%s", "type": "%s", "payload": %s}`, event.ID, event.EventType, event.Payload)
+			_, err = store.EnqueueWebhookDeliveries(ctx, book.WebhookDelivery{EventID: event.ID, EventType: event.EventType, Payload: []byte(envelope), Status: book.WebhookDeliveryPending, NextAttemptAt: event.CreatedAt, CreatedAt: event.CreatedAt})
+			is.NoErr(err)
+		}
+
+		is.NoErr(store.AnonymizeUserEvents(ctx, u.UserID, book.ErasedUserName))
+		is.NoErr(store.AnonymizeUserWebhookDeliveries(ctx, u.UserID, book.ErasedUserName))
+
+		stored, err := store.ListPendingOutboxEvents(ctx, time.Now().UTC(), 10)
+		is.NoErr(err)
+		is.Equal(len(stored), 3)
+		deliveries, err := store.ListWebhookDeliveries(ctx, wh.WebhookID, 10)
+		is.NoErr(err)
+		is.Equal(len(deliveries), 3)
+
+		payloads := []string{}
+		for _, event := range stored {
+			payloads = append(payloads, string(event.Payload))
+		}
+		for _, delivery := range deliveries {
+			payloads = append(payloads, string(delivery.Payload))
+		}
+		for _, payload := range payloads {
+			is.True(!strings.Contains(payload, "maria@silva.com"))
+			is.True(!strings.Contains(payload, "Maria Silva"))
+			is.True(!strings.Contains(payload, "front desk"))
+		}
+		is.True(strings.Contains(string(stored[1].Payload), o.OrderID.String())) //The order events are kept, only the personal fields are cleared.
+		is.True(strings.Contains(string(stored[2].Payload), "joao@souza.com"))   //Events of other users are untouched.
+	})
+}
+
 // compareBooks asserts that two books are equal,
 // handling time.Time values correctly.
 func compareBooks(is *is.I, a, b book.Book) {
@@ -782,15 +2595,26 @@ func compareOrders(is *is.I, a, b book.Order) {
 	is.Equal(a, b)
 }
 
+/* Stores a user to purchase the orders of a test, as orders need a known purchaser. */
+func createUser(t testing.TB) uuid.UUID {
+	t.Helper()
+	createdNow := time.Now().UTC().Round(time.Millisecond)
+	u, err := store.CreateUser(ctx, book.User{UserID: uuid.New(), Name: "Purchaser", Role: book.UserRoleUser, CreatedAt: createdNow, UpdatedAt: createdNow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.UserID
+}
+
 func toPointer[T any](v T) *T {
 	return &v
 }
 
-func teardownDB(t *testing.T) {
+func teardownDB(t testing.TB) {
 	is := is.New(t)
 
 	// Truncating books table, cleaning up all the records.
-	result, err := sqlDB.Exec(`TRUNCATE TABLE public.bookstable, public.users, public.orders, public.books_orders, public.payments CASCADE`)
+	result, err := sqlDB.Exec(`TRUNCATE TABLE public.bookstable, public.users, public.orders, public.books_orders, public.payments, public.idempotency_keys, public.coupons, public.outbox, public.wishlists, public.returns, public.return_items, public.order_status_history, public.shipments, public.shipment_items, public.refresh_tokens, public.api_keys, public.webhooks, public.webhook_deliveries CASCADE`)
 	is.NoErr(err)
 
 	_, err = result.RowsAffected()
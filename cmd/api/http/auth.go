@@ -0,0 +1,138 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Wraps the handler so the caller is put at the request context: the user of the bearer token, the owner of the API key, or the guest of the cart token, sent. Requests to routes not open to anyone need a valid token or key. */
+func authenticate(signingKey []byte, bookService book.ServiceAPI, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			if isPublicRoute(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			unauthorized(w)
+			return
+		}
+
+		var claims book.Claims
+		var actor string
+		if key, found := strings.CutPrefix(header, "ApiKey "); found {
+			var err error
+			claims, err = bookService.AuthenticateAPIKey(r.Context(), strings.TrimSpace(key))
+			if err != nil {
+				if errors.Is(err, book.ErrResponseAPIKeyInvalid) {
+					w.Header().Set("WWW-Authenticate", "ApiKey")
+					responseJSON(w, http.StatusUnauthorized, book.ErrResponseAPIKeyInvalid)
+					return
+				}
+				handleError(err, w, r)
+				return
+			}
+			actor = "apikey:" + claims.APIKeyID.String()
+		} else if token, found := strings.CutPrefix(header, "Cart "); found {
+			cart, err := book.ParseCartToken(signingKey, strings.TrimSpace(token), time.Now())
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Cart")
+				responseJSON(w, http.StatusUnauthorized, book.ErrResponseCartTokenInvalid)
+				return
+			}
+			claims = book.Claims{Role: book.RoleGuest, CartOrderID: cart.OrderID, ExpiresAt: cart.ExpiresAt}
+			actor = book.ActorAnonymous
+		} else {
+			token, found := strings.CutPrefix(header, "Bearer ")
+			if !found {
+				unauthorized(w)
+				return
+			}
+			var err error
+			claims, err = book.ParseAccessToken(signingKey, strings.TrimSpace(token), time.Now())
+			if err != nil { //A bad token is refused even at public routes, so clients find out it expired.
+				unauthorized(w)
+				return
+			}
+			actor = claims.UserID.String()
+		}
+
+		ctx := book.ContextWithClaims(r.Context(), claims)
+		ctx = book.ContextWithActor(ctx, actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/* Tells if the route can be called with no access token: browsing the books, signing up, logging in and starting a guest order. */
+func isPublicRoute(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case path == "/ping":
+		return true
+	case path == "/auth/login", path == "/auth/refresh", path == "/auth/logout":
+		return true
+	case r.Method == http.MethodGet && (path == "/books" || strings.HasPrefix(path, "/books/")):
+		return true
+	case r.Method == http.MethodPost && path == "/users":
+		return true
+	case r.Method == http.MethodPost && path == "/order":
+		return true
+	default:
+		return false
+	}
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	responseJSON(w, http.StatusUnauthorized, book.ErrResponseAccessTokenInvalid)
+}
+
+/* Checks if the role of the caller grants the permission on everything. Responds 403 and returns false if not. */
+func authorize(w http.ResponseWriter, r *http.Request, permission book.Permission) bool {
+	return allowed(w, r, func(claims book.Claims) error {
+		return book.Authorize(claims, permission)
+	})
+}
+
+/* Checks if the role of the caller grants the permission on a resource of ownerID. Responds 403 and returns false if not. */
+func authorizeOwner(w http.ResponseWriter, r *http.Request, permission book.Permission, ownerID uuid.UUID) bool {
+	return allowed(w, r, func(claims book.Claims) error {
+		return book.AuthorizeOwner(claims, permission, ownerID)
+	})
+}
+
+/* Checks if the role of the caller grants the permission at least on its own resources. Responds 403 and returns false if not. */
+func authorizeOwned(w http.ResponseWriter, r *http.Request, permission book.Permission) bool {
+	return allowed(w, r, func(claims book.Claims) error {
+		return book.AuthorizeOwned(claims, permission)
+	})
+}
+
+/* Checks like authorizeOwned, but also lets guests in with their cart token. The service checks the cart is of the order asked. Responds 403 and returns false if not. */
+func authorizeCart(w http.ResponseWriter, r *http.Request, permission book.Permission) bool {
+	return allowed(w, r, func(claims book.Claims) error {
+		if claims.Role == book.RoleGuest {
+			return book.AuthorizeOwned(claims, book.PermissionCart)
+		}
+		return book.AuthorizeOwned(claims, permission)
+	})
+}
+
+func allowed(w http.ResponseWriter, r *http.Request, check func(claims book.Claims) error) bool {
+	claims, authenticated := book.ClaimsFromContext(r.Context())
+	if !authenticated {
+		unauthorized(w)
+		return false
+	}
+	err := check(claims)
+	if err != nil {
+		responseJSON(w, http.StatusForbidden, err)
+		return false
+	}
+	return true
+}
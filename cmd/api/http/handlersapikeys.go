@@ -0,0 +1,189 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Addresses a call to "/apikeys" according to the requested action. API keys are managed by admins.  */
+func (h *BookHandler) apiKeys(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.listAPIKeys(w, r)
+		return
+	case http.MethodPost:
+		h.createAPIKey(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/apikeys/(expected id here)" according to the requested action.  */
+func (h *BookHandler) apiKeyById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	path, _ := strings.CutPrefix(r.URL.Path, "/apikeys/")
+	id, err := uuid.Parse(path)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseAPIKeyIdInvalidFormat)
+		return
+	}
+
+	method := r.Method
+	switch method {
+	case http.MethodDelete:
+		h.revokeAPIKey(w, r, id)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+type APIKeyEntry struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+/* Validates the entry, then issues a new API key owned by the calling admin. */
+func (h *BookHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionAPIKeysWrite) {
+		return
+	}
+
+	var apiKeyEntry APIKeyEntry
+	err := json.NewDecoder(r.Body).Decode(&apiKeyEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	apiKeyEntry.Name = strings.TrimSpace(apiKeyEntry.Name)
+	err = FilledAPIKeyFields(apiKeyEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	scopes := []book.Permission{}
+	for _, scope := range apiKeyEntry.Scopes {
+		scopes = append(scopes, book.Permission(scope))
+	}
+	claims, _ := book.ClaimsFromContext(r.Context())
+	storedKey, key, err := h.bookService.CreateAPIKey(r.Context(), book.CreateAPIKeyRequest{
+		UserID: claims.UserID,
+		Name:   apiKeyEntry.Name,
+		Scopes: scopes,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	response := apiKeyToResponse(storedKey)
+	response.Key = key //Shown only once, as just its hash is stored.
+	responseJSON(w, http.StatusCreated, response)
+}
+
+/* Verifies if all APIKey entry fields are filled and returns a warning message if not. */
+func FilledAPIKeyFields(apiKeyEntry APIKeyEntry) error {
+	if apiKeyEntry.Name == "" || len(apiKeyEntry.Scopes) == 0 {
+		return book.ErrResponseAPIKeyEntryBlankFields
+	}
+	for _, scope := range apiKeyEntry.Scopes {
+		if !book.ValidAPIKeyScope(book.Permission(scope)) {
+			return book.ErrResponseAPIKeyEntryBlankFields
+		}
+	}
+	return nil
+}
+
+/* Returns all the API keys, revoked ones included. */
+func (h *BookHandler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionAPIKeysWrite) {
+		return
+	}
+
+	keys, err := h.bookService.ListAPIKeys(r.Context())
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []APIKeyResponse{}
+	for _, k := range keys {
+		results = append(results, apiKeyToResponse(k))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+/* Revokes the API key with that specific ID. */
+func (h *BookHandler) revokeAPIKey(w http.ResponseWriter, r *http.Request, keyID uuid.UUID) {
+	if !authorize(w, r, book.PermissionAPIKeysWrite) {
+		return
+	}
+
+	revoked, err := h.bookService.RevokeAPIKey(r.Context(), keyID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, apiKeyToResponse(revoked))
+}
+
+type APIKeyResponse struct {
+	KeyID      uuid.UUID  `json:"key_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Key        string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+/*Copy the fields of an API key to an http layer struct with json tags. Its hash is never shown.*/
+func apiKeyToResponse(k book.APIKey) APIKeyResponse {
+	scopes := []string{}
+	for _, scope := range k.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return APIKeyResponse{
+		KeyID:      k.KeyID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
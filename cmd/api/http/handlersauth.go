@@ -0,0 +1,151 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+)
+
+/* Addresses a call to "/auth/(expected action here)" according to the requested action.  */
+func (h *BookHandler) auth(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	action, _ := strings.CutPrefix(r.URL.Path, "/auth/")
+	method := r.Method
+	switch {
+	case action == "login" && method == http.MethodPost:
+		h.login(w, r)
+		return
+	case action == "refresh" && method == http.MethodPost:
+		h.refreshTokens(w, r)
+		return
+	case action == "logout" && method == http.MethodPost:
+		h.logout(w, r)
+		return
+	case action == "login", action == "refresh", action == "logout":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+type LoginEntry struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+/* Validates the entry, then gives tokens to the user with that email and password. */
+func (h *BookHandler) login(w http.ResponseWriter, r *http.Request) {
+	var loginEntry LoginEntry
+	err := json.NewDecoder(r.Body).Decode(&loginEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(loginEntry.Email))
+	if email == "" || loginEntry.Password == "" {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseLoginEntryBlankFields)
+		return
+	}
+
+	tokens, err := h.bookService.Login(r.Context(), email, loginEntry.Password)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, tokensToResponse(tokens))
+}
+
+type RefreshEntry struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+/* Validates the entry, then trades the refresh token for new tokens. */
+func (h *BookHandler) refreshTokens(w http.ResponseWriter, r *http.Request) {
+	refreshEntry, err := decodeRefreshEntry(w, r)
+	if err != nil {
+		return
+	}
+
+	tokens, err := h.bookService.RefreshTokens(r.Context(), refreshEntry.RefreshToken)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, tokensToResponse(tokens))
+}
+
+/* Validates the entry, then revokes the refresh token. */
+func (h *BookHandler) logout(w http.ResponseWriter, r *http.Request) {
+	refreshEntry, err := decodeRefreshEntry(w, r)
+	if err != nil {
+		return
+	}
+
+	err = h.bookService.Logout(r.Context(), refreshEntry.RefreshToken)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* Decodes an entry with a refresh token, answering the request if it is not valid. */
+func decodeRefreshEntry(w http.ResponseWriter, r *http.Request) (RefreshEntry, error) {
+	var refreshEntry RefreshEntry
+	err := json.NewDecoder(r.Body).Decode(&refreshEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return RefreshEntry{}, err
+	}
+
+	if refreshEntry.RefreshToken == "" {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseRefreshEntryBlankFields)
+		return RefreshEntry{}, book.ErrResponseRefreshEntryBlankFields
+	}
+
+	return refreshEntry, nil
+}
+
+type TokensResponse struct {
+	AccessToken           string    `json:"access_token"`
+	TokenType             string    `json:"token_type"`
+	AccessTokenExpiresAt  time.Time `json:"expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+/*Copy the fields of the tokens given to an http layer struct with json tags*/
+func tokensToResponse(tokens book.AuthTokens) TokensResponse {
+	return TokensResponse{
+		AccessToken:           tokens.AccessToken,
+		TokenType:             "Bearer",
+		AccessTokenExpiresAt:  tokens.AccessTokenExpiresAt,
+		RefreshToken:          tokens.RefreshToken,
+		RefreshTokenExpiresAt: tokens.RefreshTokenExpiresAt,
+	}
+}
@@ -18,12 +18,14 @@ import (
 type BookHandler struct {
 	bookService    book.ServiceAPI
 	requestTimeout time.Duration
+	idempotencyTTL time.Duration
 }
 
-func NewBookHandler(bookService book.ServiceAPI, reqTimeout time.Duration) *BookHandler {
+func NewBookHandler(bookService book.ServiceAPI, reqTimeout time.Duration, idempotencyTTL time.Duration) *BookHandler {
 	return &BookHandler{
 		bookService:    bookService,
 		requestTimeout: reqTimeout,
+		idempotencyTTL: idempotencyTTL,
 	}
 }
 
@@ -64,7 +66,10 @@ func (h *BookHandler) books(w http.ResponseWriter, r *http.Request) {
 		h.listBooks(w, r)
 		return
 	case http.MethodPost:
-		h.createBook(w, r)
+		if !authorize(w, r, book.PermissionBooksWrite) { //Checked before the idempotency key, so a refused call isn't stored as its response.
+			return
+		}
+		h.idempotent(h.createBook)(w, r)
 		return
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -74,6 +79,10 @@ func (h *BookHandler) books(w http.ResponseWriter, r *http.Request) {
 
 /* Change the status of a book to "archived". */
 func (h *BookHandler) archiveBook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionBooksWrite) {
+		return
+	}
+
 	id, err := isolateId(w, r)
 	if err != nil {
 		return
@@ -89,9 +98,11 @@ func (h *BookHandler) archiveBook(w http.ResponseWriter, r *http.Request) {
 }
 
 type BookEntry struct {
-	Name      string   `json:"name"`
-	Price     *float32 `json:"price"`
-	Inventory *int     `json:"inventory"`
+	Name                 string   `json:"name"`
+	Price                *float32 `json:"price"`
+	Inventory            *int     `json:"inventory"`
+	MaxUnitsPerOrder     *int     `json:"max_units_per_order"`
+	MaxUnitsPerPurchaser *int     `json:"max_units_per_purchaser"`
 }
 
 /* Validates the entry, then stores the entry as a new book. */
@@ -127,6 +138,10 @@ func (h *BookHandler) createBook(w http.ResponseWriter, r *http.Request) {
 
 /* Validates the entry, then updates the asked book. */
 func (h *BookHandler) updateBook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionBooksWrite) {
+		return
+	}
+
 	id, err := isolateId(w, r)
 	if err != nil {
 		return
@@ -247,7 +262,7 @@ func (h *BookHandler) listBooks(w http.ResponseWriter, r *http.Request) {
 	responseJSON(w, http.StatusOK, pagedBooksToResponse(pagedBooks))
 }
 
-/* Verifies if all Book entry fields are filled and returns a warning message if so. */
+/* Verifies if all Book entry fields are filled, and the optional purchase limits are valid, returning a warning message if not. */
 func FilledBookFields(bookEntry BookEntry) error {
 	if bookEntry.Name == "" {
 		return book.ErrResponseBookEntryBlankFields
@@ -258,6 +273,12 @@ func FilledBookFields(bookEntry BookEntry) error {
 	if bookEntry.Inventory == nil {
 		return book.ErrResponseBookEntryBlankFields
 	}
+	if bookEntry.MaxUnitsPerOrder != nil && *bookEntry.MaxUnitsPerOrder < 1 { //The limits are optional, but can't block every purchase.
+		return book.ErrResponseBookEntryInvalidLimits
+	}
+	if bookEntry.MaxUnitsPerPurchaser != nil && *bookEntry.MaxUnitsPerPurchaser < 1 {
+		return book.ErrResponseBookEntryInvalidLimits
+	}
 
 	return nil
 }
@@ -265,19 +286,23 @@ func FilledBookFields(bookEntry BookEntry) error {
 /* Converts from BookEntry type to CreateBookRequest type, with no json tags. */
 func bookToCreateReq(b BookEntry) book.CreateBookRequest {
 	return book.CreateBookRequest{
-		Name:      b.Name,
-		Price:     b.Price,
-		Inventory: b.Inventory,
+		Name:                 b.Name,
+		Price:                b.Price,
+		Inventory:            b.Inventory,
+		MaxUnitsPerOrder:     b.MaxUnitsPerOrder,
+		MaxUnitsPerPurchaser: b.MaxUnitsPerPurchaser,
 	}
 }
 
 /* Converts from BookEntry type to UpdateBookRequest type, with no json tags. */
 func bookToUpdateReq(b BookEntry, id uuid.UUID) book.UpdateBookRequest {
 	return book.UpdateBookRequest{
-		ID:        id,
-		Name:      b.Name,
-		Price:     b.Price,
-		Inventory: b.Inventory,
+		ID:                   id,
+		Name:                 b.Name,
+		Price:                b.Price,
+		Inventory:            b.Inventory,
+		MaxUnitsPerOrder:     b.MaxUnitsPerOrder,
+		MaxUnitsPerPurchaser: b.MaxUnitsPerPurchaser,
 	}
 }
 
@@ -294,21 +319,25 @@ func isolateId(w http.ResponseWriter, r *http.Request) (id uuid.UUID, err error)
 }
 
 type BookResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Price     *float32  `json:"price"`
-	Inventory *int      `json:"inventory"`
-	Archived  bool      `json:"archived"`
+	ID                   uuid.UUID `json:"id"`
+	Name                 string    `json:"name"`
+	Price                *float32  `json:"price"`
+	Inventory            *int      `json:"inventory"`
+	Archived             bool      `json:"archived"`
+	MaxUnitsPerOrder     *int      `json:"max_units_per_order,omitempty"`
+	MaxUnitsPerPurchaser *int      `json:"max_units_per_purchaser,omitempty"`
 }
 
 /*Copy the fields of a book object to an http layer struct with json tags*/
 func bookToResponse(b book.Book) BookResponse {
 	return BookResponse{
-		ID:        b.ID,
-		Name:      b.Name,
-		Price:     b.Price,
-		Inventory: b.Inventory,
-		Archived:  b.Archived,
+		ID:                   b.ID,
+		Name:                 b.Name,
+		Price:                b.Price,
+		Inventory:            b.Inventory,
+		Archived:             b.Archived,
+		MaxUnitsPerOrder:     b.MaxUnitsPerOrder,
+		MaxUnitsPerPurchaser: b.MaxUnitsPerPurchaser,
 	}
 }
 
@@ -417,6 +446,11 @@ func extractPageParams(query url.Values) (page, pageSize int, valid bool) {
 
 func handleError(err error, w http.ResponseWriter, r *http.Request) {
 	log.Println(err)
+	var rejected book.ErrOrderItemsRejected
+	if errors.As(err, &rejected) {
+		responseJSON(w, http.StatusBadRequest, orderItemsRejectedToResponse(rejected))
+		return
+	}
 	if errors.As(err, &book.ErrResponse{}) {
 		switch {
 		case errors.Is(err, book.ErrResponseQueryPageOutOfRange):
@@ -431,6 +465,12 @@ func handleError(err error, w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, book.ErrResponseInsufficientInventory):
 			responseJSON(w, http.StatusBadRequest, book.ErrResponseInsufficientInventory)
 			return
+		case errors.Is(err, book.ErrResponseMaxUnitsPerOrderExceeded):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseMaxUnitsPerOrderExceeded)
+			return
+		case errors.Is(err, book.ErrResponseMaxUnitsPerPurchaserExceeded):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseMaxUnitsPerPurchaserExceeded)
+			return
 		case errors.Is(err, book.ErrResponseOrderNotFound):
 			responseJSON(w, http.StatusNotFound, book.ErrResponseOrderNotFound)
 			return
@@ -440,6 +480,93 @@ func handleError(err error, w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, book.ErrResponseBookNotAtOrder):
 			responseJSON(w, http.StatusBadRequest, book.ErrResponseBookNotAtOrder)
 			return
+		case errors.Is(err, book.ErrResponseCouponNotFound):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseCouponNotFound)
+			return
+		case errors.Is(err, book.ErrResponseCouponCodeInUse):
+			responseJSON(w, http.StatusConflict, book.ErrResponseCouponCodeInUse)
+			return
+		case errors.Is(err, book.ErrResponseCouponNotValid):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseCouponNotValid)
+			return
+		case errors.Is(err, book.ErrResponseCouponUsageLimitReached):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseCouponUsageLimitReached)
+			return
+		case errors.Is(err, book.ErrResponseCouponMinOrderTotal):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseCouponMinOrderTotal)
+			return
+		case errors.Is(err, book.ErrResponseOrderIsEmpty):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseOrderIsEmpty)
+			return
+		case errors.Is(err, book.ErrResponseShippingAddressRequired):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseShippingAddressRequired)
+			return
+		case errors.Is(err, book.ErrResponseTaxRegionNotSupported):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseTaxRegionNotSupported)
+			return
+		case errors.Is(err, book.ErrResponseBookNotAtWishlist):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseBookNotAtWishlist)
+			return
+		case errors.Is(err, book.ErrResponseOrderNotFulfillable):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseOrderNotFulfillable)
+			return
+		case errors.Is(err, book.ErrResponseOrderNotWaitingPayment):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseOrderNotWaitingPayment)
+			return
+		case errors.Is(err, book.ErrResponseShipmentUnitsExceeded):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseShipmentUnitsExceeded)
+			return
+		case errors.Is(err, book.ErrResponseCredentialsInvalid):
+			responseJSON(w, http.StatusUnauthorized, book.ErrResponseCredentialsInvalid)
+			return
+		case errors.Is(err, book.ErrResponseRefreshTokenInvalid):
+			responseJSON(w, http.StatusUnauthorized, book.ErrResponseRefreshTokenInvalid)
+			return
+		case errors.Is(err, book.ErrResponseUserEmailInUse):
+			responseJSON(w, http.StatusConflict, book.ErrResponseUserEmailInUse)
+			return
+		case errors.Is(err, book.ErrResponseForbidden):
+			responseJSON(w, http.StatusForbidden, book.ErrResponseForbidden)
+			return
+		case errors.Is(err, book.ErrResponseAPIKeyNotFound):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseAPIKeyNotFound)
+			return
+		case errors.Is(err, book.ErrResponseCartTokenInvalid):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseCartTokenInvalid)
+			return
+		case errors.Is(err, book.ErrResponseOrderAlreadyClaimed):
+			responseJSON(w, http.StatusConflict, book.ErrResponseOrderAlreadyClaimed)
+			return
+		case errors.Is(err, book.ErrResponseWebhookNotFound):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseWebhookNotFound)
+			return
+		case errors.Is(err, book.ErrResponseUserNotFound):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseUserNotFound)
+			return
+		case errors.Is(err, book.ErrResponseUserHasOrders):
+			responseJSON(w, http.StatusConflict, book.ErrResponseUserHasOrders)
+			return
+		case errors.Is(err, book.ErrResponseShipmentNotFound):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseShipmentNotFound)
+			return
+		case errors.Is(err, book.ErrResponseShipmentStepInvalid):
+			responseJSON(w, http.StatusConflict, book.ErrResponseShipmentStepInvalid)
+			return
+		case errors.Is(err, book.ErrResponseOrderNotReturnable):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseOrderNotReturnable)
+			return
+		case errors.Is(err, book.ErrResponseReturnUnitsExceeded):
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseReturnUnitsExceeded)
+			return
+		case errors.Is(err, book.ErrResponseReturnNotFound):
+			responseJSON(w, http.StatusNotFound, book.ErrResponseReturnNotFound)
+			return
+		case errors.Is(err, book.ErrResponseReturnNotPending):
+			responseJSON(w, http.StatusConflict, book.ErrResponseReturnNotPending)
+			return
+		case errors.Is(err, book.ErrResponseTxRetriesExhausted):
+			responseJSON(w, http.StatusConflict, book.ErrResponseTxRetriesExhausted)
+			return
 		}
 	} else if errors.Is(err, context.DeadlineExceeded) {
 		responseJSON(w, http.StatusGatewayTimeout, book.ErrResponseRequestTimeout)
@@ -0,0 +1,332 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Addresses a call to "/coupons" according to the requested action.  */
+func (h *BookHandler) coupons(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.listCoupons(w, r)
+		return
+	case http.MethodPost:
+		h.createCoupon(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/coupons/(expected id here)" according to the requested action.  */
+func (h *BookHandler) couponById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.getCoupon(w, r)
+		return
+	case http.MethodPut:
+		h.updateCoupon(w, r)
+		return
+	case http.MethodDelete:
+		h.deleteCoupon(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+type CouponEntry struct {
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discount_type"`
+	DiscountValue *float32   `json:"discount_value"`
+	MinOrderTotal float32    `json:"min_order_total"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+	UsageLimit    *int       `json:"usage_limit"`
+}
+
+/* Validates the entry, then stores it as a new coupon. */
+func (h *BookHandler) createCoupon(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionCouponsWrite) {
+		return
+	}
+
+	var couponEntry CouponEntry
+	err := json.NewDecoder(r.Body).Decode(&couponEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	err = FilledCouponFields(couponEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	storedCoupon, err := h.bookService.CreateCoupon(r.Context(), couponToCreateReq(couponEntry))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, couponToResponse(storedCoupon))
+}
+
+/* Returns all the coupons. */
+func (h *BookHandler) listCoupons(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionCouponsRead) {
+		return
+	}
+
+	coupons, err := h.bookService.ListCoupons(r.Context())
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []CouponResponse{}
+	for _, c := range coupons {
+		results = append(results, couponToResponse(c))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+/* Returns the coupon with that specific ID. */
+func (h *BookHandler) getCoupon(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionCouponsRead) {
+		return
+	}
+
+	id, err := isolateCouponId(w, r)
+	if err != nil {
+		return
+	}
+
+	coupon, err := h.bookService.GetCoupon(r.Context(), id)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, couponToResponse(coupon))
+}
+
+/* Validates the entry, then updates the asked coupon. */
+func (h *BookHandler) updateCoupon(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionCouponsWrite) {
+		return
+	}
+
+	id, err := isolateCouponId(w, r)
+	if err != nil {
+		return
+	}
+
+	var couponEntry CouponEntry
+	err = json.NewDecoder(r.Body).Decode(&couponEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	err = FilledCouponFields(couponEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updatedCoupon, err := h.bookService.UpdateCoupon(r.Context(), couponToUpdateReq(couponEntry, id))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, couponToResponse(updatedCoupon))
+}
+
+/* Deletes the coupon with that specific ID. */
+func (h *BookHandler) deleteCoupon(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionCouponsWrite) {
+		return
+	}
+
+	id, err := isolateCouponId(w, r)
+	if err != nil {
+		return
+	}
+
+	err = h.bookService.DeleteCoupon(r.Context(), id)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ApplyCouponEntry struct {
+	Code string `json:"code"`
+}
+
+/* Validates the entry, then applies the coupon to the order. */
+func (h *BookHandler) applyCoupon(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
+	var applyEntry ApplyCouponEntry
+	err := json.NewDecoder(r.Body).Decode(&applyEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	if applyEntry.Code == "" {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseApplyCouponEntryBlankFields)
+		return
+	}
+
+	updatedOrder, err := h.bookService.ApplyCoupon(r.Context(), orderID, applyEntry.Code)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(updatedOrder))
+}
+
+/* Verifies if all Coupon entry fields are filled and returns a warning message if so. */
+func FilledCouponFields(couponEntry CouponEntry) error {
+	if couponEntry.Code == "" {
+		return book.ErrResponseCouponEntryBlankFields
+	}
+	if couponEntry.DiscountValue == nil || *couponEntry.DiscountValue <= 0 {
+		return book.ErrResponseCouponEntryBlankFields
+	}
+	switch couponEntry.DiscountType {
+	case book.DiscountTypePercentage:
+		if *couponEntry.DiscountValue > 100 {
+			return book.ErrResponseCouponEntryBlankFields
+		}
+	case book.DiscountTypeFixedAmount:
+		break
+	default:
+		return book.ErrResponseCouponEntryBlankFields
+	}
+	if couponEntry.MinOrderTotal < 0 {
+		return book.ErrResponseCouponEntryBlankFields
+	}
+	if couponEntry.ValidFrom != nil && couponEntry.ValidUntil != nil && !couponEntry.ValidFrom.Before(*couponEntry.ValidUntil) {
+		return book.ErrResponseCouponEntryBlankFields
+	}
+	if couponEntry.UsageLimit != nil && *couponEntry.UsageLimit < 0 {
+		return book.ErrResponseCouponEntryBlankFields
+	}
+
+	return nil
+}
+
+/* Converts from CouponEntry type to CreateCouponRequest type, with no json tags. */
+func couponToCreateReq(c CouponEntry) book.CreateCouponRequest {
+	return book.CreateCouponRequest{
+		Code:          c.Code,
+		DiscountType:  c.DiscountType,
+		DiscountValue: *c.DiscountValue,
+		MinOrderTotal: c.MinOrderTotal,
+		ValidFrom:     c.ValidFrom,
+		ValidUntil:    c.ValidUntil,
+		UsageLimit:    c.UsageLimit,
+	}
+}
+
+/* Converts from CouponEntry type to UpdateCouponRequest type, with no json tags. */
+func couponToUpdateReq(c CouponEntry, id uuid.UUID) book.UpdateCouponRequest {
+	return book.UpdateCouponRequest{
+		ID:            id,
+		Code:          c.Code,
+		DiscountType:  c.DiscountType,
+		DiscountValue: *c.DiscountValue,
+		MinOrderTotal: c.MinOrderTotal,
+		ValidFrom:     c.ValidFrom,
+		ValidUntil:    c.ValidUntil,
+		UsageLimit:    c.UsageLimit,
+	}
+}
+
+/* Isolates the ID from the URL. */
+func isolateCouponId(w http.ResponseWriter, r *http.Request) (id uuid.UUID, err error) {
+	justId, _ := strings.CutPrefix(r.URL.Path, "/coupons/")
+	id, err = uuid.Parse(justId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseCouponIdInvalidFormat)
+		return id, err
+	}
+	return id, nil
+}
+
+type CouponResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discount_type"`
+	DiscountValue float32    `json:"discount_value"`
+	MinOrderTotal float32    `json:"min_order_total"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+	UsageLimit    *int       `json:"usage_limit"`
+	TimesUsed     int        `json:"times_used"`
+}
+
+/*Copy the fields of a coupon object to an http layer struct with json tags*/
+func couponToResponse(c book.Coupon) CouponResponse {
+	return CouponResponse{
+		ID:            c.ID,
+		Code:          c.Code,
+		DiscountType:  c.DiscountType,
+		DiscountValue: c.DiscountValue,
+		MinOrderTotal: c.MinOrderTotal,
+		ValidFrom:     c.ValidFrom,
+		ValidUntil:    c.ValidUntil,
+		UsageLimit:    c.UsageLimit,
+		TimesUsed:     c.TimesUsed,
+	}
+}
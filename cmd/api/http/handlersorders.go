@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/mail"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/books-service/cmd/api/book"
 	"github.com/google/uuid"
@@ -22,7 +26,7 @@ func (h *BookHandler) order(w http.ResponseWriter, r *http.Request) {
 	method := r.Method
 	switch method {
 	case http.MethodPost:
-		h.createOrder(w, r)
+		h.idempotent(h.createOrder)(w, r)
 		return
 	case http.MethodGet:
 		h.listOrderItems(w, r)
@@ -36,6 +40,105 @@ func (h *BookHandler) order(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+/* Addresses a call to "/order/items" according to the requested action.  */
+func (h *BookHandler) orderItems(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodPut:
+		h.updateOrderItems(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/order/claim" according to the requested action.  */
+func (h *BookHandler) orderClaim(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodPost:
+		h.claimOrder(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/orders/(expected id here)/(expected action here)" according to the requested action.  */
+func (h *BookHandler) orderById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, action, err := isolateOrderPath(w, r)
+	if err != nil {
+		return
+	}
+
+	method := r.Method
+	switch {
+	case action == "coupon" && method == http.MethodPost:
+		h.applyCoupon(w, r, id)
+		return
+	case action == "checkout" && method == http.MethodPost:
+		h.checkout(w, r, id)
+		return
+	case action == "payment" && method == http.MethodPost:
+		h.confirmPayment(w, r, id)
+		return
+	case action == "returns" && method == http.MethodPost:
+		h.requestReturn(w, r, id)
+		return
+	case action == "returns" && method == http.MethodGet:
+		h.listReturns(w, r, id)
+		return
+	case action == "details" && method == http.MethodPut:
+		h.updateOrderDetails(w, r, id)
+		return
+	case action == "history" && method == http.MethodGet:
+		h.listOrderHistory(w, r, id)
+		return
+	case action == "shipments" && method == http.MethodPost:
+		h.pickShipment(w, r, id)
+		return
+	case action == "shipments" && method == http.MethodGet:
+		h.listShipments(w, r, id)
+		return
+	case action == "coupon", action == "checkout", action == "payment", action == "returns", action == "details", action == "history", action == "shipments":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+/* Isolates the order ID and the asked action from the URL. */
+func isolateOrderPath(w http.ResponseWriter, r *http.Request) (id uuid.UUID, action string, err error) {
+	path, _ := strings.CutPrefix(r.URL.Path, "/orders/")
+	justId, action, _ := strings.Cut(path, "/")
+	id, err = uuid.Parse(justId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseOrderIdInvalidFormat)
+		return id, action, err
+	}
+	return id, action, nil
+}
+
 type NewOrderEntry struct {
 	UserID uuid.UUID `json:"user_id"`
 }
@@ -54,8 +157,12 @@ func (h *BookHandler) createOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if newOrderEntry.UserID.String() == "" {
-		responseJSON(w, http.StatusBadRequest, book.ErrResponseNewOrderEntryBlankFields)
+	if newOrderEntry.UserID == uuid.Nil { //Guests fill a cart before signing up.
+		h.createGuestOrder(w, r)
+		return
+	}
+	if !authorizeOwner(w, r, book.PermissionOrdersWrite, newOrderEntry.UserID) { //Users can only buy for themselves.
+		return
 	}
 
 	newOrder, err := h.bookService.CreateOrder(r.Context(), newOrderEntry.UserID)
@@ -67,6 +174,282 @@ func (h *BookHandler) createOrder(w http.ResponseWriter, r *http.Request) {
 	responseJSON(w, http.StatusOK, orderToResponse(newOrder))
 }
 
+type GuestOrderResponse struct {
+	OrderResponse
+	CartToken          string    `json:"cart_token"`
+	CartTokenExpiresAt time.Time `json:"cart_token_expires_at"`
+}
+
+/* Creates an empty order with no purchaser, returning the cart token that lets the guest see and change it. */
+func (h *BookHandler) createGuestOrder(w http.ResponseWriter, r *http.Request) {
+	newOrder, cart, err := h.bookService.CreateGuestOrder(r.Context())
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, GuestOrderResponse{
+		OrderResponse:      orderToResponse(newOrder),
+		CartToken:          cart.Token,
+		CartTokenExpiresAt: cart.ExpiresAt,
+	})
+}
+
+type ClaimOrderEntry struct {
+	CartToken string `json:"cart_token"`
+}
+
+/* Attaches the guest order of the cart token sent to the calling user, merging it with the order they already had open, if any. */
+func (h *BookHandler) claimOrder(w http.ResponseWriter, r *http.Request) {
+	if !authorizeOwned(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
+	var claimEntry ClaimOrderEntry
+	err := json.NewDecoder(r.Body).Decode(&claimEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	claimEntry.CartToken = strings.TrimSpace(claimEntry.CartToken)
+	if claimEntry.CartToken == "" {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseCartTokenInvalid)
+		return
+	}
+
+	claims, _ := book.ClaimsFromContext(r.Context())
+	claimedOrder, err := h.bookService.ClaimOrder(r.Context(), book.ClaimOrderRequest{
+		CartToken: claimEntry.CartToken,
+		UserID:    claims.UserID,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(claimedOrder))
+}
+
+/* Closes the order calculating its final prices, taxed by the region of its shipping address. */
+func (h *BookHandler) checkout(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
+	checkedOutOrder, err := h.bookService.Checkout(r.Context(), orderID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(checkedOutOrder))
+}
+
+/* Confirms the payment of a checked out order, for the payment processor. */
+func (h *BookHandler) confirmPayment(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorize(w, r, book.PermissionPaymentsWrite) {
+		return
+	}
+
+	paidOrder, err := h.bookService.ConfirmPayment(r.Context(), orderID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(paidOrder))
+}
+
+type ShippingAddressEntry struct {
+	Recipient  string `json:"recipient"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+type OrderDetailsEntry struct {
+	ShippingAddress *ShippingAddressEntry `json:"shipping_address"`
+	ContactEmail    string                `json:"contact_email"`
+	ContactPhone    string                `json:"contact_phone"`
+	Notes           string                `json:"notes"`
+}
+
+/* Validates the entry, then replaces the shipping address, contact and notes of the order. */
+func (h *BookHandler) updateOrderDetails(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
+	var detailsEntry OrderDetailsEntry
+	err := json.NewDecoder(r.Body).Decode(&detailsEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	detailsEntry = trimOrderDetailsEntry(detailsEntry)
+	err = FilledOrderDetailsFields(detailsEntry) //Verify if all entry fields are filled correctly.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updatedOrder, err := h.bookService.UpdateOrderDetails(r.Context(), orderDetailsToUpdateReq(detailsEntry, orderID))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(updatedOrder))
+}
+
+/* Verifies if the OrderDetails entry fields are filled correctly and returns a warning message if not. All of them are optional, but the address must be complete when given. */
+func FilledOrderDetailsFields(detailsEntry OrderDetailsEntry) error {
+	if a := detailsEntry.ShippingAddress; a != nil {
+		if a.Recipient == "" || a.Line1 == "" || a.City == "" || a.PostalCode == "" {
+			return book.ErrResponseShippingAddressInvalid
+		}
+		if len(a.Country) != 2 || !isLetters(a.Country) {
+			return book.ErrResponseShippingAddressInvalid
+		}
+	}
+	if detailsEntry.ContactEmail != "" && !validEmail(detailsEntry.ContactEmail) {
+		return book.ErrResponseContactEmailInvalid
+	}
+	if detailsEntry.ContactPhone != "" && !validPhone(detailsEntry.ContactPhone) {
+		return book.ErrResponseContactPhoneInvalid
+	}
+	if utf8.RuneCountInString(detailsEntry.Notes) > 500 {
+		return book.ErrResponseOrderNotesTooLong
+	}
+
+	return nil
+}
+
+/* Trims the spaces around every field of the entry, and uppercases the country code. */
+func trimOrderDetailsEntry(d OrderDetailsEntry) OrderDetailsEntry {
+	if d.ShippingAddress != nil {
+		a := *d.ShippingAddress
+		a.Recipient = strings.TrimSpace(a.Recipient)
+		a.Line1 = strings.TrimSpace(a.Line1)
+		a.Line2 = strings.TrimSpace(a.Line2)
+		a.City = strings.TrimSpace(a.City)
+		a.State = strings.TrimSpace(a.State)
+		a.PostalCode = strings.TrimSpace(a.PostalCode)
+		a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+		d.ShippingAddress = &a
+	}
+	d.ContactEmail = strings.TrimSpace(d.ContactEmail)
+	d.ContactPhone = strings.TrimSpace(d.ContactPhone)
+	d.Notes = strings.TrimSpace(d.Notes)
+	return d
+}
+
+/* Checks if the text is a bare email address. Display names, like "Name <name@mail.com>", are not accepted. */
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+/* Checks if a phone number has from 8 to 15 digits, as E.164 allows, optionally starting with '+'. Spaces, dashes, dots and parentheses between them are accepted. */
+func validPhone(phone string) bool {
+	phone, _ = strings.CutPrefix(phone, "+")
+	digits := 0
+	for _, c := range phone {
+		switch {
+		case unicode.IsDigit(c):
+			digits++
+		case c == ' ', c == '-', c == '.', c == '(', c == ')':
+			continue
+		default:
+			return false
+		}
+	}
+	return digits >= 8 && digits <= 15
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+/* Converts from OrderDetailsEntry type to UpdateOrderDetailsRequest type, with no json tags. */
+func orderDetailsToUpdateReq(d OrderDetailsEntry, orderID uuid.UUID) book.UpdateOrderDetailsRequest {
+	req := book.UpdateOrderDetailsRequest{
+		OrderID:      orderID,
+		ContactEmail: d.ContactEmail,
+		ContactPhone: d.ContactPhone,
+		Notes:        d.Notes,
+	}
+	if d.ShippingAddress != nil {
+		req.ShippingAddress = &book.ShippingAddress{
+			Recipient:  d.ShippingAddress.Recipient,
+			Line1:      d.ShippingAddress.Line1,
+			Line2:      d.ShippingAddress.Line2,
+			City:       d.ShippingAddress.City,
+			State:      d.ShippingAddress.State,
+			PostalCode: d.ShippingAddress.PostalCode,
+			Country:    d.ShippingAddress.Country,
+		}
+	}
+	return req
+}
+
+/* Returns the status changes of the order, the oldest first. */
+func (h *BookHandler) listOrderHistory(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersRead) {
+		return
+	}
+
+	history, err := h.bookService.ListOrderHistory(r.Context(), orderID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []StatusChangeResponse{}
+	for _, change := range history {
+		results = append(results, statusChangeToResponse(change))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+type StatusChangeResponse struct {
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  string    `json:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+/*Copy the fields of a status change to an http layer struct with json tags*/
+func statusChangeToResponse(c book.StatusChange) StatusChangeResponse {
+	return StatusChangeResponse{
+		FromStatus: c.FromStatus,
+		ToStatus:   c.ToStatus,
+		ChangedBy:  c.ChangedBy,
+		ChangedAt:  c.ChangedAt,
+	}
+}
+
 type UpdateOrderEntry struct {
 	OrderID        uuid.UUID `json:"order_id"`
 	BookID         uuid.UUID `json:"book_id"`
@@ -75,6 +458,10 @@ type UpdateOrderEntry struct {
 
 /* Validates the entry, then updates the order adding or removing books. */
 func (h *BookHandler) updateOrder(w http.ResponseWriter, r *http.Request) {
+	if !authorizeCart(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
 	var updateOrderEntry UpdateOrderEntry
 	err := json.NewDecoder(r.Body).Decode(&updateOrderEntry)
 	if err != nil {
@@ -128,12 +515,136 @@ func updateOrderToUpdateReq(o UpdateOrderEntry) book.UpdateOrderRequest {
 	}
 }
 
+type OrderItemChangeEntry struct {
+	BookID         uuid.UUID `json:"book_id"`
+	BookUnitsToAdd int       `json:"book_units_to_add"`
+}
+
+type UpdateOrderItemsEntry struct {
+	OrderID uuid.UUID              `json:"order_id"`
+	Items   []OrderItemChangeEntry `json:"items"`
+}
+
+/* Validates the entry, then updates many items of the order at once. */
+func (h *BookHandler) updateOrderItems(w http.ResponseWriter, r *http.Request) {
+	if !authorizeCart(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
+	var updateItemsEntry UpdateOrderItemsEntry
+	err := json.NewDecoder(r.Body).Decode(&updateItemsEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	err = FilledUpdtOrderItemsFields(updateItemsEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reqItems := updateOrderItemsToUpdateReq(updateItemsEntry)
+
+	updatedOrder, err := h.bookService.UpdateOrderItemsTx(r.Context(), reqItems)
+	if err != nil {
+		handleError(fmt.Errorf("error on call to UpdateOrderItemsTx: %w ", err), w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(updatedOrder))
+}
+
+/* Verifies if all UpdateOrderItems entry fields are filled and returns a warning message if so. */
+func FilledUpdtOrderItemsFields(updtItemsEntry UpdateOrderItemsEntry) error {
+	if updtItemsEntry.OrderID == uuid.Nil {
+		return book.ErrResponseUpdateOrderItemsEntryBlankFields
+	}
+	if len(updtItemsEntry.Items) == 0 {
+		return book.ErrResponseUpdateOrderItemsEntryBlankFields
+	}
+	for _, item := range updtItemsEntry.Items {
+		if item.BookID == uuid.Nil {
+			return book.ErrResponseUpdateOrderItemsEntryBlankFields
+		}
+		if item.BookUnitsToAdd == 0 { //If this value comes 0, than nothing changes, so it's not valid.
+			return book.ErrResponseUpdateOrderItemsEntryBlankFields
+		}
+	}
+
+	return nil
+}
+
+/* Converts from UpdateOrderItemsEntry type to UpdateOrderItemsRequest type, with no json tags. */
+func updateOrderItemsToUpdateReq(o UpdateOrderItemsEntry) book.UpdateOrderItemsRequest {
+	items := []book.OrderItemChange{}
+	for _, item := range o.Items {
+		items = append(items, book.OrderItemChange{
+			BookID:         item.BookID,
+			BookUnitsToAdd: item.BookUnitsToAdd,
+		})
+	}
+
+	return book.UpdateOrderItemsRequest{
+		OrderID: o.OrderID,
+		Items:   items,
+	}
+}
+
+type OrderItemErrorResponse struct {
+	BookID  uuid.UUID `json:"book_id"`
+	Code    int       `json:"error_code"`
+	Message string    `json:"error_message"`
+}
+
+type OrderItemsRejectedResponse struct {
+	Code    int                      `json:"error_code"`
+	Message string                   `json:"error_message"`
+	Items   []OrderItemErrorResponse `json:"items"`
+}
+
+/*Copy the errors of each rejected item to an http layer struct with json tags*/
+func orderItemsRejectedToResponse(e book.ErrOrderItemsRejected) OrderItemsRejectedResponse {
+	items := []OrderItemErrorResponse{}
+	for _, item := range e.Items {
+		items = append(items, OrderItemErrorResponse{
+			BookID:  item.BookID,
+			Code:    item.Err.Code,
+			Message: item.Err.Message,
+		})
+	}
+
+	return OrderItemsRejectedResponse{
+		Code:    book.ErrResponseOrderItemsRejected.Code,
+		Message: book.ErrResponseOrderItemsRejected.Message,
+		Items:   items,
+	}
+}
+
 type OrderResponse struct {
 	OrderID     uuid.UUID           `json:"order_id"`
 	PurchaserID uuid.UUID           `json:"purchaser_id"`
 	OrderStatus string              `json:"order_status"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	CouponCode  string              `json:"coupon_code,omitempty"`
+	Subtotal    float32             `json:"subtotal"`
+	Discount    float32             `json:"discount"`
+	TaxRegion   string              `json:"tax_region,omitempty"`
+	Tax         float32             `json:"tax"`
+	Shipping    float32             `json:"shipping"`
 	TotalPrice  float32             `json:"total_price"`
 	Items       []OrderItemResponse `json:"order_items"`
+
+	ShippingAddress *ShippingAddressEntry `json:"shipping_address,omitempty"`
+	ContactEmail    string                `json:"contact_email,omitempty"`
+	ContactPhone    string                `json:"contact_phone,omitempty"`
+	Notes           string                `json:"notes,omitempty"`
 }
 
 /*Copy the fields of an order object to an http layer struct with json tags*/
@@ -143,12 +654,38 @@ func orderToResponse(o book.Order) OrderResponse {
 		items = append(items, orderItemToResponse(item))
 	}
 
+	var shippingAddress *ShippingAddressEntry
+	if a := o.ShippingAddress; a != nil {
+		shippingAddress = &ShippingAddressEntry{
+			Recipient:  a.Recipient,
+			Line1:      a.Line1,
+			Line2:      a.Line2,
+			City:       a.City,
+			State:      a.State,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+		}
+	}
+
 	return OrderResponse{
 		OrderID:     o.OrderID,
 		PurchaserID: o.PurchaserID,
 		OrderStatus: o.OrderStatus,
+		CreatedAt:   o.CreatedAt,
+		UpdatedAt:   o.UpdatedAt,
+		CouponCode:  o.CouponCode,
+		Subtotal:    o.Subtotal,
+		Discount:    o.Discount,
+		TaxRegion:   o.TaxRegion,
+		Tax:         o.Tax,
+		Shipping:    o.Shipping,
 		TotalPrice:  o.TotalPrice,
 		Items:       items,
+
+		ShippingAddress: shippingAddress,
+		ContactEmail:    o.ContactEmail,
+		ContactPhone:    o.ContactPhone,
+		Notes:           o.Notes,
 	}
 }
 
@@ -175,6 +712,10 @@ type ListItemsEntry struct {
 
 /* Validates the entry, then creates an empty order. */
 func (h *BookHandler) listOrderItems(w http.ResponseWriter, r *http.Request) {
+	if !authorizeCart(w, r, book.PermissionOrdersRead) {
+		return
+	}
+
 	var listItemsEntry ListItemsEntry
 	err := json.NewDecoder(r.Body).Decode(&listItemsEntry)
 	if err != nil {
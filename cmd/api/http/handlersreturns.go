@@ -0,0 +1,209 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Addresses a call to "/returns/(expected id here)/(expected action here)" according to the requested action. Approving and rejecting returns are admin actions.  */
+func (h *BookHandler) returnById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, action, err := isolateReturnPath(w, r)
+	if err != nil {
+		return
+	}
+
+	method := r.Method
+	switch {
+	case action == "approve" && method == http.MethodPost:
+		h.approveReturn(w, r, id)
+		return
+	case action == "reject" && method == http.MethodPost:
+		h.rejectReturn(w, r, id)
+		return
+	case action == "approve", action == "reject":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+/* Isolates the return ID and the asked action from the URL. */
+func isolateReturnPath(w http.ResponseWriter, r *http.Request) (id uuid.UUID, action string, err error) {
+	path, _ := strings.CutPrefix(r.URL.Path, "/returns/")
+	justId, action, _ := strings.Cut(path, "/")
+	id, err = uuid.Parse(justId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseReturnIdInvalidFormat)
+		return id, action, err
+	}
+	return id, action, nil
+}
+
+type ReturnItemEntry struct {
+	BookID    uuid.UUID `json:"book_id"`
+	BookUnits int       `json:"book_units"`
+}
+
+type ReturnEntry struct {
+	Reason string            `json:"reason"`
+	Items  []ReturnItemEntry `json:"items"`
+}
+
+/* Validates the entry, then asks to return books of the order. */
+func (h *BookHandler) requestReturn(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersWrite) {
+		return
+	}
+
+	var returnEntry ReturnEntry
+	err := json.NewDecoder(r.Body).Decode(&returnEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	err = FilledReturnFields(returnEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	requested, err := h.bookService.RequestReturn(r.Context(), returnToCreateReq(returnEntry, orderID))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, returnToResponse(requested))
+}
+
+/* Returns all the returns of the order. */
+func (h *BookHandler) listReturns(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersRead) {
+		return
+	}
+
+	returns, err := h.bookService.ListReturns(r.Context(), orderID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []ReturnResponse{}
+	for _, ret := range returns {
+		results = append(results, returnToResponse(ret))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+/* Approves the return, restocking and refunding its books. */
+func (h *BookHandler) approveReturn(w http.ResponseWriter, r *http.Request, returnID uuid.UUID) {
+	if !authorize(w, r, book.PermissionReturnsReview) {
+		return
+	}
+
+	approved, err := h.bookService.ApproveReturn(r.Context(), returnID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, returnToResponse(approved))
+}
+
+/* Rejects the return. */
+func (h *BookHandler) rejectReturn(w http.ResponseWriter, r *http.Request, returnID uuid.UUID) {
+	if !authorize(w, r, book.PermissionReturnsReview) {
+		return
+	}
+
+	rejected, err := h.bookService.RejectReturn(r.Context(), returnID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, returnToResponse(rejected))
+}
+
+/* Verifies if all Return entry fields are filled and returns a warning message if not. */
+func FilledReturnFields(returnEntry ReturnEntry) error {
+	if returnEntry.Reason == "" {
+		return book.ErrResponseReturnEntryBlankFields
+	}
+	if len(returnEntry.Items) == 0 {
+		return book.ErrResponseReturnEntryBlankFields
+	}
+	for _, item := range returnEntry.Items {
+		if item.BookID == uuid.Nil || item.BookUnits < 1 {
+			return book.ErrResponseReturnEntryBlankFields
+		}
+	}
+
+	return nil
+}
+
+/* Converts from ReturnEntry type to CreateReturnRequest type, with no json tags. */
+func returnToCreateReq(returnEntry ReturnEntry, orderID uuid.UUID) book.CreateReturnRequest {
+	items := []book.ReturnItem{}
+	for _, item := range returnEntry.Items {
+		items = append(items, book.ReturnItem{BookID: item.BookID, BookUnits: item.BookUnits})
+	}
+	return book.CreateReturnRequest{
+		OrderID: orderID,
+		Reason:  returnEntry.Reason,
+		Items:   items,
+	}
+}
+
+type ReturnResponse struct {
+	ReturnID     uuid.UUID         `json:"return_id"`
+	OrderID      uuid.UUID         `json:"order_id"`
+	Status       string            `json:"status"`
+	Reason       string            `json:"reason"`
+	Items        []ReturnItemEntry `json:"items"`
+	RefundAmount float32           `json:"refund_amount"`
+	RefundID     string            `json:"refund_id,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ResolvedAt   *time.Time        `json:"resolved_at,omitempty"`
+}
+
+/*Copy the fields of a return object to an http layer struct with json tags*/
+func returnToResponse(ret book.Return) ReturnResponse {
+	items := []ReturnItemEntry{}
+	for _, item := range ret.Items {
+		items = append(items, ReturnItemEntry{BookID: item.BookID, BookUnits: item.BookUnits})
+	}
+	return ReturnResponse{
+		ReturnID:     ret.ReturnID,
+		OrderID:      ret.OrderID,
+		Status:       ret.Status,
+		Reason:       ret.Reason,
+		Items:        items,
+		RefundAmount: ret.RefundAmount,
+		RefundID:     ret.RefundID,
+		CreatedAt:    ret.CreatedAt,
+		ResolvedAt:   ret.ResolvedAt,
+	}
+}
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Addresses a call to "/shipments/(expected id here)/(expected action here)" according to the requested action. These are warehouse actions.  */
+func (h *BookHandler) shipmentById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, action, err := isolateShipmentPath(w, r)
+	if err != nil {
+		return
+	}
+
+	method := r.Method
+	switch {
+	case action == "ship" && method == http.MethodPost:
+		h.shipShipment(w, r, id)
+		return
+	case action == "deliver" && method == http.MethodPost:
+		h.deliverShipment(w, r, id)
+		return
+	case action == "ship", action == "deliver":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+/* Isolates the shipment ID and the asked action from the URL. */
+func isolateShipmentPath(w http.ResponseWriter, r *http.Request) (id uuid.UUID, action string, err error) {
+	path, _ := strings.CutPrefix(r.URL.Path, "/shipments/")
+	justId, action, _ := strings.Cut(path, "/")
+	id, err = uuid.Parse(justId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseShipmentIdInvalidFormat)
+		return id, action, err
+	}
+	return id, action, nil
+}
+
+type ShipmentItemEntry struct {
+	BookID    uuid.UUID `json:"book_id"`
+	BookUnits int       `json:"book_units"`
+}
+
+type PickShipmentEntry struct {
+	Items []ShipmentItemEntry `json:"items"`
+}
+
+/* Validates the entry, then records the books of the order picked at the warehouse as a new shipment. */
+func (h *BookHandler) pickShipment(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorize(w, r, book.PermissionFulfillmentWrite) {
+		return
+	}
+
+	var pickEntry PickShipmentEntry
+	err := json.NewDecoder(r.Body).Decode(&pickEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	err = FilledPickShipmentFields(pickEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	picked, err := h.bookService.PickShipment(r.Context(), pickShipmentToReq(pickEntry, orderID))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, shipmentToResponse(picked))
+}
+
+/* Returns all the shipments of the order. */
+func (h *BookHandler) listShipments(w http.ResponseWriter, r *http.Request, orderID uuid.UUID) {
+	if !authorizeOwned(w, r, book.PermissionOrdersRead) {
+		return
+	}
+
+	shipments, err := h.bookService.ListShipments(r.Context(), orderID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []ShipmentResponse{}
+	for _, shipment := range shipments {
+		results = append(results, shipmentToResponse(shipment))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+type ShipEntry struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+/* Validates the entry, then records that the shipment left the warehouse. */
+func (h *BookHandler) shipShipment(w http.ResponseWriter, r *http.Request, shipmentID uuid.UUID) {
+	if !authorize(w, r, book.PermissionFulfillmentWrite) {
+		return
+	}
+
+	var shipEntry ShipEntry
+	err := json.NewDecoder(r.Body).Decode(&shipEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	shipEntry.Carrier = strings.TrimSpace(shipEntry.Carrier)
+	shipEntry.TrackingNumber = strings.TrimSpace(shipEntry.TrackingNumber)
+	if shipEntry.Carrier == "" || shipEntry.TrackingNumber == "" {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseShipEntryBlankFields)
+		return
+	}
+
+	shipped, err := h.bookService.ShipShipment(r.Context(), book.ShipShipmentRequest{
+		ShipmentID:     shipmentID,
+		Carrier:        shipEntry.Carrier,
+		TrackingNumber: shipEntry.TrackingNumber,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, shipmentToResponse(shipped))
+}
+
+/* Records that the shipment reached the purchaser. */
+func (h *BookHandler) deliverShipment(w http.ResponseWriter, r *http.Request, shipmentID uuid.UUID) {
+	if !authorize(w, r, book.PermissionFulfillmentWrite) {
+		return
+	}
+
+	delivered, err := h.bookService.DeliverShipment(r.Context(), shipmentID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, shipmentToResponse(delivered))
+}
+
+/* Verifies if all PickShipment entry fields are filled and returns a warning message if not. */
+func FilledPickShipmentFields(pickEntry PickShipmentEntry) error {
+	if len(pickEntry.Items) == 0 {
+		return book.ErrResponseShipmentEntryBlankFields
+	}
+	for _, item := range pickEntry.Items {
+		if item.BookID == uuid.Nil || item.BookUnits < 1 {
+			return book.ErrResponseShipmentEntryBlankFields
+		}
+	}
+
+	return nil
+}
+
+/* Converts from PickShipmentEntry type to PickShipmentRequest type, with no json tags. */
+func pickShipmentToReq(pickEntry PickShipmentEntry, orderID uuid.UUID) book.PickShipmentRequest {
+	items := []book.ShipmentItem{}
+	for _, item := range pickEntry.Items {
+		items = append(items, book.ShipmentItem{BookID: item.BookID, BookUnits: item.BookUnits})
+	}
+	return book.PickShipmentRequest{
+		OrderID: orderID,
+		Items:   items,
+	}
+}
+
+type ShipmentResponse struct {
+	ShipmentID     uuid.UUID           `json:"shipment_id"`
+	OrderID        uuid.UUID           `json:"order_id"`
+	Status         string              `json:"status"`
+	Items          []ShipmentItemEntry `json:"items"`
+	Carrier        string              `json:"carrier,omitempty"`
+	TrackingNumber string              `json:"tracking_number,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	ShippedAt      *time.Time          `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time          `json:"delivered_at,omitempty"`
+}
+
+/*Copy the fields of a shipment object to an http layer struct with json tags*/
+func shipmentToResponse(shipment book.Shipment) ShipmentResponse {
+	items := []ShipmentItemEntry{}
+	for _, item := range shipment.Items {
+		items = append(items, ShipmentItemEntry{BookID: item.BookID, BookUnits: item.BookUnits})
+	}
+	return ShipmentResponse{
+		ShipmentID:     shipment.ShipmentID,
+		OrderID:        shipment.OrderID,
+		Status:         shipment.Status,
+		Items:          items,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		CreatedAt:      shipment.CreatedAt,
+		ShippedAt:      shipment.ShippedAt,
+		DeliveredAt:    shipment.DeliveredAt,
+	}
+}
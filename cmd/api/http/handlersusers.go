@@ -0,0 +1,538 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Addresses a call to "/users" according to the requested action.  */
+func (h *BookHandler) users(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.listUsers(w, r)
+		return
+	case http.MethodPost:
+		h.createUser(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/users/(expected id here)(optional wishlist, book id and action, or personal data action, here)" according to the requested action.  */
+func (h *BookHandler) userById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, resource, err := isolateUserPath(w, r)
+	if err != nil {
+		return
+	}
+
+	if resource == "" {
+		h.user(w, r, id)
+		return
+	}
+
+	if resource == "export" || resource == "erasure" {
+		h.personalData(w, r, id, resource)
+		return
+	}
+
+	resource, wishlistPath, found := strings.Cut(resource, "/")
+	if resource != "wishlist" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !found || wishlistPath == "" {
+		h.wishlist(w, r, id)
+		return
+	}
+
+	justBookId, action, _ := strings.Cut(wishlistPath, "/")
+	bookID, err := uuid.Parse(justBookId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseIdInvalidFormat)
+		return
+	}
+
+	method := r.Method
+	switch {
+	case action == "" && method == http.MethodDelete:
+		h.removeFromWishlist(w, r, id, bookID)
+		return
+	case action == "move" && method == http.MethodPost:
+		h.moveWishlistItemToOrder(w, r, id, bookID)
+		return
+	case action == "", action == "move":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+/* Addresses a call to "/users/(expected id here)" according to the requested action.  */
+func (h *BookHandler) user(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.getUser(w, r, userID)
+		return
+	case http.MethodPut:
+		h.updateUser(w, r, userID)
+		return
+	case http.MethodDelete:
+		h.deleteUser(w, r, userID)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/users/(expected id here)/export" or "/users/(expected id here)/erasure" according to the requested action.  */
+func (h *BookHandler) personalData(w http.ResponseWriter, r *http.Request, userID uuid.UUID, action string) {
+	method := r.Method
+	switch {
+	case action == "export" && method == http.MethodGet:
+		h.exportUserData(w, r, userID)
+		return
+	case action == "erasure" && method == http.MethodPost:
+		h.eraseUserData(w, r, userID)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/users/(expected id here)/wishlist" according to the requested action.  */
+func (h *BookHandler) wishlist(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.listWishlist(w, r, userID)
+		return
+	case http.MethodPost:
+		h.addToWishlist(w, r, userID)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Isolates the user ID and the asked resource from the URL. */
+func isolateUserPath(w http.ResponseWriter, r *http.Request) (id uuid.UUID, resource string, err error) {
+	path, _ := strings.CutPrefix(r.URL.Path, "/users/")
+	justId, resource, _ := strings.Cut(path, "/")
+	id, err = uuid.Parse(justId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseUserIdInvalidFormat)
+		return id, resource, err
+	}
+	return id, resource, nil
+}
+
+type UserEntry struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`     //'user' when not filled
+	Email    string `json:"email"`    //needed to log in
+	Password string `json:"password"` //kept when not filled at updates
+}
+
+/* Validates the entry, then stores it as a new user. */
+func (h *BookHandler) createUser(w http.ResponseWriter, r *http.Request) {
+	var userEntry UserEntry
+	err := json.NewDecoder(r.Body).Decode(&userEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	userEntry = trimUserEntry(userEntry)
+	err = FilledUserFields(userEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+	if userEntry.Role != book.UserRoleUser && !authorize(w, r, book.PermissionUsersRoles) { //Anyone can sign up, but only as a user.
+		return
+	}
+
+	storedUser, err := h.bookService.CreateUser(r.Context(), book.CreateUserRequest{
+		Name:     userEntry.Name,
+		Role:     userEntry.Role,
+		Email:    userEntry.Email,
+		Password: userEntry.Password,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, userToResponse(storedUser))
+}
+
+/* Returns all the users. */
+func (h *BookHandler) listUsers(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionUsersRead) {
+		return
+	}
+
+	users, err := h.bookService.ListUsers(r.Context())
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []UserResponse{}
+	for _, u := range users {
+		results = append(results, userToResponse(u))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+/* Returns the user with that specific ID. */
+func (h *BookHandler) getUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersRead, userID) {
+		return
+	}
+
+	user, err := h.bookService.GetUser(r.Context(), userID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, userToResponse(user))
+}
+
+/* Validates the entry, then updates the asked user. */
+func (h *BookHandler) updateUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersWrite, userID) {
+		return
+	}
+
+	var userEntry UserEntry
+	err := json.NewDecoder(r.Body).Decode(&userEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	userEntry = trimUserEntry(userEntry)
+	err = FilledUserFields(userEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return
+	}
+	if userEntry.Role != book.UserRoleUser && !authorize(w, r, book.PermissionUsersRoles) {
+		return
+	}
+
+	updatedUser, err := h.bookService.UpdateUser(r.Context(), book.UpdateUserRequest{
+		UserID:   userID,
+		Name:     userEntry.Name,
+		Role:     userEntry.Role,
+		Email:    userEntry.Email,
+		Password: userEntry.Password,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, userToResponse(updatedUser))
+}
+
+/* Deletes the user with that specific ID. */
+func (h *BookHandler) deleteUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersWrite, userID) {
+		return
+	}
+
+	err := h.bookService.DeleteUser(r.Context(), userID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* Trims the spaces around the entry fields, but the password, lowercases the email and fills the default role. */
+func trimUserEntry(userEntry UserEntry) UserEntry {
+	userEntry.Name = strings.TrimSpace(userEntry.Name)
+	userEntry.Role = strings.TrimSpace(userEntry.Role)
+	userEntry.Email = strings.ToLower(strings.TrimSpace(userEntry.Email))
+	if userEntry.Role == "" {
+		userEntry.Role = book.UserRoleUser
+	}
+	return userEntry
+}
+
+/* Verifies if all User entry fields are filled and returns a warning message if not. */
+func FilledUserFields(userEntry UserEntry) error {
+	if userEntry.Name == "" {
+		return book.ErrResponseUserEntryBlankFields
+	}
+	if userEntry.Role != book.UserRoleUser && userEntry.Role != book.UserRoleAdmin {
+		return book.ErrResponseUserEntryBlankFields
+	}
+	if userEntry.Email != "" && !validEmail(userEntry.Email) {
+		return book.ErrResponseUserEmailInvalid
+	}
+	if userEntry.Password != "" && (len(userEntry.Password) < 8 || len(userEntry.Password) > 72 || userEntry.Email == "") { //bcrypt uses only the first 72 bytes.
+		return book.ErrResponseUserPasswordInvalid
+	}
+
+	return nil
+}
+
+type UserResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+/*Copy the fields of a user object to an http layer struct with json tags*/
+func userToResponse(u book.User) UserResponse {
+	return UserResponse{
+		UserID:    u.UserID,
+		Name:      u.Name,
+		Role:      u.Role,
+		Email:     u.Email,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+/* Returns the profile of the user, with its orders and their items, as a single document. */
+func (h *BookHandler) exportUserData(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorize(w, r, book.PermissionUsersPrivacy) {
+		return
+	}
+
+	export, err := h.bookService.ExportUserData(r.Context(), userID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, UserDataExportToResponse(export))
+}
+
+/* Anonymizes the user and the personal fields of its orders, keeping their financial records. */
+func (h *BookHandler) eraseUserData(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorize(w, r, book.PermissionUsersPrivacy) {
+		return
+	}
+
+	erasure, err := h.bookService.EraseUserData(r.Context(), userID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, UserErasureToResponse(erasure))
+}
+
+type UserDataExportResponse struct {
+	User       UserResponse    `json:"user"`
+	Orders     []OrderResponse `json:"orders"`
+	ExportedAt time.Time       `json:"exported_at"`
+}
+
+/*Copy the fields of a user data export to an http layer struct with json tags. Also used by the CLI, so both give the same document.*/
+func UserDataExportToResponse(export book.UserDataExport) UserDataExportResponse {
+	orders := []OrderResponse{}
+	for _, o := range export.Orders {
+		orders = append(orders, orderToResponse(o))
+	}
+
+	return UserDataExportResponse{
+		User:       userToResponse(export.User),
+		Orders:     orders,
+		ExportedAt: export.ExportedAt,
+	}
+}
+
+type UserErasureResponse struct {
+	User             UserResponse `json:"user"`
+	OrdersAnonymized int          `json:"orders_anonymized"`
+	ErasedAt         time.Time    `json:"erased_at"`
+}
+
+/*Copy the fields of a user erasure to an http layer struct with json tags. Also used by the CLI.*/
+func UserErasureToResponse(erasure book.UserErasure) UserErasureResponse {
+	return UserErasureResponse{
+		User:             userToResponse(erasure.User),
+		OrdersAnonymized: erasure.OrdersAnonymized,
+		ErasedAt:         erasure.ErasedAt,
+	}
+}
+
+type WishlistEntry struct {
+	BookID uuid.UUID `json:"book_id"`
+}
+
+/* Validates the entry, then adds the book to the wishlist of the user. */
+func (h *BookHandler) addToWishlist(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersWrite, userID) {
+		return
+	}
+
+	var wishlistEntry WishlistEntry
+	err := json.NewDecoder(r.Body).Decode(&wishlistEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	if wishlistEntry.BookID == uuid.Nil {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseWishlistEntryBlankFields)
+		return
+	}
+
+	item, err := h.bookService.AddToWishlist(r.Context(), userID, wishlistEntry.BookID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, wishlistItemToResponse(item))
+}
+
+/* Returns the books at the wishlist of the user. */
+func (h *BookHandler) listWishlist(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersRead, userID) {
+		return
+	}
+
+	items, err := h.bookService.ListWishlist(r.Context(), userID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []WishlistItemResponse{}
+	for _, i := range items {
+		results = append(results, wishlistItemToResponse(i))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+/* Removes the book from the wishlist of the user. */
+func (h *BookHandler) removeFromWishlist(w http.ResponseWriter, r *http.Request, userID uuid.UUID, bookID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersWrite, userID) {
+		return
+	}
+
+	err := h.bookService.RemoveFromWishlist(r.Context(), userID, bookID)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type MoveWishlistItemEntry struct {
+	OrderID   uuid.UUID `json:"order_id"`
+	BookUnits *int      `json:"book_units"` //1 unit when not filled
+}
+
+/* Validates the entry, then moves the book from the wishlist of the user to one of its orders. */
+func (h *BookHandler) moveWishlistItemToOrder(w http.ResponseWriter, r *http.Request, userID uuid.UUID, bookID uuid.UUID) {
+	if !authorizeOwner(w, r, book.PermissionUsersWrite, userID) {
+		return
+	}
+
+	var moveEntry MoveWishlistItemEntry
+	err := json.NewDecoder(r.Body).Decode(&moveEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return
+	}
+
+	bookUnits := 1
+	if moveEntry.BookUnits != nil {
+		bookUnits = *moveEntry.BookUnits
+	}
+	if moveEntry.OrderID == uuid.Nil || bookUnits < 1 {
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseMoveWishlistItemEntryBlankFields)
+		return
+	}
+
+	updatedOrder, err := h.bookService.MoveWishlistItemToOrder(r.Context(), book.MoveWishlistItemRequest{
+		UserID:    userID,
+		BookID:    bookID,
+		OrderID:   moveEntry.OrderID,
+		BookUnits: bookUnits,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, orderToResponse(updatedOrder))
+}
+
+type WishlistItemResponse struct {
+	Book    BookResponse `json:"book"`
+	AddedAt time.Time    `json:"added_at"`
+}
+
+/*Copy the fields of a wishlist item to an http layer struct with json tags*/
+func wishlistItemToResponse(i book.WishlistItem) WishlistItemResponse {
+	return WishlistItemResponse{
+		Book:    bookToResponse(i.Book),
+		AddedAt: i.AddedAt,
+	}
+}
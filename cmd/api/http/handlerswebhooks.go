@@ -0,0 +1,318 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* The shortest secret accepted from an entry. Blank secrets are generated instead. */
+const webhookSecretMinLength = 16
+
+/* Addresses a call to "/webhooks" according to the requested action. Webhooks are managed by admins.  */
+func (h *BookHandler) webhooks(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	method := r.Method
+	switch method {
+	case http.MethodGet:
+		h.listWebhooks(w, r)
+		return
+	case http.MethodPost:
+		h.createWebhook(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+/* Addresses a call to "/webhooks/(expected id here)" or "/webhooks/(expected id here)/deliveries" according to the requested action.  */
+func (h *BookHandler) webhookById(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.requestTimeout))
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	id, action, err := isolateWebhookPath(w, r)
+	if err != nil {
+		return
+	}
+
+	method := r.Method
+	switch {
+	case action == "" && method == http.MethodGet:
+		h.getWebhook(w, r, id)
+		return
+	case action == "" && method == http.MethodPut:
+		h.updateWebhook(w, r, id)
+		return
+	case action == "" && method == http.MethodDelete:
+		h.deleteWebhook(w, r, id)
+		return
+	case action == "deliveries" && method == http.MethodGet:
+		h.listWebhookDeliveries(w, r, id)
+		return
+	case action == "", action == "deliveries":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+/* Isolates the webhook ID and the asked action, if any, from the URL. */
+func isolateWebhookPath(w http.ResponseWriter, r *http.Request) (id uuid.UUID, action string, err error) {
+	path, _ := strings.CutPrefix(r.URL.Path, "/webhooks/")
+	justId, action, _ := strings.Cut(path, "/")
+	id, err = uuid.Parse(justId)
+	if err != nil {
+		log.Println(err)
+		responseJSON(w, http.StatusBadRequest, book.ErrResponseWebhookIdInvalidFormat)
+		return id, action, err
+	}
+	return id, action, nil
+}
+
+type WebhookEntry struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+/* Decodes and validates a webhook entry, answering the request when it is not valid. */
+func decodeWebhookEntry(w http.ResponseWriter, r *http.Request) (WebhookEntry, bool) {
+	var webhookEntry WebhookEntry
+	err := json.NewDecoder(r.Body).Decode(&webhookEntry)
+	if err != nil {
+		log.Println(err)
+		errR := book.ErrResponse{
+			Code:    book.ErrResponseEntryInvalidJSON.Code,
+			Message: book.ErrResponseEntryInvalidJSON.Message + err.Error(),
+		}
+		responseJSON(w, http.StatusBadRequest, errR)
+		return WebhookEntry{}, false
+	}
+
+	webhookEntry.URL = strings.TrimSpace(webhookEntry.URL)
+	err = FilledWebhookFields(webhookEntry) //Verify if all entry fields are filled.
+	if err != nil {
+		responseJSON(w, http.StatusBadRequest, err)
+		return WebhookEntry{}, false
+	}
+	return webhookEntry, true
+}
+
+/* Validates the entry, then subscribes the endpoint to the event types asked. */
+func (h *BookHandler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionWebhooksWrite) {
+		return
+	}
+
+	webhookEntry, ok := decodeWebhookEntry(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.bookService.CreateWebhook(r.Context(), book.CreateWebhookRequest{
+		URL:        webhookEntry.URL,
+		EventTypes: toEventTypes(webhookEntry.EventTypes),
+		Secret:     webhookEntry.Secret,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	response := webhookToResponse(created)
+	response.Secret = created.Secret //Shown only at creation, so the partner can verify the signatures.
+	responseJSON(w, http.StatusCreated, response)
+}
+
+/* Verifies if all Webhook entry fields are filled and returns a warning message if not. */
+func FilledWebhookFields(webhookEntry WebhookEntry) error {
+	endpoint, err := url.Parse(webhookEntry.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return book.ErrResponseWebhookEntryBlankFields
+	}
+	if len(webhookEntry.EventTypes) == 0 {
+		return book.ErrResponseWebhookEntryBlankFields
+	}
+	for _, eventType := range webhookEntry.EventTypes {
+		if !book.ValidEventType(book.EventType(eventType)) {
+			return book.ErrResponseWebhookEntryBlankFields
+		}
+	}
+	if webhookEntry.Secret != "" && len(webhookEntry.Secret) < webhookSecretMinLength {
+		return book.ErrResponseWebhookEntryBlankFields
+	}
+	return nil
+}
+
+/* Returns all the webhooks, with no secrets. */
+func (h *BookHandler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, book.PermissionWebhooksWrite) {
+		return
+	}
+
+	webhooks, err := h.bookService.ListWebhooks(r.Context())
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []WebhookResponse{}
+	for _, wh := range webhooks {
+		results = append(results, webhookToResponse(wh))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+/* Returns the webhook with that specific ID. */
+func (h *BookHandler) getWebhook(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if !authorize(w, r, book.PermissionWebhooksWrite) {
+		return
+	}
+
+	wh, err := h.bookService.GetWebhook(r.Context(), id)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, webhookToResponse(wh))
+}
+
+/* Validates the entry, then changes the webhook with that specific ID. A blank secret keeps the current one. */
+func (h *BookHandler) updateWebhook(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if !authorize(w, r, book.PermissionWebhooksWrite) {
+		return
+	}
+
+	webhookEntry, ok := decodeWebhookEntry(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.bookService.UpdateWebhook(r.Context(), book.UpdateWebhookRequest{
+		WebhookID:  id,
+		URL:        webhookEntry.URL,
+		EventTypes: toEventTypes(webhookEntry.EventTypes),
+		Secret:     webhookEntry.Secret,
+	})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, webhookToResponse(updated))
+}
+
+/* Deletes the webhook with that specific ID, with its delivery log. */
+func (h *BookHandler) deleteWebhook(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if !authorize(w, r, book.PermissionWebhooksWrite) {
+		return
+	}
+
+	err := h.bookService.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* Returns the delivery log of the webhook with that specific ID, the newest deliveries first. */
+func (h *BookHandler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if !authorize(w, r, book.PermissionWebhooksWrite) {
+		return
+	}
+
+	deliveries, err := h.bookService.ListWebhookDeliveries(r.Context(), id)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	results := []WebhookDeliveryResponse{}
+	for _, d := range deliveries {
+		results = append(results, webhookDeliveryToResponse(d))
+	}
+
+	responseJSON(w, http.StatusOK, results)
+}
+
+func toEventTypes(eventTypes []string) []book.EventType {
+	types := []book.EventType{}
+	for _, eventType := range eventTypes {
+		types = append(types, book.EventType(eventType))
+	}
+	return types
+}
+
+type WebhookResponse struct {
+	WebhookID  uuid.UUID `json:"webhook_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+/*Copy the fields of a webhook to an http layer struct with json tags. Its secret is left out.*/
+func webhookToResponse(wh book.Webhook) WebhookResponse {
+	eventTypes := []string{}
+	for _, eventType := range wh.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+
+	return WebhookResponse{
+		WebhookID:  wh.WebhookID,
+		URL:        wh.URL,
+		EventTypes: eventTypes,
+		CreatedAt:  wh.CreatedAt,
+		UpdatedAt:  wh.UpdatedAt,
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	DeliveryID     uuid.UUID  `json:"delivery_id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+/*Copy the fields of a webhook delivery to an http layer struct with json tags.*/
+func webhookDeliveryToResponse(d book.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		DeliveryID:     d.DeliveryID,
+		EventID:        d.EventID,
+		EventType:      string(d.EventType),
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
@@ -3,15 +3,18 @@ package http_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/books-service/cmd/api/book"
+	bookmock "github.com/books-service/cmd/api/book/mocks"
 	bookhttp "github.com/books-service/cmd/api/http"
 	httpmock "github.com/books-service/cmd/api/http/mocks"
 	"github.com/google/uuid"
@@ -19,13 +22,36 @@ import (
 	"go.uber.org/mock/gomock"
 )
 
+const idempotencyTTL = 24 * time.Hour
+
+var signingKey = []byte("test-signing-key-with-at-least-32-bytes")
+
+var testAdmin = book.Claims{UserID: uuid.MustParse("6f1f5d3c-2b8e-4c3a-9d51-7a0e2f6b9c41"), Role: book.UserRoleAdmin}
+
+/* Sends the request with a valid access token of an admin. */
+func authenticated(r *http.Request) *http.Request {
+	return withToken(r, testAdmin, time.Now().Add(time.Hour))
+}
+
+/* Sends the request with an access token of the given claims, valid until expiresAt. */
+func withToken(r *http.Request, claims book.Claims, expiresAt time.Time) *http.Request {
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = expiresAt.Unix()
+	token, err := book.SignAccessToken(signingKey, claims)
+	if err != nil {
+		panic(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
 func TestCreateBook(t *testing.T) {
 
 	ctrl := gomock.NewController(t)
 	mockAPI := httpmock.NewMockServiceAPI(ctrl)
 	reqTimeout := time.Duration(1) * time.Second
-	bookHandler := bookhttp.NewBookHandler(mockAPI, reqTimeout)
-	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080}, bookHandler)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, reqTimeout, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
 
 	t.Run("creates a book without errors", func(t *testing.T) {
 		is := is.New(t)
@@ -57,7 +83,7 @@ func TestCreateBook(t *testing.T) {
 
 		mockAPI.EXPECT().CreateBook(gomock.Any(), reqBook).Return(expectedReturn, nil)
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -79,7 +105,7 @@ func TestCreateBook(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(invalidBookToCreate))
 		response := httptest.NewRecorder()
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -100,7 +126,7 @@ func TestCreateBook(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(invalidBookToCreate))
 		response := httptest.NewRecorder()
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -109,6 +135,69 @@ func TestCreateBook(t *testing.T) {
 
 	})
 
+	t.Run("creates a book with purchase limits", func(t *testing.T) {
+		is := is.New(t)
+
+		reqBook := book.CreateBookRequest{
+			Name:                 "HTTP tester book",
+			Price:                toPointer(float32(100.0)),
+			Inventory:            toPointer(99),
+			MaxUnitsPerOrder:     toPointer(2),
+			MaxUnitsPerPurchaser: toPointer(4),
+		}
+		bookToCreate := `{
+			"name": "HTTP tester book",
+			"price": 100,
+			"inventory": 99,
+			"max_units_per_order": 2,
+			"max_units_per_purchaser": 4
+		}`
+		newID := uuid.New()
+		expectedReturn := book.Book{
+			ID:                   newID,
+			Name:                 reqBook.Name,
+			Price:                reqBook.Price,
+			Inventory:            reqBook.Inventory,
+			MaxUnitsPerOrder:     reqBook.MaxUnitsPerOrder,
+			MaxUnitsPerPurchaser: reqBook.MaxUnitsPerPurchaser,
+		}
+		expectedJSONresponse := fmt.Sprintf(`{"id":"%s","name":"HTTP tester book","price":100,"inventory":99,"archived":false,"max_units_per_order":2,"max_units_per_purchaser":4}`+"\n", newID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(bookToCreate))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().CreateBook(gomock.Any(), reqBook).Return(expectedReturn, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected invalid limits error", func(t *testing.T) {
+		is := is.New(t)
+
+		invalidBookToCreate := `{
+			"name": "test with a limit blocking every purchase",
+			"price": 100,
+			"inventory": 99,
+			"max_units_per_purchaser": 0
+		}`
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":138,"error_message":"fields max_units_per_order and max_units_per_purchaser, when filled, must be greater than zero."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(invalidBookToCreate))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
 	t.Run("expected context timeout error", func(t *testing.T) {
 		is := is.New(t)
 
@@ -130,7 +219,7 @@ func TestCreateBook(t *testing.T) {
 
 		mockAPI.EXPECT().CreateBook(gomock.Any(), reqBook).Return(book.Book{}, context.DeadlineExceeded)
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -143,9 +232,9 @@ func TestListBooks(t *testing.T) {
 
 	ctrl := gomock.NewController(t)
 	mockAPI := httpmock.NewMockServiceAPI(ctrl)
-	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
 
-	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080}, bookHandler)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
 
 	// Setting up, creating books to be listed.
 	var testBookslist []book.Book
@@ -196,7 +285,7 @@ func TestListBooks(t *testing.T) {
 
 		mockAPI.EXPECT().ListBooks(gomock.Any(), params).Return(expectedReturn, nil)
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -236,7 +325,7 @@ func TestListBooks(t *testing.T) {
 
 		mockAPI.EXPECT().ListBooks(gomock.Any(), params).Return(expectedReturn, nil)
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -257,7 +346,7 @@ func TestListBooks(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, url, nil)
 		response := httptest.NewRecorder()
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -278,7 +367,7 @@ func TestListBooks(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, url, nil)
 		response := httptest.NewRecorder()
 
-		server.Handler.ServeHTTP(response, request)
+		server.Handler.ServeHTTP(response, authenticated(request))
 
 		body, _ := io.ReadAll(response.Result().Body)
 
@@ -335,6 +424,2340 @@ func pagedBooksToResponse(page book.PagedBooks) PageOfBooksResponse {
 	}
 }
 
-//TODO: func TestCreateOrder(t *testing.T) {}
+func TestUpdateOrder(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	updtReq := book.UpdateOrderRequest{OrderID: uuid.New(), BookID: uuid.New(), BookUnitsToAdd: 3}
+	orderToUpdate := fmt.Sprintf(`{"order_id": "%s", "book_id": "%s", "book_units_to_add": 3}`, updtReq.OrderID, updtReq.BookID)
+
+	testCases := []struct {
+		name                 string
+		err                  error
+		expectedJSONresponse string
+	}{
+		{
+			name:                 "expected per order limit error",
+			err:                  book.ErrResponseMaxUnitsPerOrderExceeded,
+			expectedJSONresponse: fmt.Sprintln(`{"error_code":136,"error_message":"the units of this book exceed the limit allowed per order"}`),
+		},
+		{
+			name:                 "expected per purchaser limit error",
+			err:                  book.ErrResponseMaxUnitsPerPurchaserExceeded,
+			expectedJSONresponse: fmt.Sprintln(`{"error_code":137,"error_message":"the units of this book exceed the limit allowed per purchaser"}`),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			request, _ := http.NewRequest(http.MethodPut, "/order", strings.NewReader(orderToUpdate))
+			response := httptest.NewRecorder()
+
+			mockAPI.EXPECT().UpdateOrderTx(gomock.Any(), updtReq).Return(book.Order{}, tc.err)
+
+			server.Handler.ServeHTTP(response, authenticated(request))
+
+			body, _ := io.ReadAll(response.Result().Body)
+
+			is.True(response.Result().StatusCode == 400)
+			is.Equal(string(body), tc.expectedJSONresponse)
+		})
+	}
+}
+
+func TestUpdateOrderItems(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+	bookID := uuid.New()
+	itemsToUpdate := fmt.Sprintf(`{
+		"order_id": "%s",
+		"items": [{"book_id": "%s", "book_units_to_add": 3}]
+	}`, orderID, bookID)
+	updtReq := book.UpdateOrderItemsRequest{
+		OrderID: orderID,
+		Items:   []book.OrderItemChange{{BookID: bookID, BookUnitsToAdd: 3}},
+	}
+
+	t.Run("updates many items of an order without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"order_id":"%s","purchaser_id":"00000000-0000-0000-0000-000000000000","order_status":"accepting_items","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","subtotal":30,"discount":0,"tax":0,"shipping":0,"total_price":30,"order_items":[{"book_id":"%s","book_name":"HTTP tester book","book_units":3,"book_price":10}]}`+"\n", orderID, bookID)
+
+		request, _ := http.NewRequest(http.MethodPut, "/order/items", strings.NewReader(itemsToUpdate))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().UpdateOrderItemsTx(gomock.Any(), updtReq).Return(book.Order{
+			OrderID:     orderID,
+			OrderStatus: "accepting_items",
+			Subtotal:    30,
+			TotalPrice:  30,
+			Items:       []book.OrderItem{{BookID: bookID, BookName: "HTTP tester book", BookUnits: 3, BookPriceAtOrder: toPointer(float32(10))}},
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected rejected items error, with the error of each item", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"error_code":119,"error_message":"order items rejected, no changes were made to the order.","items":[{"book_id":"%s","error_code":113,"error_message":"inventory is insufficient for this order"}]}`+"\n", bookID)
+
+		request, _ := http.NewRequest(http.MethodPut, "/order/items", strings.NewReader(itemsToUpdate))
+		response := httptest.NewRecorder()
+
+		rejected := book.ErrOrderItemsRejected{Items: []book.OrderItemError{{BookID: bookID, Err: book.ErrResponseInsufficientInventory}}}
+		mockAPI.EXPECT().UpdateOrderItemsTx(gomock.Any(), updtReq).Return(book.Order{}, rejected)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected conflict error when the transaction retries are exhausted", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":135,"error_message":"the request conflicted with concurrent changes, please try again."}`)
+
+		request, _ := http.NewRequest(http.MethodPut, "/order/items", strings.NewReader(itemsToUpdate))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().UpdateOrderItemsTx(gomock.Any(), updtReq).Return(book.Order{}, book.ErrResponseTxRetriesExhausted)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected blank fields error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":120,"error_message":"all the fields - order_id and items, each one with book_id and book_units_to_add - must be filled correctly."}`)
+
+		request, _ := http.NewRequest(http.MethodPut, "/order/items", strings.NewReader(fmt.Sprintf(`{"order_id": "%s", "items": []}`, orderID)))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+func TestIdempotencyKey(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	userID := testAdmin.UserID
+	caller := "user:" + userID.String()
+	orderToCreate := fmt.Sprintf(`{"user_id": "%s"}`, userID)
+	newOrder := book.Order{OrderID: uuid.New(), PurchaserID: userID, OrderStatus: "accepting_items"}
+	expectedJSONresponse := fmt.Sprintf(`{"order_id":"%s","purchaser_id":"%s","order_status":"accepting_items","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","subtotal":0,"discount":0,"tax":0,"shipping":0,"total_price":0,"order_items":[]}`+"\n", newOrder.OrderID, userID)
+
+	var storedKey book.IdempotencyKey
+
+	t.Run("reserves a new key, creates an order and stores its response", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(orderToCreate))
+		request.Header.Set("Idempotency-Key", "key-1")
+		response := httptest.NewRecorder()
+
+		gomock.InOrder(
+			mockAPI.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, k book.IdempotencyKey) error {
+				is.Equal(k.Key, "key-1")
+				is.Equal(k.Caller, caller)
+				is.True(k.ExpiresAt.After(time.Now()))
+				return nil
+			}),
+			mockAPI.EXPECT().CreateOrder(gomock.Any(), userID).Return(newOrder, nil),
+			mockAPI.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, k book.IdempotencyKey) error {
+				is.Equal(k.Key, "key-1")
+				is.Equal(k.Caller, caller)
+				is.Equal(k.ResponseStatus, 200)
+				is.Equal(string(k.ResponseBody), expectedJSONresponse)
+				is.True(k.ExpiresAt.After(time.Now().Add(time.Hour)))
+				completedAt := time.Now()
+				k.CompletedAt = &completedAt
+				storedKey = k
+				return nil
+			}),
+		)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("replays the stored response of a retried request", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(orderToCreate))
+		request.Header.Set("Idempotency-Key", "key-1")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(book.ErrResponseIdempotencyKeyReused)
+		mockAPI.EXPECT().GetIdempotencyKey(gomock.Any(), caller, "key-1").Return(storedKey, nil) //CreateOrder must not be called again.
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(response.Result().Header.Get("Idempotent-Replayed"), "true")
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected in progress error for a retry while the request still runs", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(orderToCreate))
+		request.Header.Set("Idempotency-Key", "key-1")
+		response := httptest.NewRecorder()
+
+		reserved := storedKey
+		reserved.CompletedAt = nil
+		mockAPI.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(book.ErrResponseIdempotencyKeyReused)
+		mockAPI.EXPECT().GetIdempotencyKey(gomock.Any(), caller, "key-1").Return(reserved, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), fmt.Sprintln(`{"error_code":182,"error_message":"a request with this Idempotency-Key is still being processed. Retry it later."}`))
+	})
+
+	t.Run("expected conflict error reusing the key with a different request", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(fmt.Sprintf(`{"user_id": "%s"}`, uuid.New())))
+		request.Header.Set("Idempotency-Key", "key-1")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(book.ErrResponseIdempotencyKeyReused)
+		mockAPI.EXPECT().GetIdempotencyKey(gomock.Any(), caller, "key-1").Return(storedKey, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), fmt.Sprintln(`{"error_code":122,"error_message":"this Idempotency-Key was already used with a different request."}`))
+	})
+
+	t.Run("scopes the key to the caller", func(t *testing.T) {
+		is := is.New(t)
+
+		otherUser := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+		otherOrder := book.Order{OrderID: uuid.New(), PurchaserID: otherUser.UserID, OrderStatus: "accepting_items"}
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(fmt.Sprintf(`{"user_id": "%s"}`, otherUser.UserID)))
+		request.Header.Set("Idempotency-Key", "key-1")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, k book.IdempotencyKey) error {
+			is.Equal(k.Caller, "user:"+otherUser.UserID.String())
+			return nil
+		})
+		mockAPI.EXPECT().CreateOrder(gomock.Any(), otherUser.UserID).Return(otherOrder, nil)
+		mockAPI.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).Return(nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, otherUser, time.Now().Add(time.Hour)))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("releases the key of a request that failed on the server", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(orderToCreate))
+		request.Header.Set("Idempotency-Key", "key-2")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(nil)
+		mockAPI.EXPECT().CreateOrder(gomock.Any(), userID).Return(book.Order{}, errors.New("fake error from database"))
+		mockAPI.EXPECT().ReleaseIdempotencyKey(gomock.Any(), caller, "key-2").Return(nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 500)
+	})
+}
+
+func TestCoupons(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	t.Run("creates a coupon without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		couponToCreate := `{
+			"code": "TENOFF",
+			"discount_type": "percentage",
+			"discount_value": 10,
+			"usage_limit": 100
+		}`
+		reqCoupon := book.CreateCouponRequest{
+			Code:          "TENOFF",
+			DiscountType:  book.DiscountTypePercentage,
+			DiscountValue: 10,
+			UsageLimit:    toPointer(100),
+		}
+		newID := uuid.New()
+		expectedJSONresponse := fmt.Sprintf(`{"id":"%s","code":"TENOFF","discount_type":"percentage","discount_value":10,"min_order_total":0,"valid_from":null,"valid_until":null,"usage_limit":100,"times_used":0}`+"\n", newID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/coupons", strings.NewReader(couponToCreate))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().CreateCoupon(gomock.Any(), reqCoupon).Return(book.Coupon{
+			ID:            newID,
+			Code:          reqCoupon.Code,
+			DiscountType:  reqCoupon.DiscountType,
+			DiscountValue: reqCoupon.DiscountValue,
+			UsageLimit:    reqCoupon.UsageLimit,
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected blank fields error for a percentage greater than 100", func(t *testing.T) {
+		is := is.New(t)
+
+		couponToCreate := `{
+			"code": "ALLFREE",
+			"discount_type": "percentage",
+			"discount_value": 150
+		}`
+		expectedJSONresponse, err := json.Marshal(book.ErrResponseCouponEntryBlankFields)
+		is.NoErr(err)
+		expectedJSONresponse = append(expectedJSONresponse, []byte("\n")...)
+
+		request, _ := http.NewRequest(http.MethodPost, "/coupons", strings.NewReader(couponToCreate))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), string(expectedJSONresponse))
+	})
+
+	t.Run("applies a coupon to an order without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		orderID := uuid.New()
+		expectedJSONresponse := fmt.Sprintf(`{"order_id":"%s","purchaser_id":"00000000-0000-0000-0000-000000000000","order_status":"accepting_items","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","coupon_code":"TENOFF","subtotal":100,"discount":10,"tax":0,"shipping":0,"total_price":90,"order_items":[]}`+"\n", orderID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/coupon", strings.NewReader(`{"code": "TENOFF"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ApplyCoupon(gomock.Any(), orderID, "TENOFF").Return(book.Order{
+			OrderID:     orderID,
+			OrderStatus: "accepting_items",
+			CouponCode:  "TENOFF",
+			Subtotal:    100,
+			Discount:    10,
+			TotalPrice:  90,
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected coupon usage limit reached error", func(t *testing.T) {
+		is := is.New(t)
+
+		orderID := uuid.New()
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":128,"error_message":"coupon usage limit was reached"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/coupon", strings.NewReader(`{"code": "TENOFF"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ApplyCoupon(gomock.Any(), orderID, "TENOFF").Return(book.Order{}, book.ErrResponseCouponUsageLimitReached)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
 
-//TODO: func TestUpdateOrder(t *testing.T) {}
+func TestCheckout(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+
+	t.Run("checks out an order without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"order_id":"%s","purchaser_id":"00000000-0000-0000-0000-000000000000","order_status":"waiting_payment","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","subtotal":100,"discount":0,"tax_region":"BR-SP","tax":18,"shipping":10,"total_price":128,"order_items":[]}`+"\n", orderID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/checkout", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().Checkout(gomock.Any(), orderID).Return(book.Order{
+			OrderID:     orderID,
+			OrderStatus: "waiting_payment",
+			Subtotal:    100,
+			TaxRegion:   "BR-SP",
+			Tax:         18,
+			Shipping:    10,
+			TotalPrice:  128,
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	taxRegionErrors := []struct {
+		name                 string
+		err                  error
+		expectedJSONresponse string
+	}{
+		{"shipping address required", book.ErrResponseShippingAddressRequired, `{"error_code":183,"error_message":"the order must have a shipping address, set at /orders/{id}/details, before checkout"}`},
+		{"tax region not supported", book.ErrResponseTaxRegionNotSupported, `{"error_code":184,"error_message":"the country and state of the shipping address are not a supported tax region"}`},
+	}
+	for _, tc := range taxRegionErrors {
+		t.Run("expected "+tc.name+" error", func(t *testing.T) {
+			is := is.New(t)
+
+			request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/checkout", nil)
+			response := httptest.NewRecorder()
+
+			mockAPI.EXPECT().Checkout(gomock.Any(), orderID).Return(book.Order{}, fmt.Errorf("error on call to Calculate: %w", tc.err))
+
+			server.Handler.ServeHTTP(response, authenticated(request))
+
+			body, _ := io.ReadAll(response.Result().Body)
+
+			is.True(response.Result().StatusCode == 400)
+			is.Equal(string(body), fmt.Sprintln(tc.expectedJSONresponse))
+		})
+	}
+
+	t.Run("expected invalid order ID error", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/not-an-id/checkout", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 400)
+	})
+}
+
+func TestConfirmPayment(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+
+	t.Run("confirms the payment of an order without errors", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"order_id":"%s","purchaser_id":"00000000-0000-0000-0000-000000000000","order_status":"paid","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","subtotal":100,"discount":0,"tax_region":"BR-SP","tax":18,"shipping":10,"total_price":128,"order_items":[]}`+"\n", orderID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/payment", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ConfirmPayment(gomock.Any(), orderID).Return(book.Order{
+			OrderID:     orderID,
+			OrderStatus: "paid",
+			Subtotal:    100,
+			TaxRegion:   "BR-SP",
+			Tax:         18,
+			Shipping:    10,
+			TotalPrice:  128,
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected order not waiting payment error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":185,"error_message":"only checked out orders waiting payment can have their payment confirmed"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/payment", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ConfirmPayment(gomock.Any(), orderID).Return(book.Order{}, book.ErrResponseOrderNotWaitingPayment)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected method not allowed error", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/orders/"+orderID.String()+"/payment", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 405)
+	})
+}
+
+func TestOrderDetails(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+
+	t.Run("updates the details of an order", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"order_id":"%s","purchaser_id":"00000000-0000-0000-0000-000000000000","order_status":"accepting_items","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","subtotal":0,"discount":0,"tax":0,"shipping":0,"total_price":0,"order_items":[],"shipping_address":{"recipient":"Maria Silva","line1":"Rua das Flores, 100","city":"Sao Paulo","postal_code":"01000-000","country":"BR"},"contact_email":"maria@mail.com","notes":"Leave it at the front desk"}`+"\n", orderID)
+
+		request, _ := http.NewRequest(http.MethodPut, "/orders/"+orderID.String()+"/details", strings.NewReader(`{"shipping_address": {"recipient": "Maria Silva", "line1": "Rua das Flores, 100", "city": "Sao Paulo", "postal_code": "01000-000", "country": "br"}, "contact_email": " maria@mail.com ", "notes": "Leave it at the front desk"}`))
+		response := httptest.NewRecorder()
+
+		address := &book.ShippingAddress{Recipient: "Maria Silva", Line1: "Rua das Flores, 100", City: "Sao Paulo", PostalCode: "01000-000", Country: "BR"}
+		updtReq := book.UpdateOrderDetailsRequest{OrderID: orderID, ShippingAddress: address, ContactEmail: "maria@mail.com", Notes: "Leave it at the front desk"}
+		mockAPI.EXPECT().UpdateOrderDetails(gomock.Any(), updtReq).Return(book.Order{
+			OrderID:         orderID,
+			OrderStatus:     "accepting_items",
+			ShippingAddress: address,
+			ContactEmail:    "maria@mail.com",
+			Notes:           "Leave it at the front desk",
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	testCases := []struct {
+		name         string
+		entry        string
+		expectedCode int
+	}{
+		{
+			name:         "expected shipping address error with no country",
+			entry:        `{"shipping_address": {"recipient": "Maria Silva", "line1": "Rua das Flores, 100", "city": "Sao Paulo", "postal_code": "01000-000"}}`,
+			expectedCode: 149,
+		},
+		{
+			name:         "expected contact email error",
+			entry:        `{"contact_email": "Maria <maria@mail.com>"}`,
+			expectedCode: 150,
+		},
+		{
+			name:         "expected contact phone error",
+			entry:        `{"contact_phone": "call me"}`,
+			expectedCode: 151,
+		},
+		{
+			name:         "expected notes too long error",
+			entry:        fmt.Sprintf(`{"notes": "%s"}`, strings.Repeat("a", 501)),
+			expectedCode: 152,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			request, _ := http.NewRequest(http.MethodPut, "/orders/"+orderID.String()+"/details", strings.NewReader(tc.entry))
+			response := httptest.NewRecorder()
+
+			server.Handler.ServeHTTP(response, authenticated(request))
+
+			var errR book.ErrResponse
+			err := json.NewDecoder(response.Result().Body).Decode(&errR)
+			is.NoErr(err)
+
+			is.True(response.Result().StatusCode == 400)
+			is.Equal(errR.Code, tc.expectedCode)
+		})
+	}
+
+	t.Run("expected order not accepting items error after checkout", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPut, "/orders/"+orderID.String()+"/details", strings.NewReader(`{"notes": "Gift wrap, please"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().UpdateOrderDetails(gomock.Any(), gomock.Any()).Return(book.Order{}, book.ErrResponseOrderNotAcceptingItems)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 400)
+	})
+}
+
+func TestOrderHistory(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+
+	t.Run("lists the status changes of an order", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`[{"to_status":"accepting_items","changed_by":"anonymous","changed_at":"2024-05-01T12:00:00Z"},{"from_status":"accepting_items","to_status":"waiting_payment","changed_by":"anonymous","changed_at":"2024-05-01T13:00:00Z"}]`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/orders/"+orderID.String()+"/history", nil)
+		response := httptest.NewRecorder()
+
+		createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		mockAPI.EXPECT().ListOrderHistory(gomock.Any(), orderID).Return([]book.StatusChange{
+			{OrderID: orderID, ToStatus: "accepting_items", ChangedBy: book.ActorAnonymous, ChangedAt: createdAt},
+			{OrderID: orderID, FromStatus: "accepting_items", ToStatus: "waiting_payment", ChangedBy: book.ActorAnonymous, ChangedAt: createdAt.Add(time.Hour)},
+		}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected order not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/orders/"+orderID.String()+"/history", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListOrderHistory(gomock.Any(), orderID).Return(nil, book.ErrResponseOrderNotFound)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 404)
+	})
+}
+
+func TestWishlist(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	userID := uuid.New()
+	bookID := uuid.New()
+	orderID := uuid.New()
+	addedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	item := book.WishlistItem{
+		Book:    book.Book{ID: bookID, Name: "Wished book", Price: toPointer(float32(30)), Inventory: toPointer(5)},
+		AddedAt: addedAt,
+	}
+	itemJSON := fmt.Sprintf(`{"book":{"id":"%s","name":"Wished book","price":30,"inventory":5,"archived":false},"added_at":"2024-05-01T12:00:00Z"}`, bookID)
+
+	t.Run("adds a book to the wishlist", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users/"+userID.String()+"/wishlist", strings.NewReader(fmt.Sprintf(`{"book_id": "%s"}`, bookID)))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().AddToWishlist(gomock.Any(), userID, bookID).Return(item, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), itemJSON+"\n")
+	})
+
+	t.Run("lists the wishlist", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/users/"+userID.String()+"/wishlist", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListWishlist(gomock.Any(), userID).Return([]book.WishlistItem{item}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), "["+itemJSON+"]\n")
+	})
+
+	t.Run("moves a book to an order, one unit by default", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users/"+userID.String()+"/wishlist/"+bookID.String()+"/move", strings.NewReader(fmt.Sprintf(`{"order_id": "%s"}`, orderID)))
+		response := httptest.NewRecorder()
+
+		moveReq := book.MoveWishlistItemRequest{UserID: userID, BookID: bookID, OrderID: orderID, BookUnits: 1}
+		mockAPI.EXPECT().MoveWishlistItemToOrder(gomock.Any(), moveReq).Return(book.Order{OrderID: orderID, PurchaserID: userID}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("expected book not at wishlist error removing a book", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":141,"error_message":"book is not at the wishlist"}`)
+
+		request, _ := http.NewRequest(http.MethodDelete, "/users/"+userID.String()+"/wishlist/"+bookID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().RemoveFromWishlist(gomock.Any(), userID, bookID).Return(book.ErrResponseBookNotAtWishlist)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected invalid user ID error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":139,"error_message":"the endpoint is not a valid format ID. Must be /users/{uuid}"}`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/users/not-an-id/wishlist", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+func TestReturns(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+	bookID := uuid.New()
+	returnID := uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	requested := book.Return{
+		ReturnID:  returnID,
+		OrderID:   orderID,
+		Status:    book.ReturnStatusRequested,
+		Reason:    "damaged",
+		Items:     []book.ReturnItem{{BookID: bookID, BookUnits: 1}},
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+
+	t.Run("requests a return", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"return_id":"%s","order_id":"%s","status":"requested","reason":"damaged","items":[{"book_id":"%s","book_units":1}],"refund_amount":0,"created_at":"2024-05-01T12:00:00Z"}`+"\n", returnID, orderID, bookID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/returns", strings.NewReader(fmt.Sprintf(`{"reason": "damaged", "items": [{"book_id": "%s", "book_units": 1}]}`, bookID)))
+		response := httptest.NewRecorder()
+
+		createReq := book.CreateReturnRequest{OrderID: orderID, Reason: "damaged", Items: []book.ReturnItem{{BookID: bookID, BookUnits: 1}}}
+		mockAPI.EXPECT().RequestReturn(gomock.Any(), createReq).Return(requested, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected blank fields error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":143,"error_message":"fields reason and items - each with book_id and book_units greater than zero - must be filled correctly."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/returns", strings.NewReader(fmt.Sprintf(`{"reason": "damaged", "items": [{"book_id": "%s", "book_units": 0}]}`, bookID)))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected order not returnable error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":144,"error_message":"only paid orders can have books returned"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/returns", strings.NewReader(fmt.Sprintf(`{"reason": "damaged", "items": [{"book_id": "%s", "book_units": 1}]}`, bookID)))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().RequestReturn(gomock.Any(), gomock.Any()).Return(book.Return{}, book.ErrResponseOrderNotReturnable)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("lists the returns of an order", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/orders/"+orderID.String()+"/returns", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListReturns(gomock.Any(), orderID).Return([]book.Return{requested}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("approves a return", func(t *testing.T) {
+		is := is.New(t)
+
+		resolvedAt := createdAt.Add(time.Hour)
+		approved := requested
+		approved.Status = book.ReturnStatusApproved
+		approved.RefundAmount = 30
+		approved.RefundID = "manual_" + returnID.String()
+		approved.ResolvedAt = &resolvedAt
+
+		expectedJSONresponse := fmt.Sprintf(`{"return_id":"%s","order_id":"%s","status":"approved","reason":"damaged","items":[{"book_id":"%s","book_units":1}],"refund_amount":30,"refund_id":"manual_%s","created_at":"2024-05-01T12:00:00Z","resolved_at":"2024-05-01T13:00:00Z"}`+"\n", returnID, orderID, bookID, returnID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/returns/"+returnID.String()+"/approve", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ApproveReturn(gomock.Any(), returnID).Return(approved, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected return not pending error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":147,"error_message":"return was already approved or rejected"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/returns/"+returnID.String()+"/reject", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().RejectReturn(gomock.Any(), returnID).Return(book.Return{}, book.ErrResponseReturnNotPending)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected invalid return ID error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":148,"error_message":"the endpoint is not a valid format ID. Must be /returns/{uuid}"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/returns/not-an-id/approve", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+//TODO: func TestCreateOrder(t *testing.T) {}
+
+func TestShipments(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.New()
+	bookID := uuid.New()
+	shipmentID := uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	picked := book.Shipment{
+		ShipmentID: shipmentID,
+		OrderID:    orderID,
+		Status:     book.ShipmentStatusPicked,
+		Items:      []book.ShipmentItem{{BookID: bookID, BookUnits: 1}},
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+
+	t.Run("picks a shipment", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"shipment_id":"%s","order_id":"%s","status":"picked","items":[{"book_id":"%s","book_units":1}],"created_at":"2024-05-01T12:00:00Z"}`+"\n", shipmentID, orderID, bookID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/shipments", strings.NewReader(fmt.Sprintf(`{"items": [{"book_id": "%s", "book_units": 1}]}`, bookID)))
+		response := httptest.NewRecorder()
+
+		pickReq := book.PickShipmentRequest{OrderID: orderID, Items: []book.ShipmentItem{{BookID: bookID, BookUnits: 1}}}
+		mockAPI.EXPECT().PickShipment(gomock.Any(), pickReq).Return(picked, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected blank fields error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":153,"error_message":"field items - each with book_id and book_units greater than zero - must be filled correctly."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/shipments", strings.NewReader(`{"items": []}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected order not fulfillable error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":154,"error_message":"only paid orders, with no returns asked, can have books picked and shipped"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/shipments", strings.NewReader(fmt.Sprintf(`{"items": [{"book_id": "%s", "book_units": 1}]}`, bookID)))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().PickShipment(gomock.Any(), gomock.Any()).Return(book.Shipment{}, book.ErrResponseOrderNotFulfillable)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("lists the shipments of an order", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/orders/"+orderID.String()+"/shipments", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListShipments(gomock.Any(), orderID).Return([]book.Shipment{picked}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("ships a shipment", func(t *testing.T) {
+		is := is.New(t)
+
+		shippedAt := createdAt.Add(time.Hour)
+		shipped := picked
+		shipped.Status = book.ShipmentStatusShipped
+		shipped.Carrier = "Correios"
+		shipped.TrackingNumber = "BR123456789"
+		shipped.ShippedAt = &shippedAt
+
+		expectedJSONresponse := fmt.Sprintf(`{"shipment_id":"%s","order_id":"%s","status":"shipped","items":[{"book_id":"%s","book_units":1}],"carrier":"Correios","tracking_number":"BR123456789","created_at":"2024-05-01T12:00:00Z","shipped_at":"2024-05-01T13:00:00Z"}`+"\n", shipmentID, orderID, bookID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/shipments/"+shipmentID.String()+"/ship", strings.NewReader(`{"carrier": " Correios ", "tracking_number": "BR123456789"}`))
+		response := httptest.NewRecorder()
+
+		shipReq := book.ShipShipmentRequest{ShipmentID: shipmentID, Carrier: "Correios", TrackingNumber: "BR123456789"}
+		mockAPI.EXPECT().ShipShipment(gomock.Any(), shipReq).Return(shipped, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected ship blank fields error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":158,"error_message":"fields carrier and tracking_number must be filled correctly."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/shipments/"+shipmentID.String()+"/ship", strings.NewReader(`{"carrier": "Correios"}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected shipment step invalid error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":157,"error_message":"a shipment must be picked to be shipped, and shipped to be delivered"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/shipments/"+shipmentID.String()+"/deliver", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().DeliverShipment(gomock.Any(), shipmentID).Return(book.Shipment{}, book.ErrResponseShipmentStepInvalid)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected shipment not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":156,"error_message":"shipment not found"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/shipments/"+shipmentID.String()+"/deliver", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().DeliverShipment(gomock.Any(), shipmentID).Return(book.Shipment{}, book.ErrResponseShipmentNotFound)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected invalid shipment id error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":159,"error_message":"the endpoint is not a valid format ID. Must be /shipments/{uuid}"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/shipments/123/deliver", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+func TestUsers(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	userID := uuid.New()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	someUser := book.User{
+		UserID:    userID,
+		Name:      "Maria Silva",
+		Role:      book.UserRoleUser,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+
+	t.Run("creates a user with the default role", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintf(`{"user_id":"%s","name":"Maria Silva","role":"user","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}`+"\n", userID)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": " Maria Silva "}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().CreateUser(gomock.Any(), book.CreateUserRequest{Name: "Maria Silva", Role: book.UserRoleUser}).Return(someUser, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected blank fields error for an unknown role", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":160,"error_message":"fields name and role - 'user' or 'admin' - must be filled correctly."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "Maria Silva", "role": "owner"}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("lists the users", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/users", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListUsers(gomock.Any()).Return([]book.User{someUser}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("updates a user", func(t *testing.T) {
+		is := is.New(t)
+
+		admin := someUser
+		admin.Role = book.UserRoleAdmin
+
+		request, _ := http.NewRequest(http.MethodPut, "/users/"+userID.String(), strings.NewReader(`{"name": "Maria Silva", "role": "admin"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().UpdateUser(gomock.Any(), book.UpdateUserRequest{UserID: userID, Name: "Maria Silva", Role: book.UserRoleAdmin}).Return(admin, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.True(strings.Contains(string(body), `"role":"admin"`))
+	})
+
+	t.Run("expected user not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":161,"error_message":"user not found"}`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/users/"+userID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().GetUser(gomock.Any(), userID).Return(book.User{}, book.ErrResponseUserNotFound)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected user has orders error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":162,"error_message":"users with orders can't be deleted"}`)
+
+		request, _ := http.NewRequest(http.MethodDelete, "/users/"+userID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().DeleteUser(gomock.Any(), userID).Return(book.ErrResponseUserHasOrders)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected user not found error when ordering", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(fmt.Sprintf(`{"user_id": "%s"}`, userID)))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().CreateOrder(gomock.Any(), userID).Return(book.Order{}, book.ErrResponseUserNotFound)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 404)
+	})
+}
+
+func TestAuth(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	unauthorizedJSONresponse := fmt.Sprintln(`{"error_code":163,"error_message":"a valid access token must be sent at the header 'Authorization: Bearer {token}'"}`)
+
+	t.Run("expected access token invalid error with no token at a protected route", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"name": "Book", "price": 10, "inventory": 1}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 401)
+		is.Equal(response.Result().Header.Get("WWW-Authenticate"), "Bearer")
+		is.Equal(string(body), unauthorizedJSONresponse)
+	})
+
+	t.Run("expected access token invalid error with an expired token", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/coupons", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, withToken(request, testAdmin, time.Now().Add(-time.Minute)))
+
+		is.True(response.Result().StatusCode == 401)
+	})
+
+	t.Run("expected access token invalid error with a token signed by another key", func(t *testing.T) {
+		is := is.New(t)
+
+		claims := testAdmin
+		claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+		token, err := book.SignAccessToken([]byte("another-signing-key-with-32-bytes!!"), claims)
+		is.NoErr(err)
+
+		request, _ := http.NewRequest(http.MethodGet, "/coupons", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 401)
+	})
+
+	t.Run("browses the books with no token", func(t *testing.T) {
+		is := is.New(t)
+
+		bookID := uuid.New()
+		request, _ := http.NewRequest(http.MethodGet, "/books/"+bookID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().GetBook(gomock.Any(), bookID).Return(book.Book{ID: bookID, Name: "Book", Price: toPointer(float32(10)), Inventory: toPointer(1)}, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("puts the user of the token at the request context", func(t *testing.T) {
+		is := is.New(t)
+
+		userID := uuid.New()
+		request, _ := http.NewRequest(http.MethodGet, "/users/"+userID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().GetUser(gomock.Any(), userID).DoAndReturn(func(ctx context.Context, id uuid.UUID) (book.User, error) {
+			claims, ok := book.ClaimsFromContext(ctx)
+			is.True(ok)
+			is.Equal(claims.UserID, userID)
+			is.Equal(claims.Role, book.UserRoleUser)
+			return book.User{UserID: id, Name: "Maria Silva", Role: book.UserRoleUser}, nil
+		})
+
+		server.Handler.ServeHTTP(response, withToken(request, book.Claims{UserID: userID, Role: book.UserRoleUser}, time.Now().Add(time.Hour)))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("logs in", func(t *testing.T) {
+		is := is.New(t)
+
+		expiresAt := time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC)
+		refreshExpiresAt := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+		expectedJSONresponse := fmt.Sprintln(`{"access_token":"access","token_type":"Bearer","expires_at":"2024-05-01T12:15:00Z","refresh_token":"refresh","refresh_token_expires_at":"2024-05-31T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": " Maria@Mail.com ", "password": "secret-password"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().Login(gomock.Any(), "maria@mail.com", "secret-password").Return(book.AuthTokens{
+			AccessToken:           "access",
+			AccessTokenExpiresAt:  expiresAt,
+			RefreshToken:          "refresh",
+			RefreshTokenExpiresAt: refreshExpiresAt,
+		}, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected credentials invalid error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":164,"error_message":"email or password is incorrect"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "maria@mail.com", "password": "wrong-password"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().Login(gomock.Any(), "maria@mail.com", "wrong-password").Return(book.AuthTokens{}, book.ErrResponseCredentialsInvalid)
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 401)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected refresh token invalid error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":165,"error_message":"refresh token is invalid, expired or already used"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token": "used"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().RefreshTokens(gomock.Any(), "used").Return(book.AuthTokens{}, book.ErrResponseRefreshTokenInvalid)
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 401)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("logs out", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/logout", strings.NewReader(`{"refresh_token": "refresh"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().Logout(gomock.Any(), "refresh").Return(nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 204)
+	})
+
+	t.Run("signs up with no token", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "Maria Silva", "email": "maria@mail.com", "password": "secret-password"}`))
+		response := httptest.NewRecorder()
+
+		createReq := book.CreateUserRequest{Name: "Maria Silva", Role: book.UserRoleUser, Email: "maria@mail.com", Password: "secret-password"}
+		mockAPI.EXPECT().CreateUser(gomock.Any(), createReq).Return(book.User{UserID: uuid.New(), Name: "Maria Silva", Role: book.UserRoleUser, Email: "maria@mail.com"}, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 201)
+	})
+
+	t.Run("expected access token invalid error when signing up as admin with no token", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "Maria Silva", "role": "admin", "email": "maria@mail.com", "password": "secret-password"}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 401)
+		is.Equal(string(body), unauthorizedJSONresponse)
+	})
+
+	t.Run("expected password invalid error for a short password", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":169,"error_message":"field password, when filled, must have from 8 to 72 characters, and needs an email to log in with."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "Maria Silva", "email": "maria@mail.com", "password": "short"}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+func TestAuthorization(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	testUser := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+	forbiddenJSONresponse := fmt.Sprintln(`{"error_code":171,"error_message":"the role of the user does not allow this action"}`)
+
+	forbidden := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"creating a book", http.MethodPost, "/books", `{"name": "Book", "price": 10, "inventory": 1}`},
+		{"updating a book", http.MethodPut, "/books/" + uuid.NewString(), `{"name": "Book", "price": 10, "inventory": 1}`},
+		{"archiving a book", http.MethodDelete, "/books/" + uuid.NewString(), ""},
+		{"listing the coupons", http.MethodGet, "/coupons", ""},
+		{"approving a return", http.MethodPost, "/returns/" + uuid.NewString() + "/approve", ""},
+		{"shipping a shipment", http.MethodPost, "/shipments/" + uuid.NewString() + "/ship", `{"carrier": "UPS", "tracking_number": "1Z999"}`},
+		{"confirming the payment of its order", http.MethodPost, "/orders/" + uuid.NewString() + "/payment", ""},
+		{"listing the users", http.MethodGet, "/users", ""},
+		{"seeing another user", http.MethodGet, "/users/" + uuid.NewString(), ""},
+		{"creating an order for another user", http.MethodPost, "/order", `{"user_id": "` + uuid.NewString() + `"}`},
+		{"signing up as admin", http.MethodPost, "/users", `{"name": "Maria Silva", "role": "admin"}`},
+		{"promoting itself to admin", http.MethodPut, "/users/" + testUser.UserID.String(), `{"name": "Maria Silva", "role": "admin"}`},
+		{"subscribing a webhook", http.MethodPost, "/webhooks", `{"url": "https://partner.example.com/hooks", "event_types": ["order_updated"]}`},
+	}
+	for _, tc := range forbidden {
+		t.Run("expected forbidden error for a user "+tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			request, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			response := httptest.NewRecorder()
+
+			server.Handler.ServeHTTP(response, withToken(request, testUser, time.Now().Add(time.Hour)))
+
+			body, _ := io.ReadAll(response.Result().Body)
+
+			is.True(response.Result().StatusCode == 403)
+			is.Equal(string(body), forbiddenJSONresponse)
+		})
+	}
+
+	t.Run("creates an order for the user itself", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(`{"user_id": "`+testUser.UserID.String()+`"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().CreateOrder(gomock.Any(), testUser.UserID).Return(book.Order{OrderID: uuid.New(), PurchaserID: testUser.UserID, OrderStatus: "accepting_items"}, nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, testUser, time.Now().Add(time.Hour)))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("updates the user itself", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPut, "/users/"+testUser.UserID.String(), strings.NewReader(`{"name": "Maria Souza", "role": "user"}`))
+		response := httptest.NewRecorder()
+
+		updateReq := book.UpdateUserRequest{UserID: testUser.UserID, Name: "Maria Souza", Role: book.UserRoleUser}
+		mockAPI.EXPECT().UpdateUser(gomock.Any(), updateReq).Return(book.User{UserID: testUser.UserID, Name: "Maria Souza", Role: book.UserRoleUser}, nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, testUser, time.Now().Add(time.Hour)))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("lists the history of an order", func(t *testing.T) {
+		is := is.New(t)
+
+		orderID := uuid.New()
+		request, _ := http.NewRequest(http.MethodGet, "/orders/"+orderID.String()+"/history", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListOrderHistory(gomock.Any(), orderID).Return([]book.StatusChange{}, nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, testUser, time.Now().Add(time.Hour)))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("admins give the admin role", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "Maria Silva", "role": "admin"}`))
+		response := httptest.NewRecorder()
+
+		createReq := book.CreateUserRequest{Name: "Maria Silva", Role: book.UserRoleAdmin}
+		mockAPI.EXPECT().CreateUser(gomock.Any(), createReq).Return(book.User{UserID: uuid.New(), Name: "Maria Silva", Role: book.UserRoleAdmin}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 201)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	keyID := uuid.MustParse("0b7c9e2a-4f3d-4a8e-9c1b-5d6e7f8a9b0c")
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	storedKey := book.APIKey{
+		KeyID:     keyID,
+		UserID:    testAdmin.UserID,
+		Name:      "warehouse",
+		Prefix:    "0a1b2c3d4e5f",
+		KeyHash:   "hash",
+		Scopes:    []book.Permission{book.PermissionOrdersRead, book.PermissionFulfillmentWrite},
+		CreatedAt: createdAt,
+	}
+	keyClaims := book.Claims{UserID: testAdmin.UserID, Role: book.UserRoleAdmin, APIKeyID: keyID, Scopes: storedKey.Scopes}
+
+	t.Run("issues a key for the calling admin", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"key_id":"0b7c9e2a-4f3d-4a8e-9c1b-5d6e7f8a9b0c","user_id":"6f1f5d3c-2b8e-4c3a-9d51-7a0e2f6b9c41","name":"warehouse","prefix":"0a1b2c3d4e5f","scopes":["orders:read","fulfillment:write"],"key":"bks_0a1b2c3d4e5f.secret","created_at":"2024-05-01T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(`{"name": " warehouse ", "scopes": ["orders:read", "fulfillment:write"]}`))
+		response := httptest.NewRecorder()
+
+		createReq := book.CreateAPIKeyRequest{UserID: testAdmin.UserID, Name: "warehouse", Scopes: storedKey.Scopes}
+		mockAPI.EXPECT().CreateAPIKey(gomock.Any(), createReq).Return(storedKey, "bks_0a1b2c3d4e5f.secret", nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected api key entry blank fields error for an unknown scope", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":173,"error_message":"fields name and scopes - each one of books:write, coupons:read, coupons:write, orders:read, orders:write, fulfillment:write, payments:write, returns:review, users:read or users:write - must be filled correctly."}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(`{"name": "warehouse", "scopes": ["apikeys:write"]}`))
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("revokes a key", func(t *testing.T) {
+		is := is.New(t)
+
+		revokedAt := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+		revoked := storedKey
+		revoked.RevokedAt = &revokedAt
+		expectedJSONresponse := fmt.Sprintln(`{"key_id":"0b7c9e2a-4f3d-4a8e-9c1b-5d6e7f8a9b0c","user_id":"6f1f5d3c-2b8e-4c3a-9d51-7a0e2f6b9c41","name":"warehouse","prefix":"0a1b2c3d4e5f","scopes":["orders:read","fulfillment:write"],"created_at":"2024-05-01T12:00:00Z","revoked_at":"2024-05-02T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodDelete, "/apikeys/"+keyID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().RevokeAPIKey(gomock.Any(), keyID).Return(revoked, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("acts with a key within its scopes", func(t *testing.T) {
+		is := is.New(t)
+
+		shipmentID := uuid.New()
+		request, _ := http.NewRequest(http.MethodPost, "/shipments/"+shipmentID.String()+"/deliver", nil)
+		request.Header.Set("Authorization", "ApiKey bks_0a1b2c3d4e5f.secret")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().AuthenticateAPIKey(gomock.Any(), "bks_0a1b2c3d4e5f.secret").Return(keyClaims, nil)
+		mockAPI.EXPECT().DeliverShipment(gomock.Any(), shipmentID).Return(book.Shipment{ShipmentID: shipmentID, Status: "delivered"}, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("expected forbidden error for a key out of its scopes", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"name": "Book", "price": 10, "inventory": 1}`))
+		request.Header.Set("Authorization", "ApiKey bks_0a1b2c3d4e5f.secret")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().AuthenticateAPIKey(gomock.Any(), "bks_0a1b2c3d4e5f.secret").Return(keyClaims, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 403)
+	})
+
+	t.Run("expected forbidden error for a key issuing keys", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(`{"name": "another", "scopes": ["books:write"]}`))
+		request.Header.Set("Authorization", "ApiKey bks_0a1b2c3d4e5f.secret")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().AuthenticateAPIKey(gomock.Any(), "bks_0a1b2c3d4e5f.secret").Return(keyClaims, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 403)
+	})
+
+	t.Run("expected api key invalid error for an unknown key", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":172,"error_message":"a valid API key must be sent at the header 'Authorization: ApiKey {key}'"}`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/books", nil)
+		request.Header.Set("Authorization", "ApiKey bks_unknown.secret")
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().AuthenticateAPIKey(gomock.Any(), "bks_unknown.secret").Return(book.Claims{}, book.ErrResponseAPIKeyInvalid)
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 401)
+		is.Equal(response.Result().Header.Get("WWW-Authenticate"), "ApiKey")
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	webhookID := uuid.MustParse("3c2b1a09-8f7e-4d6c-9b5a-4e3d2c1b0a9f")
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	storedWebhook := book.Webhook{
+		WebhookID:  webhookID,
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []book.EventType{book.EventOrderUpdated, book.EventShipmentUpdated},
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+
+	t.Run("subscribes an endpoint, showing its secret only once", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"webhook_id":"3c2b1a09-8f7e-4d6c-9b5a-4e3d2c1b0a9f","url":"https://partner.example.com/hooks","event_types":["order_updated","shipment_updated"],"secret":"whsec_generated","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"url": " https://partner.example.com/hooks ", "event_types": ["order_updated", "shipment_updated"]}`))
+		response := httptest.NewRecorder()
+
+		created := storedWebhook
+		created.Secret = "whsec_generated"
+		createReq := book.CreateWebhookRequest{URL: storedWebhook.URL, EventTypes: storedWebhook.EventTypes}
+		mockAPI.EXPECT().CreateWebhook(gomock.Any(), createReq).Return(created, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 201)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	invalidEntries := []struct {
+		name string
+		body string
+	}{
+		{"a relative url", `{"url": "/hooks", "event_types": ["order_updated"]}`},
+		{"an url that is not http", `{"url": "ftp://partner.example.com/hooks", "event_types": ["order_updated"]}`},
+		{"no event types", `{"url": "https://partner.example.com/hooks", "event_types": []}`},
+		{"an unknown event type", `{"url": "https://partner.example.com/hooks", "event_types": ["order_teleported"]}`},
+		{"a short secret", `{"url": "https://partner.example.com/hooks", "event_types": ["order_updated"], "secret": "short"}`},
+	}
+	for _, tc := range invalidEntries {
+		t.Run("expected webhook entry blank fields error for "+tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			expectedJSONresponse := fmt.Sprintln(`{"error_code":180,"error_message":"fields url - an absolute http or https URL - and event_types - each one a known event type - must be filled correctly. Field secret, when filled, must have at least 16 characters."}`)
+
+			request, _ := http.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(tc.body))
+			response := httptest.NewRecorder()
+
+			server.Handler.ServeHTTP(response, authenticated(request))
+
+			body, _ := io.ReadAll(response.Result().Body)
+
+			is.True(response.Result().StatusCode == 400)
+			is.Equal(string(body), expectedJSONresponse)
+		})
+	}
+
+	t.Run("updates a webhook, keeping its secret when none is sent", func(t *testing.T) {
+		is := is.New(t)
+
+		updatedAt := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+		updated := storedWebhook
+		updated.URL = "https://partner.example.com/v2/hooks"
+		updated.UpdatedAt = updatedAt
+		expectedJSONresponse := fmt.Sprintln(`{"webhook_id":"3c2b1a09-8f7e-4d6c-9b5a-4e3d2c1b0a9f","url":"https://partner.example.com/v2/hooks","event_types":["order_updated","shipment_updated"],"created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-02T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodPut, "/webhooks/"+webhookID.String(), strings.NewReader(`{"url": "https://partner.example.com/v2/hooks", "event_types": ["order_updated", "shipment_updated"]}`))
+		response := httptest.NewRecorder()
+
+		updateReq := book.UpdateWebhookRequest{WebhookID: webhookID, URL: updated.URL, EventTypes: storedWebhook.EventTypes}
+		mockAPI.EXPECT().UpdateWebhook(gomock.Any(), updateReq).Return(updated, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("deletes a webhook", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodDelete, "/webhooks/"+webhookID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().DeleteWebhook(gomock.Any(), webhookID).Return(nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 204)
+	})
+
+	t.Run("lists the delivery log of a webhook", func(t *testing.T) {
+		is := is.New(t)
+
+		deliveryID := uuid.MustParse("9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b")
+		eventID := uuid.MustParse("1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9")
+		statusCode := 503
+		deliveries := []book.WebhookDelivery{{
+			DeliveryID:     deliveryID,
+			WebhookID:      webhookID,
+			EventID:        eventID,
+			EventType:      book.EventOrderUpdated,
+			Payload:        []byte(`{}`),
+			Status:         book.WebhookDeliveryPending,
+			Attempts:       1,
+			NextAttemptAt:  createdAt.Add(time.Minute),
+			LastStatusCode: &statusCode,
+			LastError:      "webhook wrong response - want: 2xx, got: 503",
+			CreatedAt:      createdAt,
+		}}
+		expectedJSONresponse := fmt.Sprintln(`[{"delivery_id":"9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b","event_id":"1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9","event_type":"order_updated","status":"pending","attempts":1,"next_attempt_at":"2024-05-01T12:01:00Z","last_status_code":503,"last_error":"webhook wrong response - want: 2xx, got: 503","created_at":"2024-05-01T12:00:00Z"}]`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/webhooks/"+webhookID.String()+"/deliveries", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListWebhookDeliveries(gomock.Any(), webhookID).Return(deliveries, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected webhook not found error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":179,"error_message":"webhook not found"}`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/webhooks/"+webhookID.String(), nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().GetWebhook(gomock.Any(), webhookID).Return(book.Webhook{}, book.ErrResponseWebhookNotFound)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected webhook id invalid format error", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":181,"error_message":"the endpoint is not a valid format ID. Must be /webhooks/{uuid}"}`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/webhooks/not-an-id/deliveries", nil)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 400)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+}
+
+func TestOrderOwnership(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockRepo := bookmock.NewMockRepository(ctrl)
+	mockNtfy := bookmock.NewMockNotifier(ctrl)
+	mockCalc := bookmock.NewMockPriceCalculator(ctrl)
+	mockPay := bookmock.NewMockPaymentGateway(ctrl)
+	mockHooks := bookmock.NewMockWebhookSender(ctrl)
+	bookService := book.NewService(mockRepo, mockNtfy, mockCalc, mockPay, mockHooks, time.Second, book.TxConfig{}, book.AuthConfig{SigningKey: signingKey})
+	bookHandler := bookhttp.NewBookHandler(bookService, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	purchaser := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+	stranger := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+	order := book.Order{OrderID: uuid.New(), PurchaserID: purchaser.UserID, OrderStatus: "accepting_items"}
+	notFoundJSONresponse := fmt.Sprintln(`{"error_code":110,"error_message":"order not found"}`)
+
+	t.Run("lists the items of an order to its purchaser", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+order.OrderID.String()+`"}`))
+		response := httptest.NewRecorder()
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, purchaser, time.Now().Add(time.Hour)))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("lists the items of any order to admins", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+order.OrderID.String()+`"}`))
+		response := httptest.NewRecorder()
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("expected order not found error listing the items of an order of another user", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+order.OrderID.String()+`"}`))
+		response := httptest.NewRecorder()
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, stranger, time.Now().Add(time.Hour)))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), notFoundJSONresponse)
+	})
+
+	t.Run("expected order not found error updating an order of another user", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPut, "/order", strings.NewReader(`{"order_id": "`+order.OrderID.String()+`", "book_id": "`+uuid.NewString()+`", "book_units_to_add": 1}`))
+		response := httptest.NewRecorder()
+
+		mockTxRepo := bookmock.NewMockRepository(ctrl)
+		mockTx := bookmock.NewMockTx(ctrl)
+		mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+		mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+		mockTx.EXPECT().Rollback().Return(nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, stranger, time.Now().Add(time.Hour)))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), notFoundJSONresponse)
+	})
+
+	t.Run("expected order not found error for an order that doesn't exist, just like for others' orders", func(t *testing.T) {
+		is := is.New(t)
+
+		missingID := uuid.New()
+		request, _ := http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+missingID.String()+`"}`))
+		response := httptest.NewRecorder()
+
+		mockRepo.EXPECT().ListOrderItems(gomock.Any(), missingID).Return(book.Order{}, book.ErrResponseOrderNotFound)
+
+		server.Handler.ServeHTTP(response, withToken(request, stranger, time.Now().Add(time.Hour)))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 404)
+		is.Equal(string(body), notFoundJSONresponse)
+	})
+
+	actionsOnOrder := []struct {
+		name   string
+		method string
+		action string
+		body   string
+		inTx   bool //the order is searched inside the transaction that would change it
+	}{
+		{"checking out", http.MethodPost, "checkout", "", true},
+		{"updating the details of", http.MethodPut, "details", `{"notes": "leave it at the door"}`, true},
+		{"applying a coupon to", http.MethodPost, "coupon", `{"code": "TENOFF"}`, true},
+		{"asking a return of", http.MethodPost, "returns", `{"reason": "damaged", "items": [{"book_id": "` + uuid.NewString() + `", "book_units": 1}]}`, true},
+		{"listing the returns of", http.MethodGet, "returns", "", false},
+		{"listing the history of", http.MethodGet, "history", "", false},
+		{"listing the shipments of", http.MethodGet, "shipments", "", false},
+	}
+	for _, tc := range actionsOnOrder {
+		t.Run("expected order not found error "+tc.name+" an order of another user", func(t *testing.T) {
+			is := is.New(t)
+
+			request, _ := http.NewRequest(tc.method, "/orders/"+order.OrderID.String()+"/"+tc.action, strings.NewReader(tc.body))
+			response := httptest.NewRecorder()
+
+			if tc.inTx {
+				mockTxRepo := bookmock.NewMockRepository(ctrl)
+				mockTx := bookmock.NewMockTx(ctrl)
+				mockRepo.EXPECT().BeginTx(gomock.Any(), nil).Return(mockTxRepo, mockTx, nil)
+				mockTxRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+				mockTx.EXPECT().Rollback().Return(nil)
+			} else {
+				mockRepo.EXPECT().ListOrderItems(gomock.Any(), order.OrderID).Return(order, nil)
+			}
+
+			server.Handler.ServeHTTP(response, withToken(request, stranger, time.Now().Add(time.Hour)))
+
+			body, _ := io.ReadAll(response.Result().Body)
+
+			is.True(response.Result().StatusCode == 404)
+			is.Equal(string(body), notFoundJSONresponse)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{
+		Port:       8080,
+		SigningKey: signingKey,
+		RateLimit: bookhttp.RateLimitConfig{
+			ReadsPerMinute:  1,
+			WritesPerMinute: 1,
+			TrustedProxies:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		},
+	}, bookHandler)
+
+	rateLimitedJSONresponse := fmt.Sprintln(`{"error_code":176,"error_message":"too many requests, try again after the seconds at the header 'Retry-After'"}`)
+
+	/* Sends a ping from remoteAddr, through a proxy if forwardedFor is set, and returns the status code received. */
+	ping := func(remoteAddr, forwardedFor string) int {
+		request, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		request.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		return response.Result().StatusCode
+	}
+
+	t.Run("expected rate limited error once the writes of a user are spent, with reads still allowed", func(t *testing.T) {
+		is := is.New(t)
+
+		user := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+		orderID := uuid.New()
+		mockAPI.EXPECT().ListOrderItems(gomock.Any(), orderID).Return(book.Order{OrderID: orderID, PurchaserID: user.UserID}, nil)
+		mockAPI.EXPECT().Checkout(gomock.Any(), orderID).Return(book.Order{}, book.ErrResponseShippingAddressRequired)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/checkout", nil)
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, withToken(request, user, time.Now().Add(time.Hour)))
+		is.True(response.Result().StatusCode == 400) //Counted even though it was refused.
+
+		request, _ = http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/checkout", nil)
+		response = httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, withToken(request, user, time.Now().Add(time.Hour)))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 429)
+		is.Equal(response.Result().Header.Get("Retry-After"), "60")
+		is.Equal(string(body), rateLimitedJSONresponse)
+
+		request, _ = http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+orderID.String()+`"}`))
+		response = httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, withToken(request, user, time.Now().Add(time.Hour)))
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("counts the requests of each user apart", func(t *testing.T) {
+		is := is.New(t)
+
+		for i := 0; i < 2; i++ {
+			request, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, withToken(request, book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}, time.Now().Add(time.Hour)))
+			is.True(response.Result().StatusCode == 204)
+		}
+	})
+
+	t.Run("counts anonymous requests by the client IP told by a trusted proxy", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(ping("10.0.0.1:4000", "203.0.113.7, 10.0.0.2"), 204)
+		is.Equal(ping("10.0.0.1:4000", "203.0.113.8"), 204) //Another client behind the same proxy.
+		is.Equal(ping("10.0.0.3:4000", "203.0.113.7"), 429) //The first client through another proxy.
+	})
+
+	t.Run("ignores the forwarded IPs sent by untrusted clients", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(ping("198.51.100.1:4000", "203.0.113.20"), 204)
+		is.Equal(ping("198.51.100.1:4000", "203.0.113.21"), 429)
+	})
+
+	t.Run("expected rate limited error once the failed authentications of an IP are spent, before the key is checked", func(t *testing.T) {
+		is := is.New(t)
+
+		server := bookhttp.NewServer(bookhttp.ServerConfig{
+			Port:       8080,
+			SigningKey: signingKey,
+			RateLimit: bookhttp.RateLimitConfig{
+				FailedAuthsPerMinute: 2,
+				TrustedProxies:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			},
+		}, bookHandler)
+		mockAPI.EXPECT().AuthenticateAPIKey(gomock.Any(), "guessed-key").Return(book.Claims{}, book.ErrResponseAPIKeyInvalid).Times(2) //Not checked once the IP is limited.
+
+		/* Sends a request with an invalid key, or an invalid access token, from the client IP told by a trusted proxy. */
+		guess := func(clientIP, authorization string) *http.Response {
+			request, _ := http.NewRequest(http.MethodGet, "/orders/"+uuid.NewString()+"/history", nil)
+			request.RemoteAddr = "10.0.0.1:4000"
+			request.Header.Set("X-Forwarded-For", clientIP)
+			request.Header.Set("Authorization", authorization)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			return response.Result()
+		}
+
+		is.Equal(guess("203.0.113.30", "ApiKey guessed-key").StatusCode, 401)
+		is.Equal(guess("203.0.113.30", "ApiKey guessed-key").StatusCode, 401)
+
+		limited := guess("203.0.113.30", "ApiKey guessed-key")
+		body, _ := io.ReadAll(limited.Body)
+		is.Equal(limited.StatusCode, 429)
+		is.Equal(limited.Header.Get("Retry-After"), "30")
+		is.Equal(string(body), rateLimitedJSONresponse)
+
+		is.Equal(guess("203.0.113.30", "Bearer guessed-token").StatusCode, 429)
+		is.Equal(guess("203.0.113.31", "Bearer guessed-token").StatusCode, 401) //Other IPs still try.
+	})
+}
+
+func TestGuestOrders(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	orderID := uuid.MustParse("3c5e7a9b-1d2f-4e6a-8b0c-9d1e2f3a4b5c")
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	guestOrder := book.Order{OrderID: orderID, OrderStatus: "accepting_items", CreatedAt: createdAt, UpdatedAt: createdAt, Items: []book.OrderItem{}}
+	cartToken, err := book.SignCartToken(signingKey, book.CartClaims{OrderID: orderID, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("creates a guest order with no access token, returning its cart token", func(t *testing.T) {
+		is := is.New(t)
+
+		expiresAt := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+		expectedJSONresponse := fmt.Sprintln(`{"order_id":"3c5e7a9b-1d2f-4e6a-8b0c-9d1e2f3a4b5c","purchaser_id":"00000000-0000-0000-0000-000000000000","order_status":"accepting_items","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z","subtotal":0,"discount":0,"tax":0,"shipping":0,"total_price":0,"order_items":[],"cart_token":"` + cartToken + `","cart_token_expires_at":"2024-05-08T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order", strings.NewReader(`{}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().CreateGuestOrder(gomock.Any()).Return(guestOrder, book.CartToken{Token: cartToken, ExpiresAt: expiresAt}, nil)
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("lists the items of the cart to a guest with its cart token", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+orderID.String()+`"}`))
+		request.Header.Set("Authorization", "Cart "+cartToken)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ListOrderItems(gomock.Any(), orderID).DoAndReturn(func(ctx context.Context, _ uuid.UUID) (book.Order, error) {
+			claims, _ := book.ClaimsFromContext(ctx)
+			is.Equal(claims.Role, book.RoleGuest)
+			is.Equal(claims.CartOrderID, orderID) //The service checks it is the order asked.
+			return guestOrder, nil
+		})
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 200)
+	})
+
+	t.Run("expected forbidden error checking out with a cart token", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/orders/"+orderID.String()+"/checkout", nil)
+		request.Header.Set("Authorization", "Cart "+cartToken)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 403)
+	})
+
+	t.Run("expected cart token invalid error for a forged cart token", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":177,"error_message":"a valid cart token must be sent at the header 'Authorization: Cart {token}', or at the field cart_token to claim its order"}`)
+
+		forged, _ := book.SignCartToken([]byte("another-signing-key-with-32-bytes-or-more"), book.CartClaims{OrderID: orderID, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		request, _ := http.NewRequest(http.MethodGet, "/order", strings.NewReader(`{"order_id": "`+orderID.String()+`"}`))
+		request.Header.Set("Authorization", "Cart "+forged)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 401)
+		is.Equal(response.Result().Header.Get("WWW-Authenticate"), "Cart")
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("claims the guest order for the logged in user", func(t *testing.T) {
+		is := is.New(t)
+
+		user := book.Claims{UserID: uuid.New(), Role: book.UserRoleUser}
+		claimed := guestOrder
+		claimed.PurchaserID = user.UserID
+
+		request, _ := http.NewRequest(http.MethodPost, "/order/claim", strings.NewReader(`{"cart_token": "`+cartToken+`"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ClaimOrder(gomock.Any(), book.ClaimOrderRequest{CartToken: cartToken, UserID: user.UserID}).Return(claimed, nil)
+
+		server.Handler.ServeHTTP(response, withToken(request, user, time.Now().Add(time.Hour)))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.True(strings.Contains(string(body), `"purchaser_id":"`+user.UserID.String()+`"`))
+	})
+
+	t.Run("expected order already claimed error claiming an order twice", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"error_code":178,"error_message":"the order of the cart token was already claimed"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order/claim", strings.NewReader(`{"cart_token": "`+cartToken+`"}`))
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ClaimOrder(gomock.Any(), gomock.Any()).Return(book.Order{}, book.ErrResponseOrderAlreadyClaimed)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 409)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected forbidden error claiming with a cart token instead of an access token", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodPost, "/order/claim", strings.NewReader(`{"cart_token": "`+cartToken+`"}`))
+		request.Header.Set("Authorization", "Cart "+cartToken)
+		response := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(response, request)
+
+		is.True(response.Result().StatusCode == 403)
+	})
+}
+
+func TestUserPersonalData(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	mockAPI := httpmock.NewMockServiceAPI(ctrl)
+	bookHandler := bookhttp.NewBookHandler(mockAPI, time.Duration(5)*time.Second, idempotencyTTL)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: signingKey}, bookHandler)
+
+	userID := uuid.MustParse("9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d")
+	orderID := uuid.MustParse("3c5e7a9b-1d2f-4e6a-8b0c-9d1e2f3a4b5c")
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := book.User{UserID: userID, Name: "Some user", Role: book.UserRoleUser, Email: "some@user.com", CreatedAt: createdAt, UpdatedAt: createdAt}
+
+	t.Run("exports the profile and the orders of a user", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"user":{"user_id":"9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d","name":"Some user","role":"user","email":"some@user.com","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"},"orders":[{"order_id":"3c5e7a9b-1d2f-4e6a-8b0c-9d1e2f3a4b5c","purchaser_id":"9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d","order_status":"accepting_items","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z","subtotal":0,"discount":0,"tax":0,"shipping":0,"total_price":0,"order_items":[],"contact_email":"some@user.com"}],"exported_at":"2024-05-02T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodGet, "/users/"+userID.String()+"/export", nil)
+		response := httptest.NewRecorder()
+
+		export := book.UserDataExport{
+			User:       user,
+			Orders:     []book.Order{{OrderID: orderID, PurchaserID: userID, OrderStatus: "accepting_items", CreatedAt: createdAt, UpdatedAt: createdAt, ContactEmail: "some@user.com"}},
+			ExportedAt: time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC),
+		}
+		mockAPI.EXPECT().ExportUserData(gomock.Any(), userID).Return(export, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("erases the personal data of a user", func(t *testing.T) {
+		is := is.New(t)
+
+		expectedJSONresponse := fmt.Sprintln(`{"user":{"user_id":"9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d","name":"erased user","role":"user","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-02T12:00:00Z"},"orders_anonymized":1,"erased_at":"2024-05-02T12:00:00Z"}`)
+
+		request, _ := http.NewRequest(http.MethodPost, "/users/"+userID.String()+"/erasure", nil)
+		response := httptest.NewRecorder()
+
+		erasedAt := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+		erased := book.User{UserID: userID, Name: book.ErasedUserName, Role: book.UserRoleUser, CreatedAt: createdAt, UpdatedAt: erasedAt}
+		mockAPI.EXPECT().EraseUserData(gomock.Any(), userID).Return(book.UserErasure{User: erased, OrdersAnonymized: 1, ErasedAt: erasedAt}, nil)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		body, _ := io.ReadAll(response.Result().Body)
+
+		is.True(response.Result().StatusCode == 200)
+		is.Equal(string(body), expectedJSONresponse)
+	})
+
+	t.Run("expected forbidden error for users, even on their own data", func(t *testing.T) {
+		is := is.New(t)
+
+		self := book.Claims{UserID: userID, Role: book.UserRoleUser}
+
+		for _, request := range []*http.Request{
+			httptest.NewRequest(http.MethodGet, "/users/"+userID.String()+"/export", nil),
+			httptest.NewRequest(http.MethodPost, "/users/"+userID.String()+"/erasure", nil),
+		} {
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, withToken(request, self, time.Now().Add(time.Hour)))
+			is.True(response.Result().StatusCode == 403)
+		}
+	})
+
+	t.Run("expected user not found error exporting an unknown user", func(t *testing.T) {
+		is := is.New(t)
+
+		request, _ := http.NewRequest(http.MethodGet, "/users/"+userID.String()+"/export", nil)
+		response := httptest.NewRecorder()
+
+		mockAPI.EXPECT().ExportUserData(gomock.Any(), userID).Return(book.UserDataExport{}, book.ErrResponseUserNotFound)
+
+		server.Handler.ServeHTTP(response, authenticated(request))
+
+		is.True(response.Result().StatusCode == 404)
+	})
+}
@@ -6,7 +6,9 @@ import (
 )
 
 type ServerConfig struct {
-	Port int
+	Port       int
+	SigningKey []byte //HMAC key of the access tokens
+	RateLimit  RateLimitConfig
 }
 
 func NewServer(config ServerConfig, h *BookHandler) *http.Server {
@@ -15,10 +17,24 @@ func NewServer(config ServerConfig, h *BookHandler) *http.Server {
 	mux.HandleFunc("/books", h.books)
 	mux.HandleFunc("/books/", h.bookById)
 	mux.HandleFunc("/order", h.order)
+	mux.HandleFunc("/order/items", h.orderItems)
+	mux.HandleFunc("/order/claim", h.orderClaim)
+	mux.HandleFunc("/orders/", h.orderById)
+	mux.HandleFunc("/coupons", h.coupons)
+	mux.HandleFunc("/coupons/", h.couponById)
+	mux.HandleFunc("/users", h.users)
+	mux.HandleFunc("/users/", h.userById)
+	mux.HandleFunc("/returns/", h.returnById)
+	mux.HandleFunc("/shipments/", h.shipmentById)
+	mux.HandleFunc("/auth/", h.auth)
+	mux.HandleFunc("/apikeys", h.apiKeys)
+	mux.HandleFunc("/apikeys/", h.apiKeyById)
+	mux.HandleFunc("/webhooks", h.webhooks)
+	mux.HandleFunc("/webhooks/", h.webhookById)
 
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%d", config.Port),
-		Handler: mux,
+		Handler: limitFailedAuths(config.RateLimit, authenticate(config.SigningKey, h.bookService, rateLimit(config.RateLimit, mux))),
 	}
 	return &server
 }
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+const idempotencyKeyMaxLen = 255
+
+/* How long storing the outcome of a request can take, once the request itself is done. */
+const idempotencyWriteTimeout = 5 * time.Second
+
+/* Wraps a handler so a request sent with an 'Idempotency-Key' header is executed only once. The key is reserved before the request runs, so concurrent retries are answered with a conflict instead of running again; later retries receive the stored response. Keys belong to the caller that sent them. Callers with no identity can't be told apart, so their keys are ignored rather than risk replaying the response of a guest, as its cart token, to another. */
+func (h *BookHandler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		caller := idempotencyCaller(r)
+		if key == "" || caller == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseIdempotencyKeyInvalid)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Println(err)
+			responseJSON(w, http.StatusBadRequest, book.ErrResponseEntryInvalidJSON)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body)) //The body was consumed, so it is restored to the next handler.
+		hash := requestHash(r, caller, body)
+
+		err = h.bookService.ReserveIdempotencyKey(r.Context(), book.IdempotencyKey{
+			Key:         key,
+			Caller:      caller,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().UTC().Add(2 * h.requestTimeout).Round(time.Millisecond), //Freed by then if the server stops amid the request.
+		})
+		if errors.Is(err, book.ErrResponseIdempotencyKeyReused) {
+			h.replayIdempotent(w, r, caller, key, hash)
+			return
+		}
+		if err != nil {
+			handleError(err, w, r)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		//The request context may be done by now, yet the outcome must still be written.
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyWriteTimeout)
+		defer cancel()
+
+		if recorder.status >= http.StatusInternalServerError { //Server errors are not stored, so the client can retry the request.
+			err = h.bookService.ReleaseIdempotencyKey(ctx, caller, key)
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		err = h.bookService.CompleteIdempotencyKey(ctx, book.IdempotencyKey{
+			Key:            key,
+			Caller:         caller,
+			RequestHash:    hash,
+			ResponseStatus: recorder.status,
+			ResponseBody:   recorder.body.Bytes(),
+			ExpiresAt:      time.Now().UTC().Add(h.idempotencyTTL).Round(time.Millisecond),
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+/* Answers a request whose key is already held: with the stored response of the same request, or with a conflict while it still runs or when the key was used with another request. */
+func (h *BookHandler) replayIdempotent(w http.ResponseWriter, r *http.Request, caller, key, hash string) {
+	storedKey, err := h.bookService.GetIdempotencyKey(r.Context(), caller, key)
+	if errors.Is(err, book.ErrResponseIdempotencyKeyNotFound) { //Freed right after it was found held; the client may retry.
+		responseJSON(w, http.StatusConflict, book.ErrResponseIdempotencyKeyInProgress)
+		return
+	}
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	if storedKey.RequestHash != hash {
+		responseJSON(w, http.StatusConflict, book.ErrResponseIdempotencyKeyReused)
+		return
+	}
+	if storedKey.CompletedAt == nil {
+		responseJSON(w, http.StatusConflict, book.ErrResponseIdempotencyKeyInProgress)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(storedKey.ResponseStatus)
+	_, err = w.Write(storedKey.ResponseBody)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+/* Tells who sent the request: the API key, user or guest cart of its claims, or "" when it sent none. */
+func idempotencyCaller(r *http.Request) string {
+	claims, ok := book.ClaimsFromContext(r.Context())
+	switch {
+	case !ok:
+		return ""
+	case claims.APIKeyID != uuid.Nil:
+		return "apikey:" + claims.APIKeyID.String()
+	case claims.UserID != uuid.Nil:
+		return "user:" + claims.UserID.String()
+	case claims.CartOrderID != uuid.Nil:
+		return "cart:" + claims.CartOrderID.String()
+	default:
+		return ""
+	}
+}
+
+/* Hashes what identifies a request: its caller, method, path and body. */
+func requestHash(r *http.Request, caller string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(caller + "\n" + r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+/* Keeps a copy of the status and body written to a http.ResponseWriter. */
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -40,6 +40,51 @@ func (m *MockServiceAPI) EXPECT() *MockServiceAPIMockRecorder {
 	return m.recorder
 }
 
+// AddToWishlist mocks base method.
+func (m *MockServiceAPI) AddToWishlist(arg0 context.Context, arg1, arg2 uuid.UUID) (book.WishlistItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToWishlist", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.WishlistItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddToWishlist indicates an expected call of AddToWishlist.
+func (mr *MockServiceAPIMockRecorder) AddToWishlist(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToWishlist", reflect.TypeOf((*MockServiceAPI)(nil).AddToWishlist), arg0, arg1, arg2)
+}
+
+// ApplyCoupon mocks base method.
+func (m *MockServiceAPI) ApplyCoupon(arg0 context.Context, arg1 uuid.UUID, arg2 string) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyCoupon", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyCoupon indicates an expected call of ApplyCoupon.
+func (mr *MockServiceAPIMockRecorder) ApplyCoupon(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyCoupon", reflect.TypeOf((*MockServiceAPI)(nil).ApplyCoupon), arg0, arg1, arg2)
+}
+
+// ApproveReturn mocks base method.
+func (m *MockServiceAPI) ApproveReturn(arg0 context.Context, arg1 uuid.UUID) (book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveReturn", arg0, arg1)
+	ret0, _ := ret[0].(book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveReturn indicates an expected call of ApproveReturn.
+func (mr *MockServiceAPIMockRecorder) ApproveReturn(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveReturn", reflect.TypeOf((*MockServiceAPI)(nil).ApproveReturn), arg0, arg1)
+}
+
 // ArchiveBook mocks base method.
 func (m *MockServiceAPI) ArchiveBook(arg0 context.Context, arg1 uuid.UUID) (book.Book, error) {
 	m.ctrl.T.Helper()
@@ -55,6 +100,96 @@ func (mr *MockServiceAPIMockRecorder) ArchiveBook(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveBook", reflect.TypeOf((*MockServiceAPI)(nil).ArchiveBook), arg0, arg1)
 }
 
+// AuthenticateAPIKey mocks base method.
+func (m *MockServiceAPI) AuthenticateAPIKey(arg0 context.Context, arg1 string) (book.Claims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(book.Claims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateAPIKey indicates an expected call of AuthenticateAPIKey.
+func (mr *MockServiceAPIMockRecorder) AuthenticateAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIKey", reflect.TypeOf((*MockServiceAPI)(nil).AuthenticateAPIKey), arg0, arg1)
+}
+
+// Checkout mocks base method.
+func (m *MockServiceAPI) Checkout(arg0 context.Context, arg1 uuid.UUID) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checkout", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Checkout indicates an expected call of Checkout.
+func (mr *MockServiceAPIMockRecorder) Checkout(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkout", reflect.TypeOf((*MockServiceAPI)(nil).Checkout), arg0, arg1)
+}
+
+// ClaimOrder mocks base method.
+func (m *MockServiceAPI) ClaimOrder(arg0 context.Context, arg1 book.ClaimOrderRequest) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOrder", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOrder indicates an expected call of ClaimOrder.
+func (mr *MockServiceAPIMockRecorder) ClaimOrder(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOrder", reflect.TypeOf((*MockServiceAPI)(nil).ClaimOrder), arg0, arg1)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockServiceAPI) CompleteIdempotencyKey(arg0 context.Context, arg1 book.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockServiceAPIMockRecorder) CompleteIdempotencyKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockServiceAPI)(nil).CompleteIdempotencyKey), arg0, arg1)
+}
+
+// ConfirmPayment mocks base method.
+func (m *MockServiceAPI) ConfirmPayment(arg0 context.Context, arg1 uuid.UUID) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmPayment", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmPayment indicates an expected call of ConfirmPayment.
+func (mr *MockServiceAPIMockRecorder) ConfirmPayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmPayment", reflect.TypeOf((*MockServiceAPI)(nil).ConfirmPayment), arg0, arg1)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockServiceAPI) CreateAPIKey(arg0 context.Context, arg1 book.CreateAPIKeyRequest) (book.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(book.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockServiceAPIMockRecorder) CreateAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockServiceAPI)(nil).CreateAPIKey), arg0, arg1)
+}
+
 // CreateBook mocks base method.
 func (m *MockServiceAPI) CreateBook(arg0 context.Context, arg1 book.CreateBookRequest) (book.Book, error) {
 	m.ctrl.T.Helper()
@@ -70,6 +205,37 @@ func (mr *MockServiceAPIMockRecorder) CreateBook(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBook", reflect.TypeOf((*MockServiceAPI)(nil).CreateBook), arg0, arg1)
 }
 
+// CreateCoupon mocks base method.
+func (m *MockServiceAPI) CreateCoupon(arg0 context.Context, arg1 book.CreateCouponRequest) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCoupon", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCoupon indicates an expected call of CreateCoupon.
+func (mr *MockServiceAPIMockRecorder) CreateCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoupon", reflect.TypeOf((*MockServiceAPI)(nil).CreateCoupon), arg0, arg1)
+}
+
+// CreateGuestOrder mocks base method.
+func (m *MockServiceAPI) CreateGuestOrder(arg0 context.Context) (book.Order, book.CartToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGuestOrder", arg0)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(book.CartToken)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateGuestOrder indicates an expected call of CreateGuestOrder.
+func (mr *MockServiceAPIMockRecorder) CreateGuestOrder(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGuestOrder", reflect.TypeOf((*MockServiceAPI)(nil).CreateGuestOrder), arg0)
+}
+
 // CreateOrder mocks base method.
 func (m *MockServiceAPI) CreateOrder(arg0 context.Context, arg1 uuid.UUID) (book.Order, error) {
 	m.ctrl.T.Helper()
@@ -85,6 +251,123 @@ func (mr *MockServiceAPIMockRecorder) CreateOrder(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockServiceAPI)(nil).CreateOrder), arg0, arg1)
 }
 
+// CreateUser mocks base method.
+func (m *MockServiceAPI) CreateUser(arg0 context.Context, arg1 book.CreateUserRequest) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockServiceAPIMockRecorder) CreateUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockServiceAPI)(nil).CreateUser), arg0, arg1)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockServiceAPI) CreateWebhook(arg0 context.Context, arg1 book.CreateWebhookRequest) (book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1)
+	ret0, _ := ret[0].(book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockServiceAPIMockRecorder) CreateWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockServiceAPI)(nil).CreateWebhook), arg0, arg1)
+}
+
+// DeleteCoupon mocks base method.
+func (m *MockServiceAPI) DeleteCoupon(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCoupon", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCoupon indicates an expected call of DeleteCoupon.
+func (mr *MockServiceAPIMockRecorder) DeleteCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCoupon", reflect.TypeOf((*MockServiceAPI)(nil).DeleteCoupon), arg0, arg1)
+}
+
+// DeleteUser mocks base method.
+func (m *MockServiceAPI) DeleteUser(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockServiceAPIMockRecorder) DeleteUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockServiceAPI)(nil).DeleteUser), arg0, arg1)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockServiceAPI) DeleteWebhook(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockServiceAPIMockRecorder) DeleteWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockServiceAPI)(nil).DeleteWebhook), arg0, arg1)
+}
+
+// DeliverShipment mocks base method.
+func (m *MockServiceAPI) DeliverShipment(arg0 context.Context, arg1 uuid.UUID) (book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeliverShipment", arg0, arg1)
+	ret0, _ := ret[0].(book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeliverShipment indicates an expected call of DeliverShipment.
+func (mr *MockServiceAPIMockRecorder) DeliverShipment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliverShipment", reflect.TypeOf((*MockServiceAPI)(nil).DeliverShipment), arg0, arg1)
+}
+
+// EraseUserData mocks base method.
+func (m *MockServiceAPI) EraseUserData(arg0 context.Context, arg1 uuid.UUID) (book.UserErasure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseUserData", arg0, arg1)
+	ret0, _ := ret[0].(book.UserErasure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseUserData indicates an expected call of EraseUserData.
+func (mr *MockServiceAPIMockRecorder) EraseUserData(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseUserData", reflect.TypeOf((*MockServiceAPI)(nil).EraseUserData), arg0, arg1)
+}
+
+// ExportUserData mocks base method.
+func (m *MockServiceAPI) ExportUserData(arg0 context.Context, arg1 uuid.UUID) (book.UserDataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUserData", arg0, arg1)
+	ret0, _ := ret[0].(book.UserDataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportUserData indicates an expected call of ExportUserData.
+func (mr *MockServiceAPIMockRecorder) ExportUserData(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUserData", reflect.TypeOf((*MockServiceAPI)(nil).ExportUserData), arg0, arg1)
+}
+
 // GetBook mocks base method.
 func (m *MockServiceAPI) GetBook(arg0 context.Context, arg1 uuid.UUID) (book.Book, error) {
 	m.ctrl.T.Helper()
@@ -100,6 +383,81 @@ func (mr *MockServiceAPIMockRecorder) GetBook(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBook", reflect.TypeOf((*MockServiceAPI)(nil).GetBook), arg0, arg1)
 }
 
+// GetCoupon mocks base method.
+func (m *MockServiceAPI) GetCoupon(arg0 context.Context, arg1 uuid.UUID) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCoupon", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCoupon indicates an expected call of GetCoupon.
+func (mr *MockServiceAPIMockRecorder) GetCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoupon", reflect.TypeOf((*MockServiceAPI)(nil).GetCoupon), arg0, arg1)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockServiceAPI) GetIdempotencyKey(arg0 context.Context, arg1, arg2 string) (book.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockServiceAPIMockRecorder) GetIdempotencyKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockServiceAPI)(nil).GetIdempotencyKey), arg0, arg1, arg2)
+}
+
+// GetUser mocks base method.
+func (m *MockServiceAPI) GetUser(arg0 context.Context, arg1 uuid.UUID) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockServiceAPIMockRecorder) GetUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockServiceAPI)(nil).GetUser), arg0, arg1)
+}
+
+// GetWebhook mocks base method.
+func (m *MockServiceAPI) GetWebhook(arg0 context.Context, arg1 uuid.UUID) (book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", arg0, arg1)
+	ret0, _ := ret[0].(book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockServiceAPIMockRecorder) GetWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockServiceAPI)(nil).GetWebhook), arg0, arg1)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockServiceAPI) ListAPIKeys(arg0 context.Context) ([]book.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", arg0)
+	ret0, _ := ret[0].([]book.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockServiceAPIMockRecorder) ListAPIKeys(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockServiceAPI)(nil).ListAPIKeys), arg0)
+}
+
 // ListBooks mocks base method.
 func (m *MockServiceAPI) ListBooks(arg0 context.Context, arg1 book.ListBooksRequest) (book.PagedBooks, error) {
 	m.ctrl.T.Helper()
@@ -115,6 +473,36 @@ func (mr *MockServiceAPIMockRecorder) ListBooks(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBooks", reflect.TypeOf((*MockServiceAPI)(nil).ListBooks), arg0, arg1)
 }
 
+// ListCoupons mocks base method.
+func (m *MockServiceAPI) ListCoupons(arg0 context.Context) ([]book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCoupons", arg0)
+	ret0, _ := ret[0].([]book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCoupons indicates an expected call of ListCoupons.
+func (mr *MockServiceAPIMockRecorder) ListCoupons(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoupons", reflect.TypeOf((*MockServiceAPI)(nil).ListCoupons), arg0)
+}
+
+// ListOrderHistory mocks base method.
+func (m *MockServiceAPI) ListOrderHistory(arg0 context.Context, arg1 uuid.UUID) ([]book.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrderHistory", arg0, arg1)
+	ret0, _ := ret[0].([]book.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrderHistory indicates an expected call of ListOrderHistory.
+func (mr *MockServiceAPIMockRecorder) ListOrderHistory(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderHistory", reflect.TypeOf((*MockServiceAPI)(nil).ListOrderHistory), arg0, arg1)
+}
+
 // ListOrderItems mocks base method.
 func (m *MockServiceAPI) ListOrderItems(arg0 context.Context, arg1 uuid.UUID) (book.Order, error) {
 	m.ctrl.T.Helper()
@@ -130,6 +518,272 @@ func (mr *MockServiceAPIMockRecorder) ListOrderItems(arg0, arg1 any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderItems", reflect.TypeOf((*MockServiceAPI)(nil).ListOrderItems), arg0, arg1)
 }
 
+// ListReturns mocks base method.
+func (m *MockServiceAPI) ListReturns(arg0 context.Context, arg1 uuid.UUID) ([]book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReturns", arg0, arg1)
+	ret0, _ := ret[0].([]book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReturns indicates an expected call of ListReturns.
+func (mr *MockServiceAPIMockRecorder) ListReturns(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReturns", reflect.TypeOf((*MockServiceAPI)(nil).ListReturns), arg0, arg1)
+}
+
+// ListShipments mocks base method.
+func (m *MockServiceAPI) ListShipments(arg0 context.Context, arg1 uuid.UUID) ([]book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListShipments", arg0, arg1)
+	ret0, _ := ret[0].([]book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListShipments indicates an expected call of ListShipments.
+func (mr *MockServiceAPIMockRecorder) ListShipments(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListShipments", reflect.TypeOf((*MockServiceAPI)(nil).ListShipments), arg0, arg1)
+}
+
+// ListUsers mocks base method.
+func (m *MockServiceAPI) ListUsers(arg0 context.Context) ([]book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0)
+	ret0, _ := ret[0].([]book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockServiceAPIMockRecorder) ListUsers(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockServiceAPI)(nil).ListUsers), arg0)
+}
+
+// ListWebhookDeliveries mocks base method.
+func (m *MockServiceAPI) ListWebhookDeliveries(arg0 context.Context, arg1 uuid.UUID) ([]book.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]book.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockServiceAPIMockRecorder) ListWebhookDeliveries(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockServiceAPI)(nil).ListWebhookDeliveries), arg0, arg1)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockServiceAPI) ListWebhooks(arg0 context.Context) ([]book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", arg0)
+	ret0, _ := ret[0].([]book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockServiceAPIMockRecorder) ListWebhooks(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockServiceAPI)(nil).ListWebhooks), arg0)
+}
+
+// ListWishlist mocks base method.
+func (m *MockServiceAPI) ListWishlist(arg0 context.Context, arg1 uuid.UUID) ([]book.WishlistItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWishlist", arg0, arg1)
+	ret0, _ := ret[0].([]book.WishlistItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWishlist indicates an expected call of ListWishlist.
+func (mr *MockServiceAPIMockRecorder) ListWishlist(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWishlist", reflect.TypeOf((*MockServiceAPI)(nil).ListWishlist), arg0, arg1)
+}
+
+// Login mocks base method.
+func (m *MockServiceAPI) Login(arg0 context.Context, arg1, arg2 string) (book.AuthTokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", arg0, arg1, arg2)
+	ret0, _ := ret[0].(book.AuthTokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockServiceAPIMockRecorder) Login(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockServiceAPI)(nil).Login), arg0, arg1, arg2)
+}
+
+// Logout mocks base method.
+func (m *MockServiceAPI) Logout(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockServiceAPIMockRecorder) Logout(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockServiceAPI)(nil).Logout), arg0, arg1)
+}
+
+// MoveWishlistItemToOrder mocks base method.
+func (m *MockServiceAPI) MoveWishlistItemToOrder(arg0 context.Context, arg1 book.MoveWishlistItemRequest) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveWishlistItemToOrder", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MoveWishlistItemToOrder indicates an expected call of MoveWishlistItemToOrder.
+func (mr *MockServiceAPIMockRecorder) MoveWishlistItemToOrder(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveWishlistItemToOrder", reflect.TypeOf((*MockServiceAPI)(nil).MoveWishlistItemToOrder), arg0, arg1)
+}
+
+// PickShipment mocks base method.
+func (m *MockServiceAPI) PickShipment(arg0 context.Context, arg1 book.PickShipmentRequest) (book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PickShipment", arg0, arg1)
+	ret0, _ := ret[0].(book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PickShipment indicates an expected call of PickShipment.
+func (mr *MockServiceAPIMockRecorder) PickShipment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PickShipment", reflect.TypeOf((*MockServiceAPI)(nil).PickShipment), arg0, arg1)
+}
+
+// RefreshTokens mocks base method.
+func (m *MockServiceAPI) RefreshTokens(arg0 context.Context, arg1 string) (book.AuthTokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", arg0, arg1)
+	ret0, _ := ret[0].(book.AuthTokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockServiceAPIMockRecorder) RefreshTokens(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockServiceAPI)(nil).RefreshTokens), arg0, arg1)
+}
+
+// RejectReturn mocks base method.
+func (m *MockServiceAPI) RejectReturn(arg0 context.Context, arg1 uuid.UUID) (book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectReturn", arg0, arg1)
+	ret0, _ := ret[0].(book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RejectReturn indicates an expected call of RejectReturn.
+func (mr *MockServiceAPIMockRecorder) RejectReturn(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectReturn", reflect.TypeOf((*MockServiceAPI)(nil).RejectReturn), arg0, arg1)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockServiceAPI) ReleaseIdempotencyKey(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockServiceAPIMockRecorder) ReleaseIdempotencyKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockServiceAPI)(nil).ReleaseIdempotencyKey), arg0, arg1, arg2)
+}
+
+// RemoveFromWishlist mocks base method.
+func (m *MockServiceAPI) RemoveFromWishlist(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFromWishlist", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFromWishlist indicates an expected call of RemoveFromWishlist.
+func (mr *MockServiceAPIMockRecorder) RemoveFromWishlist(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFromWishlist", reflect.TypeOf((*MockServiceAPI)(nil).RemoveFromWishlist), arg0, arg1, arg2)
+}
+
+// RequestReturn mocks base method.
+func (m *MockServiceAPI) RequestReturn(arg0 context.Context, arg1 book.CreateReturnRequest) (book.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestReturn", arg0, arg1)
+	ret0, _ := ret[0].(book.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestReturn indicates an expected call of RequestReturn.
+func (mr *MockServiceAPIMockRecorder) RequestReturn(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestReturn", reflect.TypeOf((*MockServiceAPI)(nil).RequestReturn), arg0, arg1)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockServiceAPI) ReserveIdempotencyKey(arg0 context.Context, arg1 book.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockServiceAPIMockRecorder) ReserveIdempotencyKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockServiceAPI)(nil).ReserveIdempotencyKey), arg0, arg1)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockServiceAPI) RevokeAPIKey(arg0 context.Context, arg1 uuid.UUID) (book.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(book.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockServiceAPIMockRecorder) RevokeAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockServiceAPI)(nil).RevokeAPIKey), arg0, arg1)
+}
+
+// ShipShipment mocks base method.
+func (m *MockServiceAPI) ShipShipment(arg0 context.Context, arg1 book.ShipShipmentRequest) (book.Shipment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShipShipment", arg0, arg1)
+	ret0, _ := ret[0].(book.Shipment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShipShipment indicates an expected call of ShipShipment.
+func (mr *MockServiceAPIMockRecorder) ShipShipment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShipShipment", reflect.TypeOf((*MockServiceAPI)(nil).ShipShipment), arg0, arg1)
+}
+
 // UpdateBook mocks base method.
 func (m *MockServiceAPI) UpdateBook(arg0 context.Context, arg1 book.UpdateBookRequest) (book.Book, error) {
 	m.ctrl.T.Helper()
@@ -145,6 +799,51 @@ func (mr *MockServiceAPIMockRecorder) UpdateBook(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBook", reflect.TypeOf((*MockServiceAPI)(nil).UpdateBook), arg0, arg1)
 }
 
+// UpdateCoupon mocks base method.
+func (m *MockServiceAPI) UpdateCoupon(arg0 context.Context, arg1 book.UpdateCouponRequest) (book.Coupon, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCoupon", arg0, arg1)
+	ret0, _ := ret[0].(book.Coupon)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCoupon indicates an expected call of UpdateCoupon.
+func (mr *MockServiceAPIMockRecorder) UpdateCoupon(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoupon", reflect.TypeOf((*MockServiceAPI)(nil).UpdateCoupon), arg0, arg1)
+}
+
+// UpdateOrderDetails mocks base method.
+func (m *MockServiceAPI) UpdateOrderDetails(arg0 context.Context, arg1 book.UpdateOrderDetailsRequest) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderDetails", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrderDetails indicates an expected call of UpdateOrderDetails.
+func (mr *MockServiceAPIMockRecorder) UpdateOrderDetails(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderDetails", reflect.TypeOf((*MockServiceAPI)(nil).UpdateOrderDetails), arg0, arg1)
+}
+
+// UpdateOrderItemsTx mocks base method.
+func (m *MockServiceAPI) UpdateOrderItemsTx(arg0 context.Context, arg1 book.UpdateOrderItemsRequest) (book.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderItemsTx", arg0, arg1)
+	ret0, _ := ret[0].(book.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrderItemsTx indicates an expected call of UpdateOrderItemsTx.
+func (mr *MockServiceAPIMockRecorder) UpdateOrderItemsTx(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderItemsTx", reflect.TypeOf((*MockServiceAPI)(nil).UpdateOrderItemsTx), arg0, arg1)
+}
+
 // UpdateOrderTx mocks base method.
 func (m *MockServiceAPI) UpdateOrderTx(arg0 context.Context, arg1 book.UpdateOrderRequest) (book.Order, error) {
 	m.ctrl.T.Helper()
@@ -159,3 +858,33 @@ func (mr *MockServiceAPIMockRecorder) UpdateOrderTx(arg0, arg1 any) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderTx", reflect.TypeOf((*MockServiceAPI)(nil).UpdateOrderTx), arg0, arg1)
 }
+
+// UpdateUser mocks base method.
+func (m *MockServiceAPI) UpdateUser(arg0 context.Context, arg1 book.UpdateUserRequest) (book.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0, arg1)
+	ret0, _ := ret[0].(book.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockServiceAPIMockRecorder) UpdateUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockServiceAPI)(nil).UpdateUser), arg0, arg1)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockServiceAPI) UpdateWebhook(arg0 context.Context, arg1 book.UpdateWebhookRequest) (book.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", arg0, arg1)
+	ret0, _ := ret[0].(book.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockServiceAPIMockRecorder) UpdateWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockServiceAPI)(nil).UpdateWebhook), arg0, arg1)
+}
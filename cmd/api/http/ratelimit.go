@@ -0,0 +1,214 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
+)
+
+/* Configures how many requests each client can make: API keys, users and, for anonymous calls, IPs each have their own buckets. Reads and writes are counted apart. Failed authentications are counted by IP. */
+type RateLimitConfig struct {
+	ReadsPerMinute       int            //0 turns off the limit of reads
+	ReadsBurst           int            //reads allowed at once; ReadsPerMinute when not set
+	WritesPerMinute      int            //0 turns off the limit of writes
+	WritesBurst          int            //writes allowed at once; WritesPerMinute when not set
+	FailedAuthsPerMinute int            //0 turns off the limit of failed authentications
+	FailedAuthsBurst     int            //failed authentications allowed at once; FailedAuthsPerMinute when not set
+	TrustedProxies       []netip.Prefix //proxies trusted to tell the client IP at 'Fly-Client-IP' or 'X-Forwarded-For'
+}
+
+/* Wraps the handler so each client is limited to the requests configured. Must run after authenticate, so callers are known. */
+func rateLimit(config RateLimitConfig, next http.Handler) http.Handler {
+	reads := newTokenBuckets(config.ReadsPerMinute, config.ReadsBurst)
+	writes := newTokenBuckets(config.WritesPerMinute, config.WritesBurst)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buckets := writes
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			buckets = reads
+		}
+		if buckets == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, retryAfter := buckets.take(clientKey(r, config.TrustedProxies), time.Now())
+		if !allowed {
+			rateLimited(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+/* Wraps the handler so each IP is limited to the failed authentications configured. Once they are spent, its requests are refused before any token, key or password is checked, so they can't be guessed. Must run before authenticate. */
+func limitFailedAuths(config RateLimitConfig, next http.Handler) http.Handler {
+	failures := newTokenBuckets(config.FailedAuthsPerMinute, config.FailedAuthsBurst)
+	if failures == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + clientIP(r, config.TrustedProxies)
+		if spent, retryAfter := failures.spent(key, time.Now()); spent {
+			rateLimited(w, retryAfter)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == http.StatusUnauthorized { //Bad tokens, keys and logins alike.
+			failures.take(key, time.Now())
+		}
+	})
+}
+
+func rateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	responseJSON(w, http.StatusTooManyRequests, book.ErrResponseRateLimited)
+}
+
+/* Tells who is calling, to count its requests: the API key, the user, or the IP of anonymous callers and guests. */
+func clientKey(r *http.Request, trustedProxies []netip.Prefix) string {
+	if claims, authenticated := book.ClaimsFromContext(r.Context()); authenticated && claims.CartOrderID == uuid.Nil { //Cart tokens are free to get, so guests are counted by IP.
+		if claims.APIKeyID != uuid.Nil {
+			return "apikey:" + claims.APIKeyID.String()
+		}
+		return "user:" + claims.UserID.String()
+	}
+	return "ip:" + clientIP(r, trustedProxies)
+}
+
+/* Finds the IP of the client. The headers set by proxies are only believed when the request came from a trusted one, as clients can send them too. */
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !isTrusted(remote, trustedProxies) {
+		return host
+	}
+
+	if flyIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("Fly-Client-IP"))); err == nil {
+		return flyIP.Unmap().String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- { //The rightmost hops were added by our proxies; the first untrusted one is the client.
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrusted(hop, trustedProxies) {
+			return hop.Unmap().String()
+		}
+	}
+	return remote.Unmap().String()
+}
+
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Buckets of tokens refilled at a constant rate, one per client. Each request takes a token; clients with an empty bucket must wait. */
+type tokenBuckets struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+/* Returns nil when perMinute is 0, meaning no limit. */
+func newTokenBuckets(perMinute, burst int) *tokenBuckets {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &tokenBuckets{
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(burst),
+		buckets:   map[string]*tokenBucket{},
+	}
+}
+
+/* Takes a token of the bucket of key. If there is none, tells how long until there is one. */
+func (b *tokenBuckets) take(key string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	bucket, found := b.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: b.burst, last: now}
+		b.buckets[key] = bucket
+	}
+	bucket.tokens = b.refilled(bucket, now)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, b.untilToken(bucket.tokens)
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+/* Tells if the bucket of key is empty, without taking a token. If it is, tells how long until there is one. */
+func (b *tokenBuckets) spent(key string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, found := b.buckets[key]
+	if !found {
+		return false, 0
+	}
+	tokens := b.refilled(bucket, now)
+	if tokens < 1 {
+		return true, b.untilToken(tokens)
+	}
+	return false, 0
+}
+
+func (b *tokenBuckets) untilToken(tokens float64) time.Duration {
+	return time.Duration((1 - tokens) / b.perSecond * float64(time.Second))
+}
+
+func (b *tokenBuckets) refilled(bucket *tokenBucket, now time.Time) float64 {
+	return math.Min(b.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*b.perSecond)
+}
+
+/* Forgets, once a minute, the buckets already full again, so clients gone don't pile up. */
+func (b *tokenBuckets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < time.Minute {
+		return
+	}
+	b.lastSweep = now
+	for key, bucket := range b.buckets {
+		if b.refilled(bucket, now) >= b.burst {
+			delete(b.buckets, key)
+		}
+	}
+}
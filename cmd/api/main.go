@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"strconv"
@@ -17,6 +19,7 @@ import (
 	"github.com/books-service/cmd/api/database"
 	bookhttp "github.com/books-service/cmd/api/http"
 	"github.com/books-service/cmd/api/notifications"
+	"github.com/books-service/cmd/api/payments"
 
 	"github.com/golang-migrate/migrate/v4"
 )
@@ -57,6 +60,16 @@ func run() error {
 		}
 	}
 
+	//get for how long idempotency keys are kept:
+	idempotencyTTL := 24 * time.Hour
+	idempotencyTTLStr := os.Getenv("IDEMPOTENCY_KEYS_TTL") //This ENV must be written with a unit suffix, like hours
+	if idempotencyTTLStr != "" {
+		idempotencyTTL, err = time.ParseDuration(idempotencyTTLStr)
+		if err != nil {
+			return fmt.Errorf("getting idempotency keys ttl from env: %w", err)
+		}
+	}
+
 	//get Ntfy notifications config:
 	enableNotifications := false
 	enableNotificationsStr := os.Getenv("ENABLE_NOTIFICATIONS")
@@ -83,12 +96,190 @@ func run() error {
 		}
 	}
 
+	//get tax and shipping rules:
+	pricingRules := book.PricingRules{}
+	pricingRulesPath := os.Getenv("PRICING_RULES_PATH")
+	if pricingRulesPath != "" {
+		pricingRules, err = book.LoadPricingRules(pricingRulesPath)
+		if err != nil {
+			return fmt.Errorf("getting pricing rules from file: %w", err)
+		}
+	}
+	priceCalculator := book.NewRulesCalculator(pricingRules)
+
+	//get when abandoned orders expire and how often they are searched:
+	ordersExpiration := 24 * time.Hour
+	ordersExpirationStr := os.Getenv("ORDERS_EXPIRATION_TIME") //This ENV must be written with a unit suffix, like hours
+	if ordersExpirationStr != "" {
+		ordersExpiration, err = time.ParseDuration(ordersExpirationStr)
+		if err != nil {
+			return fmt.Errorf("getting orders expiration time from env: %w", err)
+		}
+	}
+	ordersExpirationInterval := 10 * time.Minute
+	ordersExpirationIntervalStr := os.Getenv("ORDERS_EXPIRATION_CHECK_INTERVAL") //This ENV must be written with a unit suffix, like minutes
+	if ordersExpirationIntervalStr != "" {
+		ordersExpirationInterval, err = time.ParseDuration(ordersExpirationIntervalStr)
+		if err != nil {
+			return fmt.Errorf("getting orders expiration check interval from env: %w", err)
+		}
+	}
+
+	//get how often the outbox events are dispatched:
+	outboxInterval := 5 * time.Second
+	outboxIntervalStr := os.Getenv("OUTBOX_DISPATCH_INTERVAL") //This ENV must be written with a unit suffix, like seconds
+	if outboxIntervalStr != "" {
+		outboxInterval, err = time.ParseDuration(outboxIntervalStr)
+		if err != nil {
+			return fmt.Errorf("getting outbox dispatch interval from env: %w", err)
+		}
+	}
+
+	//get how often expired records are purged:
+	purgeInterval := time.Hour
+	purgeIntervalStr := os.Getenv("PURGE_INTERVAL") //This ENV must be written with a unit suffix, like hours
+	if purgeIntervalStr != "" {
+		purgeInterval, err = time.ParseDuration(purgeIntervalStr)
+		if err != nil {
+			return fmt.Errorf("getting purge interval from env: %w", err)
+		}
+	}
+
+	//get how often the pending webhook deliveries are posted:
+	webhooksInterval := 5 * time.Second
+	webhooksIntervalStr := os.Getenv("WEBHOOKS_DELIVERY_INTERVAL") //This ENV must be written with a unit suffix, like seconds
+	if webhooksIntervalStr != "" {
+		webhooksInterval, err = time.ParseDuration(webhooksIntervalStr)
+		if err != nil {
+			return fmt.Errorf("getting webhooks delivery interval from env: %w", err)
+		}
+	}
+
+	//get the isolation level and retries of multi-step transactions:
+	txConfig := book.TxConfig{
+		Isolation:      sql.LevelDefault,
+		MaxRetries:     3,
+		RetryBaseDelay: 20 * time.Millisecond,
+	}
+	txIsolationStr := os.Getenv("TX_ISOLATION_LEVEL") //read_committed, repeatable_read or serializable
+	if txIsolationStr != "" {
+		txConfig.Isolation, err = parseIsolationLevel(txIsolationStr)
+		if err != nil {
+			return fmt.Errorf("getting transactions isolation level from env: %w", err)
+		}
+	}
+	txMaxRetriesStr := os.Getenv("TX_MAX_RETRIES")
+	if txMaxRetriesStr != "" {
+		txConfig.MaxRetries, err = strconv.Atoi(txMaxRetriesStr)
+		if err != nil {
+			return fmt.Errorf("getting transactions max retries from env: %w", err)
+		}
+	}
+	txRetryBaseDelayStr := os.Getenv("TX_RETRY_BASE_DELAY") //This ENV must be written with a unit suffix, like milliseconds
+	if txRetryBaseDelayStr != "" {
+		txConfig.RetryBaseDelay, err = time.ParseDuration(txRetryBaseDelayStr)
+		if err != nil {
+			return fmt.Errorf("getting transactions retry base delay from env: %w", err)
+		}
+	}
+
+	//get the key and lifetimes of the tokens given at login and to guests:
+	authConfig := book.AuthConfig{
+		SigningKey:      []byte(os.Getenv("AUTH_SIGNING_KEY")),
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		CartTokenTTL:    7 * 24 * time.Hour,
+	}
+	if len(authConfig.SigningKey) < 32 {
+		return errors.New("auth signing key must have at least 32 bytes")
+	}
+	accessTokenTTLStr := os.Getenv("ACCESS_TOKEN_TTL") //This ENV must be written with a unit suffix, like minutes
+	if accessTokenTTLStr != "" {
+		authConfig.AccessTokenTTL, err = time.ParseDuration(accessTokenTTLStr)
+		if err != nil {
+			return fmt.Errorf("getting access token ttl from env: %w", err)
+		}
+	}
+	refreshTokenTTLStr := os.Getenv("REFRESH_TOKEN_TTL") //This ENV must be written with a unit suffix, like hours
+	if refreshTokenTTLStr != "" {
+		authConfig.RefreshTokenTTL, err = time.ParseDuration(refreshTokenTTLStr)
+		if err != nil {
+			return fmt.Errorf("getting refresh token ttl from env: %w", err)
+		}
+	}
+	cartTokenTTLStr := os.Getenv("CART_TOKEN_TTL") //This ENV must be written with a unit suffix, like hours
+	if cartTokenTTLStr != "" {
+		authConfig.CartTokenTTL, err = time.ParseDuration(cartTokenTTLStr)
+		if err != nil {
+			return fmt.Errorf("getting cart token ttl from env: %w", err)
+		}
+	}
+
+	//get how many requests each client can make, and which proxies tell the client IP:
+	rateLimit := bookhttp.RateLimitConfig{}
+	rateLimitEnvs := []struct {
+		name  string
+		value *int
+	}{
+		{"RATE_LIMIT_READS_PER_MINUTE", &rateLimit.ReadsPerMinute},
+		{"RATE_LIMIT_READS_BURST", &rateLimit.ReadsBurst},
+		{"RATE_LIMIT_WRITES_PER_MINUTE", &rateLimit.WritesPerMinute},
+		{"RATE_LIMIT_WRITES_BURST", &rateLimit.WritesBurst},
+		{"RATE_LIMIT_FAILED_AUTHS_PER_MINUTE", &rateLimit.FailedAuthsPerMinute},
+		{"RATE_LIMIT_FAILED_AUTHS_BURST", &rateLimit.FailedAuthsBurst},
+	}
+	for _, env := range rateLimitEnvs { //Limits not set are turned off.
+		valueStr := os.Getenv(env.name)
+		if valueStr == "" {
+			continue
+		}
+		*env.value, err = strconv.Atoi(valueStr)
+		if err != nil {
+			return fmt.Errorf("getting %s from env: %w", strings.ToLower(env.name), err)
+		}
+	}
+	trustedProxiesStr := os.Getenv("TRUSTED_PROXIES") //IPs or CIDR ranges, separated by commas
+	if trustedProxiesStr != "" {
+		rateLimit.TrustedProxies, err = parseTrustedProxies(trustedProxiesStr)
+		if err != nil {
+			return fmt.Errorf("getting trusted proxies from env: %w", err)
+		}
+	}
+
+	//refunds of approved returns are paid back by hand:
+	paymentGateway := payments.NewManual()
+
+	//events are posted to the endpoints of partners subscribed:
+	webhookSender := notifications.NewWebhooks(&http.Client{})
+
 	//Init service with its dependencies:
-	bookService := book.NewService(store, ntfy, notificationsTimeout)
-	bookHandler := bookhttp.NewBookHandler(bookService, reqTimeout)
+	bookService := book.NewService(store, ntfy, priceCalculator, paymentGateway, webhookSender, notificationsTimeout, txConfig, authConfig)
+	bookHandler := bookhttp.NewBookHandler(bookService, reqTimeout, idempotencyTTL)
+
+	//run a maintenance command instead of serving, if one was given:
+	if len(os.Args) > 1 {
+		return runCommand(context.Background(), bookService, os.Args[1:], os.Stdout)
+	}
+
+	//create the first admin of a new deployment, if asked:
+	adminEmail := os.Getenv("BOOTSTRAP_ADMIN_EMAIL")
+	if adminEmail != "" {
+		err = bookService.BootstrapAdmin(context.Background(), strings.ToLower(adminEmail), os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"))
+		if err != nil {
+			return fmt.Errorf("creating bootstrap admin: %w", err)
+		}
+	}
 
 	//create and init http server:
-	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080}, bookHandler)
+	server := bookhttp.NewServer(bookhttp.ServerConfig{Port: 8080, SigningKey: authConfig.SigningKey, RateLimit: rateLimit}, bookHandler)
+
+	//start background workers:
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go expireAbandonedOrders(workersCtx, bookService, ordersExpiration, ordersExpirationInterval)
+	go dispatchOutbox(workersCtx, bookService, outboxInterval)
+	go deliverWebhooks(workersCtx, bookService, webhooksInterval)
+	go purgeExpired(workersCtx, bookService, purgeInterval)
 
 	go func() {
 		err := server.ListenAndServe()
@@ -101,6 +292,7 @@ func run() error {
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
 	<-sc
+	stopWorkers()
 
 	timeout := time.Duration(10) * time.Second
 	timeoutStr := os.Getenv("SERVICE_SHUTDOWN_TIMEOUT") //This ENV must be written with a unit suffix, like seconds
@@ -119,3 +311,145 @@ func run() error {
 	log.Println("Graceful shutdown complete.")
 	return nil
 }
+
+/* Periodically cancels the orders left accepting items for too long, until the context is done. */
+func expireAbandonedOrders(ctx context.Context, bookService *book.Service, maxIdle, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("stopped expiring abandoned orders.")
+			return
+		case <-ticker.C:
+			expired, err := bookService.ExpireAbandonedOrders(ctx, maxIdle)
+			if err != nil {
+				log.Printf("expiring abandoned orders: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("expired %d abandoned orders.", expired)
+			}
+		}
+	}
+}
+
+/* Periodically deletes the records kept only for a while, until the context is done. */
+func purgeExpired(ctx context.Context, bookService *book.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("stopped purging expired records.")
+			return
+		case <-ticker.C:
+			purged, err := bookService.PurgeExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				log.Printf("purging expired idempotency keys: %v", err)
+			} else if purged > 0 {
+				log.Printf("purged %d expired idempotency keys.", purged)
+			}
+
+			purged, err = bookService.PurgeSentOutboxEvents(ctx)
+			if err != nil {
+				log.Printf("purging sent outbox events: %v", err)
+			} else if purged > 0 {
+				log.Printf("purged %d sent outbox events.", purged)
+			}
+		}
+	}
+}
+
+/* Converts the isolation level names accepted at env to the ones of database/sql. */
+func parseIsolationLevel(level string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(level) {
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level: %s", level)
+	}
+}
+
+/* Parses a list of IPs and CIDR ranges, separated by commas. A single IP is a range of itself. */
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	proxies := []netip.Prefix{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+const outboxBatchSize = 100
+
+/* Periodically delivers the pending outbox events, until the context is done. */
+func dispatchOutbox(ctx context.Context, bookService *book.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("stopped dispatching outbox events.")
+			return
+		case <-ticker.C:
+			for { //Keeps dispatching while there are full batches waiting.
+				sent, err := bookService.DispatchOutbox(ctx, outboxBatchSize)
+				if err != nil {
+					log.Printf("dispatching outbox events: %v", err)
+				}
+				if err != nil || sent < outboxBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+const webhooksBatchSize = 100
+
+/* Periodically posts the webhook deliveries due, until the context is done. */
+func deliverWebhooks(ctx context.Context, bookService *book.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("stopped delivering webhooks.")
+			return
+		case <-ticker.C:
+			for { //Keeps delivering while full batches go through.
+				delivered, err := bookService.DeliverWebhooks(ctx, webhooksBatchSize)
+				if err != nil {
+					log.Printf("delivering webhooks: %v", err)
+				}
+				if err != nil || delivered < webhooksBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
@@ -1,18 +1,69 @@
 package notifications
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"text/template"
 
 	"github.com/books-service/cmd/api/book"
+	"github.com/google/uuid"
 )
 
 type Doer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+/* Where the events of a type are posted and what is written there. Both are text/templates run on the payload of the event. */
+type route struct {
+	topic   *template.Template
+	message *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"count": func(list any) int { //len, that takes null lists as empty
+		items, _ := list.([]any)
+		return len(items)
+	},
+	"known": func(id any) any { //the ID, or nothing when it is the nil UUID, as of guest orders
+		if id == uuid.Nil.String() {
+			return ""
+		}
+		return id
+	},
+}
+
+func newRoute(eventType book.EventType, topic, message string) route {
+	return route{
+		topic:   template.Must(template.New(string(eventType) + " topic").Funcs(templateFuncs).Parse(topic)),
+		message: template.Must(template.New(string(eventType) + " message").Funcs(templateFuncs).Parse(message)),
+	}
+}
+
+/* The topics and messages of each event type. Topics of purchasers have their ID at the end, so each one only gets their own. Ntfy SEEMS NOT TO ACEPT SLASHS OR DOTS AT TOPIC */
+var routes = map[book.EventType]route{
+	book.EventBookCreated: newRoute(book.EventBookCreated, "_New_book_created",
+		"New book created:\nID: {{.ID}}\nTitle: {{.Name}}\nInventory: {{.Inventory}}"),
+	book.EventBookUpdated: newRoute(book.EventBookUpdated, "_Book_updated",
+		"Book updated:\nID: {{.ID}}\nTitle: {{.Name}}\nPrice: {{.Price}}\nInventory: {{.Inventory}}"),
+	book.EventBookArchived: newRoute(book.EventBookArchived, "_Book_archived",
+		"Book archived:\nID: {{.ID}}\nTitle: {{.Name}}"),
+	book.EventBookInventoryChanged: newRoute(book.EventBookInventoryChanged, "_Book_inventory_changed",
+		"Book inventory changed:\nID: {{.BookID}}\nTitle: {{.BookName}}\nInventory: {{.PreviousInventory}} -> {{.Inventory}}"),
+	book.EventOrderUpdated: newRoute(book.EventOrderUpdated, "{{with known .PurchaserID}}_Order_updated_{{.}}{{end}}",
+		"Your order was updated:\nID: {{.OrderID}}\nStatus: {{.OrderStatus}}\nItems: {{count .Items}}\nTotal price: {{.TotalPrice}}"),
+	book.EventOrderExpired: newRoute(book.EventOrderExpired, "{{with known .PurchaserID}}_Order_expired_{{.}}{{end}}",
+		"Your order was canceled for inactivity:\nID: {{.OrderID}}\nItems: {{count .Items}}"),
+	book.EventShipmentUpdated: newRoute(book.EventShipmentUpdated, "{{with known .PurchaserID}}_Shipment_updated_{{.}}{{end}}",
+		"Your shipment was {{.Status}}:\nID: {{.ShipmentID}}\nOrder ID: {{.OrderID}}{{if .TrackingNumber}}\nCarrier: {{.Carrier}}\nTracking number: {{.TrackingNumber}}{{end}}"),
+	book.EventReturnRequested: newRoute(book.EventReturnRequested, "_Return_requested",
+		"Return requested:\nID: {{.ReturnID}}\nOrder ID: {{.OrderID}}\nItems: {{count .Items}}\nReason: {{.Reason}}"),
+}
+
 type Ntfy struct {
 	baseURL string
 	enabled bool
@@ -27,22 +78,52 @@ func NewNtfy(enableNotifications bool, notificationsBaseURL string, client Doer)
 	}
 }
 
-func (ntf *Ntfy) BookCreated(ctx context.Context, createdBook book.Book) error {
-	if !ntf.enabled {
+/* Sends the event to the topic mapped to its type, with the message of its template. Event types with no route, or whose topic comes out empty, are skipped. */
+func (ntf *Ntfy) Notify(ctx context.Context, event book.Event) error {
+	r, found := routes[event.Type]
+	if !found {
 		return nil
 	}
 
-	url := ntf.baseURL + "_New_book_created"
-	message := strings.NewReader(fmt.Sprintf("New book created:\nID: %v\nTitle: %s\nInventory: %v", createdBook.ID, createdBook.Name, *createdBook.Inventory)) //Ntfy SEEMS NOT TO ACEPT SLASHS OR DOTS AT TOPIC
+	subject := fmt.Sprintf("%s event ID: %v", event.Type, event.ID)
+
+	decoder := json.NewDecoder(bytes.NewReader(event.Payload))
+	decoder.UseNumber() //Numbers are written as they came, not as floats.
+	var payload any
+	err := decoder.Decode(&payload)
+	if err != nil {
+		return fmt.Errorf("error decoding payload to ntfy (%s): %w", subject, err)
+	}
+
+	var topic, message strings.Builder
+	err = r.topic.Execute(&topic, payload)
+	if err == nil {
+		err = r.message.Execute(&message, payload)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing message to ntfy (%s): %w", subject, err)
+	}
+	if topic.Len() == 0 {
+		return nil
+	}
+
+	return ntf.publish(ctx, topic.String(), strings.NewReader(message.String()), subject)
+}
+
+/* Posts a message to a topic under the base URL. The subject identifies the message at errors. */
+func (ntf *Ntfy) publish(ctx context.Context, topic string, message io.Reader, subject string) error {
+	if !ntf.enabled {
+		return nil
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, message)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ntf.baseURL+topic, message)
 	if err != nil {
-		return fmt.Errorf("error delivering message to ntfy (book ID: %v): %w", createdBook.ID, err)
+		return fmt.Errorf("error delivering message to ntfy (%s): %w", subject, err)
 	}
 
 	resp, err := ntf.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error delivering message to ntfy (book ID: %v): %w", createdBook.ID, err)
+		return fmt.Errorf("error delivering message to ntfy (%s): %w", subject, err)
 	}
 	defer resp.Body.Close()
 
@@ -3,6 +3,7 @@ package notifications_test
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -47,7 +48,7 @@ func TestIntegrationBookCreated(t *testing.T) {
 		defer resp.Body.Close()
 		scanner := bufio.NewScanner(resp.Body)
 
-		err = ntfy.BookCreated(ctx, testerBook)
+		err = ntfy.Notify(ctx, event(t, book.EventBookCreated, testerBook))
 		is.NoErr(err)
 
 		//Listenning to the topic:
@@ -67,7 +68,7 @@ func TestIntegrationBookCreated(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), notificationsTimeout)
 		defer cancel()
 
-		err := ntfy.BookCreated(ctx, testerBook)
+		err := ntfy.Notify(ctx, event(t, book.EventBookCreated, testerBook))
 		is.True(errors.Is(err, context.DeadlineExceeded))
 	})
 }
@@ -109,7 +110,7 @@ func TestBookCreated(t *testing.T) {
 			return resp, nil
 		})
 
-		err := ntfy.BookCreated(ctx, testerBook)
+		err := ntfy.Notify(ctx, event(t, book.EventBookCreated, testerBook))
 		is.NoErr(err)
 	})
 
@@ -138,7 +139,7 @@ func TestBookCreated(t *testing.T) {
 			return resp, nil
 		})
 
-		err := ntfy.BookCreated(ctx, testerBook)
+		err := ntfy.Notify(ctx, event(t, book.EventBookCreated, testerBook))
 		is.True(errors.As(err, &book.ErrNotificationFailed{}))
 	})
 
@@ -163,11 +164,225 @@ func TestBookCreated(t *testing.T) {
 			return nil, context.DeadlineExceeded
 		})
 
-		err := ntfy.BookCreated(ctx, testerBook)
+		err := ntfy.Notify(ctx, event(t, book.EventBookCreated, testerBook))
 		is.True(errors.Is(err, context.DeadlineExceeded))
 	})
 }
 
+func TestBookUpdated(t *testing.T) {
+	notificationsBaseURL := "https://ntfy.sh/test_Ah3mn6oD"
+	enableNotifications := true
+
+	testerBook := book.Book{
+		ID:        uuid.New(),
+		Name:      "book to test ntfy",
+		Price:     toPointer(float32(40.5)),
+		Inventory: toPointer(35),
+	}
+
+	t.Run("notificates the update of a book without errors on a mocked Client", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		url := "https://ntfy.sh/test_Ah3mn6oD_Book_updated"
+		message := "Book updated:\nID: " + testerBook.ID.String() + "\nTitle: book to test ntfy\nPrice: 40.5\nInventory: 35"
+
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			is.True(req.Method == http.MethodPost)
+			is.True(req.URL.String() == url)
+			requestedBody, _ := io.ReadAll(req.Body)
+			is.Equal(string(requestedBody), message)
+
+			resp := httptest.NewRecorder().Result()
+			resp.Status = "200 OK"
+			resp.StatusCode = http.StatusOK
+
+			return resp, nil
+		})
+
+		err := ntfy.Notify(context.Background(), event(t, book.EventBookUpdated, testerBook))
+		is.NoErr(err)
+	})
+}
+
+func TestOrderExpired(t *testing.T) {
+	notificationsBaseURL := "https://ntfy.sh/test_Ah3mn6oD"
+	enableNotifications := true
+
+	expiredOrder := book.Order{
+		OrderID:     uuid.New(),
+		PurchaserID: uuid.New(),
+		OrderStatus: "canceled",
+		Items:       []book.OrderItem{{BookID: uuid.New(), BookUnits: 2}},
+	}
+
+	t.Run("notificates the purchaser of an expired order without errors on a mocked Client", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		ctx := context.Background()
+
+		url := "https://ntfy.sh/test_Ah3mn6oD_Order_expired_" + expiredOrder.PurchaserID.String()
+		message := "Your order was canceled for inactivity:\nID: " + expiredOrder.OrderID.String() + "\nItems: 1"
+
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			is.True(req.Method == http.MethodPost)
+			is.True(req.URL.String() == url)
+			requestedBody, _ := io.ReadAll(req.Body)
+			is.Equal(string(requestedBody), message)
+
+			resp := httptest.NewRecorder().Result()
+			resp.Status = "200 OK"
+			resp.StatusCode = http.StatusOK
+
+			return resp, nil
+		})
+
+		err := ntfy.Notify(ctx, event(t, book.EventOrderExpired, expiredOrder))
+		is.NoErr(err)
+	})
+
+	t.Run("does nothing when notifications are disabled", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(false, notificationsBaseURL, mockClient)
+
+		err := ntfy.Notify(context.Background(), event(t, book.EventOrderExpired, expiredOrder))
+		is.NoErr(err)
+	})
+}
+
+func TestShipmentUpdated(t *testing.T) {
+	notificationsBaseURL := "https://ntfy.sh/test_Ah3mn6oD"
+	enableNotifications := true
+
+	shippedShipment := book.Shipment{
+		ShipmentID:     uuid.New(),
+		OrderID:        uuid.New(),
+		PurchaserID:    uuid.New(),
+		Status:         book.ShipmentStatusShipped,
+		Carrier:        "Correios",
+		TrackingNumber: "BR123456789",
+	}
+
+	t.Run("notificates the purchaser of a shipped shipment without errors on a mocked Client", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		ctx := context.Background()
+
+		url := "https://ntfy.sh/test_Ah3mn6oD_Shipment_updated_" + shippedShipment.PurchaserID.String()
+		message := "Your shipment was shipped:\nID: " + shippedShipment.ShipmentID.String() + "\nOrder ID: " + shippedShipment.OrderID.String() + "\nCarrier: Correios\nTracking number: BR123456789"
+
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			is.True(req.Method == http.MethodPost)
+			is.True(req.URL.String() == url)
+			requestedBody, _ := io.ReadAll(req.Body)
+			is.Equal(string(requestedBody), message)
+
+			resp := httptest.NewRecorder().Result()
+			resp.Status = "200 OK"
+			resp.StatusCode = http.StatusOK
+
+			return resp, nil
+		})
+
+		err := ntfy.Notify(ctx, event(t, book.EventShipmentUpdated, shippedShipment))
+		is.NoErr(err)
+	})
+}
+
+func TestOrderUpdated(t *testing.T) {
+	notificationsBaseURL := "https://ntfy.sh/test_Ah3mn6oD"
+	enableNotifications := true
+
+	updatedOrder := book.Order{
+		OrderID:     uuid.New(),
+		PurchaserID: uuid.New(),
+		OrderStatus: "paid",
+		TotalPrice:  59.9,
+		Items:       []book.OrderItem{{BookID: uuid.New(), BookUnits: 1}, {BookID: uuid.New(), BookUnits: 3}},
+	}
+
+	t.Run("notificates the purchaser of an updated order without errors on a mocked Client", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		url := "https://ntfy.sh/test_Ah3mn6oD_Order_updated_" + updatedOrder.PurchaserID.String()
+		message := "Your order was updated:\nID: " + updatedOrder.OrderID.String() + "\nStatus: paid\nItems: 2\nTotal price: 59.9"
+
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			is.True(req.URL.String() == url)
+			requestedBody, _ := io.ReadAll(req.Body)
+			is.Equal(string(requestedBody), message)
+
+			resp := httptest.NewRecorder().Result()
+			resp.StatusCode = http.StatusOK
+
+			return resp, nil
+		})
+
+		err := ntfy.Notify(context.Background(), event(t, book.EventOrderUpdated, updatedOrder))
+		is.NoErr(err)
+	})
+
+	t.Run("skips orders of guests, that have no topic", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		guestOrder := updatedOrder
+		guestOrder.PurchaserID = uuid.Nil
+
+		err := ntfy.Notify(context.Background(), event(t, book.EventOrderUpdated, guestOrder))
+		is.NoErr(err)
+	})
+}
+
+func TestNotify(t *testing.T) {
+	notificationsBaseURL := "https://ntfy.sh/test_Ah3mn6oD"
+	enableNotifications := true
+
+	t.Run("skips event types with no topic", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		err := ntfy.Notify(context.Background(), event(t, book.EventCouponCreated, book.Coupon{ID: uuid.New(), Code: "WELCOME10"}))
+		is.NoErr(err)
+	})
+
+	t.Run("expected error for a payload that is not JSON", func(t *testing.T) {
+		is := is.New(t)
+		ctrl := gomock.NewController(t)
+		mockClient := notificationmocks.NewMockDoer(ctrl)
+		ntfy := notifications.NewNtfy(enableNotifications, notificationsBaseURL, mockClient)
+
+		err := ntfy.Notify(context.Background(), book.Event{ID: uuid.New(), Type: book.EventBookCreated, Payload: []byte("not json")})
+		is.True(err != nil)
+	})
+}
+
+/* Wraps the resource into the envelope the service hands to notifiers. */
+func event(t *testing.T, eventType book.EventType, payload any) book.Event {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book.Event{ID: uuid.New(), Type: eventType, OccurredAt: time.Now().UTC(), Payload: payloadJSON}
+}
+
 func toPointer[T any](v T) *T {
 	return &v
 }
@@ -0,0 +1,98 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+)
+
+/* The header deliveries are signed at, as "t=<unix timestamp>,v1=<hex HMAC-SHA256>". The HMAC is of "<timestamp>.<body>", keyed with the secret of the webhook, so receivers can check both who sent the body and when. */
+const SignatureHeader = "X-Signature"
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+/* Posts webhook deliveries to partner endpoints. */
+type Webhooks struct {
+	client Doer
+}
+
+func NewWebhooks(client Doer) *Webhooks {
+	return &Webhooks{client: client}
+}
+
+/* Posts the event of the delivery to the URL of the webhook, signed with its secret. Returns the status code answered, and an error when it is out of 2xx. */
+func (wh *Webhooks) Send(ctx context.Context, webhook book.Webhook, delivery book.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error delivering webhook (delivery ID: %v): %w", delivery.DeliveryID, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", string(delivery.EventType))
+	req.Header.Set("X-Delivery-ID", delivery.DeliveryID.String())
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now().Unix(), delivery.Payload))
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error delivering webhook (delivery ID: %v): %w", delivery.DeliveryID, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) //Lets the connection be reused.
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, book.NewErrWebhookFailed(resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+/* Returns the value of the signature header of a body sent at the timestamp. */
+func Sign(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/* Checks a signature header against the body received, as receivers should. Signatures older than tolerance are rejected, so captured deliveries can't be replayed. */
+func VerifySignature(secret string, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: bad timestamp", ErrInvalidSignature)
+			}
+			timestamp = parsed
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == 0 || signature == "" {
+		return fmt.Errorf("%w: missing timestamp or signature", ErrInvalidSignature)
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp out of tolerance", ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(header)) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	return nil
+}
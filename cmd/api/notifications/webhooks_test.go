@@ -0,0 +1,96 @@
+package notifications_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/books-service/cmd/api/book"
+	"github.com/books-service/cmd/api/notifications"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestWebhooksSend(t *testing.T) {
+	secret := "whsec_test_secret_of_the_partner"
+	delivery := book.WebhookDelivery{
+		DeliveryID: uuid.New(),
+		EventID:    uuid.New(),
+		EventType:  book.EventOrderUpdated,
+		Payload:    []byte(`{"id":"a9b6f1c2-0d3e-4f5a-8b7c-6d5e4f3a2b1c","type":"order_updated","payload":{}}`),
+	}
+
+	t.Run("posts the event signed with the secret of the webhook", func(t *testing.T) {
+		is := is.New(t)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			is.NoErr(err)
+			is.Equal(r.Method, http.MethodPost)
+			is.Equal(r.Header.Get("Content-Type"), "application/json")
+			is.Equal(r.Header.Get("X-Event-Type"), string(book.EventOrderUpdated))
+			is.Equal(r.Header.Get("X-Delivery-ID"), delivery.DeliveryID.String())
+			is.Equal(string(body), string(delivery.Payload))
+			is.NoErr(notifications.VerifySignature(secret, r.Header.Get(notifications.SignatureHeader), body, time.Now(), 5*time.Minute))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		webhooks := notifications.NewWebhooks(&http.Client{})
+		statusCode, err := webhooks.Send(context.Background(), book.Webhook{URL: server.URL, Secret: secret}, delivery)
+		is.NoErr(err)
+		is.Equal(statusCode, http.StatusNoContent)
+	})
+
+	t.Run("expected error with the status code of an answer out of 2xx", func(t *testing.T) {
+		is := is.New(t)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "partner is down", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		webhooks := notifications.NewWebhooks(&http.Client{})
+		statusCode, err := webhooks.Send(context.Background(), book.Webhook{URL: server.URL, Secret: secret}, delivery)
+		is.True(errors.As(err, &book.ErrWebhookFailed{}))
+		is.Equal(statusCode, http.StatusServiceUnavailable)
+	})
+
+	t.Run("expected error with no status code for an unreachable endpoint", func(t *testing.T) {
+		is := is.New(t)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Close()
+
+		webhooks := notifications.NewWebhooks(&http.Client{})
+		statusCode, err := webhooks.Send(context.Background(), book.Webhook{URL: server.URL, Secret: secret}, delivery)
+		is.True(err != nil)
+		is.Equal(statusCode, 0)
+	})
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := "whsec_test_secret_of_the_partner"
+	body := []byte(`{"type":"book_created"}`)
+	signedAt := time.Now()
+	header := notifications.Sign(secret, signedAt.Unix(), body)
+
+	t.Run("accepts the signature of the body", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(notifications.VerifySignature(secret, header, body, signedAt.Add(time.Minute), 5*time.Minute))
+	})
+
+	t.Run("expected error for a wrong secret, a changed body or a stale timestamp", func(t *testing.T) {
+		is := is.New(t)
+
+		is.True(errors.Is(notifications.VerifySignature("another_secret_of_someone", header, body, signedAt, 5*time.Minute), notifications.ErrInvalidSignature))
+		is.True(errors.Is(notifications.VerifySignature(secret, header, []byte(`{"type":"book_archived"}`), signedAt, 5*time.Minute), notifications.ErrInvalidSignature))
+		is.True(errors.Is(notifications.VerifySignature(secret, header, body, signedAt.Add(10*time.Minute), 5*time.Minute), notifications.ErrInvalidSignature))
+		is.True(errors.Is(notifications.VerifySignature(secret, "v1=deadbeef", body, signedAt, 5*time.Minute), notifications.ErrInvalidSignature))
+	})
+}
@@ -9,6 +9,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/matryer/is v1.4.1
 	go.uber.org/mock v0.3.0
+	golang.org/x/crypto v0.14.0
 )
 
 require (
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS public.idempotency_keys
+(
+idempotency_key text PRIMARY KEY NOT NULL,
+request_hash text NOT NULL,
+response_status integer NOT NULL,
+response_body bytea,
+created_at timestamp with time zone DEFAULT now(),
+expires_at timestamp with time zone NOT NULL
+);
@@ -0,0 +1,6 @@
+ALTER TABLE public.orders
+  DROP COLUMN IF EXISTS coupon_id;
+
+DROP TABLE IF EXISTS public.coupons;
+
+DROP TYPE IF EXISTS discount_type;
@@ -0,0 +1,19 @@
+CREATE TYPE discount_type AS ENUM ('percentage', 'fixed_amount');
+
+CREATE TABLE IF NOT EXISTS public.coupons
+(
+coupon_id uuid PRIMARY KEY NOT NULL,
+code text UNIQUE NOT NULL,
+discount_type discount_type NOT NULL,
+discount_value numeric(6,2) NOT NULL,
+min_order_total numeric(6,2) DEFAULT 0,
+valid_from timestamp with time zone,
+valid_until timestamp with time zone,
+usage_limit integer,
+times_used integer DEFAULT 0,
+created_at timestamp with time zone DEFAULT now(),
+updated_at timestamp with time zone DEFAULT now()
+);
+
+ALTER TABLE public.orders
+  ADD COLUMN IF NOT EXISTS coupon_id uuid REFERENCES public.coupons ON DELETE SET NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE public.orders
+  DROP COLUMN IF EXISTS tax_region,
+  DROP COLUMN IF EXISTS subtotal,
+  DROP COLUMN IF EXISTS discount,
+  DROP COLUMN IF EXISTS tax,
+  DROP COLUMN IF EXISTS shipping,
+  DROP COLUMN IF EXISTS total_price;
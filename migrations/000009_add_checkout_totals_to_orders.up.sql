@@ -0,0 +1,7 @@
+ALTER TABLE public.orders
+  ADD COLUMN IF NOT EXISTS tax_region text,
+  ADD COLUMN IF NOT EXISTS subtotal numeric(8,2),
+  ADD COLUMN IF NOT EXISTS discount numeric(8,2),
+  ADD COLUMN IF NOT EXISTS tax numeric(8,2),
+  ADD COLUMN IF NOT EXISTS shipping numeric(8,2),
+  ADD COLUMN IF NOT EXISTS total_price numeric(8,2);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS public.outbox
+(
+event_id uuid PRIMARY KEY NOT NULL,
+event_type text NOT NULL,
+payload jsonb NOT NULL,
+created_at timestamp with time zone DEFAULT now(),
+sent_at timestamp with time zone,
+attempts integer DEFAULT 0,
+last_error text
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON public.outbox (created_at) WHERE sent_at IS NULL;
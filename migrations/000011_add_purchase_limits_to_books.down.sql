@@ -0,0 +1,3 @@
+ALTER TABLE public.bookstable
+  DROP COLUMN IF EXISTS max_units_per_order,
+  DROP COLUMN IF EXISTS max_units_per_purchaser;
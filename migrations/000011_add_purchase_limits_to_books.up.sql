@@ -0,0 +1,3 @@
+ALTER TABLE public.bookstable
+  ADD COLUMN IF NOT EXISTS max_units_per_order integer CHECK (max_units_per_order > 0),
+  ADD COLUMN IF NOT EXISTS max_units_per_purchaser integer CHECK (max_units_per_purchaser > 0);
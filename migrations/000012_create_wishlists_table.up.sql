@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS public.wishlists
+(
+user_id uuid NOT NULL,
+book_id uuid REFERENCES public.bookstable ON DELETE CASCADE,
+added_at timestamp with time zone DEFAULT now(),
+PRIMARY KEY (user_id, book_id)
+);
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS public.return_items;
+
+DROP INDEX IF EXISTS returns_order_idx;
+
+DROP TABLE IF EXISTS public.returns;
+
+DROP TYPE IF EXISTS return_status;
+
+UPDATE public.orders SET order_status = 'paid' WHERE order_status IN ('return_requested', 'partially_returned', 'returned');
+
+ALTER TYPE order_status RENAME TO order_status_old;
+
+CREATE TYPE order_status AS ENUM ('accepting_items', 'canceled', 'waiting_payment', 'paid');
+
+ALTER TABLE public.orders
+  ALTER COLUMN order_status DROP DEFAULT,
+  ALTER COLUMN order_status TYPE order_status USING order_status::text::order_status,
+  ALTER COLUMN order_status SET DEFAULT 'accepting_items';
+
+DROP TYPE order_status_old;
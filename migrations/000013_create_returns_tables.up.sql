@@ -0,0 +1,28 @@
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'return_requested';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'partially_returned';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'returned';
+
+CREATE TYPE return_status AS ENUM ('requested', 'approved', 'rejected');
+
+CREATE TABLE IF NOT EXISTS public.returns
+(
+return_id uuid PRIMARY KEY NOT NULL,
+order_id uuid REFERENCES public.orders ON DELETE CASCADE,
+return_status return_status DEFAULT 'requested',
+reason text NOT NULL,
+refund_amount numeric(8,2),
+refund_id text,
+created_at timestamp with time zone DEFAULT now(),
+updated_at timestamp with time zone DEFAULT now(),
+resolved_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS returns_order_idx ON public.returns (order_id);
+
+CREATE TABLE IF NOT EXISTS public.return_items
+(
+return_id uuid REFERENCES public.returns ON DELETE CASCADE,
+book_id uuid REFERENCES public.bookstable ON DELETE CASCADE,
+book_units integer NOT NULL,
+PRIMARY KEY (return_id, book_id)
+);
@@ -0,0 +1,11 @@
+ALTER TABLE public.orders
+  DROP COLUMN IF EXISTS ship_recipient,
+  DROP COLUMN IF EXISTS ship_line1,
+  DROP COLUMN IF EXISTS ship_line2,
+  DROP COLUMN IF EXISTS ship_city,
+  DROP COLUMN IF EXISTS ship_state,
+  DROP COLUMN IF EXISTS ship_postal_code,
+  DROP COLUMN IF EXISTS ship_country,
+  DROP COLUMN IF EXISTS contact_email,
+  DROP COLUMN IF EXISTS contact_phone,
+  DROP COLUMN IF EXISTS notes;
@@ -0,0 +1,11 @@
+ALTER TABLE public.orders
+  ADD COLUMN IF NOT EXISTS ship_recipient text,
+  ADD COLUMN IF NOT EXISTS ship_line1 text,
+  ADD COLUMN IF NOT EXISTS ship_line2 text,
+  ADD COLUMN IF NOT EXISTS ship_city text,
+  ADD COLUMN IF NOT EXISTS ship_state text,
+  ADD COLUMN IF NOT EXISTS ship_postal_code text,
+  ADD COLUMN IF NOT EXISTS ship_country char(2),
+  ADD COLUMN IF NOT EXISTS contact_email text,
+  ADD COLUMN IF NOT EXISTS contact_phone text,
+  ADD COLUMN IF NOT EXISTS notes text CHECK (char_length(notes) <= 500);
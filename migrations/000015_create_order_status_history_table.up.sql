@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS public.order_status_history
+(
+id bigserial PRIMARY KEY,
+order_id uuid REFERENCES public.orders ON DELETE CASCADE,
+from_status order_status,
+to_status order_status NOT NULL,
+changed_by text NOT NULL,
+changed_at timestamp with time zone DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_idx ON public.order_status_history (order_id, changed_at);
+
+INSERT INTO public.order_status_history (order_id, from_status, to_status, changed_by, changed_at)
+SELECT order_id, NULL, order_status, 'system', updated_at FROM public.orders;
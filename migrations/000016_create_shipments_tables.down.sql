@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS public.shipment_items;
+
+DROP INDEX IF EXISTS shipments_order_idx;
+
+DROP TABLE IF EXISTS public.shipments;
+
+DROP TYPE IF EXISTS shipment_status;
+
+UPDATE public.orders SET order_status = 'paid' WHERE order_status IN ('partially_shipped', 'shipped', 'delivered');
+
+DELETE FROM public.order_status_history
+WHERE from_status IN ('partially_shipped', 'shipped', 'delivered') OR to_status IN ('partially_shipped', 'shipped', 'delivered');
+
+ALTER TYPE order_status RENAME TO order_status_old;
+
+CREATE TYPE order_status AS ENUM ('accepting_items', 'canceled', 'waiting_payment', 'paid', 'return_requested', 'partially_returned', 'returned');
+
+ALTER TABLE public.orders
+  ALTER COLUMN order_status DROP DEFAULT,
+  ALTER COLUMN order_status TYPE order_status USING order_status::text::order_status,
+  ALTER COLUMN order_status SET DEFAULT 'accepting_items';
+
+ALTER TABLE public.order_status_history
+  ALTER COLUMN from_status TYPE order_status USING from_status::text::order_status,
+  ALTER COLUMN to_status TYPE order_status USING to_status::text::order_status;
+
+DROP TYPE order_status_old;
@@ -0,0 +1,30 @@
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'partially_shipped';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'shipped';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'delivered';
+
+CREATE TYPE shipment_status AS ENUM ('picked', 'shipped', 'delivered');
+
+CREATE TABLE IF NOT EXISTS public.shipments
+(
+shipment_id uuid PRIMARY KEY NOT NULL,
+order_id uuid REFERENCES public.orders ON DELETE CASCADE,
+shipment_status shipment_status DEFAULT 'picked',
+carrier text,
+tracking_number text,
+created_at timestamp with time zone DEFAULT now(),
+updated_at timestamp with time zone DEFAULT now(),
+shipped_at timestamp with time zone,
+delivered_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS shipments_order_idx ON public.shipments (order_id);
+
+CREATE TABLE IF NOT EXISTS public.shipment_items
+(
+shipment_id uuid REFERENCES public.shipments ON DELETE CASCADE,
+order_id uuid NOT NULL,
+book_id uuid NOT NULL,
+book_units integer NOT NULL CHECK (book_units > 0),
+PRIMARY KEY (shipment_id, book_id),
+FOREIGN KEY (order_id, book_id) REFERENCES public.books_orders (order_id, book_id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+ALTER TABLE public.orders DROP CONSTRAINT IF EXISTS orders_purchaser_id_fkey;
+
+ALTER TABLE public.users ALTER COLUMN user_role DROP NOT NULL;
+
+ALTER TABLE public.users
+  DROP COLUMN IF EXISTS created_at,
+  DROP COLUMN IF EXISTS updated_at;
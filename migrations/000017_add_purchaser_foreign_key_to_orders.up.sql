@@ -0,0 +1,15 @@
+ALTER TABLE public.users
+  ADD COLUMN IF NOT EXISTS created_at timestamp with time zone DEFAULT now(),
+  ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone DEFAULT now();
+
+UPDATE public.users SET user_role = 'user' WHERE user_role IS NULL;
+
+ALTER TABLE public.users ALTER COLUMN user_role SET NOT NULL;
+
+INSERT INTO public.users (user_id, name)
+SELECT DISTINCT o.purchaser_id, 'unknown' FROM public.orders o
+WHERE o.purchaser_id IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM public.users u WHERE u.user_id = o.purchaser_id);
+
+ALTER TABLE public.orders
+  ADD CONSTRAINT orders_purchaser_id_fkey FOREIGN KEY (purchaser_id) REFERENCES public.users (user_id) ON DELETE RESTRICT;
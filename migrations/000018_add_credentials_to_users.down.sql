@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.refresh_tokens;
+
+ALTER TABLE public.users
+  DROP COLUMN IF EXISTS email,
+  DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,14 @@
+ALTER TABLE public.users
+  ADD COLUMN IF NOT EXISTS email text UNIQUE,
+  ADD COLUMN IF NOT EXISTS password_hash text;
+
+CREATE TABLE IF NOT EXISTS public.refresh_tokens
+(
+token_hash text PRIMARY KEY NOT NULL,
+user_id uuid NOT NULL REFERENCES public.users ON DELETE CASCADE,
+expires_at timestamp with time zone NOT NULL,
+created_at timestamp with time zone DEFAULT now(),
+revoked_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON public.refresh_tokens (user_id);
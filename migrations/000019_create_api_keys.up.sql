@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS public.api_keys
+(
+key_id uuid PRIMARY KEY NOT NULL,
+user_id uuid NOT NULL REFERENCES public.users ON DELETE CASCADE,
+name text NOT NULL,
+prefix text NOT NULL UNIQUE,
+key_hash text NOT NULL,
+scopes text[] NOT NULL,
+created_at timestamp with time zone DEFAULT now(),
+last_used_at timestamp with time zone,
+revoked_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON public.api_keys (user_id);
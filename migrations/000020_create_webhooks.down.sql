@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS webhook_deliveries_log_idx;
+
+DROP INDEX IF EXISTS webhook_deliveries_pending_idx;
+
+DROP TABLE IF EXISTS public.webhook_deliveries;
+
+DROP TABLE IF EXISTS public.webhooks;
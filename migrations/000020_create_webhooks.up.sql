@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS public.webhooks
+(
+webhook_id uuid PRIMARY KEY NOT NULL,
+url text NOT NULL,
+event_types text[] NOT NULL,
+secret text NOT NULL,
+created_at timestamp with time zone DEFAULT now(),
+updated_at timestamp with time zone DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries
+(
+delivery_id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+webhook_id uuid NOT NULL REFERENCES public.webhooks ON DELETE CASCADE,
+event_id uuid NOT NULL,
+event_type text NOT NULL,
+payload jsonb NOT NULL,
+status text NOT NULL,
+attempts integer NOT NULL DEFAULT 0,
+next_attempt_at timestamp with time zone NOT NULL,
+last_status_code integer,
+last_error text NOT NULL DEFAULT '',
+created_at timestamp with time zone DEFAULT now(),
+delivered_at timestamp with time zone,
+UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON public.webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_log_idx ON public.webhook_deliveries (webhook_id, created_at);
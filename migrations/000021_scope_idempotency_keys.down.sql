@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idempotency_keys_expires_idx;
+
+DELETE FROM public.idempotency_keys; --Keys of different callers may share a value, so they can't keep it as the only key.
+
+ALTER TABLE public.idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE public.idempotency_keys ADD PRIMARY KEY (idempotency_key);
+
+ALTER TABLE public.idempotency_keys
+  DROP COLUMN IF EXISTS caller,
+  DROP COLUMN IF EXISTS completed_at;
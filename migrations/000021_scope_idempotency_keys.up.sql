@@ -0,0 +1,10 @@
+ALTER TABLE public.idempotency_keys
+  ADD COLUMN IF NOT EXISTS caller text NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS completed_at timestamp with time zone;
+
+UPDATE public.idempotency_keys SET completed_at = created_at WHERE completed_at IS NULL;
+
+ALTER TABLE public.idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE public.idempotency_keys ADD PRIMARY KEY (caller, idempotency_key);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON public.idempotency_keys (expires_at);
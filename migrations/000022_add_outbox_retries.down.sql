@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS outbox_sent_idx;
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON public.outbox (created_at) WHERE sent_at IS NULL;
+
+ALTER TABLE public.outbox ALTER COLUMN last_error DROP NOT NULL;
+ALTER TABLE public.outbox ALTER COLUMN last_error DROP DEFAULT;
+
+ALTER TABLE public.outbox DROP COLUMN IF EXISTS failed_at;
+ALTER TABLE public.outbox DROP COLUMN IF EXISTS next_attempt_at;
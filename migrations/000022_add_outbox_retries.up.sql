@@ -0,0 +1,11 @@
+ALTER TABLE public.outbox ADD COLUMN IF NOT EXISTS next_attempt_at timestamp with time zone NOT NULL DEFAULT now();
+ALTER TABLE public.outbox ADD COLUMN IF NOT EXISTS failed_at timestamp with time zone;
+
+UPDATE public.outbox SET next_attempt_at = created_at WHERE sent_at IS NULL;
+UPDATE public.outbox SET last_error = '' WHERE last_error IS NULL;
+ALTER TABLE public.outbox ALTER COLUMN last_error SET DEFAULT '';
+ALTER TABLE public.outbox ALTER COLUMN last_error SET NOT NULL;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON public.outbox (next_attempt_at) WHERE sent_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_sent_idx ON public.outbox (sent_at) WHERE sent_at IS NOT NULL;